@@ -1,13 +1,20 @@
 package cache
 
 import (
+	"crypto/sha1"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/garyburd/redigo/redis"
 )
 
 var (
 	DefaultExpiryTime = time.Duration(0)
-	_cache            Cache
 )
 
 // Length of time to cache an item.
@@ -20,8 +27,53 @@ var (
 	ErrNotStored    = errors.New("cache: not stored")
 	ErrInvalidValue = errors.New("cache: invalid value")
 	ErrInited       = errors.New("cache: inited")
+	ErrNotInited    = errors.New("cache: not inited")
+	ErrCASConflict  = errors.New("cache: compare-and-swap conflict")
 )
 
+// SetMultiError is returned by SetMulti when some items were not stored. It
+// maps each of those keys to the reason; all other items were stored.
+type SetMultiError map[string]error
+
+func (e SetMultiError) Error() string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = fmt.Sprintf("%q: %v", k, e[k])
+	}
+	return fmt.Sprintf("cache: %d keys not stored: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// errOrNil returns e as an error, or nil if it is empty.
+func (e SetMultiError) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// SetOptions are the conditions and expiration handling of SetOpt.
+type SetOptions struct {
+	// NX stores the value only if the key does not exist, XX only if it
+	// does. They are mutually exclusive.
+	NX bool
+	XX bool
+	// KeepTTL keeps the expiration of the key, ignoring expires. A new key
+	// does not expire.
+	KeepTTL bool
+}
+
+func (o SetOptions) validate() error {
+	if o.NX && o.XX {
+		return errors.New("cache: NX and XX are mutually exclusive")
+	}
+	return nil
+}
+
 type Cache interface {
 	// Get the content associated with the given key. decoding it into the given
 	// pointer.
@@ -40,6 +92,141 @@ type Cache interface {
 	//   - an implementation specific error otherwise
 	Set(key string, value interface{}, expires time.Duration) error
 
+	// SetOpt is Set with the conditions and expiration handling of opts;
+	// Set is SetOpt with zero SetOptions.
+	//
+	// Returns:
+	//   - nil if the value was stored
+	//   - ErrNotStored if the NX or XX condition did not hold
+	//   - an implementation specific error otherwise
+	SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) error
+
+	// Add the given key/value to the cache only if the key does not already
+	// exist.
+	//
+	// Returns:
+	//   - nil if the value was stored
+	//   - ErrNotStored if the key already exists
+	//   - an implementation specific error otherwise
+	Add(key string, value interface{}, expires time.Duration) error
+
+	// Replace the value of the given key only if it already exists.
+	//
+	// Returns:
+	//   - nil if the value was stored
+	//   - ErrNotStored if the key does not exist
+	//   - an implementation specific error otherwise
+	Replace(key string, value interface{}, expires time.Duration) error
+
+	// GetWithVersion is Get that also returns a version of the value, to be
+	// passed to CompareAndSwap. The version of a missing key is 0.
+	//
+	// Returns:
+	//   - the version and nil if the value was successfully retrieved
+	//   - 0 and ErrCacheMiss if the value was not in the cache
+	//   - an implementation specific error otherwise
+	GetWithVersion(key string, ptrValue interface{}) (version uint64, err error)
+
+	// CompareAndSwap stores value only if the key still holds the value
+	// read with the given version, or, for version 0, if the key does not
+	// exist. The version identifies the stored bytes, so a key set back to
+	// the value that was read does not conflict.
+	//
+	// Returns:
+	//   - nil if the value was stored
+	//   - ErrCASConflict if the value changed since it was read
+	//   - an implementation specific error otherwise
+	CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) error
+
+	// Exists reports whether the given key is in the cache, without
+	// fetching its value.
+	//
+	// Returns:
+	//   - whether the key exists and nil on success
+	//   - false and an implementation specific error otherwise
+	Exists(key string) (bool, error)
+
+	// ExistsMulti returns how many of the given keys are in the cache. A key
+	// given more than once is counted each time.
+	ExistsMulti(keys ...string) (int, error)
+
+	// TTL returns how long the given key has left to live.
+	//
+	// Returns:
+	//   - the remaining time, or ForEverNeverExpiry if the key never expires
+	//   - ErrCacheMiss if the key was not in the cache
+	//   - an implementation specific error otherwise
+	TTL(key string) (time.Duration, error)
+
+	// Expire sets the time to live of the given key without rewriting its
+	// value. expires is interpreted as for Set: DefaultExpiryTime applies
+	// the cache's default expiration and ForEverNeverExpiry removes the
+	// expiration.
+	//
+	// Returns:
+	//   - nil on success
+	//   - ErrCacheMiss if the key was not in the cache
+	//   - an implementation specific error otherwise
+	Expire(key string, expires time.Duration) error
+
+	// Fetch gets the value of key like Get. On a miss it calls loader, stores
+	// its result with the given expiration and decodes it into ptrValue.
+	// Concurrent Fetches of the same key on this cache share a single
+	// loader call. Loader errors are returned and not cached. Failing to
+	// store the loaded value is only logged.
+	//
+	// Returns:
+	//   - nil if the value was retrieved or loaded and ptrValue set
+	//   - the error of loader
+	//   - an implementation specific error otherwise
+	Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error
+
+	// GetMulti fetches several keys in one round trip and returns the
+	// stored bytes, as produced by the cache's Serializer, of those found.
+	// Missing keys are simply absent from the map.
+	//
+	// Returns:
+	//   - the values found, possibly none, and nil
+	//   - an implementation specific error if the lookup failed as a whole
+	GetMulti(keys []string) (map[string][]byte, error)
+
+	// SetMulti stores every item in items with the same expiration. A key
+	// that cannot be stored does not stop the others from being stored.
+	//
+	// Returns:
+	//   - nil if every item was stored
+	//   - a SetMultiError listing the keys that were not stored and why
+	//   - an implementation specific error if nothing could be stored
+	SetMulti(items map[string]interface{}, expires time.Duration) error
+
+	// SetWithTags is Set that also records key under each of tags, so it can
+	// be deleted together with the other keys of a tag by InvalidateTag.
+	// The record of a tag outlives its longest lived key slightly, then
+	// goes away by itself.
+	//
+	// Returns:
+	//   - nil on success
+	//   - an implementation specific error otherwise
+	SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) error
+
+	// InvalidateTag deletes every key recorded under tag, along with the
+	// record itself. Keys that already expired are not counted.
+	//
+	// Returns the number of keys deleted, or:
+	//   - an implementation specific error if the keys of the tag could not
+	//     all be deleted
+	InvalidateTag(tag string) (int, error)
+
+	// DeleteByPattern deletes every key matching the glob-style pattern, as
+	// understood by the Redis SCAN command, e.g. "user:42:*". The keyspace
+	// is walked incrementally rather than blocking the server, so keys
+	// written meanwhile may be missed.
+	//
+	// Returns the number of keys deleted, or:
+	//   - an implementation specific error, the keys counted so far having
+	//     been deleted
+	DeleteByPattern(pattern string) (int, error)
+
 	// Delete the given key from the cache.
 	//
 	// Returns:
@@ -56,6 +243,16 @@ type Cache interface {
 	//   - an implementation specific error otherwise
 	Increment(key string, n uint64) (newValue uint64, err error)
 
+	// IncrementWithTTL is Increment creating a missing key, with the value
+	// delta and expiring after ttl, interpreted as expires for Set. The
+	// expiration of an existing key is left alone, so that a counter
+	// created this way counts over a fixed window, as for rate limiting.
+	//
+	// Returns the new counter value if the operation was successful, or:
+	//   - ErrInvalidValue if the value is not a counter
+	//   - an implementation specific error otherwise
+	IncrementWithTTL(key string, delta uint64, ttl time.Duration) (newValue uint64, err error)
+
 	// Decrement the value stored at the given key by the given amount.
 	// The value is capped at 0 on underflow, with no error returned.
 	//
@@ -68,21 +265,163 @@ type Cache interface {
 	// This is not implemented for the memcached cache (intentionally).
 	// Returns an implementation specific error if the operation failed.
 	ClearAll() error
+
+	// Stats returns the counters of the operations of this cache since it
+	// was created or ResetStats was called. ClearAll does not reset them.
+	Stats() CacheStats
+
+	// ResetStats sets the counters returned by Stats back to zero.
+	ResetStats()
+
+	// SetHook makes the cache report every finished operation to h, or
+	// stops reporting for a nil h.
+	SetHook(h Hook)
+
+	// Close releases the resources of the cache, such as its connection
+	// pool. The cache must not be used afterwards. Closing twice is
+	// harmless.
+	Close() error
 }
 
-func Get(key string, ptrValue interface{}) error                  { return _cache.Get(key, ptrValue) }
-func Delete(key string) error                                     { return _cache.Delete(key) }
-func Increment(key string, n uint64) (newValue uint64, err error) { return _cache.Increment(key, n) }
-func Decrement(key string, n uint64) (newValue uint64, err error) { return _cache.Decrement(key, n) }
-func ClearAll() error                                             { return _cache.ClearAll() }
+func Get(key string, ptrValue interface{}) error                  { return std().Get(key, ptrValue) }
+func Delete(key string) error                                     { return std().Delete(key) }
+func Increment(key string, n uint64) (newValue uint64, err error) { return std().Increment(key, n) }
+func Decrement(key string, n uint64) (newValue uint64, err error) { return std().Decrement(key, n) }
+func ClearAll() error                                             { return std().ClearAll() }
 func Set(key string, value interface{}, expires time.Duration) error {
-	return _cache.Set(key, value, expires)
+	return std().Set(key, value, expires)
+}
+func SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) error {
+	return std().SetOpt(key, value, expires, opts)
+}
+func IncrementWithTTL(key string, delta uint64, ttl time.Duration) (uint64, error) {
+	return std().IncrementWithTTL(key, delta, ttl)
+}
+func Add(key string, value interface{}, expires time.Duration) error {
+	return std().Add(key, value, expires)
+}
+func Replace(key string, value interface{}, expires time.Duration) error {
+	return std().Replace(key, value, expires)
+}
+func GetWithVersion(key string, ptrValue interface{}) (uint64, error) {
+	return std().GetWithVersion(key, ptrValue)
 }
+func CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) error {
+	return std().CompareAndSwap(key, value, version, expires)
+}
+func Exists(key string) (bool, error)                { return std().Exists(key) }
+func ExistsMulti(keys ...string) (int, error)        { return std().ExistsMulti(keys...) }
+func TTL(key string) (time.Duration, error)          { return std().TTL(key) }
+func Expire(key string, expires time.Duration) error { return std().Expire(key, expires) }
+func Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error {
+	return std().Fetch(key, ptrValue, expires, loader)
+}
+func GetMulti(keys []string) (map[string][]byte, error) { return std().GetMulti(keys) }
+func SetMulti(items map[string]interface{}, expires time.Duration) error {
+	return std().SetMulti(items, expires)
+}
+func SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) error {
+	return std().SetWithTags(key, value, expires, tags...)
+}
+func Stats() CacheStats                           { return std().Stats() }
+func ResetStats()                                 { std().ResetStats() }
+func SetHook(h Hook)                              { std().SetHook(h) }
+func InvalidateTag(tag string) (int, error)       { return std().InvalidateTag(tag) }
+func DeleteByPattern(pattern string) (int, error) { return std().DeleteByPattern(pattern) }
 
-func InitRedisCache(host string, password string, dbNum int, defaultExpiration time.Duration) error {
-	if _cache != nil {
-		return ErrInited
+// valueVersion returns the CAS version of stored bytes: the first 64 bits of
+// their SHA-1, which the Redis script computes with redis.sha1hex, with 0
+// reserved for missing keys.
+func valueVersion(b []byte) uint64 {
+	sum := sha1.Sum(b)
+	if v := binary.BigEndian.Uint64(sum[:8]); v != 0 {
+		return v
+	}
+	return 1
+}
+
+// RedisPool returns the connection pool of the Redis cache set up by
+// InitRedisCache, so other components can share it, or nil if the cache is
+// not Redis-backed.
+func RedisPool() *redis.Pool {
+	if c, ok := std().(RedisCache); ok {
+		return c.p
 	}
-	_cache = newRedisCache(host, password, dbNum, defaultExpiration)
 	return nil
 }
+
+func InitRedisCache(host string, password string, dbNum int, defaultExpiration time.Duration) error {
+	return InitRedisCacheWithOptions(host, password, dbNum, defaultExpiration)
+}
+
+// InitRedisCacheWithOptions is InitRedisCache with the connection pool
+// configured by opts. Options with negative values are rejected.
+func InitRedisCacheWithOptions(host string, password string, dbNum int, defaultExpiration time.Duration, opts ...RedisOption) error {
+	return initCache(func() (Cache, error) {
+		return NewRedisCache(host, password, dbNum, defaultExpiration, opts...)
+	})
+}
+
+// InitRedisCacheWithSerializer is InitRedisCache with values stored using s
+// instead of GobSerializer, e.g. JSONSerializer{} for keys read by non-Go
+// consumers.
+func InitRedisCacheWithSerializer(host string, password string, dbNum int, defaultExpiration time.Duration, s Serializer) error {
+	return initCache(func() (Cache, error) {
+		o, _ := newRedisOptions(nil)
+		c := newRedisCache(host, password, dbNum, defaultExpiration, o)
+		if s != nil {
+			c.s = s
+		}
+		return c, nil
+	})
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// Closer returns an io.Closer that closes the default cache with Close, for
+// use with util.Shutdown. Closing it more than once is harmless.
+func Closer() io.Closer {
+	return closerFunc(Close)
+}
+
+// notInited is the default cache before an Init function was called.
+type notInited struct{}
+
+func (notInited) Get(string, interface{}) error                        { return ErrNotInited }
+func (notInited) Set(string, interface{}, time.Duration) error         { return ErrNotInited }
+func (notInited) Add(string, interface{}, time.Duration) error         { return ErrNotInited }
+func (notInited) Replace(string, interface{}, time.Duration) error     { return ErrNotInited }
+func (notInited) GetWithVersion(string, interface{}) (uint64, error)   { return 0, ErrNotInited }
+func (notInited) Exists(string) (bool, error)                          { return false, ErrNotInited }
+func (notInited) ExistsMulti(...string) (int, error)                   { return 0, ErrNotInited }
+func (notInited) TTL(string) (time.Duration, error)                    { return 0, ErrNotInited }
+func (notInited) Expire(string, time.Duration) error                   { return ErrNotInited }
+func (notInited) GetMulti([]string) (map[string][]byte, error)         { return nil, ErrNotInited }
+func (notInited) SetMulti(map[string]interface{}, time.Duration) error { return ErrNotInited }
+func (notInited) InvalidateTag(string) (int, error)                    { return 0, ErrNotInited }
+func (notInited) DeleteByPattern(string) (int, error)                  { return 0, ErrNotInited }
+func (notInited) Delete(string) error                                  { return ErrNotInited }
+func (notInited) Increment(string, uint64) (uint64, error)             { return 0, ErrNotInited }
+func (notInited) Decrement(string, uint64) (uint64, error)             { return 0, ErrNotInited }
+func (notInited) ClearAll() error                                      { return ErrNotInited }
+func (notInited) Stats() CacheStats                                    { return CacheStats{} }
+func (notInited) ResetStats()                                          {}
+func (notInited) SetHook(Hook)                                         {}
+func (notInited) Close() error                                         { return nil }
+func (notInited) CompareAndSwap(string, interface{}, uint64, time.Duration) error {
+	return ErrNotInited
+}
+func (notInited) SetWithTags(string, interface{}, time.Duration, ...string) error {
+	return ErrNotInited
+}
+func (notInited) SetOpt(string, interface{}, time.Duration, SetOptions) error {
+	return ErrNotInited
+}
+func (notInited) IncrementWithTTL(string, uint64, time.Duration) (uint64, error) {
+	return 0, ErrNotInited
+}
+func (notInited) Fetch(string, interface{}, time.Duration, func() (interface{}, error)) error {
+	return ErrNotInited
+}
@@ -0,0 +1,551 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheBackends returns every Cache implementation to run a conformance
+// test on: a MemoryCache and the Redis servers of redisBackends.
+func cacheBackends(t *testing.T) map[string]Cache {
+	t.Helper()
+	mem := NewMemoryCache(time.Hour)
+	t.Cleanup(func() { mem.Close() })
+	backends := map[string]Cache{"memory": mem}
+	for name, c := range redisBackends(t) {
+		backends[name] = c
+	}
+	return backends
+}
+
+type testUser struct {
+	Name  string
+	Age   int
+	Roles []string
+}
+
+func TestCacheGetSet(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			u := testUser{Name: "ann", Age: 42, Roles: []string{"admin"}}
+			if err := c.Set("user", u, DefaultExpiryTime); err != nil {
+				t.Fatal(err)
+			}
+			var got testUser
+			if err := c.Get("user", &got); err != nil || got.Name != "ann" || got.Age != 42 || len(got.Roles) != 1 {
+				t.Errorf("Get = %+v, %v", got, err)
+			}
+
+			c.Set("str", "hello", DefaultExpiryTime)
+			var s string
+			if err := c.Get("str", &s); err != nil || s != "hello" {
+				t.Errorf("Get string = %q, %v", s, err)
+			}
+
+			// A stored []byte is not aliased by the caller's slice.
+			raw := []byte("abc")
+			c.Set("raw", raw, DefaultExpiryTime)
+			raw[0] = 'X'
+			var b []byte
+			if err := c.Get("raw", &b); err != nil || string(b) != "abc" {
+				t.Errorf("Get []byte = %q, %v", b, err)
+			}
+			b[0] = 'Y'
+			c.Get("raw", &b)
+			if string(b) != "abc" {
+				t.Errorf("a read []byte aliases the stored value: %q", b)
+			}
+
+			if err := c.Get("missing", &s); err != ErrCacheMiss {
+				t.Errorf("Get(missing) = %v, want ErrCacheMiss", err)
+			}
+		})
+	}
+}
+
+func TestCacheAddReplaceDelete(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := c.Replace("k", 1, DefaultExpiryTime); err != ErrNotStored {
+				t.Errorf("Replace(missing) = %v, want ErrNotStored", err)
+			}
+			if err := c.Add("k", 1, DefaultExpiryTime); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Add("k", 2, DefaultExpiryTime); err != ErrNotStored {
+				t.Errorf("Add(existing) = %v, want ErrNotStored", err)
+			}
+			if err := c.Replace("k", 3, DefaultExpiryTime); err != nil {
+				t.Error(err)
+			}
+			var v int
+			if c.Get("k", &v); v != 3 {
+				t.Errorf("value = %d, want the replaced 3", v)
+			}
+
+			if err := c.Delete("k"); err != nil {
+				t.Error(err)
+			}
+			if err := c.Delete("k"); err != ErrCacheMiss {
+				t.Errorf("Delete(missing) = %v, want ErrCacheMiss", err)
+			}
+		})
+	}
+}
+
+func TestCacheCounters(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.Increment("n", 1); err != ErrCacheMiss {
+				t.Errorf("Increment(missing) = %v, want ErrCacheMiss", err)
+			}
+			if _, err := c.Decrement("n", 1); err != ErrCacheMiss {
+				t.Errorf("Decrement(missing) = %v, want ErrCacheMiss", err)
+			}
+
+			c.Set("n", 10, DefaultExpiryTime)
+			if v, err := c.Increment("n", 5); err != nil || v != 15 {
+				t.Errorf("Increment = %d, %v, want 15", v, err)
+			}
+			if v, err := c.Decrement("n", 3); err != nil || v != 12 {
+				t.Errorf("Decrement = %d, %v, want 12", v, err)
+			}
+			// Decrement stops at zero.
+			if v, err := c.Decrement("n", 100); err != nil || v != 0 {
+				t.Errorf("Decrement below zero = %d, %v, want 0", v, err)
+			}
+			var v int
+			if err := c.Get("n", &v); err != nil || v != 0 {
+				t.Errorf("Get counter = %d, %v", v, err)
+			}
+
+			// Increment wraps around past the uint64 range.
+			c.Set("big", uint64(math.MaxUint64), DefaultExpiryTime)
+			if v, err := c.Increment("big", 2); err != nil || v != 1 {
+				t.Errorf("Increment past the maximum = %d, %v, want 1", v, err)
+			}
+
+			c.Set("s", "text", DefaultExpiryTime)
+			if _, err := c.Increment("s", 1); err == nil {
+				t.Error("Increment of a string should fail")
+			}
+		})
+	}
+}
+
+func TestCacheClearAll(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.Set("a", 1, DefaultExpiryTime)
+			c.Set("b", 2, ForEverNeverExpiry)
+			if err := c.ClearAll(); err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range []string{"a", "b"} {
+				if err := c.Get(k, new(int)); !errors.Is(err, ErrCacheMiss) {
+					t.Errorf("Get(%s) after ClearAll = %v", k, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheMulti(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if items, err := c.GetMulti(nil); err != nil || len(items) != 0 {
+				t.Errorf("GetMulti(nil) = %v, %v", items, err)
+			}
+			if err := c.SetMulti(map[string]interface{}{"a": 1, "b": "two", "c": []byte("three")}, DefaultExpiryTime); err != nil {
+				t.Fatal(err)
+			}
+
+			// Missing keys are absent from the result, not an error.
+			items, err := c.GetMulti([]string{"a", "missing", "b", "c"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 3 {
+				t.Errorf("GetMulti returned %d items, want 3", len(items))
+			}
+			if _, ok := items["missing"]; ok {
+				t.Error("a missing key is in the result")
+			}
+			var s string
+			if err := Deserialize(items["b"], &s); err != nil || s != "two" {
+				t.Errorf("b = %q, %v", s, err)
+			}
+			if string(items["a"]) != "1" || string(items["c"]) != "three" {
+				t.Errorf("a = %q, c = %q", items["a"], items["c"])
+			}
+
+			// An item that cannot be stored is reported without keeping
+			// the others from being stored.
+			err = c.SetMulti(map[string]interface{}{"ok": 1, "bad": make(chan int)}, time.Hour)
+			var failed SetMultiError
+			if !errors.As(err, &failed) || len(failed) != 1 || failed["bad"] == nil {
+				t.Fatalf("SetMulti = %v, want a SetMultiError for bad", err)
+			}
+			if err := c.Get("ok", new(int)); err != nil {
+				t.Errorf("Get(ok) = %v", err)
+			}
+			if err := c.Get("bad", new(int)); err != ErrCacheMiss {
+				t.Errorf("Get(bad) = %v, want ErrCacheMiss", err)
+			}
+			if ttl, err := c.TTL("ok"); err != nil || ttl <= 59*time.Minute {
+				t.Errorf("TTL(ok) = %v, %v", ttl, err)
+			}
+		})
+	}
+}
+
+func TestCacheCompareAndSwap(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			var v int
+			if ver, err := c.GetWithVersion("k", &v); err != ErrCacheMiss || ver != 0 {
+				t.Errorf("GetWithVersion(missing) = %d, %v", ver, err)
+			}
+			if err := c.CompareAndSwap("k", 1, 0, DefaultExpiryTime); err != nil {
+				t.Fatalf("CompareAndSwap creating the key = %v", err)
+			}
+			if err := c.CompareAndSwap("k", 1, 0, DefaultExpiryTime); err != ErrCASConflict {
+				t.Errorf("CompareAndSwap(version 0) over an existing key = %v", err)
+			}
+			ver, err := c.GetWithVersion("k", &v)
+			if err != nil || v != 1 || ver == 0 {
+				t.Fatalf("GetWithVersion = %d, %d, %v", ver, v, err)
+			}
+			c.Set("k", 2, DefaultExpiryTime)
+			if err := c.CompareAndSwap("k", 3, ver, DefaultExpiryTime); err != ErrCASConflict {
+				t.Errorf("CompareAndSwap after a change = %v, want ErrCASConflict", err)
+			}
+			// Setting the value read back is not a conflict.
+			c.Set("k", 1, DefaultExpiryTime)
+			if err := c.CompareAndSwap("k", 3, ver, 1500*time.Millisecond); err != nil {
+				t.Errorf("CompareAndSwap of an unchanged value = %v", err)
+			}
+			if ttl, err := c.TTL("k"); err != nil || ttl <= time.Second || ttl > 1500*time.Millisecond {
+				t.Errorf("TTL = %v, %v, want up to 1.5s", ttl, err)
+			}
+		})
+	}
+}
+
+func TestCacheCompareAndSwapConcurrent(t *testing.T) {
+	const workers, updates = 10, 20
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Each update appends to a list, so a lost one is missing from it.
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < updates; i++ {
+						for {
+							var list []int
+							ver, err := c.GetWithVersion("list", &list)
+							if err != nil && err != ErrCacheMiss {
+								t.Error(err)
+								return
+							}
+							err = c.CompareAndSwap("list", append(list, w*updates+i), ver, DefaultExpiryTime)
+							if err == nil {
+								break
+							} else if err != ErrCASConflict {
+								t.Error(err)
+								return
+							}
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			var list []int
+			if err := c.Get("list", &list); err != nil {
+				t.Fatal(err)
+			}
+			seen := make(map[int]bool)
+			for _, n := range list {
+				seen[n] = true
+			}
+			if len(list) != workers*updates || len(seen) != workers*updates {
+				t.Errorf("%d updates stored, %d distinct, want %d", len(list), len(seen), workers*updates)
+			}
+		})
+	}
+}
+
+func TestCacheTTLExpire(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.TTL("missing"); err != ErrCacheMiss {
+				t.Errorf("TTL(missing) = %v, want ErrCacheMiss", err)
+			}
+			if err := c.Expire("missing", time.Minute); err != ErrCacheMiss {
+				t.Errorf("Expire(missing) = %v, want ErrCacheMiss", err)
+			}
+			if err := c.Expire("missing", ForEverNeverExpiry); err != ErrCacheMiss {
+				t.Errorf("Expire(missing, ForEverNeverExpiry) = %v, want ErrCacheMiss", err)
+			}
+
+			c.Set("session", "data", time.Minute)
+			if ttl, err := c.TTL("session"); err != nil || ttl <= 59*time.Second || ttl > time.Minute {
+				t.Errorf("TTL = %v, %v, want about a minute", ttl, err)
+			}
+			// Extending the lifetime keeps the value.
+			if err := c.Expire("session", 2*time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if ttl, _ := c.TTL("session"); ttl <= time.Hour {
+				t.Errorf("TTL after Expire = %v, want about 2h", ttl)
+			}
+			var s string
+			if err := c.Get("session", &s); err != nil || s != "data" {
+				t.Errorf("Get after Expire = %q, %v", s, err)
+			}
+			if err := c.Expire("session", DefaultExpiryTime); err != nil {
+				t.Error(err)
+			}
+			if ttl, _ := c.TTL("session"); ttl <= 59*time.Minute || ttl > time.Hour {
+				t.Errorf("TTL with DefaultExpiryTime = %v, want the default hour", ttl)
+			}
+
+			if err := c.Expire("session", ForEverNeverExpiry); err != nil {
+				t.Error(err)
+			}
+			if ttl, err := c.TTL("session"); err != nil || ttl != ForEverNeverExpiry {
+				t.Errorf("TTL after persisting = %v, %v, want ForEverNeverExpiry", ttl, err)
+			}
+			// Persisting a key without an expiration is not a miss.
+			if err := c.Expire("session", ForEverNeverExpiry); err != nil {
+				t.Errorf("persisting again = %v", err)
+			}
+
+			c.Expire("session", 20*time.Millisecond)
+			if ttl, err := c.TTL("session"); err != nil || ttl > 20*time.Millisecond {
+				t.Errorf("TTL = %v, %v, want at most 20ms", ttl, err)
+			}
+		})
+	}
+}
+
+func TestCacheExists(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if found, err := c.Exists("k"); err != nil || found {
+				t.Errorf("Exists(missing) = %v, %v", found, err)
+			}
+			// Presence does not depend on the value decoding.
+			c.Set("k", make(map[string]int), DefaultExpiryTime)
+			c.Set("empty", []byte{}, DefaultExpiryTime)
+			for _, k := range []string{"k", "empty"} {
+				if found, err := c.Exists(k); err != nil || !found {
+					t.Errorf("Exists(%s) = %v, %v", k, found, err)
+				}
+			}
+			if n, err := c.ExistsMulti("k", "missing", "empty", "k"); err != nil || n != 3 {
+				t.Errorf("ExistsMulti = %d, %v, want 3", n, err)
+			}
+			if n, err := c.ExistsMulti(); err != nil || n != 0 {
+				t.Errorf("ExistsMulti() = %d, %v", n, err)
+			}
+		})
+	}
+}
+
+func TestCacheCountersConcurrent(t *testing.T) {
+	const workers, increments = 100, 100
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.Set("n", 0, DefaultExpiryTime)
+			c.Set("down", workers/2, DefaultExpiryTime)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < increments; i++ {
+						if _, err := c.Increment("n", 1); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+					if _, err := c.Decrement("down", 1); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			var n, down uint64
+			if err := c.Get("n", &n); err != nil || n != workers*increments {
+				t.Errorf("counter = %d, %v, want %d", n, err, workers*increments)
+			}
+			// Twice as many decrements as the start value stop at zero.
+			if err := c.Get("down", &down); err != nil || down != 0 {
+				t.Errorf("decremented counter = %d, %v, want 0", down, err)
+			}
+		})
+	}
+}
+
+func TestCloseAndReinit(t *testing.T) {
+	if err := Get("k", new(int)); err != ErrNotInited {
+		t.Fatalf("Get before Init = %v, want ErrNotInited", err)
+	}
+	if err := Close(); err != nil {
+		t.Errorf("Close before Init = %v", err)
+	}
+
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	mem := Default().(*MemoryCache)
+	if err := InitInMemoryCache(time.Hour); err != ErrInited {
+		t.Errorf("second Init = %v, want ErrInited", err)
+	}
+	Set("k", 1, DefaultExpiryTime)
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-mem.done:
+	default:
+		t.Error("Close did not stop the janitor of the memory cache")
+	}
+	if err := Set("k", 1, DefaultExpiryTime); err != ErrNotInited {
+		t.Errorf("Set after Close = %v, want ErrNotInited", err)
+	}
+
+	// A Redis cache can take its place, and Close closes its pool.
+	_, m := newTestRedis(t)
+	if err := InitRedisCache(m.Addr(), "", 0, time.Hour); err != nil {
+		t.Fatalf("Init after Close = %v", err)
+	}
+	rc := Default().(RedisCache)
+	if err := Set("k", 1, DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+	if err := rc.Get("k", new(int)); err == nil {
+		t.Error("the pool is still usable after Close")
+	}
+}
+
+func TestInitConcurrent(t *testing.T) {
+	defer Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- InitInMemoryCache(time.Hour)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	inited := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			inited++
+		case ErrInited:
+		default:
+			t.Error(err)
+		}
+	}
+	if inited != 1 {
+		t.Errorf("%d Init calls succeeded, want 1", inited)
+	}
+}
+
+func TestCacheIncrementWithTTL(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if v, err := c.IncrementWithTTL("hits", 2, time.Minute); err != nil || v != 2 {
+				t.Fatalf("IncrementWithTTL creating the key = %d, %v", v, err)
+			}
+			if ttl, err := c.TTL("hits"); err != nil || ttl <= 59*time.Second || ttl > time.Minute {
+				t.Errorf("TTL = %v, %v, want the minute given on creation", ttl, err)
+			}
+			// The expiration of an existing counter is left alone.
+			c.Expire("hits", time.Hour)
+			if v, err := c.IncrementWithTTL("hits", 1, time.Minute); err != nil || v != 3 {
+				t.Errorf("IncrementWithTTL = %d, %v", v, err)
+			}
+			if ttl, _ := c.TTL("hits"); ttl <= 59*time.Minute {
+				t.Errorf("TTL = %v, want the hour set before", ttl)
+			}
+			c.Set("persistent", 10, ForEverNeverExpiry)
+			c.IncrementWithTTL("persistent", 1, time.Minute)
+			if ttl, _ := c.TTL("persistent"); ttl != ForEverNeverExpiry {
+				t.Errorf("TTL of a persistent counter = %v", ttl)
+			}
+
+			c.Set("text", "x", DefaultExpiryTime)
+			if _, err := c.IncrementWithTTL("text", 1, time.Minute); err != ErrInvalidValue {
+				t.Errorf("IncrementWithTTL of a string = %v, want ErrInvalidValue", err)
+			}
+
+			// Concurrent first calls create the key once and lose no update.
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := c.IncrementWithTTL("window", 1, time.Minute); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			var n int
+			if err := c.Get("window", &n); err != nil || n != 50 {
+				t.Errorf("counter = %d, %v, want 50", n, err)
+			}
+			if ttl, _ := c.TTL("window"); ttl <= 0 || ttl > time.Minute {
+				t.Errorf("TTL = %v, want at most a minute", ttl)
+			}
+		})
+	}
+}
+
+func TestCacheSetOpt(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := c.SetOpt("k", 1, DefaultExpiryTime, SetOptions{XX: true}); err != ErrNotStored {
+				t.Errorf("XX on a missing key = %v, want ErrNotStored", err)
+			}
+			if err := c.SetOpt("k", 1, time.Minute, SetOptions{NX: true}); err != nil {
+				t.Fatalf("NX on a missing key = %v", err)
+			}
+			if err := c.SetOpt("k", 2, DefaultExpiryTime, SetOptions{NX: true}); err != ErrNotStored {
+				t.Errorf("NX on an existing key = %v, want ErrNotStored", err)
+			}
+			if err := c.SetOpt("k", 3, DefaultExpiryTime, SetOptions{XX: true, KeepTTL: true}); err != nil {
+				t.Fatalf("XX on an existing key = %v", err)
+			}
+			var v int
+			if c.Get("k", &v); v != 3 {
+				t.Errorf("value = %d, want 3", v)
+			}
+			if ttl, _ := c.TTL("k"); ttl <= 59*time.Second || ttl > time.Minute {
+				t.Errorf("TTL with KeepTTL = %v, want the minute kept", ttl)
+			}
+			c.SetOpt("k", 4, ForEverNeverExpiry, SetOptions{})
+			if ttl, _ := c.TTL("k"); ttl != ForEverNeverExpiry {
+				t.Errorf("TTL = %v, want none", ttl)
+			}
+			if err := c.SetOpt("k", 5, DefaultExpiryTime, SetOptions{NX: true, XX: true}); err == nil || err == ErrNotStored {
+				t.Errorf("NX with XX = %v, want a usage error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,627 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	clusterSlots = 16384
+
+	// clusterMaxRedirects bounds how often an operation follows MOVED and
+	// ASK redirections or retries TRYAGAIN before giving up.
+	clusterMaxRedirects = 5
+	clusterRetryDelay   = 50 * time.Millisecond
+)
+
+// RedisClusterCache is a Cache backed by a Redis Cluster. Every key is
+// routed to the master serving its hash slot, following MOVED and ASK
+// redirections while slots are migrated. Hash tags work as in Redis: only
+// the part of a key between the first "{" and the following "}" is hashed
+// when it is not empty.
+//
+// Operations on several keys are split by slot and the results merged, so
+// they take one round trip per slot involved; SetMulti is only atomic per
+// slot. ClearAll flushes every master.
+type RedisClusterCache struct {
+	seeds             []string
+	password          string
+	defaultExpiration time.Duration
+	s                 Serializer
+	o                 *redisOptions
+
+	flights *flightGroup
+	// instruments is shared by the nodes, which record the operations.
+	*instruments
+
+	mu     sync.RWMutex
+	nodes  map[string]RedisCache // by address
+	slots  [clusterSlots]string  // master address by slot, "" if unknown
+	loaded bool
+}
+
+// NewRedisClusterCache returns a cache for the cluster reachable through
+// any of addrs. The slot map is loaded on first use.
+func NewRedisClusterCache(addrs []string, password string, defaultExpiration time.Duration, opts ...RedisOption) (*RedisClusterCache, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("cache: no cluster address")
+	}
+	o, err := newRedisOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.network != "" {
+		return nil, errors.New("cache: WithNetwork cannot be used with a cluster")
+	}
+	c := &RedisClusterCache{
+		seeds:             append([]string(nil), addrs...),
+		password:          password,
+		defaultExpiration: defaultExpiration,
+		s:                 GobSerializer{},
+		o:                 o,
+		nodes:             make(map[string]RedisCache),
+		flights:           newFlightGroup(),
+		instruments:       new(instruments),
+	}
+	if o.tls {
+		if err := c.node(addrs[0]).checkConn(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// InitRedisClusterCache sets up a RedisClusterCache as the default cache.
+func InitRedisClusterCache(addrs []string, password string, defaultExpiration time.Duration, opts ...RedisOption) error {
+	return initCache(func() (Cache, error) {
+		return NewRedisClusterCache(addrs, password, defaultExpiration, opts...)
+	})
+}
+
+// keySlot returns the cluster hash slot of key.
+func keySlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 is the CRC-16/XMODEM checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for b := 0; b < 8; b++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// node returns the cache talking to addr, creating it on first use. Nodes
+// are never in a Redis database other than 0.
+func (c *RedisClusterCache) node(addr string) RedisCache {
+	c.mu.RLock()
+	n, ok := c.nodes[addr]
+	c.mu.RUnlock()
+	if ok {
+		return n
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok = c.nodes[addr]; !ok {
+		n = newRedisCache(addr, c.password, 0, c.defaultExpiration, c.o)
+		n.s = c.s
+		n.instruments = c.instruments
+		c.nodes[addr] = n
+	}
+	return n
+}
+
+// nodeFor returns the cache serving slot, see addrFor.
+func (c *RedisClusterCache) nodeFor(ctx context.Context, slot int) (RedisCache, error) {
+	addr, err := c.addrFor(ctx, slot)
+	if err != nil {
+		return RedisCache{}, err
+	}
+	return c.node(addr), nil
+}
+
+// addrFor returns the address of the node serving slot, loading the slot
+// map first if needed. A slot of unknown owner goes to any node, which
+// redirects.
+func (c *RedisClusterCache) addrFor(ctx context.Context, slot int) (string, error) {
+	c.mu.RLock()
+	addr, loaded := c.slots[slot], c.loaded
+	c.mu.RUnlock()
+
+	if !loaded {
+		if err := c.refresh(ctx); err != nil {
+			return "", err
+		}
+		c.mu.RLock()
+		addr = c.slots[slot]
+		c.mu.RUnlock()
+	}
+	if addr == "" {
+		addr = c.seeds[0]
+	}
+	return addr, nil
+}
+
+// refresh reloads the slot map with CLUSTER SLOTS from the first known node
+// that answers.
+func (c *RedisClusterCache) refresh(ctx context.Context) error {
+	c.mu.RLock()
+	addrs := append([]string(nil), c.seeds...)
+	for addr := range c.nodes {
+		addrs = append(addrs, addr)
+	}
+	c.mu.RUnlock()
+
+	var err error
+	for _, addr := range addrs {
+		var slots [clusterSlots]string
+		if slots, err = c.loadSlots(ctx, addr); err == nil {
+			c.mu.Lock()
+			c.slots = slots
+			c.loaded = true
+			c.mu.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("cache: loading cluster slots: %v", err)
+}
+
+func (c *RedisClusterCache) loadSlots(ctx context.Context, addr string) (slots [clusterSlots]string, err error) {
+	conn, err := c.node(addr).conn(ctx)
+	if err != nil {
+		return slots, err
+	}
+	defer conn.Close()
+
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return slots, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	for _, r := range ranges {
+		// start, end, then the master as ip, port, ... followed by replicas
+		fields, err := redis.Values(r, nil)
+		if err != nil || len(fields) < 3 {
+			return slots, fmt.Errorf("cache: bad CLUSTER SLOTS reply %v", r)
+		}
+		start, err1 := redis.Int(fields[0], nil)
+		end, err2 := redis.Int(fields[1], nil)
+		node, err3 := redis.Values(fields[2], nil)
+		if err1 != nil || err2 != nil || err3 != nil || len(node) < 2 ||
+			start < 0 || end >= clusterSlots || start > end {
+			return slots, fmt.Errorf("cache: bad CLUSTER SLOTS reply %v", r)
+		}
+		ip, _ := redis.String(node[0], nil)
+		port, err := redis.Int(node[1], nil)
+		if err != nil {
+			return slots, fmt.Errorf("cache: bad CLUSTER SLOTS reply %v", r)
+		}
+		if ip == "" {
+			// the node does not know its own address
+			ip = host
+		}
+		master := net.JoinHostPort(ip, strconv.Itoa(port))
+		for i := start; i <= end; i++ {
+			slots[i] = master
+		}
+	}
+	return slots, nil
+}
+
+// redirection parses a MOVED or ASK error.
+func redirection(err error) (ask bool, slot int, addr string, ok bool) {
+	rerr, isRedis := err.(redis.Error)
+	if !isRedis {
+		return false, 0, "", false
+	}
+	f := strings.Fields(string(rerr))
+	if len(f) != 3 || (f[0] != "MOVED" && f[0] != "ASK") {
+		return false, 0, "", false
+	}
+	slot, serr := strconv.Atoi(f[1])
+	if serr != nil || slot < 0 || slot >= clusterSlots {
+		return false, 0, "", false
+	}
+	return f[0] == "ASK", slot, f[2], true
+}
+
+func isTryAgain(err error) bool {
+	rerr, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(rerr), "TRYAGAIN")
+}
+
+// do runs fn against the master serving slot, following redirections.
+func (c *RedisClusterCache) do(ctx context.Context, slot int, fn func(n RedisCache) error) error {
+	n, err := c.nodeFor(ctx, slot)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		err = fn(n)
+		if i == clusterMaxRedirects {
+			return err
+		}
+		if isTryAgain(err) {
+			select {
+			case <-time.After(clusterRetryDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		ask, _, addr, ok := redirection(err)
+		if !ok {
+			return err
+		}
+		if ask {
+			n = c.node(addr)
+			n.asking = true
+			continue
+		}
+		// the slot has moved for good, likely with others; the redirection
+		// wins over a slot map that is not up to date yet
+		c.refresh(ctx)
+		c.mu.Lock()
+		c.slots[slot] = addr
+		c.mu.Unlock()
+		n = c.node(addr)
+	}
+}
+
+// bySlot groups keys by hash slot, keeping their order within each slot.
+func bySlot(keys []string) map[int][]string {
+	groups := make(map[int][]string)
+	for _, k := range keys {
+		slot := keySlot(k)
+		groups[slot] = append(groups[slot], k)
+	}
+	return groups
+}
+
+// masters returns the caches of every master in the slot map.
+func (c *RedisClusterCache) masters(ctx context.Context) ([]RedisCache, error) {
+	if err := c.refresh(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, addr := range c.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	c.mu.RUnlock()
+
+	nodes := make([]RedisCache, len(addrs))
+	for i, addr := range addrs {
+		nodes[i] = c.node(addr)
+	}
+	return nodes, nil
+}
+
+// Serializer returns the serializer the cache stores values with.
+func (c *RedisClusterCache) Serializer() Serializer {
+	return c.s
+}
+
+// Close closes the connection pools of all nodes.
+func (c *RedisClusterCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for addr, n := range c.nodes {
+		errs = append(errs, n.p.Close())
+		delete(c.nodes, addr)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *RedisClusterCache) Get(key string, ptrValue interface{}) error {
+	return c.GetCtx(context.Background(), key, ptrValue)
+}
+
+func (c *RedisClusterCache) GetCtx(ctx context.Context, key string, ptrValue interface{}) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.GetCtx(ctx, key, ptrValue)
+	})
+}
+
+func (c *RedisClusterCache) getWithTTL(key string, ptrValue interface{}) (ttl time.Duration, err error) {
+	err = c.do(context.Background(), keySlot(key), func(n RedisCache) (err error) {
+		ttl, err = n.getWithTTL(key, ptrValue)
+		return err
+	})
+	return ttl, err
+}
+
+func (c *RedisClusterCache) Set(key string, value interface{}, expires time.Duration) error {
+	return c.SetCtx(context.Background(), key, value, expires)
+}
+
+func (c *RedisClusterCache) SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.SetCtx(ctx, key, value, expires)
+	})
+}
+
+func (c *RedisClusterCache) SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) error {
+	return c.SetOptCtx(context.Background(), key, value, expires, opts)
+}
+
+func (c *RedisClusterCache) SetOptCtx(ctx context.Context, key string, value interface{}, expires time.Duration, opts SetOptions) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.SetOptCtx(ctx, key, value, expires, opts)
+	})
+}
+
+func (c *RedisClusterCache) Add(key string, value interface{}, expires time.Duration) error {
+	return c.AddCtx(context.Background(), key, value, expires)
+}
+
+func (c *RedisClusterCache) AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.AddCtx(ctx, key, value, expires)
+	})
+}
+
+func (c *RedisClusterCache) Replace(key string, value interface{}, expires time.Duration) error {
+	return c.ReplaceCtx(context.Background(), key, value, expires)
+}
+
+func (c *RedisClusterCache) ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.ReplaceCtx(ctx, key, value, expires)
+	})
+}
+
+func (c *RedisClusterCache) GetWithVersion(key string, ptrValue interface{}) (uint64, error) {
+	return c.GetWithVersionCtx(context.Background(), key, ptrValue)
+}
+
+func (c *RedisClusterCache) GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (version uint64, err error) {
+	err = c.do(ctx, keySlot(key), func(n RedisCache) (err error) {
+		version, err = n.GetWithVersionCtx(ctx, key, ptrValue)
+		return err
+	})
+	return version, err
+}
+
+func (c *RedisClusterCache) CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) error {
+	return c.CompareAndSwapCtx(context.Background(), key, value, version, expires)
+}
+
+func (c *RedisClusterCache) CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.CompareAndSwapCtx(ctx, key, value, version, expires)
+	})
+}
+
+func (c *RedisClusterCache) Exists(key string) (bool, error) {
+	return c.ExistsCtx(context.Background(), key)
+}
+
+func (c *RedisClusterCache) ExistsCtx(ctx context.Context, key string) (found bool, err error) {
+	err = c.do(ctx, keySlot(key), func(n RedisCache) (err error) {
+		found, err = n.ExistsCtx(ctx, key)
+		return err
+	})
+	return found, err
+}
+
+func (c *RedisClusterCache) ExistsMulti(keys ...string) (int, error) {
+	return c.ExistsMultiCtx(context.Background(), keys...)
+}
+
+func (c *RedisClusterCache) ExistsMultiCtx(ctx context.Context, keys ...string) (int, error) {
+	total := 0
+	for slot, group := range bySlot(keys) {
+		err := c.do(ctx, slot, func(n RedisCache) error {
+			found, err := n.ExistsMultiCtx(ctx, group...)
+			if err == nil {
+				total += found
+			}
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+func (c *RedisClusterCache) TTL(key string) (time.Duration, error) {
+	return c.TTLCtx(context.Background(), key)
+}
+
+func (c *RedisClusterCache) TTLCtx(ctx context.Context, key string) (ttl time.Duration, err error) {
+	err = c.do(ctx, keySlot(key), func(n RedisCache) (err error) {
+		ttl, err = n.TTLCtx(ctx, key)
+		return err
+	})
+	return ttl, err
+}
+
+func (c *RedisClusterCache) Expire(key string, expires time.Duration) error {
+	return c.ExpireCtx(context.Background(), key, expires)
+}
+
+func (c *RedisClusterCache) ExpireCtx(ctx context.Context, key string, expires time.Duration) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.ExpireCtx(ctx, key, expires)
+	})
+}
+
+func (c *RedisClusterCache) Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error {
+	return fetch(c, c.s, c.flights, key, ptrValue, expires, loader)
+}
+
+func (c *RedisClusterCache) GetMulti(keys []string) (map[string][]byte, error) {
+	return c.GetMultiCtx(context.Background(), keys)
+}
+
+// GetMultiCtx sends one MGET per slot, those of the slots of a node being
+// pipelined on one connection. A slot whose MGET fails, e.g. because it was
+// redirected, is retried on its own. It fails as a whole if any slot fails.
+func (c *RedisClusterCache) GetMultiCtx(ctx context.Context, keys []string) (map[string][]byte, error) {
+	byNode := make(map[string][][]string)
+	for slot, group := range bySlot(keys) {
+		addr, err := c.addrFor(ctx, slot)
+		if err != nil {
+			return nil, err
+		}
+		byNode[addr] = append(byNode[addr], group)
+	}
+
+	items := make(map[string][]byte, len(keys))
+	for addr, groups := range byNode {
+		failed, err := c.node(addr).mgetPipelined(ctx, groups, items)
+		if err != nil {
+			return nil, err
+		}
+		for i := range failed {
+			group := groups[i]
+			err := c.do(ctx, keySlot(group[0]), func(n RedisCache) error {
+				found, err := n.GetMultiCtx(ctx, group)
+				for k, v := range found {
+					items[k] = v
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return items, nil
+}
+
+func (c *RedisClusterCache) SetMulti(items map[string]interface{}, expires time.Duration) error {
+	return c.SetMultiCtx(context.Background(), items, expires)
+}
+
+// SetMultiCtx stores the items of each slot like RedisCache.SetMulti. The
+// items of a slot that could not be stored at all are reported in the
+// SetMultiError with the reason, so the error is always a SetMultiError.
+func (c *RedisClusterCache) SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) error {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+
+	failed := make(SetMultiError)
+	for slot, group := range bySlot(keys) {
+		slotItems := make(map[string]interface{}, len(group))
+		for _, k := range group {
+			slotItems[k] = items[k]
+		}
+		err := c.do(ctx, slot, func(n RedisCache) error {
+			return n.SetMultiCtx(ctx, slotItems, expires)
+		})
+		var merr SetMultiError
+		switch {
+		case err == nil:
+		case errors.As(err, &merr):
+			for k, e := range merr {
+				failed[k] = e
+			}
+		default:
+			for _, k := range group {
+				failed[k] = err
+			}
+		}
+	}
+	return failed.errOrNil()
+}
+
+func (c *RedisClusterCache) Delete(key string) error {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+func (c *RedisClusterCache) DeleteCtx(ctx context.Context, key string) error {
+	return c.do(ctx, keySlot(key), func(n RedisCache) error {
+		return n.DeleteCtx(ctx, key)
+	})
+}
+
+func (c *RedisClusterCache) Increment(key string, delta uint64) (uint64, error) {
+	return c.IncrementCtx(context.Background(), key, delta)
+}
+
+func (c *RedisClusterCache) IncrementCtx(ctx context.Context, key string, delta uint64) (newValue uint64, err error) {
+	err = c.do(ctx, keySlot(key), func(n RedisCache) (err error) {
+		newValue, err = n.IncrementCtx(ctx, key, delta)
+		return err
+	})
+	return newValue, err
+}
+
+func (c *RedisClusterCache) IncrementWithTTL(key string, delta uint64, ttl time.Duration) (uint64, error) {
+	return c.IncrementWithTTLCtx(context.Background(), key, delta, ttl)
+}
+
+func (c *RedisClusterCache) IncrementWithTTLCtx(ctx context.Context, key string, delta uint64, ttl time.Duration) (newValue uint64, err error) {
+	err = c.do(ctx, keySlot(key), func(n RedisCache) (err error) {
+		newValue, err = n.IncrementWithTTLCtx(ctx, key, delta, ttl)
+		return err
+	})
+	return newValue, err
+}
+
+func (c *RedisClusterCache) Decrement(key string, delta uint64) (uint64, error) {
+	return c.DecrementCtx(context.Background(), key, delta)
+}
+
+func (c *RedisClusterCache) DecrementCtx(ctx context.Context, key string, delta uint64) (newValue uint64, err error) {
+	err = c.do(ctx, keySlot(key), func(n RedisCache) (err error) {
+		newValue, err = n.DecrementCtx(ctx, key, delta)
+		return err
+	})
+	return newValue, err
+}
+
+func (c *RedisClusterCache) ClearAll() error {
+	return c.ClearAllCtx(context.Background())
+}
+
+// ClearAllCtx flushes every master known from a fresh slot map, stopping at
+// the first failure.
+func (c *RedisClusterCache) ClearAllCtx(ctx context.Context) error {
+	nodes, err := c.masters(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := n.ClearAllCtx(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestKeySlot(t *testing.T) {
+	// Slots as computed by CLUSTER KEYSLOT.
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{"", 0},
+		{"foo", 12182},
+		{"bar", 5061},
+		{"123456789", 0x31c3},
+		{"{user1000}.following", 3443},
+		{"{user1000}.followers", 3443},
+		// Only the first tag counts, and an empty one hashes the whole key.
+		{"foo{}{bar}", int(crc16("foo{}{bar}") % clusterSlots)},
+		{"foo{{bar}}zap", int(crc16("{bar") % clusterSlots)},
+		{"foo{bar}{zap}", 5061},
+		{"{bar", int(crc16("{bar") % clusterSlots)},
+	}
+	for _, tt := range tests {
+		if got := keySlot(tt.key); got != tt.slot {
+			t.Errorf("keySlot(%q) = %d, want %d", tt.key, got, tt.slot)
+		}
+	}
+}
+
+func TestBySlot(t *testing.T) {
+	groups := bySlot([]string{"{u1}a", "foo", "{u1}b", "bar", "{u1}c"})
+	if len(groups) != 3 {
+		t.Fatalf("%d groups, want 3", len(groups))
+	}
+	got := groups[keySlot("u1")]
+	if len(got) != 3 || got[0] != "{u1}a" || got[1] != "{u1}b" || got[2] != "{u1}c" {
+		t.Errorf("tagged keys grouped as %v", got)
+	}
+}
+
+func TestRedirection(t *testing.T) {
+	tests := []struct {
+		err  error
+		ask  bool
+		slot int
+		addr string
+		ok   bool
+	}{
+		{redis.Error("MOVED 3999 127.0.0.1:6381"), false, 3999, "127.0.0.1:6381", true},
+		{redis.Error("ASK 3999 127.0.0.1:6381"), true, 3999, "127.0.0.1:6381", true},
+		{redis.Error("MOVED 16384 127.0.0.1:6381"), false, 0, "", false},
+		{redis.Error("MOVED x 127.0.0.1:6381"), false, 0, "", false},
+		{redis.Error("ERR wrong type"), false, 0, "", false},
+		{errors.New("MOVED 1 127.0.0.1:6381"), false, 0, "", false},
+		{nil, false, 0, "", false},
+	}
+	for _, tt := range tests {
+		ask, slot, addr, ok := redirection(tt.err)
+		if ask != tt.ask || slot != tt.slot || addr != tt.addr || ok != tt.ok {
+			t.Errorf("redirection(%v) = %v, %d, %q, %v", tt.err, ask, slot, addr, ok)
+		}
+	}
+	if !isTryAgain(redis.Error("TRYAGAIN Multiple keys request during rehashing")) || isTryAgain(redis.Error("ERR")) {
+		t.Error("isTryAgain")
+	}
+}
+
+// newTestCluster returns a cluster cache of two miniredis nodes. a serves
+// every slot, as told by CLUSTER SLOTS, except those moved to b by the test.
+func newTestCluster(t *testing.T) (c *RedisClusterCache, a, b *miniredis.Miniredis) {
+	t.Helper()
+	a, b = miniredis.RunT(t), miniredis.RunT(t)
+	c, err := NewRedisClusterCache([]string{a.Addr()}, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return c, a, b
+}
+
+func TestClusterRouting(t *testing.T) {
+	c, a, b := newTestCluster(t)
+	c.slots[keySlot("bar")] = b.Addr()
+
+	if err := c.SetMulti(map[string]interface{}{"foo": 1, "bar": 2, "{bar}2": 3}, DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	for node, keys := range map[*miniredis.Miniredis][]string{a: {"foo"}, b: {"bar", "{bar}2"}} {
+		if got := node.Keys(); len(got) != len(keys) {
+			t.Errorf("node holds %v, want %v", got, keys)
+		}
+	}
+
+	// GetMulti is split by slot and merged.
+	items, err := c.GetMulti([]string{"foo", "bar", "missing", "{bar}2"})
+	if err != nil || len(items) != 3 || string(items["bar"]) != "2" {
+		t.Errorf("GetMulti = %q, %v", items, err)
+	}
+	if n, err := c.ExistsMulti("foo", "bar", "missing"); err != nil || n != 2 {
+		t.Errorf("ExistsMulti = %d, %v", n, err)
+	}
+	var v int
+	if err := c.Get("bar", &v); err != nil || v != 2 {
+		t.Errorf("Get = %d, %v", v, err)
+	}
+	if n, err := c.Increment("bar", 1); err != nil || n != 3 {
+		t.Errorf("Increment = %d, %v", n, err)
+	}
+	if err := c.Delete("bar"); err != nil || b.Exists("bar") {
+		t.Errorf("Delete = %v", err)
+	}
+}
+
+func TestClusterGetMultiPipelined(t *testing.T) {
+	c, a, b := newTestCluster(t)
+	keys := []string{"{1}", "{2}", "{3}", "{4}", "missing"}
+	for _, k := range keys[:4] {
+		a.Set(k, k)
+	}
+	c.GetMulti(keys)
+
+	// One MGET per slot, all sent on one pooled connection, checked with a
+	// single PING when borrowed.
+	before := a.CommandCount()
+	items, err := c.GetMulti(keys)
+	if err != nil || len(items) != 4 || string(items["{3}"]) != "{3}" {
+		t.Errorf("GetMulti = %q, %v", items, err)
+	}
+	if n := a.CommandCount() - before; n != len(keys)+1 {
+		t.Errorf("%d commands for %d slots", n, len(keys))
+	}
+
+	// A redirected slot is retried on its own.
+	slot := keySlot("{2}")
+	c.slots[slot] = b.Addr()
+	b.SetError(fmt.Sprintf("MOVED %d %s", slot, a.Addr()))
+	items, err = c.GetMulti(keys)
+	if err != nil || len(items) != 4 || string(items["{2}"]) != "{2}" {
+		t.Errorf("GetMulti with a redirection = %q, %v", items, err)
+	}
+}
+
+func TestClusterFollowsMoved(t *testing.T) {
+	c, a, b := newTestCluster(t)
+	// b claims the slot of foo but redirects it to a.
+	slot := keySlot("foo")
+	c.slots[slot] = b.Addr()
+	b.SetError(fmt.Sprintf("MOVED %d %s", slot, a.Addr()))
+
+	if err := c.Set("foo", 1, DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Exists("foo") {
+		t.Error("the value did not follow the redirection")
+	}
+	if c.slots[slot] != a.Addr() {
+		t.Errorf("slot map not updated: %s", c.slots[slot])
+	}
+}
+
+func TestClusterClearAll(t *testing.T) {
+	c, a, _ := newTestCluster(t)
+	c.Set("foo", 1, DefaultExpiryTime)
+	c.Set("bar", 2, DefaultExpiryTime)
+	if err := c.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := a.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after ClearAll: %v", keys)
+	}
+}
+
+func TestClusterRejectsNetwork(t *testing.T) {
+	if _, err := NewRedisClusterCache(nil, "", time.Hour); err == nil {
+		t.Error("a cluster without addresses was accepted")
+	}
+	if _, err := NewRedisClusterCache([]string{"localhost:7000"}, "", time.Hour, WithNetwork("unix", "/tmp/redis.sock")); err == nil {
+		t.Error("WithNetwork was accepted for a cluster")
+	}
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// CacheCtx is a Cache whose operations also come in variants taking a
+// context. An operation gives up with ctx.Err() once ctx is done; RedisCache
+// stops waiting for a pool connection on cancellation and bounds the wait
+// for each reply by the deadline of ctx.
+type CacheCtx interface {
+	Cache
+
+	GetCtx(ctx context.Context, key string, ptrValue interface{}) error
+	SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error
+	AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error
+	ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error
+	GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (version uint64, err error)
+	CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) error
+	ExistsCtx(ctx context.Context, key string) (bool, error)
+	ExistsMultiCtx(ctx context.Context, keys ...string) (int, error)
+	TTLCtx(ctx context.Context, key string) (time.Duration, error)
+	ExpireCtx(ctx context.Context, key string, expires time.Duration) error
+	GetMultiCtx(ctx context.Context, keys []string) (map[string][]byte, error)
+	SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) error
+	DeleteCtx(ctx context.Context, key string) error
+	IncrementCtx(ctx context.Context, key string, n uint64) (newValue uint64, err error)
+	DecrementCtx(ctx context.Context, key string, n uint64) (newValue uint64, err error)
+	ClearAllCtx(ctx context.Context) error
+}
+
+// WithContext returns c itself if it implements CacheCtx. Otherwise the
+// context variants of the result only check that ctx is not done before
+// calling the plain operation, which suits in-process caches such as
+// MemoryCache that never block.
+func WithContext(c Cache) CacheCtx {
+	if cc, ok := c.(CacheCtx); ok {
+		return cc
+	}
+	return checkCtx{c}
+}
+
+type checkCtx struct {
+	Cache
+}
+
+func (c checkCtx) GetCtx(ctx context.Context, key string, ptrValue interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Get(key, ptrValue)
+}
+
+func (c checkCtx) SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value, expires)
+}
+
+func (c checkCtx) AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Add(key, value, expires)
+}
+
+func (c checkCtx) ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Replace(key, value, expires)
+}
+
+func (c checkCtx) GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.GetWithVersion(key, ptrValue)
+}
+
+func (c checkCtx) CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.CompareAndSwap(key, value, version, expires)
+}
+
+func (c checkCtx) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.Exists(key)
+}
+
+func (c checkCtx) ExistsMultiCtx(ctx context.Context, keys ...string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ExistsMulti(keys...)
+}
+
+func (c checkCtx) TTLCtx(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.TTL(key)
+}
+
+func (c checkCtx) ExpireCtx(ctx context.Context, key string, expires time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Expire(key, expires)
+}
+
+func (c checkCtx) GetMultiCtx(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.GetMulti(keys)
+}
+
+func (c checkCtx) SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetMulti(items, expires)
+}
+
+func (c checkCtx) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(key)
+}
+
+func (c checkCtx) IncrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.Increment(key, n)
+}
+
+func (c checkCtx) DecrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.Decrement(key, n)
+}
+
+func (c checkCtx) ClearAllCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ClearAll()
+}
+
+func GetCtx(ctx context.Context, key string, ptrValue interface{}) error {
+	return WithContext(std()).GetCtx(ctx, key, ptrValue)
+}
+func SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return WithContext(std()).SetCtx(ctx, key, value, expires)
+}
+func AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return WithContext(std()).AddCtx(ctx, key, value, expires)
+}
+func ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return WithContext(std()).ReplaceCtx(ctx, key, value, expires)
+}
+func GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (uint64, error) {
+	return WithContext(std()).GetWithVersionCtx(ctx, key, ptrValue)
+}
+func CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) error {
+	return WithContext(std()).CompareAndSwapCtx(ctx, key, value, version, expires)
+}
+func ExistsCtx(ctx context.Context, key string) (bool, error) {
+	return WithContext(std()).ExistsCtx(ctx, key)
+}
+func ExistsMultiCtx(ctx context.Context, keys ...string) (int, error) {
+	return WithContext(std()).ExistsMultiCtx(ctx, keys...)
+}
+func TTLCtx(ctx context.Context, key string) (time.Duration, error) {
+	return WithContext(std()).TTLCtx(ctx, key)
+}
+func ExpireCtx(ctx context.Context, key string, expires time.Duration) error {
+	return WithContext(std()).ExpireCtx(ctx, key, expires)
+}
+func GetMultiCtx(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return WithContext(std()).GetMultiCtx(ctx, keys)
+}
+func SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) error {
+	return WithContext(std()).SetMultiCtx(ctx, items, expires)
+}
+func DeleteCtx(ctx context.Context, key string) error {
+	return WithContext(std()).DeleteCtx(ctx, key)
+}
+func IncrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	return WithContext(std()).IncrementCtx(ctx, key, n)
+}
+func DecrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	return WithContext(std()).DecrementCtx(ctx, key, n)
+}
+func ClearAllCtx(ctx context.Context) error {
+	return WithContext(std()).ClearAllCtx(ctx)
+}
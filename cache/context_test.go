@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, m := newTestRedis(t)
+	mem := NewMemoryCache(time.Hour)
+	defer mem.Close()
+	for name, cc := range map[string]CacheCtx{"redis": c, "memory": WithContext(mem)} {
+		before := m.CommandCount()
+		start := time.Now()
+		if err := cc.GetCtx(ctx, "k", new(int)); err != context.Canceled {
+			t.Errorf("%s: GetCtx = %v, want context.Canceled", name, err)
+		}
+		if err := cc.SetCtx(ctx, "k", 1, DefaultExpiryTime); err != context.Canceled {
+			t.Errorf("%s: SetCtx = %v, want context.Canceled", name, err)
+		}
+		if _, err := cc.IncrementCtx(ctx, "k", 1); err != context.Canceled {
+			t.Errorf("%s: IncrementCtx = %v, want context.Canceled", name, err)
+		}
+		if err := cc.DeleteCtx(ctx, "k"); err != context.Canceled {
+			t.Errorf("%s: DeleteCtx = %v, want context.Canceled", name, err)
+		}
+		if d := time.Since(start); d > 100*time.Millisecond {
+			t.Errorf("%s: canceled operations took %v", name, d)
+		}
+		if n := m.CommandCount(); n != before {
+			t.Errorf("%s: %d commands sent with a canceled context", name, n-before)
+		}
+	}
+}
+
+func TestContextEndsWaitForConnection(t *testing.T) {
+	m := miniredis.RunT(t)
+	c, err := NewRedisCache(m.Addr(), "", 0, time.Hour, WithMaxActive(1), WithWait(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	busy := c.p.Get()
+	defer busy.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.GetCtx(ctx, "k", new(int)); err != context.DeadlineExceeded {
+		t.Errorf("GetCtx = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("GetCtx waited %v for a connection", d)
+	}
+}
+
+// stallingServer is a Redis server that answers the PING and SELECT sent
+// by each new connection, then never replies again.
+func stallingServer(t *testing.T) string {
+	return fakeServer(t, 2, false)
+}
+
+// fakeServer is a Redis server answering the first replies commands of each
+// connection with OK, the PING and SELECT sent by new connections included.
+// After that it stays silent or, with hangUp, closes the connection.
+func fakeServer(t *testing.T, replies int, hangUp bool) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for i := 0; ; i++ {
+					// Each command is an array of bulk strings: one line
+					// for the array, two for each element.
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					for j := 0; j < 2*n; j++ {
+						if _, err := r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					switch {
+					case i < replies:
+						conn.Write([]byte("+OK\r\n"))
+					case i == replies && hangUp:
+						// the client reads the replies sent, then EOF
+						conn.(*net.TCPConn).CloseWrite()
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestContextDeadlineBoundsReply(t *testing.T) {
+	c, err := NewRedisCache(stallingServer(t), "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.GetCtx(ctx, "k", new(int)); err != context.DeadlineExceeded {
+		t.Errorf("GetCtx = %v, want context.DeadlineExceeded", err)
+	}
+	// Well before the 5s read timeout of the pool.
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("GetCtx waited %v for a reply", d)
+	}
+}
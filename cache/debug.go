@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+// maxDebugKeyLen bounds how much of a key is written to the debug log.
+const maxDebugKeyLen = 64
+
+var debugLogger atomic.Pointer[log.Logger]
+
+// SetDebugLogger makes the cache log every operation at LevelDebug through
+// l: operation, key, outcome and elapsed time. Values are never logged. Pass
+// nil to turn it off again. Nothing is formatted unless l has LevelDebug
+// enabled.
+func SetDebugLogger(l *log.Logger) {
+	debugLogger.Store(l)
+}
+
+// trace counts a finished operation on key in the stats of the cache, and
+// logs it if a debug logger is set. It is meant to be deferred at the top of
+// an operation with a pointer to its named error result:
+//
+//	defer c.trace("GET", key, time.Now(), &err)
+func (in *instruments) trace(op string, key string, start time.Time, err *error) {
+	d := time.Since(start)
+	in.record(op, key, d, *err)
+
+	l := debugLogger.Load()
+	if l == nil || l.Level()&log.LevelDebug == 0 {
+		return
+	}
+	if len(key) > maxDebugKeyLen {
+		key = key[:maxDebugKeyLen] + "..."
+	}
+	l.Output(3, log.LevelDebug, "cache: %s %q %s (%v)", op, key, outcome(*err), d)
+}
+
+// traceN is like trace for operations on several keys; only the key count
+// is logged.
+func (in *instruments) traceN(op string, keys int, start time.Time, err *error) {
+	d := time.Since(start)
+	in.record(op, "", d, *err)
+
+	l := debugLogger.Load()
+	if l == nil || l.Level()&log.LevelDebug == 0 {
+		return
+	}
+	l.Output(3, log.LevelDebug, "cache: %s %d keys %s (%v)", op, keys, outcome(*err), d)
+}
+
+// observe is trace for caches layered on other caches, which count and log
+// their own operations: it only updates the stats and hook of the cache.
+func (in *instruments) observe(op string, key string, start time.Time, err *error) {
+	in.record(op, key, time.Since(start), *err)
+}
+
+func outcome(err error) string {
+	switch err {
+	case nil:
+		return "ok"
+	case ErrCacheMiss:
+		return "miss"
+	}
+	return "error: " + err.Error()
+}
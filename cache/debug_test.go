@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+// debugEntries runs f with a debug logger at level set and returns what it
+// logged.
+func debugEntries(t *testing.T, level log.LogLever, f func()) []log.Entry {
+	t.Helper()
+	h := log.NewMemoryHandler()
+	l := log.New(h)
+	l.SetLevel(level)
+	SetDebugLogger(l)
+	f()
+	SetDebugLogger(nil)
+	l.Close()
+	return h.Entries()
+}
+
+func TestDebugLogging(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	longKey := strings.Repeat("k", 100)
+
+	entries := debugEntries(t, log.LevelAll, func() {
+		c.Set("user:1", "secret-value", DefaultExpiryTime)
+		c.Get("user:1", new(string))
+		c.Get("user:2", new(string))
+		c.Set(longKey, 1, DefaultExpiryTime)
+		c.GetMulti([]string{"user:1", "user:2", "user:3"})
+		c.Increment("user:1", 1)
+	})
+	want := []string{
+		`cache: SET "user:1" ok`,
+		`cache: GET "user:1" ok`,
+		`cache: GET "user:2" miss`,
+		`cache: SET "` + longKey[:maxDebugKeyLen] + `..." ok`,
+		`cache: MGET 3 keys ok`,
+		`cache: INCR "user:1" error: `,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("logged %d lines, want %d: %v", len(entries), len(want), entries)
+	}
+	for i, e := range entries {
+		if !strings.Contains(e.Line, want[i]) {
+			t.Errorf("line %d = %q, want it to contain %q", i, e.Line, want[i])
+		}
+		if strings.Contains(e.Line, "secret-value") {
+			t.Errorf("line %d logs a value: %q", i, e.Line)
+		}
+		if e.Level != log.LevelDebug {
+			t.Errorf("line %d logged at %v", i, e.Level)
+		}
+		// The caller shown is the code calling the cache.
+		if !strings.Contains(e.Line, "debug_test.go") {
+			t.Errorf("line %d has the wrong caller: %q", i, e.Line)
+		}
+	}
+}
+
+func TestDebugLoggingDisabled(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	entries := debugEntries(t, log.AtLeast(log.LevelInfo), func() {
+		c.Set("k", 1, DefaultExpiryTime)
+		c.Get("k", new(int))
+	})
+	if len(entries) != 0 {
+		t.Errorf("logged without LevelDebug: %v", entries)
+	}
+
+	// Without a logger nothing is logged either, and nothing panics.
+	c.Get("k", new(int))
+}
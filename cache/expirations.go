@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x6666/util/log"
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// expirationsPingInterval is how often the expiration subscription is
+	// checked.
+	expirationsPingInterval = 30 * time.Second
+
+	// expirationsMinBackoff and expirationsMaxBackoff bound the wait before
+	// subscribing again, doubled after each failure in a row.
+	expirationsMinBackoff = 100 * time.Millisecond
+	expirationsMaxBackoff = 30 * time.Second
+)
+
+// SubscribeExpirations calls fn with the key of each value of the cache's
+// database that expires, until ctx is done, then returns nil. It blocks,
+// so it is usually run in its own goroutine.
+//
+// The notify-keyspace-events setting of the server is extended to publish
+// expired events if needed, which fails where CONFIG is not allowed; the
+// setting must then be made by hand. fn is called from a single goroutine
+// and should return quickly.
+//
+// Notifications are best-effort: Redis publishes them when it actually
+// removes an expired key, which may be some time after its expiration, and
+// they are lost while the subscription is down, e.g. while reconnecting
+// after a network failure.
+func (c RedisCache) SubscribeExpirations(ctx context.Context, fn func(key string)) error {
+	if err := c.enableExpiredEvents(ctx); err != nil {
+		return err
+	}
+	channel := fmt.Sprintf("__keyevent@%d__:expired", c.db)
+
+	backoff := expirationsMinBackoff
+	for {
+		subscribed, err := c.listenExpirations(ctx, channel, fn)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if subscribed {
+			backoff = expirationsMinBackoff
+		}
+		log.Warn("cache: expiration subscription: %v, retrying in %v", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		backoff = min(2*backoff, expirationsMaxBackoff)
+	}
+}
+
+// enableExpiredEvents makes sure notify-keyspace-events includes expired
+// keyevent notifications.
+func (c RedisCache) enableExpiredEvents(ctx context.Context) error {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	reply, err := redis.Strings(conn.Do("CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil {
+		return fmt.Errorf("cache: reading notify-keyspace-events: %v", err)
+	}
+	if len(reply) != 2 {
+		return fmt.Errorf("cache: bad CONFIG GET reply %q", reply)
+	}
+	flags := reply[1]
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	if !strings.ContainsAny(flags, "xA") {
+		flags += "x"
+	}
+	if flags == reply[1] {
+		return nil
+	}
+	if _, err := conn.Do("CONFIG", "SET", "notify-keyspace-events", flags); err != nil {
+		return fmt.Errorf("cache: setting notify-keyspace-events to %q: %v", flags, err)
+	}
+	return nil
+}
+
+// listenExpirations subscribes to channel on a connection of its own and
+// calls fn with the key of each message until the connection fails or ctx
+// is done. It reports whether the subscription was made.
+func (c RedisCache) listenExpirations(ctx context.Context, channel string, fn func(key string)) (subscribed bool, err error) {
+	conn, err := c.p.Dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(channel); err != nil {
+		return false, err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		t := time.NewTicker(expirationsPingInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if psc.Ping("") != nil {
+					return
+				}
+			case <-ctx.Done():
+				// unblocks the receive below
+				conn.Close()
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * expirationsPingInterval).(type) {
+		case redis.Message:
+			fn(string(v.Data))
+		case redis.Subscription:
+			if v.Kind == "subscribe" {
+				subscribed = true
+			}
+		case error:
+			return subscribed, v
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// keyspaceServer fronts a miniredis server with the CONFIG commands it
+// lacks, keeping the notify-keyspace-events setting in flags.
+type keyspaceServer struct {
+	mu    sync.Mutex
+	flags string
+	sets  int
+	conns []net.Conn
+}
+
+func newKeyspaceServer(t *testing.T, m *miniredis.Miniredis, flags string) (*keyspaceServer, string) {
+	t.Helper()
+	s := &keyspaceServer{flags: flags}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		s.drop()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", m.Addr())
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn, upstream)
+			s.mu.Unlock()
+			go io.Copy(conn, upstream)
+			go s.serve(conn, upstream)
+		}
+	}()
+	return s, ln.Addr().String()
+}
+
+// serve answers the CONFIG commands read from conn and forwards the others.
+// Commands are sent one at a time by the client, so local replies do not
+// interleave with those of upstream.
+func (s *keyspaceServer) serve(conn, upstream net.Conn) {
+	defer conn.Close()
+	defer upstream.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		raw := line
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			size, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			arg, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			raw += size + arg
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+		if len(args) < 2 || !strings.EqualFold(args[0], "CONFIG") {
+			upstream.Write([]byte(raw))
+			continue
+		}
+		s.mu.Lock()
+		if strings.EqualFold(args[1], "SET") {
+			s.flags = args[3]
+			s.sets++
+			conn.Write([]byte("+OK\r\n"))
+		} else {
+			fmt.Fprintf(conn, "*2\r\n$22\r\nnotify-keyspace-events\r\n$%d\r\n%s\r\n", len(s.flags), s.flags)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// drop closes every connection made so far.
+func (s *keyspaceServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func TestEnableExpiredEvents(t *testing.T) {
+	m := miniredis.RunT(t)
+	for _, tt := range []struct {
+		flags, want string
+		set         bool
+	}{
+		{"", "Ex", true},
+		{"Ex", "Ex", false},
+		{"KEA", "KEA", false},
+		{"Kx", "KxE", true},
+		{"KA", "KAE", true},
+	} {
+		s, addr := newKeyspaceServer(t, m, tt.flags)
+		c, _ := NewRedisCache(addr, "", 0, time.Hour)
+		if err := c.enableExpiredEvents(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if s.flags != tt.want || (s.sets == 1) != tt.set {
+			t.Errorf("flags %q became %q with %d CONFIG SET, want %q", tt.flags, s.flags, s.sets, tt.want)
+		}
+	}
+}
+
+func TestSubscribeExpirations(t *testing.T) {
+	m := miniredis.RunT(t)
+	s, addr := newKeyspaceServer(t, m, "")
+	c, _ := NewRedisCache(addr, "", 3, time.Hour)
+	defer c.Close()
+	const channel = "__keyevent@3__:expired"
+
+	keys := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.SubscribeExpirations(ctx, func(key string) { keys <- key })
+	}()
+	subscribed := func() bool { return m.PubSubNumSub(channel)[channel] == 1 }
+	eventually(t, "the subscription", subscribed)
+
+	m.Publish(channel, "session:1")
+	if key := <-keys; key != "session:1" {
+		t.Errorf("fn called with %q", key)
+	}
+
+	// The subscription comes back after the connection dropped.
+	s.drop()
+	eventually(t, "the subscription to drop", func() bool { return !subscribed() })
+	eventually(t, "the new subscription", subscribed)
+	m.Publish(channel, "session:2")
+	if key := <-keys; key != "session:2" {
+		t.Errorf("fn called with %q after reconnecting", key)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("SubscribeExpirations = %v, want nil once canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SubscribeExpirations did not return once canceled")
+	}
+}
+
+func TestSubscribeNamespaceExpirations(t *testing.T) {
+	m := miniredis.RunT(t)
+	_, addr := newKeyspaceServer(t, m, "Ex")
+	c, _ := NewRedisCache(addr, "", 0, time.Hour)
+	defer c.Close()
+	const channel = "__keyevent@0__:expired"
+	ns := WithNamespace(c, "app:").(expirationSubscriber)
+
+	keys := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ns.SubscribeExpirations(ctx, func(key string) { keys <- key })
+	eventually(t, "the subscription", func() bool { return m.PubSubNumSub(channel)[channel] == 1 })
+
+	m.Publish(channel, "other:1")
+	m.Publish(channel, "app:session:1")
+	if key := <-keys; key != "session:1" {
+		t.Errorf("fn called with %q, want the key of the namespace unprefixed", key)
+	}
+
+	mem := NewMemoryCache(time.Hour)
+	defer mem.Close()
+	err := WithNamespace(mem, "app:").(expirationSubscriber).SubscribeExpirations(ctx, func(string) {})
+	if err != errNoExpirations {
+		t.Errorf("SubscribeExpirations on a memory cache = %v", err)
+	}
+}
+
+func TestSubscribeExpirationsWithoutConfig(t *testing.T) {
+	// CONFIG GET is answered with OK, as by a server not allowing it.
+	c, _ := NewRedisCache(fakeServer(t, 3, false), "", 0, time.Hour)
+	defer c.Close()
+	if err := c.SubscribeExpirations(context.Background(), func(string) {}); err == nil {
+		t.Error("SubscribeExpirations succeeded without notify-keyspace-events")
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+var errLoaderPanicked = errors.New("cache: loader panicked")
+
+// flightGroup coalesces concurrent loads of the same key, so that only one
+// of them runs while the others wait for its result.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// do runs fn for key unless a call for key is already running, in which
+// case it waits for that call and returns its result.
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		f.wg.Wait()
+		return f.data, f.err
+	}
+	f := new(flight)
+	f.wg.Add(1)
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	// waiters are released even if fn panics
+	f.err = errLoaderPanicked
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		f.wg.Done()
+	}()
+
+	f.data, f.err = fn()
+	return f.data, f.err
+}
+
+// fetch implements Fetch for c, which stores values with s: on a miss the
+// value is loaded once for all concurrent callers in g, stored, and
+// decoded into every caller's ptrValue.
+func fetch(c Cache, s Serializer, g *flightGroup, key string, ptrValue interface{}, expires time.Duration,
+	loader func() (interface{}, error)) error {
+
+	if err := c.Get(key, ptrValue); err != ErrCacheMiss {
+		return err
+	}
+
+	data, err := g.do(key, func() ([]byte, error) {
+		// a flight that just finished may have stored the value; serializers
+		// hand []byte targets the stored bytes
+		var stored []byte
+		if err := c.Get(key, &stored); err != ErrCacheMiss {
+			return stored, err
+		}
+
+		v, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		b, err := s.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		// serializers store []byte values as-is, so this stores what
+		// Set(key, v) would
+		if err := c.Set(key, b, expires); err != nil {
+			log.Warn("cache: storing fetched value of %q: %v", key, err)
+		}
+		return b, nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.Unmarshal(data, ptrValue)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchLoadsOnce(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			loader := func() (interface{}, error) {
+				calls.Add(1)
+				time.Sleep(20 * time.Millisecond)
+				return testUser{Name: "ann", Age: 42}, nil
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var u testUser
+					if err := c.Fetch("user", &u, DefaultExpiryTime, loader); err != nil || u.Name != "ann" {
+						t.Errorf("Fetch = %+v, %v", u, err)
+					}
+				}()
+			}
+			wg.Wait()
+			if n := calls.Load(); n != 1 {
+				t.Errorf("loader called %d times, want once", n)
+			}
+
+			// The value was stored, so later calls do not load.
+			var u testUser
+			if err := c.Get("user", &u); err != nil || u.Age != 42 {
+				t.Errorf("Get = %+v, %v", u, err)
+			}
+			c.Fetch("user", &u, DefaultExpiryTime, loader)
+			if n := calls.Load(); n != 1 {
+				t.Errorf("loader called on a hit")
+			}
+		})
+	}
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	errDB := errors.New("database down")
+	release := make(chan struct{})
+	var calls atomic.Int32
+	failing := func() (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return nil, errDB
+	}
+
+	// Every waiter gets the error of the load.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Fetch("k", new(int), DefaultExpiryTime, failing)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != errDB {
+			t.Errorf("Fetch = %v, want the loader error", err)
+		}
+	}
+	if err := c.Get("k", new(int)); err != ErrCacheMiss {
+		t.Errorf("Get after a failed load = %v, want ErrCacheMiss", err)
+	}
+
+	// The next call loads again.
+	var v int
+	if err := c.Fetch("k", &v, DefaultExpiryTime, func() (interface{}, error) { return 7, nil }); err != nil || v != 7 {
+		t.Errorf("Fetch after a failed load = %d, %v", v, err)
+	}
+}
+
+func TestFetchLoaderPanics(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic of the loader was not passed on")
+			}
+		}()
+		c.Fetch("k", new(int), DefaultExpiryTime, func() (interface{}, error) { panic("boom") })
+	}()
+
+	// The flight is over: the key can be loaded again.
+	var v int
+	if err := c.Fetch("k", &v, DefaultExpiryTime, func() (interface{}, error) { return 1, nil }); err != nil || v != 1 {
+		t.Errorf("Fetch after a panic = %d, %v", v, err)
+	}
+}
+
+func TestFetchPackageLevel(t *testing.T) {
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	var s string
+	if err := Fetch("k", &s, time.Minute, func() (interface{}, error) { return "loaded", nil }); err != nil || s != "loaded" {
+		t.Errorf("Fetch = %q, %v", s, err)
+	}
+	if ttl, err := TTL("k"); err != nil || ttl > time.Minute {
+		t.Errorf("TTL = %v, %v, want the expiry given to Fetch", ttl, err)
+	}
+}
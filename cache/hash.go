@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// hincrScript adds ARGV[1] to the field ARGV[2] of KEYS[1] only if it
+// exists: HINCRBY would create it.
+var hincrScript = redis.NewScript(1, `
+if redis.call('HEXISTS', KEYS[1], ARGV[2]) == 0 then return false end
+return redis.call('HINCRBY', KEYS[1], ARGV[2], ARGV[1])
+`)
+
+func (c RedisCache) HSet(key, field string, value interface{}) error {
+	return c.HSetCtx(context.Background(), key, field, value)
+}
+
+// HSetCtx stores the value in field of the hash key, encoded like Set does.
+// The expiration of the hash is left as is, use Expire to change it.
+func (c RedisCache) HSetCtx(ctx context.Context, key, field string, value interface{}) (err error) {
+	defer c.trace("HSET", key, time.Now(), &err)
+	b, err := c.s.Marshal(value)
+	if err != nil {
+		return err
+	}
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Do("HSET", key, field, b)
+	return err
+}
+
+func (c RedisCache) HGet(key, field string, ptrValue interface{}) error {
+	return c.HGetCtx(context.Background(), key, field, ptrValue)
+}
+
+// HGetCtx decodes field of the hash key into ptrValue, returning
+// ErrCacheMiss if the hash or the field does not exist.
+func (c RedisCache) HGetCtx(ctx context.Context, key, field string, ptrValue interface{}) (err error) {
+	defer c.trace("HGET", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	raw, err := conn.Do("HGET", key, field)
+	if err != nil {
+		return err
+	} else if raw == nil {
+		return ErrCacheMiss
+	}
+	item, err := redis.Bytes(raw, err)
+	if err != nil {
+		return err
+	}
+	return c.s.Unmarshal(item, ptrValue)
+}
+
+func (c RedisCache) HGetAll(key string, ptrStructOrMap interface{}) error {
+	return c.HGetAllCtx(context.Background(), key, ptrStructOrMap)
+}
+
+// HGetAllCtx decodes every field of the hash key into ptrStructOrMap, which
+// points to either a map with string keys or a struct. Struct fields are
+// matched by the name in their `redis` tag, or their own name, and hash
+// fields without a match are ignored. It returns ErrCacheMiss if the hash
+// does not exist.
+func (c RedisCache) HGetAllCtx(ctx context.Context, key string, ptrStructOrMap interface{}) (err error) {
+	defer c.trace("HGETALL", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fields, err := redis.StringMap(conn.Do("HGETALL", key))
+	if err != nil {
+		return err
+	} else if len(fields) == 0 {
+		return ErrCacheMiss
+	}
+	return c.decodeHash(fields, ptrStructOrMap)
+}
+
+// decodeHash unmarshals the values of fields into the map or struct ptr
+// points to.
+func (c RedisCache) decodeHash(fields map[string]string, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("cache: HGetAll needs a non nil pointer, got %T", ptr)
+	}
+	switch v = v.Elem(); {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(fields)))
+		}
+		for f, s := range fields {
+			elem := reflect.New(v.Type().Elem())
+			if err := c.s.Unmarshal([]byte(s), elem.Interface()); err != nil {
+				return fmt.Errorf("cache: field %q: %w", f, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(f).Convert(v.Type().Key()), elem.Elem())
+		}
+		return nil
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := sf.Name
+			if tag, ok := sf.Tag.Lookup("redis"); ok {
+				if tag == "-" {
+					continue
+				} else if tag != "" {
+					name = tag
+				}
+			}
+			s, ok := fields[name]
+			if !ok {
+				continue
+			}
+			if err := c.s.Unmarshal([]byte(s), v.Field(i).Addr().Interface()); err != nil {
+				return fmt.Errorf("cache: field %q: %w", name, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cache: HGetAll needs a pointer to a struct or a map with string keys, got %T", ptr)
+}
+
+func (c RedisCache) HDel(key string, fields ...string) (int, error) {
+	return c.HDelCtx(context.Background(), key, fields...)
+}
+
+// HDelCtx removes fields from the hash key and returns how many existed, with
+// ErrCacheMiss if none did.
+func (c RedisCache) HDelCtx(ctx context.Context, key string, fields ...string) (n int, err error) {
+	defer c.trace("HDEL", key, time.Now(), &err)
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	n, err = redis.Int(conn.Do("HDEL", redis.Args{key}.AddFlat(fields)...))
+	if err == nil && n == 0 {
+		err = ErrCacheMiss
+	}
+	return n, err
+}
+
+func (c RedisCache) HIncrBy(key, field string, delta int64) (int64, error) {
+	return c.HIncrByCtx(context.Background(), key, field, delta)
+}
+
+// HIncrByCtx adds delta to the counter in field of the hash key and returns
+// its new value. As with Increment, the field is not created: it returns
+// ErrCacheMiss if it does not exist, and ErrInvalidValue if it does not
+// hold an integer.
+func (c RedisCache) HIncrByCtx(ctx context.Context, key, field string, delta int64) (v int64, err error) {
+	defer c.trace("HINCRBY", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	v, err = redis.Int64(hincrScript.Do(conn, key, delta, field))
+	switch err.(type) {
+	case nil:
+		return v, nil
+	case redis.Error:
+		return 0, ErrInvalidValue
+	}
+	if err == redis.ErrNil {
+		err = ErrCacheMiss
+	}
+	return 0, err
+}
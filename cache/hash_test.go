@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHashGetSet(t *testing.T) {
+	c, m := newTestRedis(t)
+	ann := testUser{Name: "ann", Age: 42, Roles: []string{"admin"}}
+	if err := c.HSet("user:1", "profile", ann); err != nil {
+		t.Fatal(err)
+	}
+	c.HSet("user:1", "visits", 3)
+	c.HSet("user:1", "email", "ann@example.com")
+
+	var u testUser
+	if err := c.HGet("user:1", "profile", &u); err != nil || !reflect.DeepEqual(u, ann) {
+		t.Errorf("HGet(profile) = %+v, %v", u, err)
+	}
+	// Primitives are stored raw, readable by other clients.
+	if got := m.HGet("user:1", "visits"); got != "3" {
+		t.Errorf("visits stored as %q", got)
+	}
+	var email string
+	if err := c.HGet("user:1", "email", &email); err != nil || email != "ann@example.com" {
+		t.Errorf("HGet(email) = %q, %v", email, err)
+	}
+
+	if err := c.HGet("user:1", "missing", &email); err != ErrCacheMiss {
+		t.Errorf("HGet of a missing field = %v, want ErrCacheMiss", err)
+	}
+	if err := c.HGet("user:2", "email", &email); err != ErrCacheMiss {
+		t.Errorf("HGet of a missing hash = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestHashGetAll(t *testing.T) {
+	c, _ := newTestRedis(t)
+	c.HSet("user:1", "name", "ann")
+	c.HSet("user:1", "visits", 3)
+	c.HSet("user:1", "Roles", []string{"admin", "ops"})
+	c.HSet("user:1", "unknown", "ignored")
+
+	var u struct {
+		Name   string `redis:"name"`
+		Visits int    `redis:"visits"`
+		Roles  []string
+		Skip   string `redis:"-"`
+		hidden string
+	}
+	if err := c.HGetAll("user:1", &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "ann" || u.Visits != 3 || len(u.Roles) != 2 || u.Skip != "" || u.hidden != "" {
+		t.Errorf("HGetAll into a struct = %+v", u)
+	}
+
+	c.HSet("counts", "a", 1)
+	c.HSet("counts", "b", 2)
+	var counts map[string]int
+	if err := c.HGetAll("counts", &counts); err != nil || !reflect.DeepEqual(counts, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("HGetAll into a map = %v, %v", counts, err)
+	}
+
+	if err := c.HGetAll("user:2", &counts); err != ErrCacheMiss {
+		t.Errorf("HGetAll of a missing hash = %v, want ErrCacheMiss", err)
+	}
+	for _, dst := range []interface{}{u, (*testUser)(nil), new(map[int]int), new(int)} {
+		if err := c.HGetAll("counts", dst); err == nil || err == ErrCacheMiss {
+			t.Errorf("HGetAll into %T = %v, want an error", dst, err)
+		}
+	}
+	// A value the field cannot hold is reported with its name.
+	var bad struct {
+		Name int `redis:"name"`
+	}
+	if err := c.HGetAll("user:1", &bad); err == nil {
+		t.Error("HGetAll decoded a string into an int")
+	}
+}
+
+func TestHashDel(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.HSet("h", "a", 1)
+	c.HSet("h", "b", 2)
+	if n, err := c.HDel("h", "a", "missing"); err != nil || n != 1 {
+		t.Errorf("HDel = %d, %v, want 1", n, err)
+	}
+	if n, err := c.HDel("h", "a"); err != ErrCacheMiss || n != 0 {
+		t.Errorf("HDel of a removed field = %d, %v, want ErrCacheMiss", n, err)
+	}
+	if n, err := c.HDel("h"); err != nil || n != 0 {
+		t.Errorf("HDel of no fields = %d, %v", n, err)
+	}
+	c.HDel("h", "b")
+	if m.Exists("h") {
+		t.Error("the hash is still there without fields")
+	}
+	if _, err := c.HDel("h", "b"); err != ErrCacheMiss {
+		t.Errorf("HDel of a missing hash = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestHashIncrBy(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.HSet("h", "n", 40)
+	if v, err := c.HIncrBy("h", "n", 2); err != nil || v != 42 {
+		t.Errorf("HIncrBy = %d, %v, want 42", v, err)
+	}
+	if v, err := c.HIncrBy("h", "n", -50); err != nil || v != -8 {
+		t.Errorf("HIncrBy below zero = %d, %v, want -8", v, err)
+	}
+	var n int
+	if err := c.HGet("h", "n", &n); err != nil || n != -8 {
+		t.Errorf("HGet after HIncrBy = %d, %v", n, err)
+	}
+
+	if _, err := c.HIncrBy("h", "missing", 1); err != ErrCacheMiss {
+		t.Errorf("HIncrBy of a missing field = %v, want ErrCacheMiss", err)
+	}
+	if m.HGet("h", "missing") != "" {
+		t.Error("HIncrBy created the field")
+	}
+	if _, err := c.HIncrBy("other", "n", 1); err != ErrCacheMiss {
+		t.Errorf("HIncrBy of a missing hash = %v, want ErrCacheMiss", err)
+	}
+	c.HSet("h", "name", "ann")
+	if _, err := c.HIncrBy("h", "name", 1); err != ErrInvalidValue {
+		t.Errorf("HIncrBy of a string = %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestHashExpire(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.HSet("h", "a", 1)
+	if err := c.Expire("h", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// Writing a field keeps the expiration of the hash.
+	c.HSet("h", "b", 2)
+	if ttl, err := c.TTL("h"); err != nil || ttl != time.Minute {
+		t.Errorf("TTL = %v, %v", ttl, err)
+	}
+	m.FastForward(time.Minute)
+	if err := c.HGet("h", "b", new(int)); err != ErrCacheMiss {
+		t.Errorf("HGet after the hash expired = %v, want ErrCacheMiss", err)
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// scan walks the keys matching pattern with SCAN MATCH, calling fn with
+// each batch, until the cursor is exhausted or fn fails.
+func scan(conn redis.Conn, pattern string, fn func(keys []string) error) error {
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", scanCount))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// Keys returns the keys matching the glob-style pattern, see
+// DeleteByPattern. It is meant for debugging: the keyspace is walked
+// incrementally, so keys written meanwhile may be missed. The caches
+// returned by WithNamespace list the keys of their namespace only.
+func (c RedisCache) Keys(pattern string) (keys []string, err error) {
+	return c.KeysCtx(context.Background(), pattern)
+}
+
+func (c RedisCache) KeysCtx(ctx context.Context, pattern string) (keys []string, err error) {
+	seen := make(map[string]bool)
+	err = c.ScanKeysCtx(ctx, pattern, func(key string) error {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ScanKeys calls fn with each key matching pattern, without holding them
+// all in memory. SCAN may return a key more than once, and a key may be
+// deleted by the time fn is called with it. ScanKeys stops and returns the
+// error of fn if it fails.
+func (c RedisCache) ScanKeys(pattern string, fn func(key string) error) (err error) {
+	return c.ScanKeysCtx(context.Background(), pattern, fn)
+}
+
+func (c RedisCache) ScanKeysCtx(ctx context.Context, pattern string, fn func(key string) error) (err error) {
+	defer c.trace("SCAN", pattern, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return scan(conn, pattern, func(keys []string) error {
+		for _, k := range keys {
+			if err := fn(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *RedisClusterCache) Keys(pattern string) ([]string, error) {
+	return c.KeysCtx(context.Background(), pattern)
+}
+
+// KeysCtx returns the keys of every master known from a fresh slot map.
+func (c *RedisClusterCache) KeysCtx(ctx context.Context, pattern string) ([]string, error) {
+	nodes, err := c.masters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, n := range nodes {
+		found, err := n.KeysCtx(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, found...)
+	}
+	return keys, nil
+}
+
+func (c *RedisClusterCache) ScanKeys(pattern string, fn func(key string) error) error {
+	return c.ScanKeysCtx(context.Background(), pattern, fn)
+}
+
+// ScanKeysCtx scans every master known from a fresh slot map in turn.
+func (c *RedisClusterCache) ScanKeysCtx(ctx context.Context, pattern string, fn func(key string) error) error {
+	nodes, err := c.masters(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := n.ScanKeysCtx(ctx, pattern, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	c, _ := newTestRedis(t)
+	items := make(map[string]interface{})
+	for i := 0; i < 2*scanCount+10; i++ {
+		items[fmt.Sprint("user:", i)] = i
+	}
+	items["session:1"] = 1
+	c.SetMulti(items, DefaultExpiryTime)
+
+	keys, err := c.Keys("user:*")
+	if err != nil || len(keys) != 2*scanCount+10 {
+		t.Fatalf("Keys = %d keys, %v, want %d", len(keys), err, 2*scanCount+10)
+	}
+	sort.Strings(keys)
+	if keys[0] != "user:0" {
+		t.Errorf("first key = %q", keys[0])
+	}
+	if keys, err := c.Keys("session:?"); err != nil || len(keys) != 1 || keys[0] != "session:1" {
+		t.Errorf("Keys(session:?) = %v, %v", keys, err)
+	}
+	if keys, err := c.Keys("none:*"); err != nil || len(keys) != 0 {
+		t.Errorf("Keys(none:*) = %v, %v", keys, err)
+	}
+}
+
+func TestScanKeysStopsEarly(t *testing.T) {
+	c, _ := newTestRedis(t)
+	for i := 0; i < 2*scanCount; i++ {
+		c.Set(fmt.Sprint("k", i), i, DefaultExpiryTime)
+	}
+	errStop := errors.New("stop")
+	calls := 0
+	err := c.ScanKeys("k*", func(string) error {
+		calls++
+		if calls == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop || calls != 3 {
+		t.Errorf("ScanKeys = %v after %d calls, want the error of fn after 3", err, calls)
+	}
+}
+
+func TestScanKeysWhileDeleting(t *testing.T) {
+	c, _ := newTestRedis(t)
+	for i := 0; i < 3*scanCount; i++ {
+		c.Set(fmt.Sprint("k", i), i, DefaultExpiryTime)
+	}
+	// Deleting each key seen, and others not seen yet, does not break the
+	// scan.
+	seen := 0
+	err := c.ScanKeys("k*", func(key string) error {
+		seen++
+		c.Delete(key)
+		c.Delete(fmt.Sprint("k", seen*7%(3*scanCount)))
+		return nil
+	})
+	if err != nil || seen == 0 {
+		t.Errorf("ScanKeys = %v after %d keys", err, seen)
+	}
+	if keys, _ := c.Keys("k*"); len(keys) != 0 {
+		t.Errorf("%d keys left after deleting every key scanned", len(keys))
+	}
+}
+
+func TestClusterKeys(t *testing.T) {
+	c, _, _ := newTestCluster(t)
+	c.SetMulti(map[string]interface{}{"a:1": 1, "a:2": 2, "b:1": 3}, time.Hour)
+	keys, err := c.Keys("a:*")
+	sort.Strings(keys)
+	if err != nil || len(keys) != 2 || keys[0] != "a:1" || keys[1] != "a:2" {
+		t.Errorf("Keys = %v, %v", keys, err)
+	}
+}
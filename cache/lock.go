@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var ErrLockNotHeld = errors.New("cache: lock not held")
+
+// Lock is a mutual exclusion lock shared by all processes using the same
+// Redis server. It is held by storing a random token under its key, so it
+// is released automatically when its TTL runs out, e.g. because the holder
+// died, and it can only be released or refreshed by its holder. Work
+// outliving the TTL must Refresh the lock in time.
+//
+// A Lock is not safe for concurrent use; every goroutine competing for the
+// lock needs its own.
+type Lock struct {
+	c     RedisCache
+	key   string
+	ttl   time.Duration
+	token string
+}
+
+// NewLock returns a lock stored under key that expires ttl after it was
+// acquired or last refreshed.
+func (c RedisCache) NewLock(key string, ttl time.Duration) *Lock {
+	var b [16]byte
+	rand.Read(b[:])
+	return &Lock{c: c, key: key, ttl: ttl, token: hex.EncodeToString(b[:])}
+}
+
+// Acquire tries to take the lock once and reports whether it succeeded. It
+// does not wait for the lock to become free.
+func (l *Lock) Acquire(ctx context.Context) (acquired bool, err error) {
+	defer l.c.trace("LOCK", l.key, time.Now(), &err)
+	conn, err := l.c.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	reply, err := conn.Do("SET", l.key, l.token, "NX", "PX", l.pttl())
+	return reply != nil, err
+}
+
+// unlockScript deletes KEYS[1] if it holds the token ARGV[1].
+var unlockScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// refreshScript sets the TTL of KEYS[1] to ARGV[2] milliseconds if it holds
+// the token ARGV[1].
+var refreshScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Release releases the lock. It returns ErrLockNotHeld if the lock is not
+// held by l, for instance because it expired.
+func (l *Lock) Release() (err error) {
+	defer l.c.trace("UNLOCK", l.key, time.Now(), &err)
+	return l.run(unlockScript, l.key, l.token)
+}
+
+// Refresh resets the TTL of the lock. It returns ErrLockNotHeld if the lock
+// is not held by l anymore.
+func (l *Lock) Refresh() (err error) {
+	defer l.c.trace("REFRESH", l.key, time.Now(), &err)
+	return l.run(refreshScript, l.key, l.token, l.pttl())
+}
+
+func (l *Lock) run(script *redis.Script, args ...interface{}) error {
+	conn, err := l.c.conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ok, err := redis.Bool(script.Do(conn, args...))
+	if err == nil && !ok {
+		err = ErrLockNotHeld
+	}
+	return err
+}
+
+// pttl returns the TTL in milliseconds, at least 1.
+func (l *Lock) pttl() int64 {
+	if ms := int64(l.ttl / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockMutualExclusion(t *testing.T) {
+	c, _ := newTestRedis(t)
+	var inside, entered atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := c.NewLock("job", time.Minute)
+			for i := 0; i < 20; i++ {
+				for {
+					ok, err := l.Acquire(context.Background())
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if ok {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+				if n := inside.Add(1); n != 1 {
+					t.Errorf("%d holders of the lock", n)
+				}
+				entered.Add(1)
+				time.Sleep(time.Millisecond)
+				inside.Add(-1)
+				if err := l.Release(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := entered.Load(); n != 40 {
+		t.Errorf("the lock was held %d times, want 40", n)
+	}
+}
+
+func TestLockExpiry(t *testing.T) {
+	c, m := newTestRedis(t)
+	ctx := context.Background()
+	first, second := c.NewLock("job", time.Second), c.NewLock("job", time.Second)
+	if ok, err := first.Acquire(ctx); !ok || err != nil {
+		t.Fatalf("Acquire = %v, %v", ok, err)
+	}
+	if ok, err := second.Acquire(ctx); ok || err != nil {
+		t.Fatalf("Acquire of a held lock = %v, %v", ok, err)
+	}
+	// Taking a lock again does not extend it either.
+	if ok, _ := first.Acquire(ctx); ok {
+		t.Error("a held lock was acquired again by its holder")
+	}
+
+	// Refreshing keeps the lock past its first TTL.
+	m.FastForward(900 * time.Millisecond)
+	if err := first.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	m.FastForward(900 * time.Millisecond)
+	if ok, _ := second.Acquire(ctx); ok {
+		t.Fatal("a refreshed lock was taken over")
+	}
+
+	// An expired lock can be acquired again, and its former holder can
+	// neither release nor refresh it.
+	m.FastForward(100 * time.Millisecond)
+	if ok, err := second.Acquire(ctx); !ok || err != nil {
+		t.Fatalf("Acquire of an expired lock = %v, %v", ok, err)
+	}
+	if err := first.Release(); err != ErrLockNotHeld {
+		t.Errorf("Release by the former holder = %v, want ErrLockNotHeld", err)
+	}
+	if err := first.Refresh(); err != ErrLockNotHeld {
+		t.Errorf("Refresh by the former holder = %v, want ErrLockNotHeld", err)
+	}
+	if !m.Exists("job") {
+		t.Fatal("the former holder released the lock")
+	}
+	if err := second.Release(); err != nil {
+		t.Error(err)
+	}
+	if err := second.Release(); err != ErrLockNotHeld {
+		t.Errorf("second Release = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestLockAcquireCanceled(t *testing.T) {
+	c, _ := newTestRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err := c.NewLock("job", time.Second).Acquire(ctx); ok || err != context.Canceled {
+		t.Errorf("Acquire = %v, %v, want context.Canceled", ok, err)
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+// WatchLogLevel polls key in c every interval and applies the level it
+// names to the default logger, so the level of a whole fleet can be changed
+// by writing one cache key. The value must be the raw level name ("debug",
+// "info", "warn", "error" or "all"), e.g. written with redis-cli or with
+// c.Set(key, []byte("debug"), ...). When the key is missing the logger is
+// set back to baseline.
+//
+// The level is only changed when it differs from the last one applied.
+// Invalid names and cache errors are logged once and otherwise ignored. Call
+// the returned function to stop watching; it is safe to call more than once.
+func WatchLogLevel(c Cache, key string, interval time.Duration, baseline log.LogLever) (stop func()) {
+	t := time.NewTicker(interval)
+	stopWatch := watchLogLevel(c, key, baseline, t.C, nil)
+	return func() {
+		stopWatch()
+		t.Stop()
+	}
+}
+
+// watchLogLevel runs the watcher of WatchLogLevel, polling once at start and
+// then on every value received from tick. polled, if not nil, is called
+// after each poll, so that tests can drive the watcher with their own tick
+// channel as a fake clock.
+func watchLogLevel(c Cache, key string, baseline log.LogLever, tick <-chan time.Time, polled func()) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		applied := log.GetLevel()
+		var lastProblem string
+		for {
+			level, problem := readLogLevel(c, key, baseline)
+			if problem != "" {
+				if problem != lastProblem {
+					log.Warn("cache: watching log level key %q: %s", key, problem)
+				}
+			} else if level != applied {
+				log.SetLevel(level)
+				applied = level
+			}
+			lastProblem = problem
+			if polled != nil {
+				polled()
+			}
+
+			select {
+			case <-tick:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-done
+		})
+	}
+}
+
+func readLogLevel(c Cache, key string, baseline log.LogLever) (level log.LogLever, problem string) {
+	var raw []byte
+	switch err := c.Get(key, &raw); err {
+	case nil:
+	case ErrCacheMiss:
+		return baseline, ""
+	default:
+		return 0, err.Error()
+	}
+
+	level, err := log.ParseLevel(string(raw))
+	if err != nil {
+		return 0, err.Error()
+	}
+	return log.AtLeast(level), ""
+}
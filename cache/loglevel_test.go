@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+// fakeLevelClock drives a log level watcher: tick runs one poll and waits
+// for it to finish.
+type fakeLevelClock struct {
+	ticks  chan time.Time
+	polled chan struct{}
+}
+
+func newFakeLevelClock() *fakeLevelClock {
+	return &fakeLevelClock{ticks: make(chan time.Time), polled: make(chan struct{})}
+}
+
+func (f *fakeLevelClock) done() { f.polled <- struct{}{} }
+
+func (f *fakeLevelClock) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-f.polled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher did not poll")
+	}
+}
+
+func (f *fakeLevelClock) tick(t *testing.T) {
+	t.Helper()
+	f.ticks <- time.Now()
+	f.wait(t)
+}
+
+// captureStdLog makes the default logger write to a MemoryHandler for the
+// rest of the test.
+func captureStdLog(t *testing.T) *log.MemoryHandler {
+	h := log.NewMemoryHandler()
+	level := log.GetLevel()
+	log.SetHandler(h)
+	t.Cleanup(func() {
+		stdout, _ := log.NewStreamHandler(os.Stdout)
+		log.SetHandler(stdout)
+		log.SetLevel(level)
+	})
+	return h
+}
+
+func TestWatchLogLevel(t *testing.T) {
+	logs := captureStdLog(t)
+	log.SetLevel(log.AtLeast(log.LevelInfo))
+	baseline := log.AtLeast(log.LevelWarn)
+
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	clock := newFakeLevelClock()
+	stop := watchLogLevel(c, "loglevel", baseline, clock.ticks, clock.done)
+	defer stop()
+
+	// The key is missing at start: back to the baseline.
+	clock.wait(t)
+	if got := log.GetLevel(); got != baseline {
+		t.Fatalf("level = %v, want the baseline %v", got, baseline)
+	}
+
+	c.Set("loglevel", []byte("debug"), DefaultExpiryTime)
+	clock.tick(t)
+	if got := log.GetLevel(); got != log.AtLeast(log.LevelDebug) {
+		t.Fatalf("level = %v, want debug and above", got)
+	}
+
+	// An unchanged key is not applied again, so a level set by hand stays
+	// until the key changes.
+	log.SetLevel(log.LevelError)
+	clock.tick(t)
+	if got := log.GetLevel(); got != log.LevelError {
+		t.Errorf("level = %v, the unchanged key was applied again", got)
+	}
+	c.Set("loglevel", []byte("WARNING"), DefaultExpiryTime)
+	clock.tick(t)
+	if got := log.GetLevel(); got != log.AtLeast(log.LevelWarn) {
+		t.Errorf("level = %v, want warn and above", got)
+	}
+
+	// Invalid names are logged once and ignored.
+	c.Set("loglevel", []byte("loud"), DefaultExpiryTime)
+	clock.tick(t)
+	clock.tick(t)
+	clock.tick(t)
+	if got := log.GetLevel(); got != log.AtLeast(log.LevelWarn) {
+		t.Errorf("level = %v after an invalid name", got)
+	}
+	log.StdLogger().Flush()
+	warnings := 0
+	for _, e := range logs.Entries() {
+		if strings.Contains(e.Line, `"loglevel"`) && strings.Contains(e.Line, "loud") {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("the invalid name was logged %d times, want once", warnings)
+	}
+
+	c.Set("loglevel", []byte("info"), DefaultExpiryTime)
+	clock.tick(t)
+	if got := log.GetLevel(); got != log.AtLeast(log.LevelInfo) {
+		t.Errorf("level = %v, want info and above", got)
+	}
+	c.Delete("loglevel")
+	clock.tick(t)
+	if got := log.GetLevel(); got != baseline {
+		t.Errorf("level = %v after the key disappeared, want the baseline", got)
+	}
+}
+
+// failingGetCache fails every Get with err.
+type failingGetCache struct {
+	Cache
+	err error
+}
+
+func (c failingGetCache) Get(string, interface{}) error { return c.err }
+
+func TestWatchLogLevelCacheErrors(t *testing.T) {
+	logs := captureStdLog(t)
+	log.SetLevel(log.AtLeast(log.LevelInfo))
+
+	c := failingGetCache{err: errors.New("connection refused")}
+	clock := newFakeLevelClock()
+	stop := watchLogLevel(c, "loglevel", log.LevelAll, clock.ticks, clock.done)
+	clock.wait(t)
+	clock.tick(t)
+	clock.tick(t)
+	stop()
+	stop()
+
+	if got := log.GetLevel(); got != log.AtLeast(log.LevelInfo) {
+		t.Errorf("level = %v, a failing cache must not change it", got)
+	}
+	log.StdLogger().Flush()
+	n := 0
+	for _, e := range logs.Entries() {
+		if strings.Contains(e.Line, "connection refused") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("the cache error was logged %d times, want once", n)
+	}
+}
+
+func TestWatchLogLevelStop(t *testing.T) {
+	captureStdLog(t)
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	stop := WatchLogLevel(c, "loglevel", time.Millisecond, log.LevelAll)
+	time.Sleep(5 * time.Millisecond)
+	stop()
+	stop()
+
+	// Once stopped, the key is no longer applied.
+	log.SetLevel(log.LevelError)
+	c.Set("loglevel", []byte("debug"), DefaultExpiryTime)
+	time.Sleep(5 * time.Millisecond)
+	if got := log.GetLevel(); got != log.LevelError {
+		t.Errorf("level = %v after stop", got)
+	}
+}
@@ -0,0 +1,570 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	memoryShards = 32
+
+	// memoryJanitorInterval is how often expired entries are purged. Reads
+	// never return an expired entry regardless.
+	memoryJanitorInterval = time.Minute
+)
+
+// MemoryCache is an in-process implementation of Cache, for tests and
+// single-instance tools. Values are serialized like in Redis, so Get has the
+// same ptrValue contract and a stored value is never aliased by the caller.
+// It is safe for concurrent use. Call Close to stop its janitor goroutine.
+//
+// A MemoryCache made by NewLRUCache holds a bounded number of items and is
+// suited as the local layer of a TieredCache.
+type MemoryCache struct {
+	defaultExpiration time.Duration
+	s                 Serializer
+
+	seed    maphash.Seed
+	shards  [memoryShards]memoryShard
+	flights *flightGroup
+	*instruments
+
+	tagsMu sync.Mutex
+	tags   map[string]map[string]struct{} // keys by tag
+
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type memoryShard struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+
+	// lru holds the keys, least recently used last, if the shard holds at
+	// most max items; it is nil otherwise.
+	lru *list.List
+	max int
+}
+
+type memoryItem struct {
+	data []byte
+	// expires is zero for items that never expire.
+	expires time.Time
+	// elem is the key in the lru of the shard, if any.
+	elem *list.Element
+}
+
+func (it memoryItem) expired(now time.Time) bool {
+	return !it.expires.IsZero() && !now.Before(it.expires)
+}
+
+// NewMemoryCache returns an empty cache. A defaultExpiration <= 0 makes
+// items stored with DefaultExpiryTime never expire.
+func NewMemoryCache(defaultExpiration time.Duration) *MemoryCache {
+	return NewMemoryCacheWithSerializer(defaultExpiration, GobSerializer{})
+}
+
+// NewMemoryCacheWithSerializer is NewMemoryCache with values stored using s.
+func NewMemoryCacheWithSerializer(defaultExpiration time.Duration, s Serializer) *MemoryCache {
+	return newMemoryCache(defaultExpiration, s, 0)
+}
+
+// NewLRUCache is NewMemoryCache holding a bounded number of items: storing
+// a new key into a full cache evicts the least recently read or written
+// one. Items are evicted per shard, so at most maxEntries rounded up to a
+// multiple of 32 are kept, and fewer when keys hash unevenly. A maxEntries
+// <= 0 means no limit.
+func NewLRUCache(maxEntries int, defaultExpiration time.Duration) *MemoryCache {
+	return newMemoryCache(defaultExpiration, GobSerializer{}, maxEntries)
+}
+
+func newMemoryCache(defaultExpiration time.Duration, s Serializer, maxEntries int) *MemoryCache {
+	if s == nil {
+		s = GobSerializer{}
+	}
+	c := &MemoryCache{
+		defaultExpiration: defaultExpiration,
+		s:                 s,
+		seed:              maphash.MakeSeed(),
+		flights:           newFlightGroup(),
+		instruments:       new(instruments),
+		tags:              make(map[string]map[string]struct{}),
+		quit:              make(chan struct{}),
+		done:              make(chan struct{}),
+	}
+	for i := range c.shards {
+		c.shards[i].items = make(map[string]memoryItem)
+		if maxEntries > 0 {
+			c.shards[i].lru = list.New()
+			c.shards[i].max = (maxEntries + memoryShards - 1) / memoryShards
+		}
+	}
+	go c.janitor()
+	return c
+}
+
+// InitInMemoryCache sets up a MemoryCache as the default cache.
+func InitInMemoryCache(defaultExpiration time.Duration) error {
+	return initCache(func() (Cache, error) {
+		return NewMemoryCache(defaultExpiration), nil
+	})
+}
+
+func (c *MemoryCache) shard(key string) *memoryShard {
+	return &c.shards[maphash.String(c.seed, key)%memoryShards]
+}
+
+// get returns the item of key, marking it as recently used.
+func (s *memoryShard) get(key string) (memoryItem, bool) {
+	if s.lru == nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		it, ok := s.items[key]
+		return it, ok
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if ok {
+		s.lru.MoveToFront(it.elem)
+	}
+	return it, ok
+}
+
+// put stores it under key, evicting the least recently used item if the
+// shard is full. s.mu must be held.
+func (s *memoryShard) put(key string, it memoryItem) {
+	if s.lru != nil {
+		if old, ok := s.items[key]; ok {
+			it.elem = old.elem
+			s.lru.MoveToFront(it.elem)
+		} else {
+			it.elem = s.lru.PushFront(key)
+			if s.lru.Len() > s.max {
+				s.del(s.lru.Back().Value.(string))
+			}
+		}
+	}
+	s.items[key] = it
+}
+
+// del removes key. s.mu must be held.
+func (s *memoryShard) del(key string) {
+	if it, ok := s.items[key]; ok && it.elem != nil {
+		s.lru.Remove(it.elem)
+	}
+	delete(s.items, key)
+}
+
+func (c *MemoryCache) expiry(expires time.Duration) time.Time {
+	switch expires {
+	case DefaultExpiryTime:
+		expires = c.defaultExpiration
+	case ForEverNeverExpiry:
+		expires = 0
+	}
+	if expires <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expires)
+}
+
+func (c *MemoryCache) Set(key string, value interface{}, expires time.Duration) (err error) {
+	defer c.trace("SET", key, time.Now(), &err)
+	return c.store(key, value, expires, func(memoryItem, bool) bool { return true })
+}
+
+func (c *MemoryCache) Add(key string, value interface{}, expires time.Duration) (err error) {
+	defer c.trace("ADD", key, time.Now(), &err)
+	return c.store(key, value, expires, func(_ memoryItem, exists bool) bool { return !exists })
+}
+
+func (c *MemoryCache) Replace(key string, value interface{}, expires time.Duration) (err error) {
+	defer c.trace("REPLACE", key, time.Now(), &err)
+	return c.store(key, value, expires, func(_ memoryItem, exists bool) bool { return exists })
+}
+
+func (c *MemoryCache) SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) (err error) {
+	defer c.trace("SET", key, time.Now(), &err)
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	return c.storeKeepTTL(key, value, expires, opts.KeepTTL, func(_ memoryItem, exists bool) bool {
+		return !(opts.NX && exists || opts.XX && !exists)
+	})
+}
+
+// store sets key to value if ok, called with the current item and whether
+// it exists, allows it, and returns ErrNotStored otherwise.
+func (c *MemoryCache) store(key string, value interface{}, expires time.Duration, ok func(it memoryItem, exists bool) bool) error {
+	return c.storeKeepTTL(key, value, expires, false, ok)
+}
+
+// storeKeepTTL is store ignoring expires if keepTTL, keeping the expiration
+// of the current item.
+func (c *MemoryCache) storeKeepTTL(key string, value interface{}, expires time.Duration, keepTTL bool, ok func(it memoryItem, exists bool) bool) error {
+	b, err := c.s.Marshal(value)
+	if err != nil {
+		return err
+	}
+	// serializers return []byte values as-is
+	b = append([]byte(nil), b...)
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, found := s.items[key]
+	exists := found && !it.expired(time.Now())
+	if !ok(it, exists) {
+		return ErrNotStored
+	}
+	exp := c.expiry(expires)
+	if keepTTL {
+		exp = time.Time{}
+		if exists {
+			exp = it.expires
+		}
+	}
+	s.put(key, memoryItem{data: b, expires: exp})
+	return nil
+}
+
+func (c *MemoryCache) Get(key string, ptrValue interface{}) (err error) {
+	defer c.trace("GET", key, time.Now(), &err)
+	it, ok := c.shard(key).get(key)
+	if !ok || it.expired(time.Now()) {
+		return ErrCacheMiss
+	}
+	// serializers hand []byte targets the stored slice itself
+	return c.s.Unmarshal(append([]byte(nil), it.data...), ptrValue)
+}
+
+func (c *MemoryCache) GetWithVersion(key string, ptrValue interface{}) (version uint64, err error) {
+	defer c.trace("GET", key, time.Now(), &err)
+	it, ok := c.shard(key).get(key)
+	if !ok || it.expired(time.Now()) {
+		return 0, ErrCacheMiss
+	}
+	return valueVersion(it.data), c.s.Unmarshal(append([]byte(nil), it.data...), ptrValue)
+}
+
+func (c *MemoryCache) CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) (err error) {
+	defer c.trace("CAS", key, time.Now(), &err)
+	err = c.store(key, value, expires, func(it memoryItem, exists bool) bool {
+		if !exists {
+			return version == 0
+		}
+		return valueVersion(it.data) == version
+	})
+	if err == ErrNotStored {
+		err = ErrCASConflict
+	}
+	return err
+}
+
+func (c *MemoryCache) Exists(key string) (found bool, err error) {
+	defer c.trace("EXISTS", key, time.Now(), &err)
+	return c.exists(key, time.Now()), nil
+}
+
+func (c *MemoryCache) ExistsMulti(keys ...string) (n int, err error) {
+	defer c.traceN("EXISTS", len(keys), time.Now(), &err)
+	now := time.Now()
+	for _, k := range keys {
+		if c.exists(k, now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (c *MemoryCache) exists(key string, now time.Time) bool {
+	s := c.shard(key)
+	s.mu.RLock()
+	it, ok := s.items[key]
+	s.mu.RUnlock()
+	return ok && !it.expired(now)
+}
+
+func (c *MemoryCache) TTL(key string) (ttl time.Duration, err error) {
+	defer c.trace("PTTL", key, time.Now(), &err)
+	s := c.shard(key)
+	s.mu.RLock()
+	it, ok := s.items[key]
+	s.mu.RUnlock()
+
+	now := time.Now()
+	if !ok || it.expired(now) {
+		return 0, ErrCacheMiss
+	}
+	if it.expires.IsZero() {
+		return ForEverNeverExpiry, nil
+	}
+	return it.expires.Sub(now), nil
+}
+
+func (c *MemoryCache) Expire(key string, expires time.Duration) (err error) {
+	defer c.trace("EXPIRE", key, time.Now(), &err)
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[key]
+	if !ok || it.expired(time.Now()) {
+		return ErrCacheMiss
+	}
+	it.expires = c.expiry(expires)
+	s.put(key, it)
+	return nil
+}
+
+func (c *MemoryCache) Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error {
+	return fetch(c, c.s, c.flights, key, ptrValue, expires, loader)
+}
+
+func (c *MemoryCache) GetMulti(keys []string) (items map[string][]byte, err error) {
+	defer c.traceN("MGET", len(keys), time.Now(), &err)
+	items = make(map[string][]byte, len(keys))
+	now := time.Now()
+	for _, k := range keys {
+		it, ok := c.shard(k).get(k)
+		if ok && !it.expired(now) {
+			items[k] = append([]byte(nil), it.data...)
+		}
+	}
+	return items, nil
+}
+
+// SetMulti stores every item that serializes; the others are reported in
+// the SetMultiError.
+func (c *MemoryCache) SetMulti(items map[string]interface{}, expires time.Duration) (err error) {
+	defer c.traceN("MSET", len(items), time.Now(), &err)
+	failed := make(SetMultiError)
+	exp := c.expiry(expires)
+	for k, v := range items {
+		b, err := c.s.Marshal(v)
+		if err != nil {
+			failed[k] = err
+			continue
+		}
+		b = append([]byte(nil), b...)
+
+		s := c.shard(k)
+		s.mu.Lock()
+		s.put(k, memoryItem{data: b, expires: exp})
+		s.mu.Unlock()
+	}
+	return failed.errOrNil()
+}
+
+func (c *MemoryCache) Delete(key string) (err error) {
+	defer c.trace("DEL", key, time.Now(), &err)
+	if !c.remove(key, time.Now()) {
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+// remove deletes key and reports whether it was live at now.
+func (c *MemoryCache) remove(key string, now time.Time) bool {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	s.del(key)
+	return !it.expired(now)
+}
+
+func (c *MemoryCache) SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) (err error) {
+	defer c.trace("SET", key, time.Now(), &err)
+	if err := c.store(key, value, expires, func(memoryItem, bool) bool { return true }); err != nil {
+		return err
+	}
+	c.tagsMu.Lock()
+	defer c.tagsMu.Unlock()
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return nil
+}
+
+func (c *MemoryCache) InvalidateTag(tag string) (n int, err error) {
+	defer c.trace("DEL", tagKey(tag), time.Now(), &err)
+	c.tagsMu.Lock()
+	keys := c.tags[tag]
+	delete(c.tags, tag)
+	c.tagsMu.Unlock()
+
+	now := time.Now()
+	for k := range keys {
+		if c.remove(k, now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (c *MemoryCache) DeleteByPattern(pattern string) (n int, err error) {
+	defer c.trace("DEL", pattern, time.Now(), &err)
+	now := time.Now()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		for k, it := range s.items {
+			if globMatch(pattern, k) {
+				s.del(k)
+				if !it.expired(now) {
+					n++
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	return n, nil
+}
+
+func (c *MemoryCache) Increment(key string, delta uint64) (newValue uint64, err error) {
+	defer c.trace("INCR", key, time.Now(), &err)
+	return c.update(key, func(v uint64) uint64 {
+		return v + delta
+	})
+}
+
+func (c *MemoryCache) IncrementWithTTL(key string, delta uint64, ttl time.Duration) (newValue uint64, err error) {
+	defer c.trace("INCR", key, time.Now(), &err)
+	newValue, err = c.update(key, func(v uint64) uint64 {
+		return v + delta
+	})
+	if err != ErrCacheMiss {
+		return newValue, err
+	}
+	err = c.store(key, strconv.AppendUint(nil, delta, 10), ttl, func(_ memoryItem, exists bool) bool {
+		return !exists
+	})
+	if err == ErrNotStored {
+		// created meanwhile
+		return c.update(key, func(v uint64) uint64 {
+			return v + delta
+		})
+	}
+	return delta, err
+}
+
+func (c *MemoryCache) Decrement(key string, delta uint64) (newValue uint64, err error) {
+	defer c.trace("DECR", key, time.Now(), &err)
+	return c.update(key, func(v uint64) uint64 {
+		if delta > v {
+			return 0
+		}
+		return v - delta
+	})
+}
+
+// update applies fn to the counter stored at key, keeping its expiry.
+func (c *MemoryCache) update(key string, fn func(uint64) uint64) (uint64, error) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[key]
+	if !ok || it.expired(time.Now()) {
+		return 0, ErrCacheMiss
+	}
+	v, err := strconv.ParseUint(string(it.data), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidValue
+	}
+	v = fn(v)
+	it.data = strconv.AppendUint(nil, v, 10)
+	s.put(key, it)
+	return v, nil
+}
+
+func (c *MemoryCache) ClearAll() (err error) {
+	defer c.trace("FLUSHDB", "", time.Now(), &err)
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		clear(s.items)
+		if s.lru != nil {
+			s.lru.Init()
+		}
+		s.mu.Unlock()
+	}
+	c.tagsMu.Lock()
+	clear(c.tags)
+	c.tagsMu.Unlock()
+	return nil
+}
+
+// Serializer returns the serializer the cache stores values with.
+func (c *MemoryCache) Serializer() Serializer {
+	return c.s
+}
+
+// Close stops the janitor goroutine. The cache stays usable, expired items
+// are then only dropped when read.
+func (c *MemoryCache) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+	})
+	<-c.done
+	return nil
+}
+
+func (c *MemoryCache) janitor() {
+	defer close(c.done)
+
+	t := time.NewTicker(memoryJanitorInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			c.deleteExpired()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+func (c *MemoryCache) deleteExpired() {
+	now := time.Now()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		for k, it := range s.items {
+			if it.expired(now) {
+				s.del(k)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	// forget the keys of tags that are gone, and the tags left empty
+	c.tagsMu.Lock()
+	defer c.tagsMu.Unlock()
+	for tag, keys := range c.tags {
+		for k := range keys {
+			if !c.exists(k, now) {
+				delete(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
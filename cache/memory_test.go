@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// storedItems counts the items held by c, expired or not.
+func storedItems(c *MemoryCache) int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		n += len(s.items)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	c := NewMemoryCache(20 * time.Millisecond)
+	defer c.Close()
+	c.Set("default", 1, DefaultExpiryTime)
+	c.Set("short", 1, 10*time.Millisecond)
+	c.Set("forever", 1, ForEverNeverExpiry)
+	c.Set("long", 1, time.Hour)
+
+	time.Sleep(30 * time.Millisecond)
+	for key, live := range map[string]bool{"default": false, "short": false, "forever": true, "long": true} {
+		err := c.Get(key, new(int))
+		if live && err != nil || !live && err != ErrCacheMiss {
+			t.Errorf("Get(%s) = %v, live %v", key, err, live)
+		}
+	}
+	if ttl, err := c.TTL("forever"); err != nil || ttl != ForEverNeverExpiry {
+		t.Errorf("TTL(forever) = %v, %v", ttl, err)
+	}
+	if ttl, err := c.TTL("long"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL(long) = %v, %v", ttl, err)
+	}
+
+	// Expired items are invisible to every operation, even before the
+	// janitor removes them.
+	if err := c.Delete("short"); err != ErrCacheMiss {
+		t.Errorf("Delete(expired) = %v", err)
+	}
+	if _, err := c.Increment("default", 1); err != ErrCacheMiss {
+		t.Errorf("Increment(expired) = %v", err)
+	}
+	if err := c.Add("default", 2, DefaultExpiryTime); err != nil {
+		t.Errorf("Add over an expired item = %v", err)
+	}
+}
+
+func TestMemoryCacheJanitor(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint("old", i), i, time.Millisecond)
+		c.Set(fmt.Sprint("new", i), i, time.Hour)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := storedItems(c); n != 200 {
+		t.Fatalf("%d items before the janitor ran, want the expired ones still there", n)
+	}
+	c.deleteExpired()
+	if n := storedItems(c); n != 100 {
+		t.Errorf("%d items after the janitor ran, want 100", n)
+	}
+}
+
+func TestMemoryCacheClose(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	c.Set("k", 1, DefaultExpiryTime)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Error("the janitor is still running after Close")
+	}
+	c.Close()
+
+	// Without the janitor the cache keeps working.
+	var v int
+	if err := c.Get("k", &v); err != nil || v != 1 {
+		t.Errorf("Get after Close = %d, %v", v, err)
+	}
+}
+
+func TestMemoryCacheConcurrent(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	c.Set("counter", 0, DefaultExpiryTime)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprint("k", i%20)
+				c.Set(key, i, DefaultExpiryTime)
+				c.Get(key, new(int))
+				c.Increment("counter", 1)
+				if i%50 == 0 {
+					c.deleteExpired()
+					c.Delete(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var n int
+	if err := c.Get("counter", &n); err != nil || n != 8*200 {
+		t.Errorf("counter = %d, %v, want %d", n, err, 8*200)
+	}
+}
+
+func TestInitInMemoryCache(t *testing.T) {
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if _, ok := Default().(*MemoryCache); !ok {
+		t.Fatalf("default cache is %T", Default())
+	}
+	if err := Set("k", "v", DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := Get("k", &s); err != nil || s != "v" {
+		t.Errorf("Get = %q, %v", s, err)
+	}
+}
+
+func TestMemoryCacheExpireRunsOut(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	c.Set("k", 1, ForEverNeverExpiry)
+	if err := c.Expire("k", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(15 * time.Millisecond)
+	if _, err := c.TTL("k"); err != ErrCacheMiss {
+		t.Errorf("TTL after expiry = %v, want ErrCacheMiss", err)
+	}
+	if err := c.Expire("k", time.Hour); err != ErrCacheMiss {
+		t.Errorf("Expire after expiry = %v, want ErrCacheMiss", err)
+	}
+}
@@ -0,0 +1,350 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Namespaced is implemented by the caches returned by WithNamespace, so
+// that data kept beside a cache, such as a RedisStrSet, can be stored
+// under the same prefix.
+type Namespaced interface {
+	Namespace() string
+}
+
+// NamespaceKey returns key under the namespace of c, or key itself if c has
+// no namespace.
+func NamespaceKey(c Cache, key string) string {
+	if n, ok := c.(Namespaced); ok {
+		return n.Namespace() + key
+	}
+	return key
+}
+
+// WithNamespace returns a cache storing every key of c, and every tag, under
+// the prefix ns, e.g. "billing:", so that several components can share one
+// cache without their keys colliding. Keys are given and returned without
+// the prefix. ClearAll only deletes the keys of the namespace, and the
+// patterns of DeleteByPattern only match within it.
+//
+// The result shares the statistics and hook of c. Closing it does not close
+// c, which other namespaces may still be using.
+func WithNamespace(c Cache, ns string) CacheCtx {
+	return nsCache{c: WithContext(c), prefix: ns, ns: NamespaceKey(c, ns)}
+}
+
+type nsCache struct {
+	c CacheCtx
+	// prefix is added to the keys given to c, and ns is the full namespace,
+	// including that of c if it is namespaced too.
+	prefix string
+	ns     string
+}
+
+func (c nsCache) Namespace() string {
+	return c.ns
+}
+
+func (c nsCache) key(key string) string {
+	return c.prefix + key
+}
+
+func (c nsCache) keys(keys []string) []string {
+	r := make([]string, len(keys))
+	for i, k := range keys {
+		r[i] = c.key(k)
+	}
+	return r
+}
+
+// unprefixed maps the keys of m back to the names used by the caller.
+func (c nsCache) unprefixed(m map[string][]byte) map[string][]byte {
+	if m == nil {
+		return nil
+	}
+	r := make(map[string][]byte, len(m))
+	for k, v := range m {
+		r[strings.TrimPrefix(k, c.prefix)] = v
+	}
+	return r
+}
+
+// pattern returns pattern restricted to the namespace, whose prefix is
+// escaped so that it matches literally.
+func (c nsCache) pattern(pattern string) string {
+	var b strings.Builder
+	for _, r := range c.prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + pattern
+}
+
+func (c nsCache) Get(key string, ptrValue interface{}) error {
+	return c.c.Get(c.key(key), ptrValue)
+}
+
+func (c nsCache) Set(key string, value interface{}, expires time.Duration) error {
+	return c.c.Set(c.key(key), value, expires)
+}
+
+func (c nsCache) SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) error {
+	return c.c.SetOpt(c.key(key), value, expires, opts)
+}
+
+func (c nsCache) Add(key string, value interface{}, expires time.Duration) error {
+	return c.c.Add(c.key(key), value, expires)
+}
+
+func (c nsCache) Replace(key string, value interface{}, expires time.Duration) error {
+	return c.c.Replace(c.key(key), value, expires)
+}
+
+func (c nsCache) GetWithVersion(key string, ptrValue interface{}) (uint64, error) {
+	return c.c.GetWithVersion(c.key(key), ptrValue)
+}
+
+func (c nsCache) CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) error {
+	return c.c.CompareAndSwap(c.key(key), value, version, expires)
+}
+
+func (c nsCache) Exists(key string) (bool, error) {
+	return c.c.Exists(c.key(key))
+}
+
+func (c nsCache) ExistsMulti(keys ...string) (int, error) {
+	return c.c.ExistsMulti(c.keys(keys)...)
+}
+
+func (c nsCache) TTL(key string) (time.Duration, error) {
+	return c.c.TTL(c.key(key))
+}
+
+func (c nsCache) Expire(key string, expires time.Duration) error {
+	return c.c.Expire(c.key(key), expires)
+}
+
+func (c nsCache) Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error {
+	return c.c.Fetch(c.key(key), ptrValue, expires, loader)
+}
+
+func (c nsCache) GetMulti(keys []string) (map[string][]byte, error) {
+	items, err := c.c.GetMulti(c.keys(keys))
+	return c.unprefixed(items), err
+}
+
+func (c nsCache) SetMulti(items map[string]interface{}, expires time.Duration) error {
+	return c.unprefixedErr(c.c.SetMulti(c.items(items), expires))
+}
+
+func (c nsCache) items(items map[string]interface{}) map[string]interface{} {
+	r := make(map[string]interface{}, len(items))
+	for k, v := range items {
+		r[c.key(k)] = v
+	}
+	return r
+}
+
+// unprefixedErr maps the keys of a SetMultiError back to the names used by
+// the caller.
+func (c nsCache) unprefixedErr(err error) error {
+	failed, ok := err.(SetMultiError)
+	if !ok {
+		return err
+	}
+	r := make(SetMultiError, len(failed))
+	for k, e := range failed {
+		r[strings.TrimPrefix(k, c.prefix)] = e
+	}
+	return r
+}
+
+func (c nsCache) SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) error {
+	return c.c.SetWithTags(c.key(key), value, expires, c.keys(tags)...)
+}
+
+func (c nsCache) InvalidateTag(tag string) (int, error) {
+	return c.c.InvalidateTag(c.key(tag))
+}
+
+func (c nsCache) DeleteByPattern(pattern string) (int, error) {
+	return c.c.DeleteByPattern(c.pattern(pattern))
+}
+
+// keyLister is implemented by the caches that can list their keys, see
+// RedisCache.Keys.
+type keyLister interface {
+	KeysCtx(ctx context.Context, pattern string) ([]string, error)
+	ScanKeysCtx(ctx context.Context, pattern string, fn func(key string) error) error
+}
+
+var errNoKeyListing = errors.New("cache: the namespaced cache cannot list its keys")
+
+// Keys returns the keys of the namespace matching pattern, without the
+// prefix. It fails if the underlying cache cannot list its keys.
+func (c nsCache) Keys(pattern string) ([]string, error) {
+	return c.KeysCtx(context.Background(), pattern)
+}
+
+func (c nsCache) KeysCtx(ctx context.Context, pattern string) ([]string, error) {
+	l, ok := c.c.(keyLister)
+	if !ok {
+		return nil, errNoKeyListing
+	}
+	keys, err := l.KeysCtx(ctx, c.pattern(pattern))
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, c.prefix)
+	}
+	return keys, err
+}
+
+// ScanKeys calls fn with each key of the namespace matching pattern,
+// without the prefix.
+func (c nsCache) ScanKeys(pattern string, fn func(key string) error) error {
+	return c.ScanKeysCtx(context.Background(), pattern, fn)
+}
+
+func (c nsCache) ScanKeysCtx(ctx context.Context, pattern string, fn func(key string) error) error {
+	l, ok := c.c.(keyLister)
+	if !ok {
+		return errNoKeyListing
+	}
+	return l.ScanKeysCtx(ctx, c.pattern(pattern), func(key string) error {
+		return fn(strings.TrimPrefix(key, c.prefix))
+	})
+}
+
+// expirationSubscriber is implemented by the caches notifying of expired
+// keys, see RedisCache.SubscribeExpirations.
+type expirationSubscriber interface {
+	SubscribeExpirations(ctx context.Context, fn func(key string)) error
+}
+
+var errNoExpirations = errors.New("cache: the namespaced cache cannot notify of expirations")
+
+// SubscribeExpirations calls fn with each key of the namespace that
+// expires, without the prefix, like RedisCache.SubscribeExpirations. The
+// keys expiring outside the namespace are ignored.
+func (c nsCache) SubscribeExpirations(ctx context.Context, fn func(key string)) error {
+	s, ok := c.c.(expirationSubscriber)
+	if !ok {
+		return errNoExpirations
+	}
+	return s.SubscribeExpirations(ctx, func(key string) {
+		if k, ok := strings.CutPrefix(key, c.prefix); ok {
+			fn(k)
+		}
+	})
+}
+
+func (c nsCache) Delete(key string) error {
+	return c.c.Delete(c.key(key))
+}
+
+func (c nsCache) Increment(key string, n uint64) (uint64, error) {
+	return c.c.Increment(c.key(key), n)
+}
+
+func (c nsCache) IncrementWithTTL(key string, delta uint64, ttl time.Duration) (uint64, error) {
+	return c.c.IncrementWithTTL(c.key(key), delta, ttl)
+}
+
+func (c nsCache) Decrement(key string, n uint64) (uint64, error) {
+	return c.c.Decrement(c.key(key), n)
+}
+
+// ClearAll deletes the keys of the namespace only.
+func (c nsCache) ClearAll() error {
+	_, err := c.c.DeleteByPattern(c.pattern("*"))
+	return err
+}
+
+func (c nsCache) Stats() CacheStats {
+	return c.c.Stats()
+}
+
+func (c nsCache) ResetStats() {
+	c.c.ResetStats()
+}
+
+func (c nsCache) SetHook(h Hook) {
+	c.c.SetHook(h)
+}
+
+// Close does nothing: the underlying cache is not owned by the namespace.
+func (c nsCache) Close() error {
+	return nil
+}
+
+func (c nsCache) GetCtx(ctx context.Context, key string, ptrValue interface{}) error {
+	return c.c.GetCtx(ctx, c.key(key), ptrValue)
+}
+
+func (c nsCache) SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return c.c.SetCtx(ctx, c.key(key), value, expires)
+}
+
+func (c nsCache) AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return c.c.AddCtx(ctx, c.key(key), value, expires)
+}
+
+func (c nsCache) ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	return c.c.ReplaceCtx(ctx, c.key(key), value, expires)
+}
+
+func (c nsCache) GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (uint64, error) {
+	return c.c.GetWithVersionCtx(ctx, c.key(key), ptrValue)
+}
+
+func (c nsCache) CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) error {
+	return c.c.CompareAndSwapCtx(ctx, c.key(key), value, version, expires)
+}
+
+func (c nsCache) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	return c.c.ExistsCtx(ctx, c.key(key))
+}
+
+func (c nsCache) ExistsMultiCtx(ctx context.Context, keys ...string) (int, error) {
+	return c.c.ExistsMultiCtx(ctx, c.keys(keys)...)
+}
+
+func (c nsCache) TTLCtx(ctx context.Context, key string) (time.Duration, error) {
+	return c.c.TTLCtx(ctx, c.key(key))
+}
+
+func (c nsCache) ExpireCtx(ctx context.Context, key string, expires time.Duration) error {
+	return c.c.ExpireCtx(ctx, c.key(key), expires)
+}
+
+func (c nsCache) GetMultiCtx(ctx context.Context, keys []string) (map[string][]byte, error) {
+	items, err := c.c.GetMultiCtx(ctx, c.keys(keys))
+	return c.unprefixed(items), err
+}
+
+func (c nsCache) SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) error {
+	return c.unprefixedErr(c.c.SetMultiCtx(ctx, c.items(items), expires))
+}
+
+func (c nsCache) DeleteCtx(ctx context.Context, key string) error {
+	return c.c.DeleteCtx(ctx, c.key(key))
+}
+
+func (c nsCache) IncrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	return c.c.IncrementCtx(ctx, c.key(key), n)
+}
+
+func (c nsCache) DecrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	return c.c.DecrementCtx(ctx, c.key(key), n)
+}
+
+// ClearAllCtx deletes the keys of the namespace only, checking ctx first.
+func (c nsCache) ClearAllCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ClearAll()
+}
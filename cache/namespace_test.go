@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNamespaceKeys(t *testing.T) {
+	base := NewMemoryCache(time.Hour)
+	defer base.Close()
+	a, b := WithNamespace(base, "a:"), WithNamespace(base, "b:")
+
+	a.Set("k", "in a", DefaultExpiryTime)
+	b.Set("k", "in b", DefaultExpiryTime)
+	var got string
+	if err := a.Get("k", &got); err != nil || got != "in a" {
+		t.Errorf("a.Get = %q, %v", got, err)
+	}
+	if err := base.Get("b:k", &got); err != nil || got != "in b" {
+		t.Errorf("base.Get(b:k) = %q, %v", got, err)
+	}
+	if err := base.Get("k", &got); err != ErrCacheMiss {
+		t.Errorf("the key should only exist under its namespace: %v", err)
+	}
+
+	a.Set("other", 1, DefaultExpiryTime)
+	items, err := a.GetMulti([]string{"k", "other", "missing"})
+	if err != nil || len(items) != 2 || items["k"] == nil || items["other"] == nil {
+		t.Errorf("GetMulti = %v, %v, want the unprefixed keys", items, err)
+	}
+	if n, _ := a.ExistsMulti("k", "other", "missing"); n != 2 {
+		t.Errorf("ExistsMulti = %d", n)
+	}
+
+	err = a.SetMulti(map[string]interface{}{"ok": 1, "bad": func() {}}, DefaultExpiryTime)
+	failed, isMulti := err.(SetMultiError)
+	if !isMulti || len(failed) != 1 || failed["bad"] == nil {
+		t.Errorf("SetMulti error = %v, want the unprefixed failed key", err)
+	}
+	if ok, _ := base.Exists("a:ok"); !ok {
+		t.Error("SetMulti should store under the namespace")
+	}
+
+	if n, err := a.Increment("missing", 1); err != ErrCacheMiss {
+		t.Errorf("Increment(missing) = %d, %v", n, err)
+	}
+	if n, _ := a.IncrementWithTTL("hits", 2, time.Minute); n != 2 {
+		t.Errorf("IncrementWithTTL = %d", n)
+	}
+	if ok, _ := base.Exists("a:hits"); !ok {
+		t.Error("IncrementWithTTL should count under the namespace")
+	}
+}
+
+func TestNamespaceClearAllAndPatterns(t *testing.T) {
+	base := NewMemoryCache(time.Hour)
+	defer base.Close()
+	// The prefix holds glob metacharacters, which must match literally.
+	a := WithNamespace(base, "a*[1]:")
+	base.Set("a*[1]:x", 1, DefaultExpiryTime)
+	base.Set("a*[1]:y", 1, DefaultExpiryTime)
+	base.Set("ab[1]:x", 1, DefaultExpiryTime)
+	base.Set("a1:x", 1, DefaultExpiryTime)
+	base.Set("x", 1, DefaultExpiryTime)
+
+	if n, err := a.DeleteByPattern("x"); err != nil || n != 1 {
+		t.Errorf("DeleteByPattern(x) = %d, %v", n, err)
+	}
+	if err := a.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"ab[1]:x", "a1:x", "x"} {
+		if ok, _ := base.Exists(k); !ok {
+			t.Errorf("ClearAll of the namespace deleted %q", k)
+		}
+	}
+	if ok, _ := base.Exists("a*[1]:y"); ok {
+		t.Error("ClearAll left a key of the namespace")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.ClearAllCtx(ctx); err != context.Canceled {
+		t.Errorf("ClearAllCtx(canceled) = %v", err)
+	}
+}
+
+func TestNamespaceTags(t *testing.T) {
+	base := NewMemoryCache(time.Hour)
+	defer base.Close()
+	a, b := WithNamespace(base, "a:"), WithNamespace(base, "b:")
+	a.SetWithTags("k1", 1, DefaultExpiryTime, "user")
+	b.SetWithTags("k1", 1, DefaultExpiryTime, "user")
+
+	if n, err := a.InvalidateTag("user"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v", n, err)
+	}
+	if ok, _ := b.Exists("k1"); !ok {
+		t.Error("tags of another namespace must not be invalidated")
+	}
+}
+
+func TestNamespaceNested(t *testing.T) {
+	base := NewMemoryCache(time.Hour)
+	defer base.Close()
+	outer := WithNamespace(base, "app:")
+	inner := WithNamespace(outer, "users:")
+	if got := NamespaceKey(inner, "set"); got != "app:users:set" {
+		t.Errorf("NamespaceKey = %q", got)
+	}
+	inner.Set("42", "x", DefaultExpiryTime)
+	if ok, _ := base.Exists("app:users:42"); !ok {
+		t.Error("nested namespaces should add both prefixes")
+	}
+	if items, _ := inner.GetMulti([]string{"42"}); items["42"] == nil {
+		t.Errorf("GetMulti = %v", items)
+	}
+}
+
+func TestNamespaceSharesCache(t *testing.T) {
+	base := NewMemoryCache(time.Hour)
+	defer base.Close()
+	a := WithNamespace(base, "a:")
+	base.ResetStats()
+
+	a.Set("k", 1, DefaultExpiryTime)
+	a.Get("missing", new(int))
+	if s := a.Stats(); s.Misses != 1 || s.Sets != 1 || base.Stats().Misses != 1 {
+		t.Errorf("Stats = %+v, want those of the shared cache", a.Stats())
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Set("still", "open", DefaultExpiryTime); err != nil {
+		t.Errorf("closing a namespace closed the cache: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := a.SetCtx(ctx, "c", 7, DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	if err := a.GetCtx(ctx, "c", &v); err != nil || v != 7 {
+		t.Errorf("GetCtx = %d, %v", v, err)
+	}
+}
+
+// scanner is the part of the namespaced caches listing their keys.
+type scanner interface {
+	Keys(pattern string) ([]string, error)
+	ScanKeys(pattern string, fn func(key string) error) error
+}
+
+func TestNamespaceListKeys(t *testing.T) {
+	m := miniredis.RunT(t)
+	base, err := NewRedisCache(m.Addr(), "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+	a := WithNamespace(base, "a*:")
+	for _, k := range []string{"a*:x1", "a*:x2", "a*:y", "ab:x3", "x4"} {
+		m.Set(k, "v")
+	}
+
+	keys, err := a.(scanner).Keys("x*")
+	slices.Sort(keys)
+	if err != nil || !slices.Equal(keys, []string{"x1", "x2"}) {
+		t.Errorf("Keys = %q, %v", keys, err)
+	}
+
+	var scanned []string
+	err = WithNamespace(a, "x").(scanner).ScanKeys("*", func(key string) error {
+		scanned = append(scanned, key)
+		return nil
+	})
+	slices.Sort(scanned)
+	if err != nil || !slices.Equal(scanned, []string{"1", "2"}) {
+		t.Errorf("nested ScanKeys = %q, %v", scanned, err)
+	}
+
+	mem := NewMemoryCache(time.Hour)
+	defer mem.Close()
+	if _, err := WithNamespace(mem, "a:").(scanner).Keys("*"); err != errNoKeyListing {
+		t.Errorf("Keys on a memory cache = %v", err)
+	}
+}
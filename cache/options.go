@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// RedisOption configures the connection pool of a Redis cache.
+type RedisOption func(*redisOptions)
+
+type redisOptions struct {
+	maxIdle      int
+	maxActive    int
+	idleTimeout  time.Duration
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	wait         bool
+
+	// network and address replace "tcp" and the host when network is set
+	network   string
+	address   string
+	tls       bool
+	tlsConfig *tls.Config
+	username  string
+}
+
+// WithMaxIdle sets the maximum number of idle connections kept in the pool.
+// The default is 5.
+func WithMaxIdle(n int) RedisOption {
+	return func(o *redisOptions) {
+		o.maxIdle = n
+	}
+}
+
+// WithMaxActive limits the number of connections open at once. The default
+// 0 means no limit.
+func WithMaxActive(n int) RedisOption {
+	return func(o *redisOptions) {
+		o.maxActive = n
+	}
+}
+
+// WithIdleTimeout closes connections idle for longer than d. The default is
+// 240 seconds, 0 keeps idle connections forever.
+func WithIdleTimeout(d time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithDialTimeout bounds connecting to Redis. The default is 10 seconds.
+func WithDialTimeout(d time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.dialTimeout = d
+	}
+}
+
+// WithReadTimeout bounds the wait for each reply. The default is 5 seconds,
+// 0 means no timeout.
+func WithReadTimeout(d time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.readTimeout = d
+	}
+}
+
+// WithWriteTimeout bounds sending each command. The default is 5 seconds,
+// 0 means no timeout.
+func WithWriteTimeout(d time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.writeTimeout = d
+	}
+}
+
+// WithWait makes operations wait for a free connection when the pool has
+// MaxActive connections open, instead of failing with
+// redis.ErrPoolExhausted. The wait ends with the operation's context.
+func WithWait(wait bool) RedisOption {
+	return func(o *redisOptions) {
+		o.wait = wait
+	}
+}
+
+// WithTLS connects over TLS configured by cfg, which may be nil for the
+// defaults. Unless cfg sets ServerName, the host is used for SNI and
+// certificate verification; set InsecureSkipVerify to skip verification.
+// With TLS, the cache is initialized with one connection, so a failing
+// handshake is reported by the Init function rather than by the first
+// operation.
+func WithTLS(cfg *tls.Config) RedisOption {
+	return func(o *redisOptions) {
+		o.tls = true
+		o.tlsConfig = cfg
+	}
+}
+
+// WithNetwork connects to address on network instead of to the host over
+// TCP, e.g. WithNetwork("unix", "/var/run/redis.sock"). It cannot be used
+// with a RedisClusterCache.
+func WithNetwork(network, address string) RedisOption {
+	return func(o *redisOptions) {
+		o.network = network
+		o.address = address
+	}
+}
+
+// WithUsername authenticates as the given Redis 6 ACL user with the
+// password, instead of with the password alone.
+func WithUsername(username string) RedisOption {
+	return func(o *redisOptions) {
+		o.username = username
+	}
+}
+
+func newRedisOptions(opts []RedisOption) (*redisOptions, error) {
+	o := &redisOptions{
+		maxIdle:      5,
+		idleTimeout:  240 * time.Second,
+		dialTimeout:  10 * time.Second,
+		readTimeout:  5 * time.Second,
+		writeTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	switch {
+	case o.maxIdle < 0:
+		return nil, fmt.Errorf("cache: negative max idle %d", o.maxIdle)
+	case o.maxActive < 0:
+		return nil, fmt.Errorf("cache: negative max active %d", o.maxActive)
+	case o.idleTimeout < 0:
+		return nil, fmt.Errorf("cache: negative idle timeout %v", o.idleTimeout)
+	case o.dialTimeout < 0:
+		return nil, fmt.Errorf("cache: negative dial timeout %v", o.dialTimeout)
+	case o.readTimeout < 0:
+		return nil, fmt.Errorf("cache: negative read timeout %v", o.readTimeout)
+	case o.writeTimeout < 0:
+		return nil, fmt.Errorf("cache: negative write timeout %v", o.writeTimeout)
+	}
+	return o, nil
+}
@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRedisOptionsDefaults(t *testing.T) {
+	c, err := NewRedisCache("localhost:6379", "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	p := c.p
+	if p.MaxIdle != 5 || p.MaxActive != 0 || p.IdleTimeout != 240*time.Second || p.Wait {
+		t.Errorf("pool = MaxIdle %d, MaxActive %d, IdleTimeout %v, Wait %v",
+			p.MaxIdle, p.MaxActive, p.IdleTimeout, p.Wait)
+	}
+	o, _ := newRedisOptions(nil)
+	if o.dialTimeout != 10*time.Second || o.readTimeout != 5*time.Second || o.writeTimeout != 5*time.Second {
+		t.Errorf("timeouts = %v, %v, %v", o.dialTimeout, o.readTimeout, o.writeTimeout)
+	}
+}
+
+func TestRedisOptions(t *testing.T) {
+	c, err := NewRedisCache("localhost:6379", "", 0, time.Hour,
+		WithMaxIdle(2), WithMaxActive(8), WithIdleTimeout(time.Minute), WithWait(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	p := c.p
+	if p.MaxIdle != 2 || p.MaxActive != 8 || p.IdleTimeout != time.Minute || !p.Wait {
+		t.Errorf("pool = MaxIdle %d, MaxActive %d, IdleTimeout %v, Wait %v",
+			p.MaxIdle, p.MaxActive, p.IdleTimeout, p.Wait)
+	}
+
+	o, err := newRedisOptions([]RedisOption{
+		WithDialTimeout(time.Second), WithReadTimeout(0), WithWriteTimeout(time.Millisecond),
+	})
+	if err != nil || o.dialTimeout != time.Second || o.readTimeout != 0 || o.writeTimeout != time.Millisecond {
+		t.Errorf("options = %+v, %v", o, err)
+	}
+}
+
+func TestRedisOptionsRejectNegative(t *testing.T) {
+	for name, opt := range map[string]RedisOption{
+		"max idle":      WithMaxIdle(-1),
+		"max active":    WithMaxActive(-1),
+		"idle timeout":  WithIdleTimeout(-time.Second),
+		"dial timeout":  WithDialTimeout(-time.Second),
+		"read timeout":  WithReadTimeout(-time.Second),
+		"write timeout": WithWriteTimeout(-time.Second),
+	} {
+		if _, err := NewRedisCache("localhost:6379", "", 0, time.Hour, opt); err == nil {
+			t.Errorf("a negative %s was accepted", name)
+		}
+		if err := InitRedisCacheWithOptions("localhost:6379", "", 0, time.Hour, opt); err == nil {
+			Close()
+			t.Errorf("InitRedisCacheWithOptions accepted a negative %s", name)
+		}
+	}
+}
+
+func TestRedisReadTimeout(t *testing.T) {
+	c, err := NewRedisCache(stallingServer(t), "", 0, time.Hour, WithReadTimeout(30*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	start := time.Now()
+	if err := c.Get("k", new(int)); err == nil {
+		t.Error("Get from a server that does not reply should fail")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Get waited %v with a 30ms read timeout", d)
+	}
+}
+
+func TestRedisMaxActive(t *testing.T) {
+	m := miniredis.RunT(t)
+	c, err := NewRedisCache(m.Addr(), "", 0, time.Hour, WithMaxActive(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	busy := c.p.Get()
+	// Without WithWait a full pool fails at once.
+	if err := c.Get("k", new(int)); err != redis.ErrPoolExhausted {
+		t.Errorf("Get with the pool exhausted = %v, want redis.ErrPoolExhausted", err)
+	}
+	busy.Close()
+	if err := c.Get("k", new(int)); err != ErrCacheMiss {
+		t.Errorf("Get once a connection is back = %v, want ErrCacheMiss", err)
+	}
+}
+
+// selfSigned returns a certificate for 127.0.0.1 and a pool trusting it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"redis.test"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestRedisTLS(t *testing.T) {
+	cert, roots := selfSigned(t)
+	m, err := miniredis.RunTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	c, err := NewRedisCache(m.Addr(), "", 0, time.Hour, WithTLS(&tls.Config{RootCAs: roots}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k", 1, DefaultExpiryTime)
+	c.Close()
+	if !m.Exists("k") {
+		t.Error("nothing stored over TLS")
+	}
+
+	// SNI and verification use the configured name rather than the host.
+	c, err = NewRedisCache(m.Addr(), "", 0, time.Hour, WithTLS(&tls.Config{RootCAs: roots, ServerName: "redis.test"}))
+	if err != nil {
+		t.Errorf("ServerName: %v", err)
+	} else {
+		c.Close()
+	}
+	c, err = NewRedisCache(m.Addr(), "", 0, time.Hour, WithTLS(&tls.Config{InsecureSkipVerify: true}))
+	if err != nil {
+		t.Errorf("InsecureSkipVerify: %v", err)
+	} else {
+		c.Close()
+	}
+}
+
+func TestRedisTLSFailsAtInit(t *testing.T) {
+	cert, _ := selfSigned(t)
+	m, err := miniredis.RunTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	// The certificate is not trusted.
+	if err := InitRedisCacheWithOptions(m.Addr(), "", 0, time.Hour, WithTLS(nil)); err == nil {
+		Close()
+		t.Error("a failed handshake was not reported by the Init function")
+	}
+
+	// Nor is a server that does not speak TLS.
+	plain := miniredis.RunT(t)
+	if _, err := NewRedisCache(plain.Addr(), "", 0, time.Hour,
+		WithTLS(&tls.Config{InsecureSkipVerify: true}), WithDialTimeout(time.Second), WithReadTimeout(time.Second)); err == nil {
+		t.Error("TLS to a plain server was accepted")
+	}
+}
+
+func TestRedisUnixSocket(t *testing.T) {
+	m := miniredis.RunT(t)
+	// Forward a unix socket to miniredis, which only listens on TCP.
+	sock := filepath.Join(t.TempDir(), "redis.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", m.Addr())
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go func() {
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			go func() {
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+		}
+	}()
+
+	// The host is ignored.
+	c, err := NewRedisCache("unused:6379", "", 0, time.Hour, WithNetwork("unix", sock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Set("k", 1, DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Exists("k") {
+		t.Error("nothing stored through the socket")
+	}
+}
+
+func TestRedisUsername(t *testing.T) {
+	m := miniredis.RunT(t)
+	m.RequireUserAuth("app", "secret")
+
+	c, err := NewRedisCache(m.Addr(), "secret", 0, time.Hour, WithUsername("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Set("k", 1, DefaultExpiryTime); err != nil {
+		t.Errorf("Set as app = %v", err)
+	}
+
+	wrong, _ := NewRedisCache(m.Addr(), "secret", 0, time.Hour, WithUsername("other"))
+	defer wrong.Close()
+	if err := wrong.Set("k", 1, DefaultExpiryTime); err == nil {
+		t.Error("an unknown user was let in")
+	}
+}
@@ -0,0 +1,281 @@
+// Package otel instruments cache operations with OpenTelemetry spans.
+//
+// Wrap a cache and call its context-taking methods; every operation whose
+// context carries a span gets a client child span with the operation name,
+// key count, hit/miss and error status. Operations without a span in the
+// context, or on a wrapper without a tracer, go straight to the cache. The
+// wrapper is itself a cache.CacheCtx, so it can replace the cache it wraps;
+// the methods without a context are not traced.
+package otel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x6666/util/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errNoPipeline is returned by Pipeline when the wrapped cache cannot
+// pipeline.
+var errNoPipeline = errors.New("otel: the wrapped cache does not support pipelining")
+
+// Cache wraps a cache.Cache with tracing. The methods of cache.Cache are
+// those of the wrapped cache, untraced; the context variants are traced.
+type Cache struct {
+	cache.CacheCtx
+
+	c      cache.Cache
+	tracer trace.Tracer
+}
+
+var _ cache.CacheCtx = (*Cache)(nil)
+
+// Wrap returns c instrumented with spans from tracer. The context given to
+// an operation, carrying its span, is passed on to c, see cache.WithContext.
+func Wrap(c cache.Cache, tracer trace.Tracer) *Cache {
+	return &Cache{CacheCtx: cache.WithContext(c), c: c, tracer: tracer}
+}
+
+// Unwrap returns the underlying cache.
+func (c *Cache) Unwrap() cache.Cache {
+	return c.c
+}
+
+// start opens a span for op unless tracing is off for ctx, and returns the
+// context carrying it.
+func (c *Cache) start(ctx context.Context, op string, keys int) (context.Context, trace.Span) {
+	if c.tracer == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, span := c.tracer.Start(ctx, "cache."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cache.operation", op),
+			attribute.Int("cache.key_count", keys),
+		))
+	return ctx, span
+}
+
+func end(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil && err != cache.ErrCacheMiss {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *Cache) GetCtx(ctx context.Context, key string, ptrValue interface{}) error {
+	ctx, span := c.start(ctx, "get", 1)
+	err := c.CacheCtx.GetCtx(ctx, key, ptrValue)
+	if span != nil {
+		span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	}
+	end(span, err)
+	return err
+}
+
+// SetCtx stores value. While the span is recording and the wrapped cache
+// tells its Serializer, as the caches of package cache do, the value is
+// serialized an extra time with it to report its size.
+func (c *Cache) SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	ctx, span := c.start(ctx, "set", 1)
+	if span != nil && span.IsRecording() {
+		if sc, ok := c.c.(interface{ Serializer() cache.Serializer }); ok {
+			if b, err := sc.Serializer().Marshal(value); err == nil {
+				span.SetAttributes(attribute.Int("cache.value_size", len(b)))
+			}
+		}
+	}
+	err := c.CacheCtx.SetCtx(ctx, key, value, expires)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	ctx, span := c.start(ctx, "add", 1)
+	err := c.CacheCtx.AddCtx(ctx, key, value, expires)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	ctx, span := c.start(ctx, "replace", 1)
+	err := c.CacheCtx.ReplaceCtx(ctx, key, value, expires)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (uint64, error) {
+	ctx, span := c.start(ctx, "get", 1)
+	v, err := c.CacheCtx.GetWithVersionCtx(ctx, key, ptrValue)
+	if span != nil {
+		span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	}
+	end(span, err)
+	return v, err
+}
+
+func (c *Cache) CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) error {
+	ctx, span := c.start(ctx, "compare_and_swap", 1)
+	err := c.CacheCtx.CompareAndSwapCtx(ctx, key, value, version, expires)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	ctx, span := c.start(ctx, "exists", 1)
+	found, err := c.CacheCtx.ExistsCtx(ctx, key)
+	if span != nil {
+		span.SetAttributes(attribute.Bool("cache.hit", found))
+	}
+	end(span, err)
+	return found, err
+}
+
+func (c *Cache) ExistsMultiCtx(ctx context.Context, keys ...string) (int, error) {
+	ctx, span := c.start(ctx, "exists_multi", len(keys))
+	n, err := c.CacheCtx.ExistsMultiCtx(ctx, keys...)
+	if span != nil {
+		span.SetAttributes(attribute.Int("cache.hit_count", n))
+	}
+	end(span, err)
+	return n, err
+}
+
+func (c *Cache) TTLCtx(ctx context.Context, key string) (time.Duration, error) {
+	ctx, span := c.start(ctx, "ttl", 1)
+	ttl, err := c.CacheCtx.TTLCtx(ctx, key)
+	end(span, err)
+	return ttl, err
+}
+
+func (c *Cache) ExpireCtx(ctx context.Context, key string, expires time.Duration) error {
+	ctx, span := c.start(ctx, "expire", 1)
+	err := c.CacheCtx.ExpireCtx(ctx, key, expires)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) GetMultiCtx(ctx context.Context, keys []string) (map[string][]byte, error) {
+	ctx, span := c.start(ctx, "get_multi", len(keys))
+	items, err := c.CacheCtx.GetMultiCtx(ctx, keys)
+	if span != nil {
+		size := 0
+		for _, b := range items {
+			size += len(b)
+		}
+		span.SetAttributes(
+			attribute.Int("cache.hit_count", len(items)),
+			attribute.Int("cache.value_size", size),
+		)
+	}
+	end(span, err)
+	return items, err
+}
+
+func (c *Cache) SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) error {
+	ctx, span := c.start(ctx, "set_multi", len(items))
+	err := c.CacheCtx.SetMultiCtx(ctx, items, expires)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) DeleteCtx(ctx context.Context, key string) error {
+	ctx, span := c.start(ctx, "delete", 1)
+	err := c.CacheCtx.DeleteCtx(ctx, key)
+	end(span, err)
+	return err
+}
+
+func (c *Cache) IncrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	ctx, span := c.start(ctx, "increment", 1)
+	v, err := c.CacheCtx.IncrementCtx(ctx, key, n)
+	end(span, err)
+	return v, err
+}
+
+func (c *Cache) DecrementCtx(ctx context.Context, key string, n uint64) (uint64, error) {
+	ctx, span := c.start(ctx, "decrement", 1)
+	v, err := c.CacheCtx.DecrementCtx(ctx, key, n)
+	end(span, err)
+	return v, err
+}
+
+func (c *Cache) ClearAllCtx(ctx context.Context) error {
+	ctx, span := c.start(ctx, "clear_all", 0)
+	err := c.CacheCtx.ClearAllCtx(ctx)
+	end(span, err)
+	return err
+}
+
+// Pipeline runs fn on the wrapped cache without tracing, if it supports
+// pipelining like cache.RedisCache.
+func (c *Cache) Pipeline(fn func(p cache.Pipeliner) error) error {
+	return c.PipelineCtx(context.Background(), fn)
+}
+
+// PipelineCtx runs fn on the wrapped cache in one span, whose key count is
+// the number of queued commands. It returns an error if the wrapped cache cannot pipeline.
+func (c *Cache) PipelineCtx(ctx context.Context, fn func(p cache.Pipeliner) error) error {
+	pc, ok := c.c.(interface {
+		PipelineCtx(ctx context.Context, fn func(p cache.Pipeliner) error) error
+	})
+	if !ok {
+		return errNoPipeline
+	}
+	ctx, span := c.start(ctx, "pipeline", 0)
+	if span == nil {
+		return pc.PipelineCtx(ctx, fn)
+	}
+	cp := &countingPipeliner{}
+	err := pc.PipelineCtx(ctx, func(p cache.Pipeliner) error {
+		cp.Pipeliner = p
+		return fn(cp)
+	})
+	span.SetAttributes(attribute.Int("cache.key_count", cp.n))
+	end(span, err)
+	return err
+}
+
+// countingPipeliner counts the commands queued on a cache.Pipeliner.
+type countingPipeliner struct {
+	cache.Pipeliner
+	n int
+}
+
+func (p *countingPipeliner) Set(key string, value interface{}, expires time.Duration) *cache.PipelineResult {
+	p.n++
+	return p.Pipeliner.Set(key, value, expires)
+}
+
+func (p *countingPipeliner) Get(key string, ptrValue interface{}) *cache.PipelineResult {
+	p.n++
+	return p.Pipeliner.Get(key, ptrValue)
+}
+
+func (p *countingPipeliner) Delete(key string) *cache.PipelineResult {
+	p.n++
+	return p.Pipeliner.Delete(key)
+}
+
+func (p *countingPipeliner) Expire(key string, expires time.Duration) *cache.PipelineResult {
+	p.n++
+	return p.Pipeliner.Expire(key, expires)
+}
+
+func (p *countingPipeliner) Increment(key string, n uint64) *cache.PipelineCounter {
+	p.n++
+	return p.Pipeliner.Increment(key, n)
+}
+
+func (p *countingPipeliner) Decrement(key string, n uint64) *cache.PipelineCounter {
+	p.n++
+	return p.Pipeliner.Decrement(key, n)
+}
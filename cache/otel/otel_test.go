@@ -0,0 +1,323 @@
+package otel
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x6666/util/cache"
+	"github.com/alicebob/miniredis/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// fakeTracer records the spans it starts.
+type fakeTracer struct {
+	embedded.Tracer
+
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	parent := trace.SpanContextFromContext(ctx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &fakeSpan{
+		name:   name,
+		kind:   cfg.SpanKind(),
+		parent: parent,
+		attrs:  make(map[attribute.Key]attribute.Value),
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    parent.TraceID(),
+			SpanID:     trace.SpanID{0, 0, 0, 0, 0, 0, 1, byte(len(t.spans) + 1)},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	for _, kv := range cfg.Attributes() {
+		s.attrs[kv.Key] = kv.Value
+	}
+	t.spans = append(t.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (t *fakeTracer) started() []*fakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*fakeSpan(nil), t.spans...)
+}
+
+type fakeSpan struct {
+	embedded.Span
+
+	name   string
+	kind   trace.SpanKind
+	parent trace.SpanContext
+	sc     trace.SpanContext
+	attrs  map[attribute.Key]attribute.Value
+	code   codes.Code
+	errs   []error
+	ended  bool
+}
+
+func (s *fakeSpan) End(...trace.SpanEndOption)            { s.ended = true }
+func (s *fakeSpan) AddEvent(string, ...trace.EventOption) {}
+func (s *fakeSpan) IsRecording() bool                     { return !s.ended }
+func (s *fakeSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+func (s *fakeSpan) SpanContext() trace.SpanContext       { return s.sc }
+func (s *fakeSpan) SetStatus(code codes.Code, _ string)  { s.code = code }
+func (s *fakeSpan) SetName(name string)                  { s.name = name }
+func (s *fakeSpan) TracerProvider() trace.TracerProvider { return noop.NewTracerProvider() }
+func (s *fakeSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+// parentContext returns a context carrying a sampled remote span, as an
+// incoming request would.
+func parentContext() context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func newTraced(t *testing.T) (*Cache, *fakeTracer) {
+	c := cache.NewMemoryCache(time.Hour)
+	t.Cleanup(func() { c.Close() })
+	tracer := new(fakeTracer)
+	return Wrap(c, tracer), tracer
+}
+
+func TestSpanPerOperation(t *testing.T) {
+	c, tracer := newTraced(t)
+	ctx := parentContext()
+	value := []string{"a", "b"}
+	if err := c.SetCtx(ctx, "k", value, cache.DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	c.GetCtx(ctx, "k", &got)
+	c.GetCtx(ctx, "missing", &got)
+
+	spans := tracer.started()
+	if len(spans) != 3 {
+		t.Fatalf("started %d spans, want 3", len(spans))
+	}
+	size, _ := cache.Serialize(value)
+	want := []struct {
+		name  string
+		attrs map[attribute.Key]attribute.Value
+	}{
+		{"cache.set", map[attribute.Key]attribute.Value{
+			"cache.operation":  attribute.StringValue("set"),
+			"cache.key_count":  attribute.IntValue(1),
+			"cache.value_size": attribute.IntValue(len(size)),
+		}},
+		{"cache.get", map[attribute.Key]attribute.Value{
+			"cache.operation": attribute.StringValue("get"),
+			"cache.hit":       attribute.BoolValue(true),
+		}},
+		{"cache.get", map[attribute.Key]attribute.Value{
+			"cache.hit": attribute.BoolValue(false),
+		}},
+	}
+	for i, s := range spans {
+		if s.name != want[i].name {
+			t.Errorf("span %d is %q, want %q", i, s.name, want[i].name)
+		}
+		for k, v := range want[i].attrs {
+			if s.attrs[k] != v {
+				t.Errorf("span %d: %s = %v, want %v", i, k, s.attrs[k].Emit(), v.Emit())
+			}
+		}
+		if s.kind != trace.SpanKindClient || !s.ended {
+			t.Errorf("span %d: kind %v, ended %v", i, s.kind, s.ended)
+		}
+		if s.parent.SpanID() != (trace.SpanID{2}) || s.sc.TraceID() != (trace.TraceID{1}) {
+			t.Errorf("span %d is not a child of the caller's span", i)
+		}
+		// A miss is an answer, not a failure.
+		if s.code == codes.Error || len(s.errs) != 0 {
+			t.Errorf("span %d has an error status", i)
+		}
+	}
+}
+
+func TestMultiKeyOperationsUseOneSpan(t *testing.T) {
+	c, tracer := newTraced(t)
+	ctx := parentContext()
+	c.SetMultiCtx(ctx, map[string]interface{}{"a": 1, "b": 2}, cache.DefaultExpiryTime)
+	c.GetMultiCtx(ctx, []string{"a", "b", "c"})
+	c.ExistsMultiCtx(ctx, "a", "c", "d", "e")
+
+	spans := tracer.started()
+	if len(spans) != 3 {
+		t.Fatalf("started %d spans, want one per call", len(spans))
+	}
+	checks := []struct {
+		name           string
+		keys, hitCount int64
+	}{
+		{"cache.set_multi", 2, -1},
+		{"cache.get_multi", 3, 2},
+		{"cache.exists_multi", 4, 1},
+	}
+	for i, want := range checks {
+		s := spans[i]
+		if s.name != want.name || s.attrs["cache.key_count"].AsInt64() != want.keys {
+			t.Errorf("span %d = %q with %d keys, want %q with %d", i, s.name, s.attrs["cache.key_count"].AsInt64(), want.name, want.keys)
+		}
+		if want.hitCount >= 0 && s.attrs["cache.hit_count"].AsInt64() != want.hitCount {
+			t.Errorf("span %d hit_count = %d, want %d", i, s.attrs["cache.hit_count"].AsInt64(), want.hitCount)
+		}
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	c, tracer := newTraced(t)
+	ctx := parentContext()
+	c.SetCtx(ctx, "s", "not a number", cache.DefaultExpiryTime)
+	_, err := c.IncrementCtx(ctx, "s", 1)
+	if err == nil {
+		t.Fatal("Increment of a string should fail")
+	}
+
+	s := tracer.started()[1]
+	if s.name != "cache.increment" || s.code != codes.Error || len(s.errs) != 1 || s.errs[0] != err {
+		t.Errorf("span %q: status %v, errors %v, want the error %v", s.name, s.code, s.errs, err)
+	}
+}
+
+func TestNoSpanWithoutParentOrTracer(t *testing.T) {
+	c, tracer := newTraced(t)
+	c.SetCtx(context.Background(), "k", 1, cache.DefaultExpiryTime)
+	var v int
+	if err := c.GetCtx(context.Background(), "k", &v); err != nil || v != 1 {
+		t.Fatalf("untraced Get = %d, %v", v, err)
+	}
+	if n := len(tracer.started()); n != 0 {
+		t.Errorf("started %d spans without a span in the context", n)
+	}
+
+	untraced := Wrap(c.Unwrap(), nil)
+	if err := untraced.GetCtx(parentContext(), "k", &v); err != nil || v != 1 {
+		t.Errorf("Get without a tracer = %d, %v", v, err)
+	}
+}
+
+func TestValueSizeUsesCacheSerializer(t *testing.T) {
+	mem := cache.NewMemoryCacheWithSerializer(time.Hour, cache.JSONSerializer{})
+	defer mem.Close()
+	tracer := new(fakeTracer)
+	c := Wrap(mem, tracer)
+	ctx := parentContext()
+	value := map[string]interface{}{"name": "ann", "tags": []string{"a"}}
+	if err := c.SetCtx(ctx, "k", value, cache.DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	c.GetMultiCtx(ctx, []string{"k"})
+
+	want, _ := cache.JSONSerializer{}.Marshal(value)
+	for _, s := range tracer.started() {
+		if got := s.attrs["cache.value_size"].AsInt64(); got != int64(len(want)) {
+			t.Errorf("%s: value_size = %d, want %d", s.name, got, len(want))
+		}
+	}
+}
+
+func TestCacheInterface(t *testing.T) {
+	c, tracer := newTraced(t)
+	var _ cache.Cache = c
+	if err := cache.Register("otel-test", c); err != nil {
+		t.Fatal(err)
+	}
+
+	// The methods without a context reach the wrapped cache untraced.
+	var v int
+	err := c.Fetch("k", &v, cache.DefaultExpiryTime, func() (interface{}, error) { return 7, nil })
+	if err != nil || v != 7 {
+		t.Errorf("Fetch = %d, %v", v, err)
+	}
+	if err := c.SetWithTags("t", 1, cache.DefaultExpiryTime, "tag"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.InvalidateTag("tag"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v", n, err)
+	}
+	if n, err := c.DeleteByPattern("k*"); err != nil || n != 1 {
+		t.Errorf("DeleteByPattern = %d, %v", n, err)
+	}
+	if n := len(tracer.started()); n != 0 {
+		t.Errorf("started %d spans for untraced methods", n)
+	}
+
+	if err := c.Pipeline(func(cache.Pipeliner) error { return nil }); err != errNoPipeline {
+		t.Errorf("Pipeline on a memory cache = %v", err)
+	}
+}
+
+func TestPipelineSpan(t *testing.T) {
+	m := miniredis.RunT(t)
+	rc, err := cache.NewRedisCache(m.Addr(), "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	tracer := new(fakeTracer)
+	c := Wrap(rc, tracer)
+
+	var got int
+	err = c.PipelineCtx(parentContext(), func(p cache.Pipeliner) error {
+		p.Set("a", 1, cache.DefaultExpiryTime)
+		p.Set("b", 2, cache.DefaultExpiryTime)
+		p.Get("a", &got)
+		return nil
+	})
+	if err != nil || got != 1 {
+		t.Fatalf("Pipeline = %d, %v", got, err)
+	}
+	spans := tracer.started()
+	if len(spans) != 1 || spans[0].name != "cache.pipeline" || spans[0].attrs["cache.key_count"].AsInt64() != 3 {
+		t.Errorf("spans = %v", spans)
+	}
+}
+
+func TestNoSpanAddsNoAllocations(t *testing.T) {
+	mem := cache.NewMemoryCache(time.Hour)
+	defer mem.Close()
+	mem.Set("k", 1, cache.DefaultExpiryTime)
+	plain := cache.WithContext(mem)
+	wrapped := Wrap(mem, new(fakeTracer))
+	ctx := context.Background()
+	var v int
+
+	direct := testing.AllocsPerRun(100, func() { plain.GetCtx(ctx, "k", &v) })
+	traced := testing.AllocsPerRun(100, func() { wrapped.GetCtx(ctx, "k", &v) })
+	if traced != direct {
+		t.Errorf("Get without a span: %v allocations, %v unwrapped", traced, direct)
+	}
+}
+
+func BenchmarkGetNoSpan(b *testing.B) {
+	mem := cache.NewMemoryCache(time.Hour)
+	defer mem.Close()
+	mem.Set("k", 1, cache.DefaultExpiryTime)
+	c := Wrap(mem, new(fakeTracer))
+	ctx := context.Background()
+	var v int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GetCtx(ctx, "k", &v)
+	}
+}
@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Pipeliner queues commands for RedisCache.Pipeline. Each method returns
+// the result of its command, to be read once Pipeline has returned.
+type Pipeliner interface {
+	Set(key string, value interface{}, expires time.Duration) *PipelineResult
+	// Get decodes the value into ptrValue when the pipeline runs.
+	Get(key string, ptrValue interface{}) *PipelineResult
+	Delete(key string) *PipelineResult
+	Expire(key string, expires time.Duration) *PipelineResult
+	Increment(key string, n uint64) *PipelineCounter
+	Decrement(key string, n uint64) *PipelineCounter
+}
+
+// PipelineResult is the outcome of a pipelined command.
+type PipelineResult struct {
+	err error
+}
+
+// Err returns the error of the command, with the same meaning as for the
+// Cache method of the same name, e.g. ErrCacheMiss for a Get of a missing
+// key.
+func (r *PipelineResult) Err() error {
+	return r.err
+}
+
+// PipelineCounter is the outcome of a pipelined Increment or Decrement.
+type PipelineCounter struct {
+	PipelineResult
+	v uint64
+}
+
+// Value returns the new value of the counter.
+func (r *PipelineCounter) Value() uint64 {
+	return r.v
+}
+
+// PipelineError is returned by Pipeline when some commands failed. It maps
+// the index of each of those commands, in the order they were queued, to
+// the reason. ErrCacheMiss is not a failure, it is only reported by the
+// result of the command.
+type PipelineError map[int]error
+
+func (e PipelineError) Error() string {
+	idx := make([]int, 0, len(e))
+	for i := range e {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	msgs := make([]string, len(idx))
+	for i, n := range idx {
+		msgs[i] = fmt.Sprintf("#%d: %v", n, e[n])
+	}
+	return fmt.Sprintf("cache: %d pipelined commands failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// persistScript removes the expiration of KEYS[1] and returns whether it
+// exists, which PERSIST alone does not tell.
+var persistScript = redis.NewScript(1, `
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+redis.call('PERSIST', KEYS[1])
+return 1
+`)
+
+// pipelineCmd is a queued command: send writes it to the connection and
+// recv handles its reply.
+type pipelineCmd struct {
+	name string
+	key  string
+	res  *PipelineResult
+	send func(conn redis.Conn) error
+	recv func(reply interface{}, err error) error
+}
+
+type pipeline struct {
+	c    RedisCache
+	cmds []pipelineCmd
+	// after runs once every reply was read, for the counters the scripts
+	// could not update.
+	after []func(conn redis.Conn)
+}
+
+func (p *pipeline) queue(name, key string, res *PipelineResult, send func(redis.Conn) error, recv func(interface{}, error) error) {
+	p.cmds = append(p.cmds, pipelineCmd{name: name, key: key, res: res, send: send, recv: recv})
+}
+
+// fail records a command that failed before being sent.
+func (p *pipeline) fail(name, key string, res *PipelineResult, err error) {
+	p.queue(name, key, res, nil, func(interface{}, error) error { return err })
+}
+
+// abort sets err as the result of the commands from the i-th on, whose
+// replies will not be read. Those that failed before being sent keep their
+// own error.
+func (p *pipeline) abort(i int, err error) {
+	for _, cmd := range p.cmds[i:] {
+		if cmd.send == nil {
+			cmd.res.err = cmd.recv(nil, nil)
+		} else {
+			cmd.res.err = err
+		}
+	}
+}
+
+func (p *pipeline) Set(key string, value interface{}, expires time.Duration) *PipelineResult {
+	res := new(PipelineResult)
+	b, err := p.c.s.Marshal(value)
+	if err != nil {
+		p.fail("SET", key, res, err)
+		return res
+	}
+	args := p.c.setArgs(key, b, expires, SetOptions{})
+	p.queue("SET", key, res, func(conn redis.Conn) error {
+		return conn.Send("SET", args...)
+	}, func(_ interface{}, err error) error {
+		return err
+	})
+	return res
+}
+
+func (p *pipeline) Get(key string, ptrValue interface{}) *PipelineResult {
+	res := new(PipelineResult)
+	p.queue("GET", key, res, func(conn redis.Conn) error {
+		return conn.Send("GET", key)
+	}, func(reply interface{}, err error) error {
+		if err != nil {
+			return err
+		} else if reply == nil {
+			return ErrCacheMiss
+		}
+		b, err := redis.Bytes(reply, nil)
+		if err != nil {
+			return err
+		}
+		return p.c.s.Unmarshal(b, ptrValue)
+	})
+	return res
+}
+
+func (p *pipeline) Delete(key string) *PipelineResult {
+	res := new(PipelineResult)
+	p.queue("DEL", key, res, func(conn redis.Conn) error {
+		return conn.Send("DEL", key)
+	}, func(reply interface{}, err error) error {
+		existed, err := redis.Bool(reply, err)
+		if err == nil && !existed {
+			err = ErrCacheMiss
+		}
+		return err
+	})
+	return res
+}
+
+func (p *pipeline) Expire(key string, expires time.Duration) *PipelineResult {
+	res := new(PipelineResult)
+	expires = p.c.expiry(expires)
+	p.queue("EXPIRE", key, res, func(conn redis.Conn) error {
+		if expires > 0 {
+			return conn.Send("PEXPIRE", key, int64(expires/time.Millisecond))
+		}
+		return persistScript.Send(conn, key)
+	}, func(reply interface{}, err error) error {
+		ok, err := redis.Bool(reply, err)
+		if err == nil && !ok {
+			err = ErrCacheMiss
+		}
+		return err
+	})
+	return res
+}
+
+func (p *pipeline) Increment(key string, delta uint64) *PipelineCounter {
+	return p.counter("INCR", key, incrScript, delta, func(v uint64) uint64 {
+		return v + delta
+	})
+}
+
+func (p *pipeline) Decrement(key string, delta uint64) *PipelineCounter {
+	return p.counter("DECR", key, decrScript, delta, func(v uint64) uint64 {
+		if delta > v {
+			return 0
+		}
+		return v - delta
+	})
+}
+
+// counter queues script like IncrementCtx and DecrementCtx, falling back to
+// updateWatched with fn after the pipeline for the values it cannot handle.
+func (p *pipeline) counter(name, key string, script *redis.Script, delta uint64, fn func(uint64) uint64) *PipelineCounter {
+	res := new(PipelineCounter)
+	p.queue(name, key, &res.PipelineResult, func(conn redis.Conn) error {
+		return script.Send(conn, key, delta)
+	}, func(reply interface{}, err error) error {
+		v, err := redis.Int64(reply, err)
+		switch err.(type) {
+		case nil:
+			res.v = uint64(v)
+			return nil
+		case redis.Error:
+			p.after = append(p.after, func(conn redis.Conn) {
+				res.v, res.err = updateWatched(conn, key, fn)
+			})
+			return nil
+		}
+		if err == redis.ErrNil {
+			err = ErrCacheMiss
+		}
+		return err
+	})
+	return res
+}
+
+// Pipeline runs the commands queued by fn in a single round trip, in
+// order, and sets their results. Nothing is sent if fn returns an error,
+// which is then returned. The commands are not a transaction: other
+// clients' commands may run in between, and one failing does not stop the
+// following ones.
+//
+// Returns:
+//   - nil if no command failed
+//   - a PipelineError mapping the index of each failed command to its error
+//   - an implementation specific error if the batch could not be sent
+func (c RedisCache) Pipeline(fn func(p Pipeliner) error) error {
+	return c.PipelineCtx(context.Background(), fn)
+}
+
+func (c RedisCache) PipelineCtx(ctx context.Context, fn func(p Pipeliner) error) (err error) {
+	p := &pipeline{c: c}
+	defer func(start time.Time) {
+		c.traceN("PIPELINE", len(p.cmds), start, &err)
+	}(time.Now())
+	if err := fn(p); err != nil {
+		return err
+	}
+	if len(p.cmds) == 0 {
+		return nil
+	}
+
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, cmd := range p.cmds {
+		if cmd.send == nil {
+			continue
+		}
+		if err := cmd.send(conn); err != nil {
+			p.abort(0, err)
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		p.abort(0, err)
+		return err
+	}
+
+	for i, cmd := range p.cmds {
+		var reply interface{}
+		var err error
+		if cmd.send != nil {
+			reply, err = conn.Receive()
+			if _, ok := err.(redis.Error); err != nil && !ok {
+				// the connection is broken, the replies left are lost
+				p.abort(i, err)
+				return err
+			}
+		}
+		cmd.res.err = cmd.recv(reply, err)
+	}
+	for _, f := range p.after {
+		f(conn)
+	}
+
+	failed := make(PipelineError)
+	for i, cmd := range p.cmds {
+		if err := cmd.res.err; err != nil && err != ErrCacheMiss {
+			failed[i] = fmt.Errorf("%s %q: %w", cmd.name, cmd.key, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.Set("n", 10, DefaultExpiryTime)
+	c.Set("text", "x", DefaultExpiryTime)
+
+	var got testUser
+	var set, get, miss, del, expire, persist, bad *PipelineResult
+	var incr, decr, incrText *PipelineCounter
+	err := c.Pipeline(func(p Pipeliner) error {
+		set = p.Set("user", testUser{Name: "ann"}, 1500*time.Millisecond)
+		get = p.Get("user", &got)
+		miss = p.Get("missing", new(int))
+		incr = p.Increment("n", 5)
+		decr = p.Decrement("n", 100)
+		del = p.Delete("missing")
+		expire = p.Expire("text", time.Minute)
+		persist = p.Expire("text", ForEverNeverExpiry)
+		incrText = p.Increment("text", 1)
+		bad = p.Set("bad", make(chan int), DefaultExpiryTime)
+		return nil
+	})
+
+	// The failures are those of the Cache methods; misses are not failures.
+	var failed PipelineError
+	if !errors.As(err, &failed) || len(failed) != 2 || failed[8] == nil || failed[9] == nil {
+		t.Fatalf("Pipeline = %v, want commands 8 and 9 failed", err)
+	}
+	if set.Err() != nil || get.Err() != nil || got.Name != "ann" {
+		t.Errorf("Set, Get = %v, %v, %+v", set.Err(), get.Err(), got)
+	}
+	if miss.Err() != ErrCacheMiss || del.Err() != ErrCacheMiss {
+		t.Errorf("misses = %v, %v", miss.Err(), del.Err())
+	}
+	if incr.Err() != nil || incr.Value() != 15 || decr.Err() != nil || decr.Value() != 0 {
+		t.Errorf("counters = %d, %v and %d, %v", incr.Value(), incr.Err(), decr.Value(), decr.Err())
+	}
+	if expire.Err() != nil || persist.Err() != nil || m.TTL("text") != 0 {
+		t.Errorf("Expire = %v, %v, TTL %v", expire.Err(), persist.Err(), m.TTL("text"))
+	}
+	if m.TTL("user") != 1500*time.Millisecond {
+		t.Errorf("TTL = %v, want 1.5s", m.TTL("user"))
+	}
+	if incrText.Err() == nil || bad.Err() == nil {
+		t.Error("failed commands have no error")
+	}
+}
+
+func TestPipelineNothingSent(t *testing.T) {
+	c, m := newTestRedis(t)
+	errStop := errors.New("stop")
+	before := m.CommandCount()
+	err := c.Pipeline(func(p Pipeliner) error {
+		p.Set("k", 1, DefaultExpiryTime)
+		return errStop
+	})
+	if err != errStop || m.CommandCount() != before {
+		t.Errorf("Pipeline = %v, %d commands sent", err, m.CommandCount()-before)
+	}
+	if err := c.Pipeline(func(Pipeliner) error { return nil }); err != nil {
+		t.Errorf("empty Pipeline = %v", err)
+	}
+}
+
+func TestPipelineConnectionLost(t *testing.T) {
+	// The server answers the handshake and the first SET, then hangs up.
+	c, err := NewRedisCache(fakeServer(t, 3, true), "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var results []*PipelineResult
+	var bad *PipelineResult
+	err = c.Pipeline(func(p Pipeliner) error {
+		for i := 0; i < 3; i++ {
+			results = append(results, p.Set(fmt.Sprint("k", i), i, DefaultExpiryTime))
+		}
+		bad = p.Set("bad", make(chan int), DefaultExpiryTime)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Pipeline succeeded without the replies")
+	}
+	if results[0].Err() != nil {
+		t.Errorf("the answered command failed: %v", results[0].Err())
+	}
+	// The commands whose replies were lost report it rather than success.
+	for i, r := range results[1:] {
+		if r.Err() != err {
+			t.Errorf("command %d = %v, want the connection error %v", i+1, r.Err(), err)
+		}
+	}
+	if bad.Err() == nil || bad.Err() == err {
+		t.Errorf("unsent command = %v, want its own error", bad.Err())
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	c, _ := newTestRedis(b)
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for k := 0; k < 10; k++ {
+				c.Set(fmt.Sprint("k", k), k, DefaultExpiryTime)
+			}
+		}
+	})
+	b.Run("Pipelined", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.Pipeline(func(p Pipeliner) error {
+				for k := 0; k < 10; k++ {
+					p.Set(fmt.Sprint("k", k), k, DefaultExpiryTime)
+				}
+				return nil
+			})
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"context"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -9,25 +12,64 @@ import (
 // RedisCache wraps the Redis client to meet the Cache interface.
 type RedisCache struct {
 	p                 *redis.Pool
+	db                int
 	defaultExpiration time.Duration
+	s                 Serializer
+
+	// asking makes every connection send ASKING first, for following a
+	// cluster ASK redirection.
+	asking bool
+
+	flights *flightGroup
+	*instruments
 }
 
-// NewRedisCache returns a new RedisCache with given parameters
+// NewRedisCache returns a cache using database dbNum of the Redis server at
+// host, for use without or besides the default cache. Options with
+// negative values are rejected.
+func NewRedisCache(host string, password string, dbNum int, defaultExpiration time.Duration, opts ...RedisOption) (RedisCache, error) {
+	o, err := newRedisOptions(opts)
+	if err != nil {
+		return RedisCache{}, err
+	}
+	c := newRedisCache(host, password, dbNum, defaultExpiration, o)
+	if o.tls {
+		if err := c.checkConn(); err != nil {
+			c.p.Close()
+			return RedisCache{}, err
+		}
+	}
+	return c, nil
+}
+
+// newRedisCache returns a new RedisCache with given parameters
 // until redigo supports sharding/clustering, only one host will be in hostList
-func newRedisCache(host string, password string, dbNum int, defaultExpiration time.Duration) RedisCache {
+func newRedisCache(host string, password string, dbNum int, defaultExpiration time.Duration, o *redisOptions) RedisCache {
 	var pool = &redis.Pool{
-		MaxIdle:     5,
-		MaxActive:   0,
-		IdleTimeout: 240 * time.Second,
+		MaxIdle:     o.maxIdle,
+		MaxActive:   o.maxActive,
+		IdleTimeout: o.idleTimeout,
+		Wait:        o.wait,
 		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", host,
-				redis.DialConnectTimeout(time.Millisecond*10000),
-				redis.DialReadTimeout(time.Millisecond*5000),
-				redis.DialWriteTimeout(time.Millisecond*5000))
+			network, address := "tcp", host
+			if o.network != "" {
+				network, address = o.network, o.address
+			}
+			c, err := redis.Dial(network, address,
+				redis.DialConnectTimeout(o.dialTimeout),
+				redis.DialReadTimeout(o.readTimeout),
+				redis.DialWriteTimeout(o.writeTimeout),
+				redis.DialUseTLS(o.tls),
+				redis.DialTLSConfig(o.tlsConfig))
 			if err != nil {
 				return nil, err
 			}
-			if len(password) > 0 {
+			if len(o.username) > 0 {
+				if _, err = c.Do("AUTH", o.username, password); err != nil {
+					_ = c.Close()
+					return nil, err
+				}
+			} else if len(password) > 0 {
 				if _, err = c.Do("AUTH", password); err != nil {
 					_ = c.Close()
 					return nil, err
@@ -53,17 +95,120 @@ func newRedisCache(host string, password string, dbNum int, defaultExpiration ti
 			return err
 		},
 	}
-	return RedisCache{pool, defaultExpiration}
+	return RedisCache{p: pool, db: dbNum, defaultExpiration: defaultExpiration, s: GobSerializer{}, flights: newFlightGroup(), instruments: new(instruments)}
 }
 
-func (c RedisCache) Set(key string, value interface{}, expires time.Duration) error {
+// checkConn opens a connection and returns it to the pool, reporting any
+// error dialing or authenticating.
+func (c RedisCache) checkConn() error {
 	conn := c.p.Get()
 	defer conn.Close()
-	return c.invoke(conn.Do, key, value, expires)
+	return conn.Err()
 }
 
-func (c RedisCache) Get(key string, ptrValue interface{}) error {
-	conn := c.p.Get()
+// conn gets a connection from the pool whose commands give up when ctx is
+// done. Waiting for a free connection is aborted by cancellation, and a
+// deadline bounds the wait for each reply; a command already sent is not
+// interrupted by cancellation without a deadline.
+func (c RedisCache) conn(ctx context.Context) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var conn redis.Conn
+	if ctx.Done() == nil {
+		conn = c.p.Get()
+	} else {
+		var err error
+		if conn, err = c.p.GetContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		}
+		conn = ctxConn{conn, ctx}
+	}
+	if c.asking {
+		if _, err := conn.Do("ASKING"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// ctxConn applies the deadline of ctx to the replies of Do and Receive and
+// reports ctx.Err() instead of the errors caused by ctx being done.
+type ctxConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func (c ctxConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	var reply interface{}
+	var err error
+	if deadline, ok := c.ctx.Deadline(); ok {
+		reply, err = redis.DoWithTimeout(c.Conn, time.Until(deadline), cmd, args...)
+	} else {
+		reply, err = c.Conn.Do(cmd, args...)
+	}
+	return reply, c.err(err)
+}
+
+func (c ctxConn) Receive() (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	var reply interface{}
+	var err error
+	if deadline, ok := c.ctx.Deadline(); ok {
+		reply, err = redis.ReceiveWithTimeout(c.Conn, time.Until(deadline))
+	} else {
+		reply, err = c.Conn.Receive()
+	}
+	return reply, c.err(err)
+}
+
+func (c ctxConn) err(err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// the read deadline may fire just before the context notices
+	if deadline, ok := c.ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func (c RedisCache) Set(key string, value interface{}, expires time.Duration) (err error) {
+	return c.SetCtx(context.Background(), key, value, expires)
+}
+
+func (c RedisCache) SetCtx(ctx context.Context, key string, value interface{}, expires time.Duration) (err error) {
+	defer c.trace("SET", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return c.invoke(conn.Do, key, value, expires, SetOptions{})
+}
+
+func (c RedisCache) Get(key string, ptrValue interface{}) (err error) {
+	return c.GetCtx(context.Background(), key, ptrValue)
+}
+
+func (c RedisCache) GetCtx(ctx context.Context, key string, ptrValue interface{}) (err error) {
+	defer c.trace("GET", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 	raw, err := conn.Do("GET", key)
 	if err != nil {
@@ -75,103 +220,670 @@ func (c RedisCache) Get(key string, ptrValue interface{}) error {
 	if err != nil {
 		return err
 	}
-	return Deserialize(item, ptrValue)
+	return c.s.Unmarshal(item, ptrValue)
 }
 
-func exists(conn redis.Conn, key string) (bool, error) {
-	return redis.Bool(conn.Do("EXISTS", key))
+// getWithTTL is Get also returning the time the value has left to live,
+// read with a PTTL pipelined after the GET so that both take one round
+// trip. The TTL is 0 if the key expired between the two commands.
+func (c RedisCache) getWithTTL(key string, ptrValue interface{}) (ttl time.Duration, err error) {
+	defer c.trace("GET", key, time.Now(), &err)
+	conn, err := c.conn(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.Send("GET", key)
+	conn.Send("PTTL", key)
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+	raw, err := conn.Receive()
+	if err != nil {
+		return 0, err
+	}
+	ms, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return 0, err
+	}
+	if raw == nil {
+		return 0, ErrCacheMiss
+	}
+	item, err := redis.Bytes(raw, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.s.Unmarshal(item, ptrValue); err != nil {
+		return 0, err
+	}
+	switch {
+	case ms == -2:
+		return 0, nil
+	case ms == -1:
+		return ForEverNeverExpiry, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
 }
 
-func (c RedisCache) Delete(key string) error {
-	conn := c.p.Get()
+func (c RedisCache) Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error {
+	return fetch(c, c.s, c.flights, key, ptrValue, expires, loader)
+}
+
+// GetMulti fetches keys with a single MGET.
+func (c RedisCache) GetMulti(keys []string) (items map[string][]byte, err error) {
+	return c.GetMultiCtx(context.Background(), keys)
+}
+
+func (c RedisCache) GetMultiCtx(ctx context.Context, keys []string) (items map[string][]byte, err error) {
+	defer c.traceN("MGET", len(keys), time.Now(), &err)
+	items = make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return items, nil
+	}
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer conn.Close()
-	existed, err := redis.Bool(conn.Do("DEL", key))
-	if err == nil && !existed {
-		err = ErrCacheMiss
+
+	args := make([]interface{}, len(keys))
+	for i, k := range keys {
+		args[i] = k
 	}
-	return err
+	values, err := redis.Values(conn.Do("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			items[keys[i]] = b
+		}
+	}
+	return items, nil
 }
 
-func (c RedisCache) Increment(key string, delta uint64) (uint64, error) {
-	conn := c.p.Get()
+// mgetPipelined sends one MGET per group of keys, pipelined on a single
+// connection, and adds the values found to items. The error replies of the
+// groups whose MGET failed, such as cluster redirections, are returned by
+// group index for the caller to retry them; err is only set when the
+// connection failed.
+func (c RedisCache) mgetPipelined(ctx context.Context, groups [][]string, items map[string][]byte) (failed map[int]error, err error) {
+	n := 0
+	for _, g := range groups {
+		n += len(g)
+	}
+	defer c.traceN("MGET", n, time.Now(), &err)
+	failed = make(map[int]error)
+	// failAll reports err for every group if it is an error reply, such as
+	// one to the commands sent when dialing, so that they are retried.
+	failAll := func(err error) (map[int]error, error) {
+		if _, ok := err.(redis.Error); !ok {
+			return nil, err
+		}
+		for i := range groups {
+			failed[i] = err
+		}
+		return failed, nil
+	}
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return failAll(err)
+	}
+	defer conn.Close()
+
+	for _, g := range groups {
+		conn.Send("MGET", redis.Args{}.AddFlat(g)...)
+	}
+	if err := conn.Flush(); err != nil {
+		return failAll(err)
+	}
+	for i, g := range groups {
+		values, err := redis.Values(conn.Receive())
+		if rerr, ok := err.(redis.Error); ok {
+			failed[i] = rerr
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for j, v := range values {
+			if b, ok := v.([]byte); ok {
+				items[g[j]] = b
+			}
+		}
+	}
+	return failed, nil
+}
+
+// SetMulti stores items with a single MSET when they do not expire, and
+// with pipelined PSETEX commands otherwise. Items that fail to serialize are
+// reported in the SetMultiError and skipped. MSET stores all remaining
+// items or none of them, PSETEX fails per key.
+func (c RedisCache) SetMulti(items map[string]interface{}, expires time.Duration) (err error) {
+	return c.SetMultiCtx(context.Background(), items, expires)
+}
+
+func (c RedisCache) SetMultiCtx(ctx context.Context, items map[string]interface{}, expires time.Duration) (err error) {
+	defer c.traceN("MSET", len(items), time.Now(), &err)
+
+	expires = c.expiry(expires)
+
+	failed := make(SetMultiError)
+	keys := make([]string, 0, len(items))
+	values := make([][]byte, 0, len(items))
+	for k, v := range items {
+		b, err := c.s.Marshal(v)
+		if err != nil {
+			failed[k] = err
+			continue
+		}
+		keys = append(keys, k)
+		values = append(values, b)
+	}
+	if len(keys) == 0 {
+		return failed.errOrNil()
+	}
+
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
-	// Check for existance *before* increment as per the cache contract.
-	// redis will auto create the key, and we don't want that. Since we need to do increment
-	// ourselves instead of natively via INCRBY (redis doesn't support wrapping), we get the value
-	// and do the exists check this way to minimize calls to Redis
-	val, err := conn.Do("GET", key)
+
+	if expires <= 0 {
+		args := make([]interface{}, 0, 2*len(keys))
+		for i, k := range keys {
+			args = append(args, k, values[i])
+		}
+		if _, err := conn.Do("MSET", args...); err != nil {
+			if len(failed) == 0 {
+				return err
+			}
+			for _, k := range keys {
+				failed[k] = err
+			}
+		}
+		return failed.errOrNil()
+	}
+
+	for i, k := range keys {
+		if err := conn.Send("PSETEX", k, int64(expires/time.Millisecond), values[i]); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := conn.Receive(); err != nil {
+			failed[k] = err
+		}
+	}
+	return failed.errOrNil()
+}
+
+// casScript sets KEYS[1] to ARGV[2], with a TTL of ARGV[3] milliseconds if
+// positive, if the hex version of its value is ARGV[1]; see valueVersion.
+var casScript = redis.NewScript(1, `
+local cur = redis.call('GET', KEYS[1])
+local v = '0000000000000000'
+if cur then
+	v = string.sub(redis.sha1hex(cur), 1, 16)
+	if v == '0000000000000000' then v = '0000000000000001' end
+end
+if v ~= ARGV[1] then return 0 end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func (c RedisCache) GetWithVersion(key string, ptrValue interface{}) (version uint64, err error) {
+	return c.GetWithVersionCtx(context.Background(), key, ptrValue)
+}
+
+func (c RedisCache) GetWithVersionCtx(ctx context.Context, key string, ptrValue interface{}) (version uint64, err error) {
+	defer c.trace("GET", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
 	if err != nil {
 		return 0, err
-	} else if val == nil {
+	}
+	defer conn.Close()
+	item, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
 		return 0, ErrCacheMiss
+	} else if err != nil {
+		return 0, err
 	}
-	currentVal, err := redis.Int64(val, nil)
+	return valueVersion(item), c.s.Unmarshal(item, ptrValue)
+}
+
+// CompareAndSwap compares and sets the value atomically in a Lua script.
+func (c RedisCache) CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) (err error) {
+	return c.CompareAndSwapCtx(context.Background(), key, value, version, expires)
+}
+
+func (c RedisCache) CompareAndSwapCtx(ctx context.Context, key string, value interface{}, version uint64, expires time.Duration) (err error) {
+	defer c.trace("CAS", key, time.Now(), &err)
+
+	expires = c.expiry(expires)
+
+	b, err := c.s.Marshal(value)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	sum := currentVal + int64(delta)
-	_, err = conn.Do("SET", key, sum)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	swapped, err := redis.Bool(casScript.Do(conn, key, fmt.Sprintf("%016x", version), b, int64(expires/time.Millisecond)))
+	if err == nil && !swapped {
+		err = ErrCASConflict
+	}
+	return err
+}
+
+func (c RedisCache) TTL(key string) (ttl time.Duration, err error) {
+	return c.TTLCtx(context.Background(), key)
+}
+
+func (c RedisCache) TTLCtx(ctx context.Context, key string) (ttl time.Duration, err error) {
+	defer c.trace("PTTL", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
 	if err != nil {
 		return 0, err
 	}
-	return uint64(sum), nil
+	defer conn.Close()
+	ms, err := redis.Int64(conn.Do("PTTL", key))
+	switch {
+	case err != nil:
+		return 0, err
+	case ms == -2:
+		return 0, ErrCacheMiss
+	case ms == -1:
+		return ForEverNeverExpiry, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
 }
 
-func (c RedisCache) Decrement(key string, delta uint64) (newValue uint64, err error) {
-	conn := c.p.Get()
+// Expire maps to PEXPIRE, or PERSIST when the key must not expire.
+func (c RedisCache) Expire(key string, expires time.Duration) (err error) {
+	return c.ExpireCtx(context.Background(), key, expires)
+}
+
+func (c RedisCache) ExpireCtx(ctx context.Context, key string, expires time.Duration) (err error) {
+	defer c.trace("EXPIRE", key, time.Now(), &err)
+
+	expires = c.expiry(expires)
+
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
-	// Check for existance *before* increment as per the cache contract.
-	// redis will auto create the key, and we don't want that, hence the exists call
+
+	if expires > 0 {
+		ok, err := redis.Bool(conn.Do("PEXPIRE", key, int64(expires/time.Millisecond)))
+		if err == nil && !ok {
+			err = ErrCacheMiss
+		}
+		return err
+	}
+	// PERSIST also returns 0 for keys without an expiration
+	if ok, err := redis.Bool(conn.Do("PERSIST", key)); err != nil || ok {
+		return err
+	}
 	existed, err := exists(conn, key)
+	if err == nil && !existed {
+		err = ErrCacheMiss
+	}
+	return err
+}
+
+func exists(conn redis.Conn, key string) (bool, error) {
+	return redis.Bool(conn.Do("EXISTS", key))
+}
+
+func (c RedisCache) Exists(key string) (found bool, err error) {
+	return c.ExistsCtx(context.Background(), key)
+}
+
+func (c RedisCache) ExistsCtx(ctx context.Context, key string) (found bool, err error) {
+	defer c.trace("EXISTS", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return exists(conn, key)
+}
+
+// ExistsMulti counts keys with a single EXISTS.
+func (c RedisCache) ExistsMulti(keys ...string) (n int, err error) {
+	return c.ExistsMultiCtx(context.Background(), keys...)
+}
+
+func (c RedisCache) ExistsMultiCtx(ctx context.Context, keys ...string) (n int, err error) {
+	defer c.traceN("EXISTS", len(keys), time.Now(), &err)
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	conn, err := c.conn(ctx)
 	if err != nil {
 		return 0, err
-	} else if !existed {
-		return 0, ErrCacheMiss
 	}
-	// Decrement contract says you can only go to 0
-	// so we go fetch the value and if the delta is greater than the amount,
-	// 0 out the value
-	currentVal, err := redis.Int64(conn.Do("GET", key))
+	defer conn.Close()
+	return redis.Int(conn.Do("EXISTS", redis.Args{}.AddFlat(keys)...))
+}
+
+func (c RedisCache) Delete(key string) (err error) {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+func (c RedisCache) DeleteCtx(ctx context.Context, key string) (err error) {
+	defer c.trace("DEL", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	existed, err := redis.Bool(conn.Do("DEL", key))
+	if err == nil && !existed {
+		err = ErrCacheMiss
+	}
+	return err
+}
+
+func (c RedisCache) Increment(key string, delta uint64) (newValue uint64, err error) {
+	return c.IncrementCtx(context.Background(), key, delta)
+}
+
+func (c RedisCache) IncrementCtx(ctx context.Context, key string, delta uint64) (newValue uint64, err error) {
+	defer c.trace("INCR", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
 	if err != nil {
 		return 0, err
 	}
-	if delta > uint64(currentVal) {
-		var tempint int64
-		tempint, err = redis.Int64(conn.Do("DECRBY", key, currentVal))
-		return uint64(tempint), err
+	defer conn.Close()
+	// Check for existance *before* increment as per the cache contract:
+	// redis would auto create the key. Values and deltas INCRBY cannot
+	// handle, beyond the int64 range, are wrapped around in Go.
+	v, err := redis.Int64(incrScript.Do(conn, key, delta))
+	switch err.(type) {
+	case nil:
+		return uint64(v), nil
+	case redis.Error:
+		return updateWatched(conn, key, func(v uint64) uint64 {
+			return v + delta
+		})
+	}
+	if err == redis.ErrNil {
+		err = ErrCacheMiss
 	}
-	tempint, err := redis.Int64(conn.Do("DECRBY", key, delta))
-	return uint64(tempint), err
+	return 0, err
 }
 
-func (c RedisCache) ClearAll() error {
-	conn := c.p.Get()
+func (c RedisCache) IncrementWithTTL(key string, delta uint64, ttl time.Duration) (newValue uint64, err error) {
+	return c.IncrementWithTTLCtx(context.Background(), key, delta, ttl)
+}
+
+func (c RedisCache) IncrementWithTTLCtx(ctx context.Context, key string, delta uint64, ttl time.Duration) (newValue uint64, err error) {
+	defer c.trace("INCR", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
 	defer conn.Close()
-	_, err := conn.Do( /*"FLUSHALL"*/ "FLUSHDB")
+	v, err := redis.Int64(incrTTLScript.Do(conn, key, delta, int64(c.expiry(ttl)/time.Millisecond)))
+	if _, ok := err.(redis.Error); ok {
+		// beyond the int64 range, the key exists since INCRBY would create
+		// it otherwise
+		return updateWatched(conn, key, func(v uint64) uint64 {
+			return v + delta
+		})
+	}
+	return uint64(v), err
+}
+
+func (c RedisCache) Decrement(key string, delta uint64) (newValue uint64, err error) {
+	return c.DecrementCtx(context.Background(), key, delta)
+}
+
+func (c RedisCache) DecrementCtx(ctx context.Context, key string, delta uint64) (newValue uint64, err error) {
+	defer c.trace("DECR", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	// Decrement contract says you can only go to 0, the script clamps the
+	// value atomically
+	v, err := redis.Int64(decrScript.Do(conn, key, delta))
+	switch err.(type) {
+	case nil:
+		return uint64(v), nil
+	case redis.Error:
+		return updateWatched(conn, key, func(v uint64) uint64 {
+			if delta > v {
+				return 0
+			}
+			return v - delta
+		})
+	}
+	if err == redis.ErrNil {
+		err = ErrCacheMiss
+	}
+	return 0, err
+}
+
+// incrScript adds ARGV[1] to KEYS[1] if it exists, and returns nil
+// otherwise. The new value is read back with GET, since numbers lose
+// precision beyond 2^53 in Lua.
+var incrScript = redis.NewScript(1, `
+if redis.call('EXISTS', KEYS[1]) == 0 then return false end
+redis.call('INCRBY', KEYS[1], ARGV[1])
+return redis.call('GET', KEYS[1])
+`)
+
+// incrTTLScript adds ARGV[1] to KEYS[1], creating it with an expiration of
+// ARGV[2] milliseconds, if positive, when it does not exist.
+var incrTTLScript = redis.NewScript(1, `
+local created = redis.call('EXISTS', KEYS[1]) == 0
+redis.call('INCRBY', KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return redis.call('GET', KEYS[1])
+`)
+
+// decrScript subtracts ARGV[1] from KEYS[1] if it exists, stopping at 0,
+// and returns nil otherwise. The TTL is kept.
+var decrScript = redis.NewScript(1, `
+if redis.call('EXISTS', KEYS[1]) == 0 then return false end
+if redis.call('DECRBY', KEYS[1], ARGV[1]) < 0 then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl > 0 then
+		redis.call('SET', KEYS[1], 0, 'PX', ttl)
+	else
+		redis.call('SET', KEYS[1], 0)
+	end
+end
+return redis.call('GET', KEYS[1])
+`)
+
+// maxWatchRetries bounds how often updateWatched retries after the key was
+// changed concurrently.
+const maxWatchRetries = 16
+
+// updateWatched applies fn to the counter at key in a WATCH/MULTI/EXEC
+// transaction, keeping its TTL. It handles the counters the scripts cannot:
+// values outside of the int64 range.
+func updateWatched(conn redis.Conn, key string, fn func(uint64) uint64) (uint64, error) {
+	for i := 0; i < maxWatchRetries; i++ {
+		if _, err := conn.Do("WATCH", key); err != nil {
+			return 0, err
+		}
+		raw, err := redis.String(conn.Do("GET", key))
+		if err == redis.ErrNil {
+			conn.Do("UNWATCH")
+			return 0, ErrCacheMiss
+		} else if err != nil {
+			conn.Do("UNWATCH")
+			return 0, err
+		}
+		cur, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			i, ierr := strconv.ParseInt(raw, 10, 64)
+			if ierr != nil {
+				conn.Do("UNWATCH")
+				return 0, ErrInvalidValue
+			}
+			cur = uint64(i)
+		}
+		ttl, err := redis.Int64(conn.Do("PTTL", key))
+		if err != nil {
+			conn.Do("UNWATCH")
+			return 0, err
+		}
+
+		v := fn(cur)
+		conn.Send("MULTI")
+		if ttl > 0 {
+			conn.Send("SET", key, v, "PX", ttl)
+		} else {
+			conn.Send("SET", key, v)
+		}
+		reply, err := conn.Do("EXEC")
+		if err != nil {
+			return 0, err
+		}
+		if reply != nil {
+			return v, nil
+		}
+	}
+	return 0, ErrCASConflict
+}
+
+func (c RedisCache) ClearAll() (err error) {
+	return c.ClearAllCtx(context.Background())
+}
+
+func (c RedisCache) ClearAllCtx(ctx context.Context) (err error) {
+	defer c.trace("FLUSHDB", "", time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Do( /*"FLUSHALL"*/ "FLUSHDB")
 	return err
 }
 
-func (c RedisCache) invoke(f func(string, ...interface{}) (interface{}, error),
-	key string, value interface{}, expires time.Duration) error {
+// Close closes the connection pool.
+func (c RedisCache) Close() error {
+	return c.p.Close()
+}
 
-	switch expires {
-	case DefaultExpiryTime:
-		expires = c.defaultExpiration
-	case ForEverNeverExpiry:
-		expires = time.Duration(0)
+// Serializer returns the serializer the cache stores values with.
+func (c RedisCache) Serializer() Serializer {
+	return c.s
+}
+
+// Pool returns the connection pool of the cache, so other components such
+// as a TieredCache invalidation channel can share it.
+func (c RedisCache) Pool() *redis.Pool {
+	return c.p
+}
+
+func (c RedisCache) Add(key string, value interface{}, expires time.Duration) (err error) {
+	return c.AddCtx(context.Background(), key, value, expires)
+}
+
+func (c RedisCache) AddCtx(ctx context.Context, key string, value interface{}, expires time.Duration) (err error) {
+	defer c.trace("ADD", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+	return c.invoke(conn.Do, key, value, expires, SetOptions{NX: true})
+}
+
+func (c RedisCache) Replace(key string, value interface{}, expires time.Duration) (err error) {
+	return c.ReplaceCtx(context.Background(), key, value, expires)
+}
 
-	b, err := Serialize(value)
+func (c RedisCache) ReplaceCtx(ctx context.Context, key string, value interface{}, expires time.Duration) (err error) {
+	defer c.trace("REPLACE", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
 	if err != nil {
 		return err
 	}
-	conn := c.p.Get()
 	defer conn.Close()
+	return c.invoke(conn.Do, key, value, expires, SetOptions{XX: true})
+}
 
-	if expires > 0 {
-		_, err = f("SETEX", key, int32(expires/time.Second), b)
+func (c RedisCache) SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) (err error) {
+	return c.SetOptCtx(context.Background(), key, value, expires, opts)
+}
+
+func (c RedisCache) SetOptCtx(ctx context.Context, key string, value interface{}, expires time.Duration, opts SetOptions) (err error) {
+	defer c.trace("SET", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
 		return err
 	}
-	_, err = f("SET", key, b)
+	defer conn.Close()
+	return c.invoke(conn.Do, key, value, expires, opts)
+}
+
+// expiry resolves DefaultExpiryTime and ForEverNeverExpiry, the latter to 0.
+func (c RedisCache) expiry(expires time.Duration) time.Duration {
+	switch expires {
+	case DefaultExpiryTime:
+		return c.defaultExpiration
+	case ForEverNeverExpiry:
+		return 0
+	}
+	return expires
+}
+
+// invoke stores value under key with SET, built by setArgs. ErrNotStored
+// is returned when the NX or XX condition of opts does not hold.
+func (c RedisCache) invoke(f func(string, ...interface{}) (interface{}, error),
+	key string, value interface{}, expires time.Duration, opts SetOptions) error {
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	b, err := c.s.Marshal(value)
+	if err != nil {
+		return err
+	}
+	reply, err := f("SET", c.setArgs(key, b, expires, opts)...)
+	if err == nil && reply == nil {
+		err = ErrNotStored
+	}
 	return err
 }
+
+// setArgs returns the arguments of SET storing b under key as specified by
+// expires and opts.
+func (c RedisCache) setArgs(key string, b []byte, expires time.Duration, opts SetOptions) []interface{} {
+	args := []interface{}{key, b}
+	if opts.KeepTTL {
+		args = append(args, "KEEPTTL")
+	} else if expires = c.expiry(expires); expires > 0 {
+		args = append(args, "PX", int64(expires/time.Millisecond))
+	}
+	switch {
+	case opts.NX:
+		args = append(args, "NX")
+	case opts.XX:
+		args = append(args, "XX")
+	}
+	return args
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis returns a RedisCache backed by an in-process miniredis
+// server, closed at the end of the test.
+func newTestRedis(t testing.TB) (RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	c, err := NewRedisCache(m.Addr(), "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, m
+}
+
+// redisBackends returns the Redis servers to run a conformance test on:
+// miniredis always, and the real server at $REDIS_ADDR if it is set. The
+// real server's database 15 is flushed before the test.
+func redisBackends(t *testing.T) map[string]RedisCache {
+	t.Helper()
+	mini, _ := newTestRedis(t)
+	backends := map[string]RedisCache{"miniredis": mini}
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		return backends
+	}
+	c, err := NewRedisCache(addr, os.Getenv("REDIS_PASSWORD"), 15, time.Hour)
+	if err != nil {
+		t.Fatalf("REDIS_ADDR=%s: %v", addr, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.ClearAll(); err != nil {
+		t.Fatalf("REDIS_ADDR=%s: %v", addr, err)
+	}
+	backends["redis"] = c
+	return backends
+}
+
+func TestRedisSetMultiSubSecond(t *testing.T) {
+	c, m := newTestRedis(t)
+	items := map[string]interface{}{"a": 1, "b": 2}
+	if err := c.SetMulti(items, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL("a"); ttl != 1500*time.Millisecond {
+		t.Errorf("TTL = %v, want 1.5s", ttl)
+	}
+	m.FastForward(1499 * time.Millisecond)
+	if n := len(mustGetMulti(t, c, "a", "b")); n != 2 {
+		t.Errorf("%d items left before the TTL ran out, want 2", n)
+	}
+	m.FastForward(time.Millisecond)
+	if n := len(mustGetMulti(t, c, "a", "b")); n != 0 {
+		t.Errorf("%d items left after the TTL ran out, want 0", n)
+	}
+
+	// Less than a second is kept, not rounded down to an invalid 0.
+	if err := c.SetMulti(items, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL("b"); ttl != 300*time.Millisecond {
+		t.Errorf("TTL = %v, want 300ms", ttl)
+	}
+
+	// Items that never expire go out with MSET.
+	c.SetMulti(items, ForEverNeverExpiry)
+	if m.TTL("a") != 0 {
+		t.Errorf("TTL = %v, want none", m.TTL("a"))
+	}
+}
+
+func mustGetMulti(t *testing.T, c Cache, keys ...string) map[string][]byte {
+	t.Helper()
+	items, err := c.GetMulti(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestRedisAddReplaceExpiry(t *testing.T) {
+	c, m := newTestRedis(t)
+	if err := c.Add("k", 1, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL("k"); ttl != 1500*time.Millisecond {
+		t.Errorf("TTL after Add = %v, want 1.5s", ttl)
+	}
+	if err := c.Replace("k", 2, 250*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL("k"); ttl != 250*time.Millisecond {
+		t.Errorf("TTL after Replace = %v, want 250ms", ttl)
+	}
+	m.FastForward(250 * time.Millisecond)
+	if err := c.Replace("k", 3, DefaultExpiryTime); err != ErrNotStored {
+		t.Errorf("Replace after expiry = %v, want ErrNotStored", err)
+	}
+	// An idempotency token can be taken again once it expired.
+	if err := c.Add("k", 4, DefaultExpiryTime); err != nil {
+		t.Error(err)
+	}
+	if ttl := m.TTL("k"); ttl != time.Hour {
+		t.Errorf("TTL with DefaultExpiryTime = %v, want the default hour", ttl)
+	}
+	if err := c.Replace("k", 5, ForEverNeverExpiry); err != nil {
+		t.Error(err)
+	}
+	if ttl := m.TTL("k"); ttl != 0 {
+		t.Errorf("TTL with ForEverNeverExpiry = %v, want none", ttl)
+	}
+}
+
+func TestAddReplace(t *testing.T) {
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if err := Replace("token", "x", DefaultExpiryTime); err != ErrNotStored {
+		t.Errorf("Replace(missing) = %v, want ErrNotStored", err)
+	}
+	if err := Add("token", "x", DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add("token", "y", DefaultExpiryTime); err != ErrNotStored {
+		t.Errorf("Add(existing) = %v, want ErrNotStored", err)
+	}
+	if err := Replace("token", "z", DefaultExpiryTime); err != nil {
+		t.Error(err)
+	}
+	var s string
+	if Get("token", &s); s != "z" {
+		t.Errorf("token = %q, want z", s)
+	}
+}
+
+func TestRedisExpireRunsOut(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.Set("k", 1, ForEverNeverExpiry)
+	if err := c.Expire("k", 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	m.FastForward(1499 * time.Millisecond)
+	if ttl, err := c.TTL("k"); err != nil || ttl != time.Millisecond {
+		t.Errorf("TTL = %v, %v, want 1ms", ttl, err)
+	}
+	m.FastForward(time.Millisecond)
+	if _, err := c.TTL("k"); err != ErrCacheMiss {
+		t.Errorf("TTL after expiry = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestRedisExistsConnectionError(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.Set("k", 1, DefaultExpiryTime)
+	m.Close()
+	if found, err := c.Exists("k"); err == nil || found {
+		t.Errorf("Exists with the server down = %v, %v, want an error", found, err)
+	}
+	if n, err := c.ExistsMulti("k"); err == nil || n != 0 {
+		t.Errorf("ExistsMulti with the server down = %d, %v, want an error", n, err)
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// redisSetBatch bounds the number of members sent per SADD command and
+// requested per SSCAN iteration.
+const redisSetBatch = 1000
+
+// RedisStrSet is a string set stored as a Redis set, shared by every process
+// using the same key. Each method is a network round trip.
+type RedisStrSet struct {
+	p   *redis.Pool
+	key string
+	ttl time.Duration
+}
+
+// NewRedisStrSet returns a set stored under key, using connections from p.
+// Pass NamespaceKey(c, key) as key to keep the set under the namespace of
+// a cache c.
+func NewRedisStrSet(p *redis.Pool, key string) *RedisStrSet {
+	return &RedisStrSet{p: p, key: key}
+}
+
+// StrSet returns a set stored under key that shares the cache's connection
+// pool.
+func (c RedisCache) StrSet(key string) *RedisStrSet {
+	return NewRedisStrSet(c.p, key)
+}
+
+// SetTTL makes every subsequent Add or AddAll refresh the expiry of the
+// whole set to d. A d <= 0 disables it, so the set never expires.
+func (s *RedisStrSet) SetTTL(d time.Duration) {
+	s.ttl = d
+}
+
+// Add inserts member and reports whether it was newly added.
+func (s *RedisStrSet) Add(member string) (bool, error) {
+	n, err := s.AddAll(member)
+	return n > 0, err
+}
+
+// AddAll inserts the members with pipelined SADD commands and returns how
+// many were newly added.
+func (s *RedisStrSet) AddAll(members ...string) (int, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	conn := s.p.Get()
+	defer conn.Close()
+
+	sent := 0
+	for start := 0; start < len(members); start += redisSetBatch {
+		end := start + redisSetBatch
+		if end > len(members) {
+			end = len(members)
+		}
+		args := redis.Args{s.key}.AddFlat(members[start:end])
+		if err := conn.Send("SADD", args...); err != nil {
+			return 0, err
+		}
+		sent++
+	}
+	if s.ttl > 0 {
+		if err := conn.Send("PEXPIRE", s.key, int64(s.ttl/time.Millisecond)); err != nil {
+			return 0, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := 0; i < sent; i++ {
+		n, err := redis.Int(conn.Receive())
+		if err != nil {
+			return added, err
+		}
+		added += n
+	}
+	if s.ttl > 0 {
+		if _, err := conn.Receive(); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+func (s *RedisStrSet) Has(member string) (bool, error) {
+	conn := s.p.Get()
+	defer conn.Close()
+	return redis.Bool(conn.Do("SISMEMBER", s.key, member))
+}
+
+// Remove deletes member, returning ErrCacheMiss if it was not present.
+func (s *RedisStrSet) Remove(member string) error {
+	conn := s.p.Get()
+	defer conn.Close()
+	removed, err := redis.Bool(conn.Do("SREM", s.key, member))
+	if err == nil && !removed {
+		err = ErrCacheMiss
+	}
+	return err
+}
+
+// Count returns the number of members; a missing key counts as empty.
+func (s *RedisStrSet) Count() (int, error) {
+	conn := s.p.Get()
+	defer conn.Close()
+	return redis.Int(conn.Do("SCARD", s.key))
+}
+
+// ToSlice returns the members in unspecified order. It iterates with SSCAN
+// rather than SMEMBERS so a large set does not block Redis.
+func (s *RedisStrSet) ToSlice() ([]string, error) {
+	conn := s.p.Get()
+	defer conn.Close()
+
+	var members []string
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SSCAN", s.key, cursor, "COUNT", redisSetBatch))
+		if err != nil {
+			return nil, err
+		}
+		if cursor, err = redis.Int(values[0], nil); err != nil {
+			return nil, err
+		}
+		batch, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, batch...)
+		if cursor == 0 {
+			return members, nil
+		}
+	}
+}
+
+// Delete removes the whole set, returning ErrCacheMiss if it did not exist.
+func (s *RedisStrSet) Delete() error {
+	conn := s.p.Get()
+	defer conn.Close()
+	existed, err := redis.Bool(conn.Do("DEL", s.key))
+	if err == nil && !existed {
+		err = ErrCacheMiss
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRedisStrSetConformance(t *testing.T) {
+	for name, c := range redisBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			s := c.StrSet("processed")
+			if n, err := s.Count(); err != nil || n != 0 {
+				t.Fatalf("Count of a missing set = %d, %v", n, err)
+			}
+			if members, err := s.ToSlice(); err != nil || len(members) != 0 {
+				t.Fatalf("ToSlice of a missing set = %v, %v", members, err)
+			}
+			if ok, err := s.Has("a"); err != nil || ok {
+				t.Fatalf("Has on a missing set = %v, %v", ok, err)
+			}
+
+			if added, err := s.Add("a"); err != nil || !added {
+				t.Fatalf("Add(a) = %v, %v", added, err)
+			}
+			if added, err := s.Add("a"); err != nil || added {
+				t.Fatalf("second Add(a) = %v, %v", added, err)
+			}
+			if ok, err := s.Has("a"); err != nil || !ok {
+				t.Fatalf("Has(a) = %v, %v", ok, err)
+			}
+
+			if err := s.Remove("a"); err != nil {
+				t.Fatalf("Remove(a): %v", err)
+			}
+			if err := s.Remove("a"); err != ErrCacheMiss {
+				t.Fatalf("Remove of a non member: %v, want ErrCacheMiss", err)
+			}
+
+			if err := s.Delete(); err != ErrCacheMiss {
+				t.Fatalf("Delete of an empty set: %v, want ErrCacheMiss", err)
+			}
+			s.Add("x")
+			if err := s.Delete(); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if n, _ := s.Count(); n != 0 {
+				t.Fatalf("Count after Delete = %d", n)
+			}
+		})
+	}
+}
+
+func TestRedisStrSetAddAllAndScan(t *testing.T) {
+	for name, c := range redisBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			s := NewRedisStrSet(c.Pool(), "big")
+			if n, err := s.AddAll(); err != nil || n != 0 {
+				t.Fatalf("AddAll() = %d, %v", n, err)
+			}
+
+			// More than two batches, with duplicates across batches.
+			members := make([]string, 2*redisSetBatch+500)
+			for i := range members {
+				members[i] = fmt.Sprintf("m%d", i%(2*redisSetBatch+100))
+			}
+			n, err := s.AddAll(members...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 2*redisSetBatch + 100; n != want {
+				t.Errorf("AddAll added %d, want %d", n, want)
+			}
+			if n, err := s.AddAll("m0", "new"); err != nil || n != 1 {
+				t.Errorf("AddAll of one new member = %d, %v", n, err)
+			}
+
+			got, err := s.ToSlice()
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, _ := s.Count()
+			if len(got) != count || count != 2*redisSetBatch+101 {
+				t.Errorf("ToSlice returned %d members, Count %d", len(got), count)
+			}
+			sort.Strings(got)
+			for i := 1; i < len(got); i++ {
+				if got[i] == got[i-1] {
+					t.Fatalf("ToSlice returned %q twice", got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRedisStrSetTTL(t *testing.T) {
+	c, m := newTestRedis(t)
+	s := c.StrSet("seen")
+	s.Add("a")
+	if ttl := m.TTL("seen"); ttl != 0 {
+		t.Fatalf("set without TTL expires in %v", ttl)
+	}
+
+	s.SetTTL(time.Minute)
+	s.AddAll("b", "c")
+	if ttl := m.TTL("seen"); ttl != time.Minute {
+		t.Fatalf("TTL after AddAll = %v, want 1m", ttl)
+	}
+	m.FastForward(30 * time.Second)
+	s.Add("a") // refreshes the expiry even when nothing is added
+	if ttl := m.TTL("seen"); ttl != time.Minute {
+		t.Fatalf("TTL after Add = %v, want 1m", ttl)
+	}
+	m.FastForward(2 * time.Minute)
+	if n, err := s.Count(); err != nil || n != 0 {
+		t.Fatalf("Count of an expired set = %d, %v", n, err)
+	}
+
+	s.SetTTL(0)
+	s.Add("d")
+	if ttl := m.TTL("seen"); ttl != 0 {
+		t.Fatalf("TTL after SetTTL(0) = %v", ttl)
+	}
+}
+
+func TestRedisStrSetNamespace(t *testing.T) {
+	c, m := newTestRedis(t)
+	ns := WithNamespace(c, "jobs:")
+	s := NewRedisStrSet(c.Pool(), NamespaceKey(ns, "processed"))
+	s.Add("42")
+	if !m.Exists("jobs:processed") {
+		t.Errorf("keys = %v, want the set under the namespace", m.Keys())
+	}
+	if got := NamespaceKey(c, "processed"); got != "processed" {
+		t.Errorf("NamespaceKey without a namespace = %q", got)
+	}
+}
+
+func TestRedisStrSetErrors(t *testing.T) {
+	c, m := newTestRedis(t)
+	m.Set("str", "not a set")
+	s := c.StrSet("str")
+	if _, err := s.Add("a"); err == nil {
+		t.Error("Add to a string key should fail")
+	}
+	if _, err := s.ToSlice(); err == nil {
+		t.Error("ToSlice of a string key should fail")
+	}
+
+	m.Close()
+	if _, err := c.StrSet("x").Has("a"); err == nil {
+		t.Error("Has should fail when Redis is down")
+	}
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// DefaultInstance is the name of the cache used by the package level
+// functions and set up by the Init functions.
+const DefaultInstance = "default"
+
+var (
+	instancesMu sync.RWMutex
+	instances   = make(map[string]Cache)
+	// closeHooks holds the functions to call when an instance is closed,
+	// by name and registration id.
+	closeHooks = make(map[string]map[uint64]func())
+	nextHookID uint64
+)
+
+// Register makes c available as Instance(name). It returns ErrInited if the
+// name is taken. Registering DefaultInstance is equivalent to an Init
+// function.
+func Register(name string, c Cache) error {
+	if c == nil {
+		return errors.New("cache: registering a nil cache")
+	}
+	return register(name, func() (Cache, error) {
+		return c, nil
+	})
+}
+
+// Instance returns the cache registered under name, or ErrNotInited.
+func Instance(name string) (Cache, error) {
+	instancesMu.RLock()
+	defer instancesMu.RUnlock()
+	c, ok := instances[name]
+	if !ok {
+		return nil, ErrNotInited
+	}
+	return c, nil
+}
+
+// CloseInstance closes the cache registered under name and removes it, so
+// the name can be registered again. Other instances are not affected.
+// Closing a name without a cache does nothing.
+func CloseInstance(name string) error {
+	instancesMu.Lock()
+	c, ok := instances[name]
+	delete(instances, name)
+	hooks := closeHooks[name]
+	delete(closeHooks, name)
+	instancesMu.Unlock()
+	for _, f := range hooks {
+		f()
+	}
+	if !ok {
+		return nil
+	}
+	return c.Close()
+}
+
+// onClose arranges for f to be called when the instance name is closed by
+// CloseInstance, before the cache itself is closed. The returned function
+// cancels it.
+func onClose(name string, f func()) (cancel func()) {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+	nextHookID++
+	id := nextHookID
+	if closeHooks[name] == nil {
+		closeHooks[name] = make(map[uint64]func())
+	}
+	closeHooks[name][id] = f
+	return func() {
+		instancesMu.Lock()
+		defer instancesMu.Unlock()
+		delete(closeHooks[name], id)
+	}
+}
+
+// register registers the cache returned by create under name, unless the
+// name is taken.
+func register(name string, create func() (Cache, error)) error {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+	if _, ok := instances[name]; ok {
+		return ErrInited
+	}
+	c, err := create()
+	if err != nil {
+		return err
+	}
+	instances[name] = c
+	return nil
+}
+
+// initCache makes the cache returned by create the default cache, unless
+// there already is one.
+func initCache(create func() (Cache, error)) error {
+	return register(DefaultInstance, create)
+}
+
+// std returns the default cache, or one failing with ErrNotInited if there
+// is none.
+func std() Cache {
+	c, err := Instance(DefaultInstance)
+	if err != nil {
+		return notInited{}
+	}
+	return c
+}
+
+// Default returns the default cache, for functions taking a Cache such as
+// GetAs. Until an Init function is called, its operations return
+// ErrNotInited.
+func Default() Cache {
+	return std()
+}
+
+// Close closes the default cache and removes it, so that a cache can be
+// initialized again. Until then the package level functions return
+// ErrNotInited. Closing without a default cache does nothing.
+func Close() error {
+	return CloseInstance(DefaultInstance)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRegistry(t *testing.T) {
+	m := miniredis.RunT(t)
+	sessions, _ := NewRedisCache(m.Addr(), "", 0, 30*time.Minute)
+	rates, _ := NewRedisCache(m.Addr(), "", 2, time.Second)
+	if err := Register("sessions", sessions); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseInstance("sessions")
+	if err := Register("rates", rates); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseInstance("rates")
+
+	if err := Register("sessions", rates); err != ErrInited {
+		t.Errorf("Register of a taken name = %v, want ErrInited", err)
+	}
+	if err := Register("nil", nil); err == nil {
+		t.Error("a nil cache was registered")
+	}
+	if _, err := Instance("unknown"); err != ErrNotInited {
+		t.Errorf("Instance(unknown) = %v, want ErrNotInited", err)
+	}
+
+	s, err := Instance("sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("k", "session", DefaultExpiryTime)
+	r, _ := Instance("rates")
+	r.Set("k", 1, DefaultExpiryTime)
+	// Each instance has its own database and default expiration.
+	if got, _ := m.DB(0).Get("k"); got == "1" {
+		t.Error("the instances share a database")
+	}
+	if ttl := m.DB(2).TTL("k"); ttl != time.Second {
+		t.Errorf("TTL in rates = %v, want 1s", ttl)
+	}
+
+	// Closing one instance leaves the others and the default alone.
+	if err := CloseInstance("sessions"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Instance("sessions"); err != ErrNotInited {
+		t.Errorf("Instance after CloseInstance = %v", err)
+	}
+	if _, err := r.Increment("k", 1); err != nil {
+		t.Errorf("rates after closing sessions = %v", err)
+	}
+	if err := Get("k", new(int)); err != ErrNotInited {
+		t.Errorf("default cache = %v, want ErrNotInited", err)
+	}
+	// The name can be taken again.
+	if err := Register("sessions", NewMemoryCache(time.Hour)); err != nil {
+		t.Errorf("Register after CloseInstance = %v", err)
+	}
+	if err := CloseInstance("never registered"); err != nil {
+		t.Errorf("CloseInstance(unknown) = %v", err)
+	}
+}
+
+func TestRegisterDefault(t *testing.T) {
+	mem := NewMemoryCache(time.Hour)
+	if err := Register(DefaultInstance, mem); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if err := InitInMemoryCache(time.Hour); err != ErrInited {
+		t.Errorf("Init after registering the default = %v, want ErrInited", err)
+	}
+	Set("k", 1, DefaultExpiryTime)
+	if found, _ := mem.Exists("k"); !found {
+		t.Error("the package level functions do not use the registered default")
+	}
+}
+
+func TestRegisterConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprint("cache", i%5)
+			if err := Register(name, NewMemoryCache(time.Hour)); err != nil && err != ErrInited {
+				t.Error(err)
+			}
+			if _, err := Instance(name); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 5; i++ {
+		CloseInstance(fmt.Sprint("cache", i))
+	}
+}
+
+func TestOnClose(t *testing.T) {
+	Register("hooked", NewMemoryCache(time.Hour))
+	var called, canceled bool
+	onClose("hooked", func() { called = true })
+	cancel := onClose("hooked", func() { canceled = true })
+	cancel()
+	CloseInstance("hooked")
+	if !called || canceled {
+		t.Errorf("hooks called: %v, canceled one called: %v", called, canceled)
+	}
+}
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0x6666/util/log"
+	"github.com/0x6666/util/set"
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	replicatedMinBackoff = time.Second
+	replicatedMaxBackoff = 30 * time.Second
+)
+
+// ReplicatedStrSet is a string set kept in memory on every instance and
+// replicated through Redis. Membership is persisted in a Redis set, and
+// every Add or Remove is published on a channel so the other instances
+// apply it to their local copy. Whenever the subscription is
+// (re)established the local copy is resynchronized from the Redis set.
+//
+// Reads (Has, Count, ToSlice) only touch the local copy and never the
+// network. Conflicting writes are resolved last-write-wins: each write
+// updates the Redis set and publishes its event in one MULTI/EXEC
+// transaction, and every instance applies the events in the order Redis
+// executed them, so all copies end up agreeing with the Redis set. A write
+// that fails leaves the local copy unchanged.
+type ReplicatedStrSet struct {
+	local   *set.SyncStrSet
+	remote  *RedisStrSet
+	pool    *redis.Pool
+	channel string
+
+	// applyMu serializes the changes to the local copy, so that a write
+	// applies its own change before any event published after it.
+	applyMu sync.Mutex
+
+	mu        sync.Mutex
+	psc       *redis.PubSubConn
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReplicatedStrSet starts replicating the set called name. Its members
+// are stored in the Redis set NamespaceKey(c, name), using connections
+// from pool, and its events are published on that key followed by
+// ":events". c only provides the namespace. Call Close to stop.
+func NewReplicatedStrSet(name string, c Cache, pool *redis.Pool) *ReplicatedStrSet {
+	key := NamespaceKey(c, name)
+	s := &ReplicatedStrSet{
+		local:   set.NewSyncStrSet(),
+		remote:  NewRedisStrSet(pool, key),
+		pool:    pool,
+		channel: key + ":events",
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *ReplicatedStrSet) Has(member string) bool {
+	return s.local.Has(member)
+}
+
+func (s *ReplicatedStrSet) Count() int {
+	return s.local.Count()
+}
+
+func (s *ReplicatedStrSet) ToSlice() []string {
+	return s.local.ToSlice()
+}
+
+// Add persists member and announces it to the other instances, then
+// inserts it locally.
+func (s *ReplicatedStrSet) Add(member string) error {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	if err := s.write("SADD", '+', member); err != nil {
+		return err
+	}
+	s.local.Add(member)
+	return nil
+}
+
+// Remove persists the removal of member and announces it to the other
+// instances, then deletes it locally.
+func (s *ReplicatedStrSet) Remove(member string) error {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	if err := s.write("SREM", '-', member); err != nil {
+		return err
+	}
+	s.local.Remove(member)
+	return nil
+}
+
+func (s *ReplicatedStrSet) write(cmd string, op byte, member string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	if err := conn.Send(cmd, s.remote.key, member); err != nil {
+		return err
+	}
+	if err := conn.Send("PUBLISH", s.channel, string(op)+member); err != nil {
+		return err
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+	for _, r := range replies {
+		if err, ok := r.(redis.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the replication. The local copy stays readable.
+func (s *ReplicatedStrSet) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		close(s.quit)
+		if s.psc != nil {
+			s.psc.Unsubscribe()
+		}
+		s.mu.Unlock()
+	})
+	<-s.done
+	return nil
+}
+
+func (s *ReplicatedStrSet) run() {
+	defer close(s.done)
+
+	backoff := replicatedMinBackoff
+	for {
+		err := s.subscribe()
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+		if err == nil {
+			backoff = replicatedMinBackoff
+			continue
+		}
+
+		log.Warn("cache: replicating set %q: %v, retrying in %v", s.remote.key, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-s.quit:
+			return
+		}
+		if backoff *= 2; backoff > replicatedMaxBackoff {
+			backoff = replicatedMaxBackoff
+		}
+	}
+}
+
+// subscribe listens for events until the connection fails or Close
+// unsubscribes.
+func (s *ReplicatedStrSet) subscribe() error {
+	s.mu.Lock()
+	select {
+	case <-s.quit:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	psc := &redis.PubSubConn{Conn: s.pool.Get()}
+	s.psc = psc
+	err := psc.Subscribe(s.channel)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.psc = nil
+		psc.Close()
+	}()
+	if err != nil {
+		return err
+	}
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Subscription:
+			switch {
+			case v.Kind == "subscribe":
+				if err := s.resync(); err != nil {
+					return err
+				}
+			case v.Kind == "unsubscribe" && v.Count == 0:
+				// by Close
+				return nil
+			}
+		case redis.Message:
+			s.apply(string(v.Data))
+		case error:
+			return v
+		}
+	}
+}
+
+// resync replaces the local copy with the members stored in Redis.
+func (s *ReplicatedStrSet) resync() error {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	members, err := s.remote.ToSlice()
+	if err != nil {
+		return err
+	}
+	s.local.Do(func(local set.StrSet) {
+		local.Clear()
+		local.AddAll(members...)
+	})
+	return nil
+}
+
+func (s *ReplicatedStrSet) apply(event string) {
+	if len(event) == 0 {
+		return
+	}
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	switch member := event[1:]; event[0] {
+	case '+':
+		s.local.Add(member)
+	case '-':
+		s.local.Remove(member)
+	}
+}
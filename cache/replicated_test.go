@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// eventually fails the test unless cond becomes true within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// subscribed waits until n subscribers listen to the channel of s on m.
+func subscribed(t *testing.T, s *ReplicatedStrSet, m *miniredis.Miniredis, n int) {
+	t.Helper()
+	eventually(t, "the subscriptions", func() bool {
+		return m.PubSubNumSub(s.channel)[s.channel] >= n
+	})
+}
+
+func TestReplicatedStrSetTwoReplicas(t *testing.T) {
+	c, m := newTestRedis(t)
+	ns := WithNamespace(c, "app:")
+	m.SAdd("app:banned", "old")
+
+	a := NewReplicatedStrSet("banned", ns, c.Pool())
+	defer a.Close()
+	b := NewReplicatedStrSet("banned", ns, c.Pool())
+	defer b.Close()
+	subscribed(t, a, m, 2)
+	if a.channel != "app:banned:events" {
+		t.Errorf("channel = %q", a.channel)
+	}
+
+	// Both resynchronized from Redis on subscribing.
+	eventually(t, "the resync", func() bool { return a.Has("old") && b.Has("old") })
+
+	if err := a.Add("tok1"); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Has("tok1") {
+		t.Error("Add should be visible locally once it returns")
+	}
+	eventually(t, "tok1 on b", func() bool { return b.Has("tok1") })
+	if ok, _ := m.SIsMember("app:banned", "tok1"); !ok {
+		t.Error("tok1 not persisted under the namespace")
+	}
+
+	if err := b.Remove("old"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the removal on a", func() bool { return !a.Has("old") })
+	if a.Count() != 1 || b.Count() != 1 {
+		t.Errorf("counts = %d and %d, want 1", a.Count(), b.Count())
+	}
+}
+
+func TestReplicatedStrSetLastWriteWins(t *testing.T) {
+	c, m := newTestRedis(t)
+	a := NewReplicatedStrSet("s", c, c.Pool())
+	defer a.Close()
+	b := NewReplicatedStrSet("s", c, c.Pool())
+	defer b.Close()
+	subscribed(t, a, m, 2)
+
+	// Conflicting writes from both replicas at once: whatever order Redis
+	// ran them in, both copies end up agreeing with the Redis set.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			a.Add("x")
+			a.Remove("y")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		b.Remove("x")
+		b.Add("y")
+	}
+	<-done
+	a.Add("z")
+	b.Add("z")
+
+	members, _ := m.Members("s")
+	want := make(map[string]bool)
+	for _, member := range members {
+		want[member] = true
+	}
+	eventually(t, "the replicas to converge", func() bool {
+		for _, r := range []*ReplicatedStrSet{a, b} {
+			if r.Count() != len(want) {
+				return false
+			}
+			for _, member := range r.ToSlice() {
+				if !want[member] {
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
+
+func TestReplicatedStrSetWriteErrors(t *testing.T) {
+	c, m := newTestRedis(t)
+	s := NewReplicatedStrSet("s", c, c.Pool())
+	defer s.Close()
+	subscribed(t, s, m, 1)
+
+	// A command failing inside the transaction is reported.
+	m.Set("s", "not a set")
+	if err := s.Add("a"); err == nil {
+		t.Error("Add to a string key should fail")
+	}
+	if s.Has("a") {
+		t.Error("a failed Add changed the local copy")
+	}
+	m.Del("s")
+	if err := s.Add("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// So is an unreachable server.
+	m.Close()
+	if err := s.Remove("b"); err == nil {
+		t.Error("Remove should fail when Redis is down")
+	}
+	if !s.Has("b") {
+		t.Error("a failed Remove changed the local copy")
+	}
+}
+
+func TestReplicatedStrSetResyncOnReconnect(t *testing.T) {
+	c, m := newTestRedis(t)
+	s := NewReplicatedStrSet("s", c, c.Pool())
+	defer s.Close()
+	subscribed(t, s, m, 1)
+	s.Add("a")
+
+	// Changes made while disconnected are picked up on reconnecting.
+	m.Close()
+	eventually(t, "the subscription to drop", func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.psc == nil
+	})
+	m.SRem("s", "a")
+	m.SAdd("s", "b")
+	if err := m.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the resync", func() bool { return s.Has("b") && !s.Has("a") })
+}
+
+func TestReplicatedStrSetClose(t *testing.T) {
+	c, m := newTestRedis(t)
+	s := NewReplicatedStrSet("s", c, c.Pool())
+	subscribed(t, s, m, 1)
+	s.Add("a")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if !s.Has("a") {
+		t.Error("the local copy should stay readable after Close")
+	}
+	eventually(t, "the unsubscription", func() bool {
+		return m.PubSubNumSub(s.channel)[s.channel] == 0
+	})
+}
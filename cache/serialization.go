@@ -12,7 +12,8 @@ import (
 // Serialize transforms the given value into bytes following these rules:
 //   - If value is a byte array, it is returned as-is.
 //   - If value is an int or uint type, it is returned as the ASCII representation
-//   - Else, encoding/gob is used to serialize
+//   - Else, encoding/gob is used to serialize; gob has no encoding for nil,
+//     so a nil pointer yields ErrInvalidValue
 func Serialize(value interface{}) ([]byte, error) {
 	if data, ok := value.([]byte); ok {
 		return data, nil
@@ -23,6 +24,10 @@ func Serialize(value interface{}) ([]byte, error) {
 		return []byte(strconv.FormatInt(v.Int(), 10)), nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return []byte(strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, ErrInvalidValue
+		}
 	}
 
 	var b bytes.Buffer
@@ -58,7 +63,7 @@ func Deserialize(byt []byte, ptr interface{}) (err error) {
 			var i uint64
 			i, err = strconv.ParseUint(string(byt), 10, 64)
 			if err != nil {
-				log.Error("Deserialize: failed to parse uint value: %v, error: ", string(byt), err)
+				log.Error("Deserialize: failed to parse uint value: %v, error: %v", string(byt), err)
 			} else {
 				p.SetUint(i)
 			}
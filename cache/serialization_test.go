@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0x6666/util/set"
+)
+
+func TestStrSetThroughCache(t *testing.T) {
+	for name, s := range map[string]Serializer{
+		"gob":     GobSerializer{},
+		"json":    JSONSerializer{},
+		"msgpack": MsgpackSerializer{},
+	} {
+		c := NewMemoryCacheWithSerializer(time.Hour, s)
+		in := set.NewStrSet("b", "a", "日本")
+		if err := c.Set("recent_ids", in, DefaultExpiryTime); err != nil {
+			t.Fatalf("%s: Set: %v", name, err)
+		}
+		var out set.StrSet
+		if err := c.Get("recent_ids", &out); err != nil {
+			t.Fatalf("%s: Get: %v", name, err)
+		}
+		if !out.Equal(in) {
+			t.Errorf("%s: got %v, want %v", name, out, in)
+		}
+		c.Close()
+	}
+}
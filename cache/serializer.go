@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Serializer converts values to and from the bytes stored in the cache.
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, ptr interface{}) error
+}
+
+// GobSerializer is the default Serializer. It uses Serialize and
+// Deserialize: []byte values and integers are stored raw, anything else
+// with encoding/gob.
+type GobSerializer struct{}
+
+func (GobSerializer) Marshal(v interface{}) ([]byte, error) {
+	return Serialize(v)
+}
+
+func (GobSerializer) Unmarshal(data []byte, ptr interface{}) error {
+	return Deserialize(data, ptr)
+}
+
+// JSONSerializer stores values as JSON, so they can be read by non-Go
+// consumers. Strings, []byte values and integers are stored raw, so
+// counters stay usable with INCR and strings are not quoted.
+type JSONSerializer struct{}
+
+func (JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	if b, ok := marshalRaw(v); ok {
+		return b, nil
+	}
+	return json.Marshal(v)
+}
+
+func (JSONSerializer) Unmarshal(data []byte, ptr interface{}) error {
+	if ok, err := unmarshalRaw(data, ptr); ok {
+		return err
+	}
+	return json.Unmarshal(data, ptr)
+}
+
+// MsgpackSerializer stores values as MessagePack. Like JSONSerializer it
+// stores strings, []byte values and integers raw.
+type MsgpackSerializer struct{}
+
+func (MsgpackSerializer) Marshal(v interface{}) ([]byte, error) {
+	if b, ok := marshalRaw(v); ok {
+		return b, nil
+	}
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackSerializer) Unmarshal(data []byte, ptr interface{}) error {
+	if ok, err := unmarshalRaw(data, ptr); ok {
+		return err
+	}
+	return msgpack.Unmarshal(data, ptr)
+}
+
+// marshalRaw encodes strings, byte slices and integers as their plain
+// bytes and reports whether v was one of those.
+func marshalRaw(v interface{}) ([]byte, bool) {
+	if b, ok := v.([]byte); ok {
+		return b, true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.String:
+		return []byte(rv.String()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(nil, rv.Uint(), 10), true
+	}
+	return nil, false
+}
+
+// unmarshalRaw decodes data written by marshalRaw if ptr points to a
+// string, byte slice or integer, and reports whether it did.
+func unmarshalRaw(data []byte, ptr interface{}) (bool, error) {
+	if b, ok := ptr.(*[]byte); ok {
+		*b = data
+		return true, nil
+	}
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false, nil
+	}
+	switch p := v.Elem(); p.Kind() {
+	case reflect.String:
+		p.SetString(string(data))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(string(data), 10, p.Type().Bits())
+		if err != nil {
+			return true, err
+		}
+		p.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(string(data), 10, p.Type().Bits())
+		if err != nil {
+			return true, err
+		}
+		p.SetUint(i)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
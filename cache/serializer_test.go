@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var serializers = map[string]Serializer{
+	"gob":     GobSerializer{},
+	"json":    JSONSerializer{},
+	"msgpack": MsgpackSerializer{},
+}
+
+func TestSerializersRoundTrip(t *testing.T) {
+	u := testUser{Name: "ann", Age: 42, Roles: []string{"admin", "ops"}}
+	values := []struct {
+		name string
+		in   interface{}
+		// out makes a pointer to decode into.
+		out func() interface{}
+	}{
+		{"struct", u, func() interface{} { return new(testUser) }},
+		{"pointer", &u, func() interface{} { return new(testUser) }},
+		{"map", map[string]int{"a": 1}, func() interface{} { return new(map[string]int) }},
+		{"slice", []string{"x", "y"}, func() interface{} { return new([]string) }},
+		{"string", "héllo", func() interface{} { return new(string) }},
+		{"empty string", "", func() interface{} { return new(string) }},
+		{"bytes", []byte{0, 1, 0xff}, func() interface{} { return new([]byte) }},
+		{"int", -42, func() interface{} { return new(int) }},
+		{"int8", int8(-8), func() interface{} { return new(int8) }},
+		{"uint64", uint64(1 << 63), func() interface{} { return new(uint64) }},
+		{"bool", true, func() interface{} { return new(bool) }},
+		{"float", 2.5, func() interface{} { return new(float64) }},
+	}
+	for name, s := range serializers {
+		for _, v := range values {
+			b, err := s.Marshal(v.in)
+			if err != nil {
+				t.Errorf("%s: Marshal(%s): %v", name, v.name, err)
+				continue
+			}
+			out := v.out()
+			if err := s.Unmarshal(b, out); err != nil {
+				t.Errorf("%s: Unmarshal(%s): %v", name, v.name, err)
+				continue
+			}
+			want := v.in
+			if rv := reflect.ValueOf(want); rv.Kind() == reflect.Ptr {
+				want = rv.Elem().Interface()
+			}
+			if got := reflect.ValueOf(out).Elem().Interface(); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s round-tripped to %#v, want %#v", name, v.name, got, want)
+			}
+		}
+	}
+}
+
+func TestSerializersRawPrimitives(t *testing.T) {
+	for name, s := range serializers {
+		for in, want := range map[interface{}]string{42: "42", int64(-7): "-7", uint16(9): "9"} {
+			if b, err := s.Marshal(in); err != nil || string(b) != want {
+				t.Errorf("%s: Marshal(%v) = %q, %v, want %q", name, in, b, err, want)
+			}
+		}
+		if b, _ := s.Marshal([]byte("raw")); string(b) != "raw" {
+			t.Errorf("%s: []byte stored as %q", name, b)
+		}
+	}
+	// Only the cross-language serializers store strings raw; gob keeps
+	// encoding them as before, so existing keys stay readable.
+	for _, name := range []string{"json", "msgpack"} {
+		if b, _ := serializers[name].Marshal("plain"); string(b) != "plain" {
+			t.Errorf("%s: string stored as %q", name, b)
+		}
+	}
+}
+
+func TestSerializersNil(t *testing.T) {
+	for _, name := range []string{"json", "msgpack"} {
+		s := serializers[name]
+		b, err := s.Marshal((*testUser)(nil))
+		if err != nil {
+			t.Fatalf("%s: Marshal(nil pointer): %v", name, err)
+		}
+		out := &testUser{Name: "stale"}
+		if err := s.Unmarshal(b, &out); err != nil || out != nil {
+			t.Errorf("%s: nil round-tripped to %v, %v", name, out, err)
+		}
+		if _, err := s.Marshal(nil); err != nil {
+			t.Errorf("%s: Marshal(nil): %v", name, err)
+		}
+	}
+	// gob has no encoding for nil: storing one fails instead of writing
+	// something that cannot be read back.
+	if _, err := (GobSerializer{}).Marshal(nil); err == nil {
+		t.Error("gob: Marshal(nil) should fail")
+	}
+	if _, err := (GobSerializer{}).Marshal((*testUser)(nil)); err != ErrInvalidValue {
+		t.Errorf("gob: Marshal(nil pointer) = %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestSerializerRawCountersAndInterop(t *testing.T) {
+	m := miniredis.RunT(t)
+	if err := InitRedisCacheWithSerializer(m.Addr(), "", 0, time.Hour, JSONSerializer{}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	// Non-Go readers see plain JSON and plain numbers.
+	if err := Set("user", testUser{Name: "ann", Age: 42}, DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get("user"); got != `{"Name":"ann","Age":42,"Roles":null}` {
+		t.Errorf("stored %s", got)
+	}
+	Set("hits", 41, DefaultExpiryTime)
+	if v, err := Increment("hits", 1); err != nil || v != 42 {
+		t.Errorf("Increment of a JSON counter = %d, %v", v, err)
+	}
+	if got, _ := m.Get("hits"); got != "42" {
+		t.Errorf("counter stored as %q", got)
+	}
+
+	// Values written by other clients are read back.
+	m.Set("external", `{"Name":"bob","Roles":["dev"]}`)
+	var u testUser
+	if err := Get("external", &u); err != nil || u.Name != "bob" || len(u.Roles) != 1 {
+		t.Errorf("Get = %+v, %v", u, err)
+	}
+}
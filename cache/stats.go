@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+// StartStatsReporter logs a summary line of the default cache through
+// logger every interval: the hits, misses, hit ratio and errors since the
+// previous report, the calls of each operation, and the Redis pool usage.
+// Intervals without any operation are not reported. A nil logger uses the
+// default logger.
+//
+// Reporting stops when the returned function is called, which is safe to
+// do more than once, or when the default cache is closed with Close.
+func StartStatsReporter(interval time.Duration, logger *log.Logger) (stop func()) {
+	if logger == nil {
+		logger = log.StdLogger()
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(quit)
+			<-done
+		})
+	}
+	cancel := onClose(DefaultInstance, stop)
+
+	last := std().Stats()
+	go func() {
+		defer close(done)
+		defer cancel()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				cur := std().Stats()
+				reportStats(logger, cur, last)
+				last = cur
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// statsDelta returns cur - last, or cur if the counter was reset meanwhile.
+func statsDelta(cur, last uint64) uint64 {
+	if cur < last {
+		return cur
+	}
+	return cur - last
+}
+
+func reportStats(logger *log.Logger, cur, last CacheStats) {
+	ops := make([]string, 0, len(cur.Ops))
+	for op, o := range cur.Ops {
+		if n := statsDelta(o.Calls, last.Ops[op].Calls); n > 0 {
+			ops = append(ops, fmt.Sprintf("%s:%d", op, n))
+		}
+	}
+	if len(ops) == 0 {
+		return
+	}
+	sort.Strings(ops)
+
+	hits, misses := statsDelta(cur.Hits, last.Hits), statsDelta(cur.Misses, last.Misses)
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+
+	var active, idle int
+	if p := RedisPool(); p != nil {
+		ps := p.Stats()
+		active, idle = ps.ActiveCount, ps.IdleCount
+	}
+	logger.Info("cache stats: hits=%d misses=%d hit_ratio=%.3f errors=%d ops=%s pool_active=%d pool_idle=%d",
+		hits, misses, ratio, statsDelta(cur.Errors, last.Errors), strings.Join(ops, ","), active, idle)
+}
+
+// CacheStats is a snapshot of the operations of one cache since it was
+// created or its stats were last reset.
+type CacheStats struct {
+	// Hits and Misses count the Gets that found their key and those that
+	// did not.
+	Hits   uint64
+	Misses uint64
+	// Sets and Deletes count successful writes and deletions.
+	Sets    uint64
+	Deletes uint64
+	// Errors counts the operations that failed with an error other than
+	// ErrCacheMiss.
+	Errors uint64
+	// Ops holds the calls and cumulative latency of each operation by
+	// name, e.g. "GET" or "MSET".
+	Ops map[string]OpStats
+}
+
+// OpStats is the usage of one cache operation.
+type OpStats struct {
+	Calls   uint64
+	Latency time.Duration
+}
+
+// Hook is notified of every finished cache operation, e.g. to feed
+// Prometheus metrics. key is empty for operations on several keys. It is
+// called synchronously, so it must be fast and safe for concurrent use.
+type Hook interface {
+	OnOperation(op string, key string, d time.Duration, err error)
+}
+
+// isWrite and isDelete tell which operations Sets and Deletes count.
+func isWrite(op string) bool {
+	switch op {
+	case "SET", "ADD", "REPLACE", "CAS", "MSET":
+		return true
+	}
+	return false
+}
+
+func isDelete(op string) bool {
+	return op == "DEL"
+}
+
+// instruments holds the stats and hook of a cache. It is embedded in the
+// cache implementations, which thus get Stats, ResetStats and SetHook, and
+// is updated only atomically so that it adds no contention to operations.
+type instruments struct {
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	sets    atomic.Uint64
+	deletes atomic.Uint64
+	errors  atomic.Uint64
+	ops     sync.Map // op name -> *opInstruments
+
+	hook atomic.Pointer[Hook]
+}
+
+type opInstruments struct {
+	calls atomic.Uint64
+	nanos atomic.Int64
+}
+
+func (in *instruments) record(op string, key string, d time.Duration, err error) {
+	switch {
+	case err == ErrCacheMiss:
+		if op == "GET" {
+			in.misses.Add(1)
+		}
+	case err != nil:
+		in.errors.Add(1)
+	case op == "GET":
+		in.hits.Add(1)
+	case isWrite(op):
+		in.sets.Add(1)
+	case isDelete(op):
+		in.deletes.Add(1)
+	}
+
+	o, ok := in.ops.Load(op)
+	if !ok {
+		o, _ = in.ops.LoadOrStore(op, new(opInstruments))
+	}
+	o.(*opInstruments).calls.Add(1)
+	o.(*opInstruments).nanos.Add(int64(d))
+
+	if h := in.hook.Load(); h != nil {
+		(*h).OnOperation(op, key, d, err)
+	}
+}
+
+// Stats returns the counters of the cache. ClearAll does not reset them.
+func (in *instruments) Stats() CacheStats {
+	s := CacheStats{
+		Hits:    in.hits.Load(),
+		Misses:  in.misses.Load(),
+		Sets:    in.sets.Load(),
+		Deletes: in.deletes.Load(),
+		Errors:  in.errors.Load(),
+		Ops:     make(map[string]OpStats),
+	}
+	in.ops.Range(func(op, o any) bool {
+		s.Ops[op.(string)] = OpStats{
+			Calls:   o.(*opInstruments).calls.Load(),
+			Latency: time.Duration(o.(*opInstruments).nanos.Load()),
+		}
+		return true
+	})
+	return s
+}
+
+// ResetStats sets the counters of the cache back to zero. Operations
+// finishing meanwhile may be partly counted.
+func (in *instruments) ResetStats() {
+	in.hits.Store(0)
+	in.misses.Store(0)
+	in.sets.Store(0)
+	in.deletes.Store(0)
+	in.errors.Store(0)
+	in.ops.Range(func(op, _ any) bool {
+		in.ops.Delete(op)
+		return true
+	})
+}
+
+// SetHook makes the cache report every operation to h. Pass nil to stop.
+func (in *instruments) SetHook(h Hook) {
+	if h == nil {
+		in.hook.Store(nil)
+		return
+	}
+	in.hook.Store(&h)
+}
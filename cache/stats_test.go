@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x6666/util/log"
+)
+
+func TestStatsCountMissesOnlyForReads(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	c.Get("missing", new(int))
+	c.Delete("missing")
+	c.Expire("missing", time.Minute)
+	c.Increment("missing", 1)
+
+	s := c.Stats()
+	if s.Misses != 1 || s.Errors != 0 {
+		t.Errorf("Stats = %+v, want only the GET counted as a miss", s)
+	}
+	if s.Ops["DEL"].Calls != 1 || s.Ops["GET"].Calls != 1 {
+		t.Errorf("Ops = %+v", s.Ops)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.Set("a", 1, DefaultExpiryTime)
+			c.Add("b", 1, DefaultExpiryTime)
+			c.SetMulti(map[string]interface{}{"c": 1, "d": 1}, DefaultExpiryTime)
+			c.Get("a", new(int))
+			c.Get("b", new(int))
+			c.Get("missing", new(int))
+			c.Delete("a")
+			c.Get("c", new(map[string]int)) // a decoding error
+
+			s := c.Stats()
+			want := CacheStats{Hits: 2, Misses: 1, Sets: 3, Deletes: 1, Errors: 1}
+			if s.Hits != want.Hits || s.Misses != want.Misses || s.Sets != want.Sets ||
+				s.Deletes != want.Deletes || s.Errors != want.Errors {
+				t.Errorf("Stats = %+v, want %+v", s, want)
+			}
+			if get := s.Ops["GET"]; get.Calls != 4 || get.Latency <= 0 {
+				t.Errorf("GET stats = %+v", get)
+			}
+			if s.Ops["MSET"].Calls != 1 || s.Ops["ADD"].Calls != 1 {
+				t.Errorf("Ops = %+v", s.Ops)
+			}
+
+			// ClearAll keeps the stats, ResetStats clears them.
+			c.ClearAll()
+			if s := c.Stats(); s.Hits != 2 {
+				t.Errorf("Stats after ClearAll = %+v", s)
+			}
+			c.ResetStats()
+			if s := c.Stats(); s.Hits != 0 || s.Sets != 0 || len(s.Ops) != 0 {
+				t.Errorf("Stats after ResetStats = %+v", s)
+			}
+		})
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	c.Set("k", 1, DefaultExpiryTime)
+	c.ResetStats()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				c.Get("k", new(int))
+				c.Get("missing", new(int))
+				c.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+	if s := c.Stats(); s.Hits != 8000 || s.Misses != 8000 || s.Ops["GET"].Calls != 16000 {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestRedisStatsErrors(t *testing.T) {
+	c, m := newTestRedis(t)
+	m.Close()
+	c.Get("k", new(int))
+	c.Set("k", 1, DefaultExpiryTime)
+	if s := c.Stats(); s.Errors != 2 || s.Misses != 0 || s.Sets != 0 {
+		t.Errorf("Stats = %+v, want 2 errors", s)
+	}
+}
+
+type hookCall struct {
+	op, key string
+	err     error
+}
+
+type recordingHook struct {
+	mu    sync.Mutex
+	calls []hookCall
+}
+
+func (h *recordingHook) OnOperation(op string, key string, d time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, hookCall{op, key, err})
+}
+
+func TestHook(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	h := new(recordingHook)
+	c.SetHook(h)
+	c.Set("k", 1, DefaultExpiryTime)
+	c.Get("missing", new(int))
+	c.GetMulti([]string{"k"})
+	c.SetHook(nil)
+	c.Get("k", new(int))
+
+	want := []hookCall{{"SET", "k", nil}, {"GET", "missing", ErrCacheMiss}, {"MGET", "", nil}}
+	if len(h.calls) != len(want) {
+		t.Fatalf("hook calls = %v, want %v", h.calls, want)
+	}
+	for i := range want {
+		if h.calls[i] != want[i] {
+			t.Errorf("call %d = %v, want %v", i, h.calls[i], want[i])
+		}
+	}
+}
+
+// waitStatsLine waits for the next line logged to h and returns it.
+func waitStatsLine(t *testing.T, h *log.MemoryHandler, n int) string {
+	t.Helper()
+	if !h.WaitFor(n, 5*time.Second) {
+		t.Fatalf("no report %d", n)
+	}
+	return h.Entries()[n-1].Line
+}
+
+func TestStatsReporterDeltas(t *testing.T) {
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	h := log.NewMemoryHandler()
+	l := log.New(h)
+	defer l.Close()
+	// The interval is long enough for the operations between two reports
+	// to fall into the same one.
+	stop := StartStatsReporter(100*time.Millisecond, l)
+	defer stop()
+
+	Set("a", 1, DefaultExpiryTime)
+	Get("a", new(int))
+	Get("a", new(int))
+	Get("b", new(int))
+	line := waitStatsLine(t, h, 1)
+	for _, want := range []string{"hits=2 ", "misses=1 ", "hit_ratio=0.667 ", "errors=0 ", "ops=GET:3,SET:1 "} {
+		if !strings.Contains(line, want) {
+			t.Errorf("report %q lacks %q", line, want)
+		}
+	}
+
+	// Only the operations since the last report are counted.
+	Get("b", new(int))
+	line = waitStatsLine(t, h, 2)
+	for _, want := range []string{"hits=0 ", "misses=1 ", "ops=GET:1 "} {
+		if !strings.Contains(line, want) {
+			t.Errorf("second report %q lacks %q", line, want)
+		}
+	}
+
+	// Idle intervals are not reported.
+	time.Sleep(300 * time.Millisecond)
+	if n := len(h.Entries()); n != 2 {
+		t.Errorf("%d reports after an idle period, want 2", n)
+	}
+
+	// A reset of the counters is not reported as a huge delta.
+	Default().ResetStats()
+	Get("a", new(int))
+	line = waitStatsLine(t, h, 3)
+	if !strings.Contains(line, "hits=1 ") || !strings.Contains(line, "ops=GET:1 ") {
+		t.Errorf("report after ResetStats = %q", line)
+	}
+}
+
+func TestStatsReporterStopsOnClose(t *testing.T) {
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	h := log.NewMemoryHandler()
+	l := log.New(h)
+	defer l.Close()
+	stop := StartStatsReporter(10*time.Millisecond, l)
+
+	closed := make(chan struct{})
+	go func() {
+		Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	// A new default cache is not reported on by the stopped reporter.
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	Get("x", new(int))
+	time.Sleep(50 * time.Millisecond)
+	if entries := h.Entries(); len(entries) != 0 {
+		t.Errorf("reported after Close: %v", entries)
+	}
+	stop() // still safe to call
+	stop()
+	instancesMu.RLock()
+	n := len(closeHooks[DefaultInstance])
+	instancesMu.RUnlock()
+	if n != 0 {
+		t.Errorf("%d close hooks left after stop", n)
+	}
+}
+
+func TestStatsReporterStop(t *testing.T) {
+	stop := StartStatsReporter(time.Hour, nil)
+	stop()
+	stop()
+	instancesMu.RLock()
+	n := len(closeHooks[DefaultInstance])
+	instancesMu.RUnlock()
+	if n != 0 {
+		t.Errorf("%d close hooks left after stop", n)
+	}
+}
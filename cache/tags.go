@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// tagKeyPrefix prefixes the Redis sets holding the keys of each tag.
+	tagKeyPrefix = "cache:tag:"
+
+	// tagTTLMargin is how much longer a tag set lives than its longest
+	// lived member, so it is never gone while one of its keys remains.
+	tagTTLMargin = time.Minute
+
+	// scanCount is the COUNT hint of SCAN and SSCAN, and thus roughly the
+	// number of keys deleted per round trip.
+	scanCount = 100
+)
+
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+// tagTTL returns the time to live in milliseconds of a tag set holding a key
+// that expires after the resolved expires, 0 if the set must not expire.
+func tagTTL(expires time.Duration) int64 {
+	if expires <= 0 {
+		return 0
+	}
+	return (expires + tagTTLMargin).Milliseconds()
+}
+
+// tagScript adds ARGV[1] to the tag set KEYS[1] and extends its time to
+// live to ARGV[2] milliseconds if it is shorter, with 0 making the set
+// persistent. A set that is already persistent stays so.
+var tagScript = redis.NewScript(1, `
+local existed = redis.call('EXISTS', KEYS[1]) == 1
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+local ms = tonumber(ARGV[2])
+if ms <= 0 then
+	redis.call('PERSIST', KEYS[1])
+elseif not existed or (ttl >= 0 and ttl < ms) then
+	redis.call('PEXPIRE', KEYS[1], ms)
+end
+return 1
+`)
+
+func (c RedisCache) addTag(conn redis.Conn, tag, key string, expires time.Duration) error {
+	_, err := tagScript.Do(conn, tagKey(tag), key, tagTTL(c.expiry(expires)))
+	return err
+}
+
+// tagMembers returns the keys in the tag set tk, read with SSCAN.
+func tagMembers(conn redis.Conn, tk string) ([]string, error) {
+	var keys []string
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("SSCAN", tk, cursor, "COUNT", scanCount))
+		if err != nil {
+			return nil, err
+		}
+		var batch []string
+		if _, err := redis.Scan(reply, &cursor, &batch); err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+// delKeys deletes keys with one pipelined DEL each, so they need not share a
+// cluster slot, and returns how many existed.
+func delKeys(conn redis.Conn, keys []string) (int, error) {
+	for _, k := range keys {
+		if err := conn.Send("DEL", k); err != nil {
+			return 0, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+	n := 0
+	var firstErr error
+	for range keys {
+		deleted, err := redis.Int(conn.Receive())
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		n += deleted
+	}
+	return n, firstErr
+}
+
+func (c RedisCache) SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) (err error) {
+	return c.SetWithTagsCtx(context.Background(), key, value, expires, tags...)
+}
+
+// SetWithTagsCtx stores the value, then adds key to the set of each tag.
+func (c RedisCache) SetWithTagsCtx(ctx context.Context, key string, value interface{}, expires time.Duration, tags ...string) (err error) {
+	defer c.trace("SET", key, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := c.invoke(conn.Do, key, value, expires, SetOptions{}); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := c.addTag(conn, tag, key, expires); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c RedisCache) InvalidateTag(tag string) (n int, err error) {
+	return c.InvalidateTagCtx(context.Background(), tag)
+}
+
+// InvalidateTagCtx deletes the keys of tag in pipelined batches, then the
+// tag set.
+func (c RedisCache) InvalidateTagCtx(ctx context.Context, tag string) (n int, err error) {
+	defer c.trace("DEL", tagKey(tag), time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	keys, err := tagMembers(conn, tagKey(tag))
+	if err != nil {
+		return 0, err
+	}
+	if n, err = delKeys(conn, keys); err != nil {
+		return n, err
+	}
+	_, err = conn.Do("DEL", tagKey(tag))
+	return n, err
+}
+
+func (c RedisCache) DeleteByPattern(pattern string) (n int, err error) {
+	return c.DeleteByPatternCtx(context.Background(), pattern)
+}
+
+// DeleteByPatternCtx walks the keyspace with SCAN MATCH, deleting each batch
+// of keys found before fetching the next.
+func (c RedisCache) DeleteByPatternCtx(ctx context.Context, pattern string) (n int, err error) {
+	defer c.trace("DEL", pattern, time.Now(), &err)
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	err = scan(conn, pattern, func(keys []string) error {
+		deleted, err := delKeys(conn, keys)
+		n += deleted
+		return err
+	})
+	return n, err
+}
+
+func (c *RedisClusterCache) SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) error {
+	return c.SetWithTagsCtx(context.Background(), key, value, expires, tags...)
+}
+
+// SetWithTagsCtx stores the value, then adds key to the set of each tag on
+// the node serving that set.
+func (c *RedisClusterCache) SetWithTagsCtx(ctx context.Context, key string, value interface{}, expires time.Duration, tags ...string) error {
+	if err := c.SetCtx(ctx, key, value, expires); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		err := c.do(ctx, keySlot(tagKey(tag)), func(n RedisCache) error {
+			conn, err := n.conn(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return n.addTag(conn, tag, key, expires)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisClusterCache) InvalidateTag(tag string) (int, error) {
+	return c.InvalidateTagCtx(context.Background(), tag)
+}
+
+// InvalidateTagCtx deletes the keys of tag slot by slot, then the tag set.
+func (c *RedisClusterCache) InvalidateTagCtx(ctx context.Context, tag string) (int, error) {
+	tk := tagKey(tag)
+	var keys []string
+	err := c.do(ctx, keySlot(tk), func(n RedisCache) error {
+		conn, err := n.conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		keys, err = tagMembers(conn, tk)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for slot, group := range bySlot(keys) {
+		err := c.do(ctx, slot, func(n RedisCache) error {
+			conn, err := n.conn(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			deleted, err := delKeys(conn, group)
+			if err == nil {
+				total += deleted
+			}
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+	err = c.do(ctx, keySlot(tk), func(n RedisCache) error {
+		err := n.DeleteCtx(ctx, tk)
+		if err == ErrCacheMiss {
+			err = nil
+		}
+		return err
+	})
+	return total, err
+}
+
+func (c *RedisClusterCache) DeleteByPattern(pattern string) (int, error) {
+	return c.DeleteByPatternCtx(context.Background(), pattern)
+}
+
+// DeleteByPatternCtx scans every master known from a fresh slot map,
+// stopping at the first failure.
+func (c *RedisClusterCache) DeleteByPatternCtx(ctx context.Context, pattern string) (int, error) {
+	nodes, err := c.masters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, n := range nodes {
+		deleted, err := n.DeleteByPatternCtx(ctx, pattern)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
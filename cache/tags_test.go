@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "", true},
+		{"user:42:*", "user:42:profile", true},
+		{"user:42:*", "user:420:profile", false},
+		{"user:*:profile", "user:42:profile", true},
+		{"user:**", "user:", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"abc", "abcd", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.match {
+			t.Errorf("globMatch(%q, %q) = %v", tt.pattern, tt.s, got)
+		}
+	}
+}
+
+func TestCacheDeleteByPattern(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// More keys than fit in one SCAN batch.
+			items := make(map[string]interface{})
+			for i := 0; i < 3*scanCount; i++ {
+				items[fmt.Sprintf("user:42:%d", i)] = i
+			}
+			items["user:420:0"] = 0
+			items["session:42"] = 0
+			c.SetMulti(items, DefaultExpiryTime)
+
+			if n, err := c.DeleteByPattern("user:42:*"); err != nil || n != 3*scanCount {
+				t.Errorf("DeleteByPattern = %d, %v, want %d", n, err, 3*scanCount)
+			}
+			if n, _ := c.ExistsMulti("user:42:0", "user:42:299", "user:420:0", "session:42"); n != 2 {
+				t.Errorf("%d keys left of 4, want the 2 not matching", n)
+			}
+			if n, err := c.DeleteByPattern("nothing:*"); err != nil || n != 0 {
+				t.Errorf("DeleteByPattern(no match) = %d, %v", n, err)
+			}
+		})
+	}
+}
+
+func TestCacheInvalidateTag(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.SetWithTags("user:42:profile", "p", DefaultExpiryTime, "user:42")
+			c.SetWithTags("user:42:orders", "o", DefaultExpiryTime, "user:42", "orders")
+			c.SetWithTags("user:7:orders", "o", DefaultExpiryTime, "user:7", "orders")
+			c.Delete("user:42:profile")
+
+			// Only keys that were still there are counted.
+			if n, err := c.InvalidateTag("user:42"); err != nil || n != 1 {
+				t.Errorf("InvalidateTag = %d, %v, want 1", n, err)
+			}
+			if found, _ := c.Exists("user:42:orders"); found {
+				t.Error("a tagged key survived")
+			}
+			if found, _ := c.Exists("user:7:orders"); !found {
+				t.Error("a key of another tag was deleted")
+			}
+			// The tag is gone with its keys.
+			if n, err := c.InvalidateTag("user:42"); err != nil || n != 0 {
+				t.Errorf("second InvalidateTag = %d, %v", n, err)
+			}
+			if n, err := c.InvalidateTag("orders"); err != nil || n != 1 {
+				t.Errorf("InvalidateTag(orders) = %d, %v, want 1", n, err)
+			}
+		})
+	}
+}
+
+func TestRedisTagExpiry(t *testing.T) {
+	c, m := newTestRedis(t)
+	c.SetWithTags("short", 1, time.Second, "t")
+	c.SetWithTags("long", 1, time.Minute, "t")
+	c.SetWithTags("shorter", 1, time.Millisecond, "t")
+	if ttl := m.TTL(tagKey("t")); ttl != time.Minute+tagTTLMargin {
+		t.Errorf("tag TTL = %v, want the longest member's plus the margin", ttl)
+	}
+
+	// Keys expired before the invalidation are not counted.
+	m.FastForward(2 * time.Second)
+	if n, err := c.InvalidateTag("t"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v, want 1", n, err)
+	}
+	if m.Exists(tagKey("t")) {
+		t.Error("the tag set was not deleted")
+	}
+
+	// The tag set outlives its members and then goes away.
+	c.SetWithTags("k", 1, time.Second, "gone")
+	m.FastForward(time.Second + tagTTLMargin)
+	if m.Exists(tagKey("gone")) {
+		t.Error("the tag set outlived its margin")
+	}
+
+	// A member that never expires makes the set persistent.
+	c.SetWithTags("a", 1, time.Second, "p")
+	c.SetWithTags("b", 1, ForEverNeverExpiry, "p")
+	c.SetWithTags("c", 1, time.Second, "p")
+	if ttl := m.TTL(tagKey("p")); ttl != 0 {
+		t.Errorf("tag TTL = %v, want none", ttl)
+	}
+}
+
+func TestMemoryTagExpiry(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	c.SetWithTags("short", 1, time.Millisecond, "t")
+	c.SetWithTags("long", 1, time.Hour, "t")
+	c.Set("p:short", 1, time.Millisecond)
+	c.Set("p:long", 1, time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if n, err := c.InvalidateTag("t"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v, want 1", n, err)
+	}
+	if n, err := c.DeleteByPattern("p:*"); err != nil || n != 1 {
+		t.Errorf("DeleteByPattern = %d, %v, want 1", n, err)
+	}
+}
+
+func TestTagsPackageLevel(t *testing.T) {
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	SetWithTags("a", 1, DefaultExpiryTime, "t")
+	Set("b:1", 1, DefaultExpiryTime)
+	if n, err := InvalidateTag("t"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v", n, err)
+	}
+	if n, err := DeleteByPattern("b:*"); err != nil || n != 1 {
+		t.Errorf("DeleteByPattern = %d, %v", n, err)
+	}
+}
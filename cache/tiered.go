@@ -0,0 +1,465 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x6666/util/log"
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// defaultLocalTTL is the local time to live of a TieredCache created
+	// with a localTTL <= 0.
+	defaultLocalTTL = 10 * time.Second
+
+	// tieredPingInterval is how often the invalidation subscription is
+	// checked, and tieredRetryDelay how long to wait before subscribing
+	// again after it failed.
+	tieredPingInterval = 30 * time.Second
+	tieredRetryDelay   = time.Second
+)
+
+// Kinds of invalidation messages, followed by their argument.
+const (
+	invalidateKey     = "K"
+	invalidateTag     = "T"
+	invalidatePattern = "P"
+	invalidateAll     = "*"
+)
+
+// TieredCache is a Cache keeping recently used values in a local cache in
+// front of a shared remote one, typically a NewLRUCache in front of a
+// RedisCache, to take the load of hot keys off the remote cache.
+//
+// Get reads the local layer first and back-fills it from the remote one on
+// a local miss. Values stay local for at most the local time to live, so
+// other processes' writes show up after that delay at the latest. Writes go
+// to the remote cache, then are written through to the local layer or drop
+// the local copy. Operations needing the authoritative value, such as
+// GetWithVersion, TTL or GetMulti, go to the remote cache only.
+//
+// With WithInvalidation, every write is also published on a Redis channel so
+// that the TieredCaches of other processes drop their local copy at once.
+type TieredCache struct {
+	local    Cache
+	remote   Cache
+	localTTL time.Duration
+	*instruments
+
+	// pool and channel are set by WithInvalidation; id tells the messages
+	// of this cache from those of others.
+	pool    *redis.Pool
+	channel string
+	id      string
+
+	// mu guards psc and serializes the commands sent on it, the replies
+	// being read by the subscribe goroutine.
+	mu        sync.Mutex
+	psc       *redis.PubSubConn // current subscription, if any
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// TieredOption configures a TieredCache.
+type TieredOption func(*TieredCache)
+
+// WithInvalidation publishes the keys written through the cache on channel
+// using connections from p, e.g. RedisCache.Pool of the remote cache, and
+// drops the local copies of the keys published by the other caches on the
+// same channel. Messages missed while the subscription was down are made
+// up for by clearing the local layer when it is back. It is off by default.
+func WithInvalidation(p *redis.Pool, channel string) TieredOption {
+	return func(c *TieredCache) {
+		c.pool = p
+		c.channel = channel
+	}
+}
+
+// NewTieredCache returns a cache reading through local to remote. Values
+// are kept in local for localTTL, or less if they expire sooner remotely
+// and remote is a RedisCache or RedisClusterCache; a localTTL <= 0 means 10
+// seconds. local should be bounded, see NewLRUCache.
+// The TieredCache owns both layers, which its Close closes.
+func NewTieredCache(local Cache, remote Cache, localTTL time.Duration, opts ...TieredOption) Cache {
+	if localTTL <= 0 {
+		localTTL = defaultLocalTTL
+	}
+	c := &TieredCache{
+		local:       local,
+		remote:      remote,
+		localTTL:    localTTL,
+		instruments: new(instruments),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.pool == nil || c.channel == "" {
+		close(c.done)
+		return c
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	c.id = hex.EncodeToString(b)
+	go c.subscribe()
+	return c
+}
+
+// localExpiry returns the local time to live of a value stored with
+// expires.
+func (c *TieredCache) localExpiry(expires time.Duration) time.Duration {
+	if expires > 0 && expires < c.localTTL {
+		return expires
+	}
+	return c.localTTL
+}
+
+// ttlGetter is implemented by the Redis caches, which read a value and its
+// time to live in one round trip.
+type ttlGetter interface {
+	getWithTTL(key string, ptrValue interface{}) (time.Duration, error)
+}
+
+// getRemote reads key from the remote cache, and returns how long the value
+// may be kept locally: no longer than the remote copy has left to live when
+// the remote cache tells it in the same round trip, or the local time to
+// live otherwise. In the latter case a value may thus outlive its remote
+// copy locally, by the local time to live at most.
+func (c *TieredCache) getRemote(key string, ptrValue interface{}) (time.Duration, error) {
+	if g, ok := c.remote.(ttlGetter); ok {
+		ttl, err := g.getWithTTL(key, ptrValue)
+		if ttl == 0 {
+			return 0, err
+		}
+		return c.localExpiry(ttl), err
+	}
+	return c.localTTL, c.remote.Get(key, ptrValue)
+}
+
+// backfill stores the value ptrValue points to in the local layer for ttl,
+// or not at all if ttl is 0, which means the value expired meanwhile.
+func (c *TieredCache) backfill(key string, ptrValue interface{}, ttl time.Duration) {
+	v := reflect.ValueOf(ptrValue)
+	if v.Kind() != reflect.Ptr || v.IsNil() || ttl == 0 {
+		return
+	}
+	c.local.Set(key, v.Elem().Interface(), ttl)
+}
+
+// drop deletes the local copy of key and tells the other caches to do the
+// same.
+func (c *TieredCache) drop(key string) {
+	c.local.Delete(key)
+	c.publish(invalidateKey, key)
+}
+
+// publish sends an invalidation message, if enabled. Failures are only
+// logged: the other caches see the change after their local time to live.
+func (c *TieredCache) publish(kind, arg string) {
+	if c.pool == nil || c.channel == "" {
+		return
+	}
+	conn := c.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PUBLISH", c.channel, c.id+" "+kind+" "+arg); err != nil {
+		log.Warn("cache: publishing invalidation on %q: %v", c.channel, err)
+	}
+}
+
+// subscribe applies the invalidation messages of the other caches until
+// Close is called, subscribing again whenever the subscription fails.
+func (c *TieredCache) subscribe() {
+	defer close(c.done)
+	for {
+		err := c.listen()
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+		log.Warn("cache: invalidation subscription on %q: %v", c.channel, err)
+		select {
+		case <-time.After(tieredRetryDelay):
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// listen subscribes once and applies messages until the subscription fails
+// or Close unsubscribes.
+func (c *TieredCache) listen() error {
+	c.mu.Lock()
+	select {
+	case <-c.quit:
+		c.mu.Unlock()
+		return nil
+	default:
+	}
+	psc := &redis.PubSubConn{Conn: c.pool.Get()}
+	c.psc = psc
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.psc = nil
+		psc.Close()
+	}()
+
+	c.mu.Lock()
+	err := psc.Subscribe(c.channel)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go func() {
+		t := time.NewTicker(tieredPingInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				c.mu.Lock()
+				err := psc.Ping("")
+				c.mu.Unlock()
+				if err != nil {
+					return
+				}
+			case <-stopPing:
+				return
+			}
+		}
+	}()
+
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * tieredPingInterval).(type) {
+		case redis.Message:
+			c.apply(string(v.Data))
+		case redis.Subscription:
+			switch {
+			case v.Kind == "subscribe":
+				// messages may have been missed while not subscribed
+				c.local.ClearAll()
+			case v.Kind == "unsubscribe" && v.Count == 0:
+				// by Close
+				return nil
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// apply handles an invalidation message of another cache.
+func (c *TieredCache) apply(msg string) {
+	f := strings.SplitN(msg, " ", 3)
+	if len(f) != 3 || f[0] == c.id {
+		return
+	}
+	switch f[1] {
+	case invalidateKey:
+		c.local.Delete(f[2])
+	case invalidateTag:
+		c.local.InvalidateTag(f[2])
+	case invalidatePattern:
+		c.local.DeleteByPattern(f[2])
+	case invalidateAll:
+		c.local.ClearAll()
+	}
+}
+
+func (c *TieredCache) Get(key string, ptrValue interface{}) (err error) {
+	defer c.observe("GET", key, time.Now(), &err)
+	if err := c.local.Get(key, ptrValue); err == nil {
+		return nil
+	}
+	ttl, err := c.getRemote(key, ptrValue)
+	if err != nil {
+		return err
+	}
+	c.backfill(key, ptrValue, ttl)
+	return nil
+}
+
+func (c *TieredCache) Set(key string, value interface{}, expires time.Duration) (err error) {
+	defer c.observe("SET", key, time.Now(), &err)
+	if err := c.remote.Set(key, value, expires); err != nil {
+		c.drop(key)
+		return err
+	}
+	c.local.Set(key, value, c.localExpiry(expires))
+	c.publish(invalidateKey, key)
+	return nil
+}
+
+func (c *TieredCache) SetOpt(key string, value interface{}, expires time.Duration, opts SetOptions) (err error) {
+	defer c.observe("SET", key, time.Now(), &err)
+	err = c.remote.SetOpt(key, value, expires, opts)
+	c.drop(key)
+	return err
+}
+
+func (c *TieredCache) Add(key string, value interface{}, expires time.Duration) (err error) {
+	defer c.observe("ADD", key, time.Now(), &err)
+	err = c.remote.Add(key, value, expires)
+	c.drop(key)
+	return err
+}
+
+func (c *TieredCache) Replace(key string, value interface{}, expires time.Duration) (err error) {
+	defer c.observe("REPLACE", key, time.Now(), &err)
+	err = c.remote.Replace(key, value, expires)
+	c.drop(key)
+	return err
+}
+
+func (c *TieredCache) GetWithVersion(key string, ptrValue interface{}) (version uint64, err error) {
+	defer c.observe("GET", key, time.Now(), &err)
+	return c.remote.GetWithVersion(key, ptrValue)
+}
+
+func (c *TieredCache) CompareAndSwap(key string, value interface{}, version uint64, expires time.Duration) (err error) {
+	defer c.observe("CAS", key, time.Now(), &err)
+	err = c.remote.CompareAndSwap(key, value, version, expires)
+	c.drop(key)
+	return err
+}
+
+func (c *TieredCache) Exists(key string) (found bool, err error) {
+	defer c.observe("EXISTS", key, time.Now(), &err)
+	return c.remote.Exists(key)
+}
+
+func (c *TieredCache) ExistsMulti(keys ...string) (n int, err error) {
+	defer c.observe("EXISTS", "", time.Now(), &err)
+	return c.remote.ExistsMulti(keys...)
+}
+
+func (c *TieredCache) TTL(key string) (ttl time.Duration, err error) {
+	defer c.observe("PTTL", key, time.Now(), &err)
+	return c.remote.TTL(key)
+}
+
+func (c *TieredCache) Expire(key string, expires time.Duration) (err error) {
+	defer c.observe("EXPIRE", key, time.Now(), &err)
+	err = c.remote.Expire(key, expires)
+	c.drop(key)
+	return err
+}
+
+// Fetch is Get, with the value loaded through the remote cache's Fetch on a
+// miss of both layers.
+func (c *TieredCache) Fetch(key string, ptrValue interface{}, expires time.Duration, loader func() (interface{}, error)) error {
+	if err := c.Get(key, ptrValue); err != ErrCacheMiss {
+		return err
+	}
+	if err := c.remote.Fetch(key, ptrValue, expires, loader); err != nil {
+		return err
+	}
+	// The value may have been found remotely rather than loaded, with less
+	// time left than expires: the local copy is bounded as by getRemote.
+	c.backfill(key, ptrValue, c.localExpiry(expires))
+	return nil
+}
+
+func (c *TieredCache) GetMulti(keys []string) (items map[string][]byte, err error) {
+	defer c.observe("MGET", "", time.Now(), &err)
+	return c.remote.GetMulti(keys)
+}
+
+func (c *TieredCache) SetMulti(items map[string]interface{}, expires time.Duration) (err error) {
+	defer c.observe("MSET", "", time.Now(), &err)
+	err = c.remote.SetMulti(items, expires)
+	for k := range items {
+		c.drop(k)
+	}
+	return err
+}
+
+func (c *TieredCache) SetWithTags(key string, value interface{}, expires time.Duration, tags ...string) (err error) {
+	defer c.observe("SET", key, time.Now(), &err)
+	if err := c.remote.SetWithTags(key, value, expires, tags...); err != nil {
+		c.drop(key)
+		return err
+	}
+	c.local.SetWithTags(key, value, c.localExpiry(expires), tags...)
+	c.publish(invalidateKey, key)
+	return nil
+}
+
+func (c *TieredCache) InvalidateTag(tag string) (n int, err error) {
+	defer c.observe("DEL", tagKey(tag), time.Now(), &err)
+	n, err = c.remote.InvalidateTag(tag)
+	c.local.InvalidateTag(tag)
+	c.publish(invalidateTag, tag)
+	return n, err
+}
+
+func (c *TieredCache) DeleteByPattern(pattern string) (n int, err error) {
+	defer c.observe("DEL", pattern, time.Now(), &err)
+	n, err = c.remote.DeleteByPattern(pattern)
+	c.local.DeleteByPattern(pattern)
+	c.publish(invalidatePattern, pattern)
+	return n, err
+}
+
+func (c *TieredCache) Delete(key string) (err error) {
+	defer c.observe("DEL", key, time.Now(), &err)
+	err = c.remote.Delete(key)
+	c.drop(key)
+	return err
+}
+
+func (c *TieredCache) Increment(key string, n uint64) (newValue uint64, err error) {
+	defer c.observe("INCR", key, time.Now(), &err)
+	newValue, err = c.remote.Increment(key, n)
+	c.drop(key)
+	return newValue, err
+}
+
+func (c *TieredCache) IncrementWithTTL(key string, delta uint64, ttl time.Duration) (newValue uint64, err error) {
+	defer c.observe("INCR", key, time.Now(), &err)
+	newValue, err = c.remote.IncrementWithTTL(key, delta, ttl)
+	c.drop(key)
+	return newValue, err
+}
+
+func (c *TieredCache) Decrement(key string, n uint64) (newValue uint64, err error) {
+	defer c.observe("DECR", key, time.Now(), &err)
+	newValue, err = c.remote.Decrement(key, n)
+	c.drop(key)
+	return newValue, err
+}
+
+func (c *TieredCache) ClearAll() (err error) {
+	defer c.observe("FLUSHDB", "", time.Now(), &err)
+	err = c.remote.ClearAll()
+	c.local.ClearAll()
+	c.publish(invalidateAll, "")
+	return err
+}
+
+// Close stops listening for invalidations and closes both layers, even a
+// remote cache that is also used elsewhere.
+func (c *TieredCache) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		close(c.quit)
+		if c.psc != nil {
+			c.psc.Unsubscribe()
+		}
+		c.mu.Unlock()
+	})
+	<-c.done
+	return errors.Join(c.local.Close(), c.remote.Close())
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestTiered returns a TieredCache in front of a miniredis server, and
+// its two layers.
+func newTestTiered(t *testing.T, localTTL time.Duration, opts ...TieredOption) (Cache, *MemoryCache, RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	remote, m := newTestRedis(t)
+	local := NewLRUCache(100, time.Hour)
+	c := NewTieredCache(local, remote, localTTL, opts...)
+	t.Cleanup(func() { c.Close() })
+	return c, local, remote, m
+}
+
+func TestTieredLocalHit(t *testing.T) {
+	c, local, _, m := newTestTiered(t, time.Minute)
+	if err := c.Set("k", "v", DefaultExpiryTime); err != nil {
+		t.Fatal(err)
+	}
+	// Written through to both layers.
+	if !m.Exists("k") {
+		t.Fatal("not stored remotely")
+	}
+	if ttl, err := local.TTL("k"); err != nil || ttl > time.Minute {
+		t.Errorf("local TTL = %v, %v, want at most the local TTL", ttl, err)
+	}
+
+	// With the local copy, Redis is not asked.
+	before := m.CommandCount()
+	var s string
+	if err := c.Get("k", &s); err != nil || s != "v" {
+		t.Errorf("Get = %q, %v", s, err)
+	}
+	if m.CommandCount() != before {
+		t.Error("a local hit went to Redis")
+	}
+}
+
+func TestTieredRemoteHitBackfills(t *testing.T) {
+	c, local, remote, _ := newTestTiered(t, time.Minute)
+	remote.Set("forever", 1, ForEverNeverExpiry)
+	remote.Set("short", 2, 2*time.Second)
+
+	for key, maxTTL := range map[string]time.Duration{"forever": time.Minute, "short": 2 * time.Second} {
+		var v int
+		if err := c.Get(key, &v); err != nil {
+			t.Fatalf("Get(%s) = %v", key, err)
+		}
+		// The local copy lives no longer than the remote one.
+		ttl, err := local.TTL(key)
+		if err != nil || ttl > maxTTL || ttl < maxTTL-time.Second {
+			t.Errorf("local TTL of %s = %v, %v, want about %v", key, ttl, err, maxTTL)
+		}
+	}
+
+	if err := c.Get("missing", new(int)); err != ErrCacheMiss {
+		t.Errorf("Get(missing) = %v", err)
+	}
+	if found, _ := local.Exists("missing"); found {
+		t.Error("a miss was backfilled")
+	}
+}
+
+func TestTieredBackfillRoundTrips(t *testing.T) {
+	c, _, remote, m := newTestTiered(t, time.Minute)
+	remote.Set("k", 1, 2*time.Second)
+
+	// The commands of a plain Get, including the PING checking the pooled
+	// connection.
+	before := m.CommandCount()
+	remote.Get("k", new(int))
+	get := m.CommandCount() - before
+
+	// PTTL is pipelined after the GET on the same connection.
+	before = m.CommandCount()
+	if err := c.Get("k", new(int)); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.CommandCount() - before; n != get+1 {
+		t.Errorf("a remote hit sent %d commands, want %d and PTTL", n, get)
+	}
+
+	// A remote cache not telling the TTL along with the value is read once,
+	// and the local copy kept for the local time to live.
+	local := NewLRUCache(100, time.Hour)
+	other := NewTieredCache(local, WithNamespace(remote, ""), time.Minute)
+	defer other.Close()
+	before = m.CommandCount()
+	if err := other.Get("k", new(int)); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.CommandCount() - before; n != get {
+		t.Errorf("a remote hit sent %d commands, want those of a GET only", n)
+	}
+	if ttl, _ := local.TTL("k"); ttl < 59*time.Second {
+		t.Errorf("local TTL = %v, want the local time to live", ttl)
+	}
+}
+
+func TestTieredWrites(t *testing.T) {
+	c, local, _, m := newTestTiered(t, time.Minute)
+	c.Set("k", 1, DefaultExpiryTime)
+	if err := c.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := local.Exists("k"); found || m.Exists("k") {
+		t.Error("Delete left a copy")
+	}
+
+	// Writes the local layer cannot mirror drop the local copy.
+	c.Set("n", 1, DefaultExpiryTime)
+	if v, err := c.Increment("n", 1); err != nil || v != 2 {
+		t.Fatalf("Increment = %d, %v", v, err)
+	}
+	var v int
+	if c.Get("n", &v); v != 2 {
+		t.Errorf("Get after Increment = %d, want 2", v)
+	}
+
+	// A failed remote write leaves no stale local copy.
+	c.Set("k", 1, DefaultExpiryTime)
+	m.SetError("READONLY")
+	if err := c.Set("k", 2, DefaultExpiryTime); err == nil {
+		t.Fatal("Set with Redis failing succeeded")
+	}
+	m.SetError("")
+	if found, _ := local.Exists("k"); found {
+		t.Error("the local copy survived a failed write")
+	}
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	remote, m := newTestRedis(t)
+	a := NewTieredCache(NewLRUCache(100, time.Hour), remote, time.Hour, WithInvalidation(remote.Pool(), "inv"))
+	bLocal := NewLRUCache(100, time.Hour)
+	b := NewTieredCache(bLocal, remote, time.Hour, WithInvalidation(remote.Pool(), "inv"))
+	defer a.Close()
+	defer b.Close()
+	eventually(t, "the subscriptions", func() bool { return m.PubSubNumSub("inv")["inv"] == 2 })
+
+	a.Set("k", 1, DefaultExpiryTime)
+	b.Get("k", new(int))
+	if found, _ := bLocal.Exists("k"); !found {
+		t.Fatal("b did not backfill")
+	}
+	// A Delete through a drops b's local copy at once.
+	a.Delete("k")
+	eventually(t, "the invalidation", func() bool {
+		found, _ := bLocal.Exists("k")
+		return !found
+	})
+
+	b.SetWithTags("t1", 1, DefaultExpiryTime, "tag")
+	a.InvalidateTag("tag")
+	eventually(t, "the tag invalidation", func() bool {
+		found, _ := bLocal.Exists("t1")
+		return !found
+	})
+}
+
+// closeCounter counts the calls to Close of the cache it wraps.
+type closeCounter struct {
+	Cache
+	closed int
+	err    error
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return c.err
+}
+
+func TestTieredCloseOwnsLayers(t *testing.T) {
+	errRemote := errors.New("remote close")
+	local := &closeCounter{Cache: NewMemoryCache(time.Hour)}
+	remote := &closeCounter{Cache: NewMemoryCache(time.Hour), err: errRemote}
+	c := NewTieredCache(local, remote, time.Minute)
+	if err := c.Close(); !errors.Is(err, errRemote) {
+		t.Errorf("Close = %v, want the error of the remote layer", err)
+	}
+	if local.closed != 1 || remote.closed != 1 {
+		t.Errorf("layers closed %d and %d times, want once each", local.closed, remote.closed)
+	}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import "time"
+
+// GetAs is Get decoding into a new T, so that the type is checked at
+// compile time. Use Default() for the default cache:
+//
+//	u, err := cache.GetAs[User](cache.Default(), key)
+//
+// Returns:
+//   - the value and nil if it was successfully retrieved
+//   - the zero T and ErrCacheMiss if the value was not in the cache
+//   - the zero T and an implementation specific error otherwise
+func GetAs[T any](c Cache, key string) (T, error) {
+	var v T
+	if err := c.Get(key, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// SetAs is Set restricted to values of type T, to be read with GetAs[T].
+func SetAs[T any](c Cache, key string, v T, expires time.Duration) error {
+	return c.Set(key, v, expires)
+}
+
+// FetchAs is Fetch decoding into a new T, with a loader of T values. The
+// zero T is returned along with any error.
+func FetchAs[T any](c Cache, key string, expires time.Duration, loader func() (T, error)) (T, error) {
+	var v T
+	err := c.Fetch(key, &v, expires, func() (interface{}, error) {
+		return loader()
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
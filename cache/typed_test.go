@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTypedAccessors(t *testing.T) {
+	for name, c := range cacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			u := testUser{Name: "ann", Age: 42, Roles: []string{"admin"}}
+			if err := SetAs(c, "user", u, DefaultExpiryTime); err != nil {
+				t.Fatal(err)
+			}
+			got, err := GetAs[testUser](c, "user")
+			if err != nil || got.Name != "ann" || got.Age != 42 || len(got.Roles) != 1 {
+				t.Errorf("GetAs = %+v, %v", got, err)
+			}
+			p, err := GetAs[*testUser](c, "user")
+			if err != nil || p == nil || p.Name != "ann" {
+				t.Errorf("GetAs pointer = %+v, %v", p, err)
+			}
+			SetAs(c, "n", uint16(7), DefaultExpiryTime)
+			if n, err := GetAs[uint16](c, "n"); err != nil || n != 7 {
+				t.Errorf("GetAs[uint16] = %d, %v", n, err)
+			}
+
+			if got, err := GetAs[testUser](c, "missing"); err != ErrCacheMiss || got.Name != "" {
+				t.Errorf("GetAs(missing) = %+v, %v", got, err)
+			}
+			// A value of another type is an error, with nothing half decoded.
+			SetAs(c, "text", "not a user", DefaultExpiryTime)
+			if got, err := GetAs[testUser](c, "text"); err == nil || got.Name != "" || got.Roles != nil {
+				t.Errorf("GetAs of a string = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestFetchAs(t *testing.T) {
+	c := NewMemoryCache(time.Hour)
+	defer c.Close()
+	calls := 0
+	load := func() (testUser, error) {
+		calls++
+		return testUser{Name: "bob"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		if u, err := FetchAs(c, "user", DefaultExpiryTime, load); err != nil || u.Name != "bob" {
+			t.Errorf("FetchAs = %+v, %v", u, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader called %d times, want once", calls)
+	}
+
+	errDB := errors.New("database down")
+	u, err := FetchAs(c, "other", DefaultExpiryTime, func() (testUser, error) {
+		return testUser{Name: "partial"}, errDB
+	})
+	if err != errDB || u.Name != "" {
+		t.Errorf("FetchAs with a failing loader = %+v, %v, want the zero value", u, err)
+	}
+}
+
+func TestTypedAccessorsDefault(t *testing.T) {
+	if _, err := GetAs[int](Default(), "k"); err != ErrNotInited {
+		t.Errorf("GetAs before Init = %v, want ErrNotInited", err)
+	}
+	if err := InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	SetAs(Default(), "k", 3, DefaultExpiryTime)
+	if v, err := GetAs[int](Default(), "k"); err != nil || v != 3 {
+		t.Errorf("GetAs = %d, %v", v, err)
+	}
+}
@@ -1,8 +1,23 @@
 module github.com/0x6666/util
 
-go 1.16
+go 1.23
 
 require (
-	github.com/fatih/color v1.10.0 // indirect
-	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/alicebob/miniredis/v2 v2.37.0
+	github.com/fatih/color v1.10.0
+	github.com/garyburd/redigo v1.6.4
+	github.com/mattn/go-isatty v0.0.12
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/mattn/go-colorable v0.1.8 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae // indirect
 )
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"bufio"
+	"sync"
+	"time"
+)
+
+// LeveledHandler is implemented by handlers that want to know the level of
+// each record. The Logger calls WriteLevel instead of Write for them.
+type LeveledHandler interface {
+	Handler
+	WriteLevel(level LogLever, p []byte) (n int, err error)
+}
+
+// BufferedHandler batches writes to another handler, cutting the number of
+// write syscalls. Buffered data is written when the buffer fills, every
+// flush interval, and on Close.
+type BufferedHandler struct {
+	// SyncOnError makes records at LevelError and above flush the buffer and
+	// fsync the underlying handler immediately, if it has a Sync() error
+	// method like the file handlers. Set it before logging.
+	SyncOnError bool
+
+	h Handler
+
+	mu sync.Mutex
+	w  *bufio.Writer
+
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewBufferedHandler(h Handler, bufSize int, flushInterval time.Duration) *BufferedHandler {
+	b := &BufferedHandler{
+		h:    h,
+		w:    bufio.NewWriterSize(h, bufSize),
+		quit: make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		b.wg.Add(1)
+		go b.run(flushInterval)
+	}
+	return b
+}
+
+func (b *BufferedHandler) run(interval time.Duration) {
+	defer b.wg.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			b.Flush()
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+func (b *BufferedHandler) Write(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Write(p)
+}
+
+func (b *BufferedHandler) WriteLevel(level LogLever, p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n, err = b.w.Write(p)
+	if err != nil || !b.SyncOnError || level&AtLeast(LevelError) == 0 {
+		return n, err
+	}
+	if err = b.w.Flush(); err != nil {
+		return n, err
+	}
+	if s, ok := b.h.(interface{ Sync() error }); ok {
+		err = s.Sync()
+	}
+	return n, err
+}
+
+// Flush writes the buffered data to the underlying handler.
+func (b *BufferedHandler) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Flush()
+}
+
+// Reopen flushes the buffer, then reopens the underlying handler if it
+// supports it.
+func (b *BufferedHandler) Reopen() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.w.Flush(); err != nil {
+		return err
+	}
+	r, ok := b.h.(interface{ Reopen() error })
+	if !ok {
+		return ErrNotReopenable
+	}
+	return r.Reopen()
+}
+
+// Close flushes the buffer and closes the underlying handler. Calling it
+// again does nothing.
+func (b *BufferedHandler) Close() (err error) {
+	b.closeOnce.Do(func() {
+		close(b.quit)
+		b.wg.Wait()
+
+		err = b.Flush()
+		if cerr := b.h.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
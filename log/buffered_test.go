@@ -0,0 +1,169 @@
+package log
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingHandler records what reaches it and how.
+type countingHandler struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+	syncs  int
+	closes int
+}
+
+func (h *countingHandler) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writes++
+	return h.buf.Write(p)
+}
+
+func (h *countingHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.syncs++
+	return nil
+}
+
+func (h *countingHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closes++
+	return nil
+}
+
+func (h *countingHandler) state() (string, int, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.buf.String(), h.writes, h.syncs
+}
+
+func TestBufferedHandlerBatches(t *testing.T) {
+	under := new(countingHandler)
+	b := NewBufferedHandler(under, 64, 0)
+	b.Write([]byte("one\n"))
+	b.Write([]byte("two\n"))
+	if _, writes, _ := under.state(); writes != 0 {
+		t.Fatalf("%d writes before the buffer filled", writes)
+	}
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if data, writes, _ := under.state(); data != "one\ntwo\n" || writes != 1 {
+		t.Errorf("after Flush: %q in %d writes, want one write", data, writes)
+	}
+
+	// A full buffer is written out by itself.
+	b.Write([]byte(strings.Repeat("x", 100)))
+	if data, _, _ := under.state(); len(data) < 100 {
+		t.Errorf("a write larger than the buffer was held back")
+	}
+
+	b.Write([]byte("last\n"))
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, _ := under.state(); !strings.HasSuffix(data, "last\n") || under.closes != 1 {
+		t.Errorf("Close did not flush and close: %q, closed %d times", data, under.closes)
+	}
+	if err := b.Close(); err != nil || under.closes != 1 {
+		t.Errorf("closing again = %v, closed %d times", err, under.closes)
+	}
+}
+
+func TestBufferedHandlerFlushInterval(t *testing.T) {
+	under := new(countingHandler)
+	b := NewBufferedHandler(under, 4096, 5*time.Millisecond)
+	defer b.Close()
+	b.Write([]byte("tick\n"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, _, _ := under.state(); data == "tick\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the buffer was not flushed by the ticker")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBufferedHandlerSyncOnError(t *testing.T) {
+	under := new(countingHandler)
+	b := NewBufferedHandler(under, 4096, 0)
+	b.SyncOnError = true
+	defer b.Close()
+
+	b.WriteLevel(LevelInfo, []byte("info\n"))
+	b.WriteLevel(LevelWarn, []byte("warn\n"))
+	if data, _, syncs := under.state(); data != "" || syncs != 0 {
+		t.Fatalf("info and warn flushed (%q) or synced (%d)", data, syncs)
+	}
+	b.WriteLevel(LevelError, []byte("error\n"))
+	if data, _, syncs := under.state(); data != "info\nwarn\nerror\n" || syncs != 1 {
+		t.Errorf("after an error: %q, %d syncs, want everything flushed and one sync", data, syncs)
+	}
+	b.WriteLevel(LevelPanic, []byte("panic\n"))
+	if _, _, syncs := under.state(); syncs != 2 {
+		t.Errorf("%d syncs, panic records should sync too", syncs)
+	}
+
+	// Without the knob errors are buffered like everything else.
+	under2 := new(countingHandler)
+	b2 := NewBufferedHandler(under2, 4096, 0)
+	b2.WriteLevel(LevelError, []byte("error\n"))
+	if data, _, syncs := under2.state(); data != "" || syncs != 0 {
+		t.Errorf("without SyncOnError: %q, %d syncs", data, syncs)
+	}
+	b2.Close()
+}
+
+func TestBufferedHandlerThroughLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	fh, _ := NewRotatingFileHandler(path, 1<<20, 1)
+	b := NewBufferedHandler(fh, 4096, time.Hour)
+	b.SyncOnError = true
+	l := New(b)
+	l.SetLevel(LevelAll)
+	l.SetFlags(Llevel)
+	l.Info("queued")
+	l.Error("failed")
+	l.Flush()
+
+	// The error forced everything before it to disk.
+	if got := readFile(t, path); got != "INFO - queued\nERROR - failed\n" {
+		t.Errorf("file = %q", got)
+	}
+	l.Close()
+}
+
+var benchLine = []byte(strings.Repeat("x", 99) + "\n")
+
+func benchmarkFileWrites(b *testing.B, buffered bool) {
+	fh, err := NewRotatingFileHandler(filepath.Join(b.TempDir(), "bench.log"), 1<<30, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var h Handler = fh
+	if buffered {
+		h = NewBufferedHandler(fh, 64<<10, time.Second)
+	}
+	defer h.Close()
+	b.SetBytes(int64(len(benchLine)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write(benchLine)
+	}
+}
+
+func BenchmarkFileHandlerUnbuffered(b *testing.B) { benchmarkFileWrites(b, false) }
+func BenchmarkFileHandlerBuffered(b *testing.B)   { benchmarkFileWrites(b, true) }
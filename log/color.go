@@ -0,0 +1,85 @@
+package log
+
+import (
+	"github.com/fatih/color"
+)
+
+// ColorMode controls whether the text output colors level names.
+type ColorMode int32
+
+const (
+	// ColorAuto colors only when the handler writes to a terminal, as
+	// reported by an IsTerminal() bool method like StreamHandler's.
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+var defaultLevelColors = map[LogLever]*color.Color{
+	LevelDebug: color.New(color.FgCyan),
+	LevelInfo:  color.New(color.FgGreen),
+	LevelWarn:  color.New(color.FgYellow),
+	LevelError: color.New(color.FgRed),
+	LevelFatal: color.New(color.FgRed, color.Bold),
+	LevelPanic: color.New(color.FgRed, color.Bold),
+}
+
+// SetColor sets when level names are colored. The default is ColorAuto.
+func (l *Logger) SetColor(mode ColorMode) {
+	l.colorMode.Store(int32(mode))
+
+	l.state.RLock()
+	h := l.handler
+	l.state.RUnlock()
+	l.updateColor(h)
+}
+
+// updateColor decides whether to color from the color mode and h, the
+// logger's handler.
+func (l *Logger) updateColor(h Handler) {
+	on := false
+	switch ColorMode(l.colorMode.Load()) {
+	case ColorAlways:
+		on = true
+	case ColorAuto:
+		t, ok := h.(interface{ IsTerminal() bool })
+		on = ok && t.IsTerminal()
+	}
+	l.colorOn.Store(on)
+}
+
+// SetLevelColor overrides the color of a level name, e.g.
+//
+//	l.SetLevelColor(log.LevelWarn, color.New(color.FgMagenta))
+//
+// A nil c restores the default color.
+func (l *Logger) SetLevelColor(level LogLever, c *color.Color) {
+	l.Lock()
+	defer l.Unlock()
+
+	colors := make(map[LogLever]*color.Color, len(defaultLevelColors))
+	if cur := l.levelColors.Load(); cur != nil {
+		for k, v := range *cur {
+			colors[k] = v
+		}
+	}
+	if c == nil {
+		delete(colors, level)
+	} else {
+		colors[level] = c
+	}
+	l.levelColors.Store(&colors)
+}
+
+// levelColor returns the color for level, or nil when coloring is off.
+func (l *Logger) levelColor(level LogLever) *color.Color {
+	if !l.colorOn.Load() {
+		return nil
+	}
+	if colors := l.levelColors.Load(); colors != nil {
+		if c, ok := (*colors)[level]; ok {
+			return c
+		}
+	}
+	return defaultLevelColors[level]
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// terminalHandler is a MemoryHandler claiming to write to a terminal.
+type terminalHandler struct{ *MemoryHandler }
+
+func (terminalHandler) IsTerminal() bool { return true }
+
+// coloredLine logs "msg" at level to h with mode, after f configured the
+// logger.
+func coloredLine(t *testing.T, h Handler, mode ColorMode, level LogLever, f func(l *Logger)) {
+	t.Helper()
+	l := New(h)
+	l.SetLevel(LevelAll)
+	l.SetFlags(Llevel)
+	l.SetColor(mode)
+	if f != nil {
+		f(l)
+	}
+	l.Output(1, level, "msg")
+	l.Close()
+}
+
+func TestColorMode(t *testing.T) {
+	green := color.New(color.FgGreen).Sprint("INFO")
+	tests := []struct {
+		name    string
+		h       func(m *MemoryHandler) Handler
+		mode    ColorMode
+		colored bool
+	}{
+		{"auto to a file", func(m *MemoryHandler) Handler { return m }, ColorAuto, false},
+		{"auto to a terminal", func(m *MemoryHandler) Handler { return terminalHandler{m} }, ColorAuto, true},
+		{"always", func(m *MemoryHandler) Handler { return m }, ColorAlways, true},
+		{"never to a terminal", func(m *MemoryHandler) Handler { return terminalHandler{m} }, ColorNever, false},
+	}
+	for _, tt := range tests {
+		m := NewMemoryHandler()
+		coloredLine(t, tt.h(m), tt.mode, LevelInfo, nil)
+		want := "INFO - msg"
+		if tt.colored {
+			want = green + " - msg"
+		}
+		if got := m.Entries()[0].Line; got != want {
+			t.Errorf("%s: line = %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestColorAutoFollowsHandler(t *testing.T) {
+	m := NewMemoryHandler()
+	l := New(m)
+	l.SetFlags(Llevel)
+	l.SetHandler(terminalHandler{m})
+	l.Info("to the terminal")
+	l.SetHandler(m)
+	l.Info("to the file")
+	l.Close()
+
+	entries := m.Entries()
+	if len(entries) != 2 || !strings.Contains(entries[0].Line, "\x1b[") || strings.Contains(entries[1].Line, "\x1b[") {
+		t.Errorf("entries = %q", entries)
+	}
+}
+
+func TestStreamHandlerIsTerminal(t *testing.T) {
+	h, _ := NewStreamHandler(new(bytes.Buffer))
+	if h.IsTerminal() {
+		t.Error("a buffer is not a terminal")
+	}
+}
+
+func TestSetLevelColor(t *testing.T) {
+	magenta := color.New(color.FgMagenta)
+	m := NewMemoryHandler()
+	coloredLine(t, m, ColorAlways, LevelWarn, func(l *Logger) {
+		l.SetLevelColor(LevelWarn, magenta)
+	})
+	if got, want := m.Entries()[0].Line, magenta.Sprint("WARN")+" - msg"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+
+	m = NewMemoryHandler()
+	coloredLine(t, m, ColorAlways, LevelWarn, func(l *Logger) {
+		l.SetLevelColor(LevelWarn, magenta)
+		l.SetLevelColor(LevelWarn, nil)
+	})
+	if got, want := m.Entries()[0].Line, color.New(color.FgYellow).Sprint("WARN")+" - msg"; got != want {
+		t.Errorf("line after restoring the default = %q, want %q", got, want)
+	}
+}
+
+func TestDebugLabeled(t *testing.T) {
+	for _, mode := range []ColorMode{ColorNever, ColorAlways} {
+		m := NewMemoryHandler()
+		coloredLine(t, m, mode, LevelDebug, nil)
+		if got := m.Entries()[0].Line; !strings.Contains(got, "DEBUG") {
+			t.Errorf("mode %d: line = %q, want the DEBUG label", mode, got)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+const gzSuffix = ".gz"
+
+// compressFile writes path+".gz" and removes path. The compressed file is
+// written under a temporary name and synced before being renamed into
+// place, and path is only removed after that, so an interrupted compression
+// leaves the original intact.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + gzSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+gzSuffix)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
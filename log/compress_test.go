@@ -0,0 +1,111 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gunzip returns the uncompressed content of the gzip file at path.
+func gunzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompressFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.1")
+	content := strings.Repeat("line\n", 1000)
+	os.WriteFile(path, []byte(content), 0666)
+	if err := compressFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := gunzip(t, path+".gz"); got != content {
+		t.Errorf("decompressed %d bytes, want %d", len(got), len(content))
+	}
+	if got := strings.Join(listDir(t, filepath.Dir(path)), " "); got != "app.log.1.gz" {
+		t.Errorf("files = %s, want only the compressed one", got)
+	}
+}
+
+func TestCompressFileFailureKeepsOriginal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.1")
+	os.WriteFile(path, []byte("precious\n"), 0666)
+	// The temporary file cannot be created where a directory stands.
+	os.Mkdir(path+".gz.tmp", 0777)
+	if err := compressFile(path); err == nil {
+		t.Fatal("compressFile succeeded")
+	}
+	if got := readFile(t, path); got != "precious\n" {
+		t.Errorf("original = %q", got)
+	}
+	if _, err := os.Stat(path + ".gz"); !os.IsNotExist(err) {
+		t.Errorf("a compressed file was left: %v", err)
+	}
+}
+
+func TestRotatingFileHandlerCompress(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	h, err := NewRotatingFileHandler(base, 10, 3, WithCompress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first line\n", "second line\n", "third line\n"} {
+		h.Write([]byte(line))
+	}
+	h.Close()
+
+	want := "app.log app.log.1.gz app.log.2.gz"
+	if got := strings.Join(listDir(t, dir), " "); got != want {
+		t.Fatalf("files = %s, want %s", got, want)
+	}
+	if got := gunzip(t, base+".2.gz"); got != "first line\n" {
+		t.Errorf("app.log.2.gz = %q", got)
+	}
+	if got := gunzip(t, base+".1.gz"); got != "second line\n" {
+		t.Errorf("app.log.1.gz = %q", got)
+	}
+	if got := readFile(t, base); got != "third line\n" {
+		t.Errorf("app.log = %q", got)
+	}
+}
+
+func TestTimeRotatingFileHandlerCompress(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	now := time.Date(2025, 1, 10, 9, 59, 0, 0, time.Local)
+	h, err := NewTimeRotatingFileHandler(base, WhenHour, 1, WithCompress(), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("09:59\n"))
+	now = now.Add(time.Minute)
+	h.Write([]byte("10:00\n"))
+	// Close waits for the compression started by the rotation.
+	h.Close()
+
+	want := "app.log app.log2025-01-10_09.gz"
+	if got := strings.Join(listDir(t, dir), " "); got != want {
+		t.Fatalf("files = %s, want %s", got, want)
+	}
+	if got := gunzip(t, base+"2025-01-10_09.gz"); got != "09:59\n" {
+		t.Errorf("rotated file = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestPanic(t *testing.T) {
+	h := NewMemoryHandler()
+	// The slow handler leaves the line queued unless Panic waits for it.
+	l := New(slowHandler{h, 20 * time.Millisecond})
+	defer l.Close()
+	// The default mask of New, info only, does not hide the panic.
+	l.SetFlags(Llevel)
+	defer func() {
+		if r := recover(); r != "bad state 42" {
+			t.Errorf("recovered %v", r)
+		}
+		if entries := h.Entries(); len(entries) != 2 || entries[1].Line != "PANIC - bad state 42" {
+			t.Errorf("entries = %v", entries)
+		}
+	}()
+	l.Info("before")
+	l.Panic("bad state %d", 42)
+}
+
+func TestPanicDefaultLogger(t *testing.T) {
+	old := StdLogger()
+	defer defLoger.Store(old)
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetLevel(LevelAll)
+	defLoger.Store(l)
+	defer l.Close()
+
+	defer func() {
+		if r := recover(); r != "oops" || !h.Contains("PANIC") || !h.Contains("oops") {
+			t.Errorf("recovered %v with %v", r, h.Entries())
+		}
+	}()
+	Panic("oops")
+}
+
+// TestFatal runs itself in a child process, which logs with Fatal through a
+// slow file handler and must exit with status 1 after writing the line.
+func TestFatal(t *testing.T) {
+	if path := os.Getenv("LOG_TEST_FATAL"); path != "" {
+		fh, err := NewFileHandler(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+		if err != nil {
+			os.Exit(2)
+		}
+		l := New(fh)
+		l.SetLevel(LevelInfo | LevelWarn | LevelError)
+		defLoger.Store(l)
+		for i := 0; i < 100; i++ {
+			Info("queued %d", i)
+		}
+		Fatal("giving up: %s", "disk full")
+		os.Exit(3)
+	}
+
+	path := filepath.Join(t.TempDir(), "fatal.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatal$")
+	cmd.Env = append(os.Environ(), "LOG_TEST_FATAL="+path)
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("child exited with %v, want status 1", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 101 || !strings.Contains(lines[100], "FATAL - fatal_test.go:[") ||
+		!strings.HasSuffix(lines[100], "] - giving up: disk full") {
+		t.Errorf("%d lines, last %q", len(lines), lines[len(lines)-1])
+	}
+}
+
+func TestFatalPanicColors(t *testing.T) {
+	l := New(NewMemoryHandler())
+	defer l.Close()
+	l.SetColor(ColorAlways)
+	for _, level := range []LogLever{LevelFatal, LevelPanic} {
+		if c := l.levelColor(level); !c.Equals(color.New(color.FgRed, color.Bold)) {
+			t.Errorf("%v is not bold red", level)
+		}
+	}
+}
+
+func TestFatalPanicAlwaysEnabled(t *testing.T) {
+	l := New(NewMemoryHandler())
+	defer l.Close()
+	for _, mask := range []LogLever{0, LevelInfo, LevelInfo | LevelWarn | LevelError} {
+		l.SetLevel(mask)
+		if !l.Enabled(LevelFatal) || !l.Enabled(LevelPanic) || !l.Enabled(LevelFatal|LevelPanic) {
+			t.Errorf("fatal or panic disabled by the mask %v", mask)
+		}
+		if l.Enabled(LevelPanic | LevelDebug) {
+			t.Errorf("debug enabled by the mask %v", mask)
+		}
+	}
+}
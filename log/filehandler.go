@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sync"
 	"time"
 )
 
 //FileHandler writes log to a file.
 type FileHandler struct {
+	mu sync.Mutex
 	fd *os.File
+
+	fileName string
 }
 
 func NewFileHandler(fileName string, flag int) (*FileHandler, error) {
@@ -24,31 +28,54 @@ func NewFileHandler(fileName string, flag int) (*FileHandler, error) {
 	h := new(FileHandler)
 
 	h.fd = f
+	h.fileName = fileName
 
 	return h, nil
 }
 
 func (h *FileHandler) Write(b []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.fd.Write(b)
 }
 
 func (h *FileHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.fd.Close()
 }
 
+//Sync commits the file to stable storage.
+func (h *FileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.fd.Sync()
+}
+
+//Reopen reopens the file by name, for use after it was moved away by an external
+//tool such as logrotate. The file is reopened in append mode.
+func (h *FileHandler) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return reopen(&h.fd, h.fileName)
+}
+
 //RotatingFileHandler writes log a file, if file size exceeds maxBytes, 
 //it will backup current file and open a new one.
 //
 //max backup file number is set by backupCount, it will delete oldest if backups too many.
 type RotatingFileHandler struct {
+	mu sync.Mutex
 	fd *os.File
 
 	fileName    string
 	maxBytes    int
 	backupCount int
+
+	opts *rotateOptions
 }
 
-func NewRotatingFileHandler(fileName string, maxBytes int, backupCount int) (*RotatingFileHandler, error) {
+func NewRotatingFileHandler(fileName string, maxBytes int, backupCount int, opts ...RotateOption) (*RotatingFileHandler, error) {
 	dir := path.Dir(fileName)
 	os.Mkdir(dir, 0777)
 
@@ -61,6 +88,7 @@ func NewRotatingFileHandler(fileName string, maxBytes int, backupCount int) (*Ro
 	h.fileName = fileName
 	h.maxBytes = maxBytes
 	h.backupCount = backupCount
+	h.opts = newRotateOptions(opts)
 
 	var err error
 	h.fd, err = os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -72,17 +100,38 @@ func NewRotatingFileHandler(fileName string, maxBytes int, backupCount int) (*Ro
 }
 
 func (h *RotatingFileHandler) Write(p []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	h.doRollover()
 	return h.fd.Write(p)
 }
 
+//Close waits for any in-flight compression, then closes the file.
 func (h *RotatingFileHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opts.wait()
 	if h.fd != nil {
 		return h.fd.Close()
 	}
 	return nil
 }
 
+//Sync commits the file to stable storage.
+func (h *RotatingFileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.fd.Sync()
+}
+
+//Reopen reopens the file by name, for use after it was moved away by an external
+//tool such as logrotate.
+func (h *RotatingFileHandler) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return reopen(&h.fd, h.fileName)
+}
+
 func (h *RotatingFileHandler) doRollover() {
 	f, err := h.fd.Stat()
 	if err != nil {
@@ -98,17 +147,26 @@ func (h *RotatingFileHandler) doRollover() {
 	if h.backupCount > 0 {
 		h.fd.Close()
 
+		// the backups are about to be renamed, so the previous compression
+		// must be finished
+		h.opts.wait()
+
 		for i := h.backupCount - 1; i > 0; i-- {
 			sfn := fmt.Sprintf("%s.%d", h.fileName, i)
 			dfn := fmt.Sprintf("%s.%d", h.fileName, i+1)
 
 			os.Rename(sfn, dfn)
+			os.Rename(sfn+gzSuffix, dfn+gzSuffix)
 		}
 
 		dfn := fmt.Sprintf("%s.1", h.fileName)
 		os.Rename(h.fileName, dfn)
 
 		h.fd, _ = os.OpenFile(h.fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+
+		h.opts.afterRotate(dfn, func() ([]rotatedFile, error) {
+			return sizeRotatedFiles(h.fileName)
+		})
 	}
 }
 
@@ -118,12 +176,20 @@ func (h *RotatingFileHandler) doRollover() {
 //refer: http://docs.python.org/2/library/logging.handlers.html.
 //same like python TimedRotatingFileHandler.
 type TimeRotatingFileHandler struct {
+	mu sync.Mutex
 	fd *os.File
 
-	baseName   string
-	interval   int64
-	suffix     string
-	rolloverAt int64
+	baseName string
+	when     int8
+	interval int
+	suffix   string
+
+	//periodStart is the start of the period the current file covers, rolloverAt
+	//the start of the next one. Both are aligned to the wall clock.
+	periodStart time.Time
+	rolloverAt  time.Time
+
+	opts *rotateOptions
 }
 
 const (
@@ -133,32 +199,44 @@ const (
 	WhenDay
 )
 
-func NewTimeRotatingFileHandler(baseName string, when int8, interval int) (*TimeRotatingFileHandler, error) {
+//NewTimeRotatingFileHandler rotates baseName every interval units of when. Periods are
+//aligned to the local wall clock: hourly files start at the top of the hour, daily
+//files at midnight, and a rotated file is suffixed with the start of the period it
+//covers, e.g. "2006-01-02_15" for hourly rotation.
+func NewTimeRotatingFileHandler(baseName string, when int8, interval int, opts ...RotateOption) (*TimeRotatingFileHandler, error) {
 	dir := path.Dir(baseName)
 	os.Mkdir(dir, 0777)
 
 	h := new(TimeRotatingFileHandler)
 
 	h.baseName = baseName
+	h.opts = newRotateOptions(opts)
 
 	switch when {
 	case WhenSecond:
-		h.interval = 1
 		h.suffix = "2006-01-02_15-04-05"
 	case WhenMinute:
-		h.interval = 60
 		h.suffix = "2006-01-02_15-04"
 	case WhenHour:
-		h.interval = 3600
 		h.suffix = "2006-01-02_15"
 	case WhenDay:
-		h.interval = 3600 * 24
 		h.suffix = "2006-01-02"
 	default:
 		return nil, fmt.Errorf("invalid when_rotate: %d", when)
 	}
 
-	h.interval = h.interval * int64(interval)
+	if interval < 1 {
+		interval = 1
+	}
+	h.when = when
+	h.interval = interval
+
+	if h.opts.symlink {
+		if err := h.openLinked(); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
 
 	var err error
 	h.fd, err = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -166,19 +244,40 @@ func NewTimeRotatingFileHandler(baseName string, when int8, interval int) (*Time
 		return nil, err
 	}
 
-	fInfo, _ := h.fd.Stat()
-	h.rolloverAt = fInfo.ModTime().Unix() + h.interval
+	//an existing file belongs to the period it was last written in
+	start := h.opts.now()
+	if fInfo, err := h.fd.Stat(); err == nil && fInfo.Size() > 0 {
+		start = fInfo.ModTime()
+	}
+	h.setPeriod(start)
 
 	return h, nil
 }
 
+func (h *TimeRotatingFileHandler) setPeriod(t time.Time) {
+	h.periodStart = periodStart(t, h.when, h.interval)
+	h.rolloverAt = nextPeriod(h.periodStart, h.when, h.interval)
+}
+
 func (h *TimeRotatingFileHandler) doRollover() {
 	//refer http://hg.python.org/cpython/file/2.7/Lib/logging/handlers.py
-	now := time.Now()
+	now := h.opts.now()
 
-	if h.rolloverAt <= now.Unix() {
-		fName := h.baseName + now.Format(h.suffix)
+	if !now.Before(h.rolloverAt) && h.opts.symlink {
+		fName := h.baseName + h.periodStart.Format(h.suffix)
+		h.fd.Close()
+		h.opts.wait()
+		h.setPeriod(now)
+		h.fd, _ = h.openPeriodFile()
+
+		h.opts.afterRotate(fName, func() ([]rotatedFile, error) {
+			files, err := timeRotatedFiles(h.baseName, h.suffix)
+			return withoutFile(files, h.baseName+h.periodStart.Format(h.suffix)), err
+		})
+	} else if !now.Before(h.rolloverAt) {
+		fName := h.baseName + h.periodStart.Format(h.suffix)
 		h.fd.Close()
+		h.opts.wait()
 		e := os.Rename(h.baseName, fName)
 		if e != nil {
 			panic(e)
@@ -186,15 +285,64 @@ func (h *TimeRotatingFileHandler) doRollover() {
 
 		h.fd, _ = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 
-		h.rolloverAt = time.Now().Unix() + h.interval
+		//after an idle spell the new file starts in the current period, skipping
+		//the empty ones in between
+		h.setPeriod(now)
+
+		h.opts.afterRotate(fName, func() ([]rotatedFile, error) {
+			return timeRotatedFiles(h.baseName, h.suffix)
+		})
 	}
 }
 
 func (h *TimeRotatingFileHandler) Write(b []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	h.doRollover()
 	return h.fd.Write(b)
 }
 
+//Close waits for any in-flight compression, then closes the file.
 func (h *TimeRotatingFileHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opts.wait()
 	return h.fd.Close()
 }
+
+//Sync commits the file to stable storage.
+func (h *TimeRotatingFileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.fd.Sync()
+}
+
+//SetMaxBackups keeps at most n rotated files, deleting the oldest after each
+//rotation. n <= 0 means no limit. Only files named like the handler's own backups
+//are considered.
+func (h *TimeRotatingFileHandler) SetMaxBackups(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opts.wait()
+	h.opts.maxBackups = n
+}
+
+//SetMaxAge deletes rotated files last modified more than d ago, checked after each
+//rotation. d <= 0 means no limit.
+func (h *TimeRotatingFileHandler) SetMaxAge(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opts.wait()
+	h.opts.maxAge = d
+}
+
+//Reopen reopens the file by name, for use after it was moved away by an external
+//tool such as logrotate.
+func (h *TimeRotatingFileHandler) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.opts.symlink {
+		return reopen(&h.fd, h.baseName+h.periodStart.Format(h.suffix))
+	}
+	return reopen(&h.fd, h.baseName)
+}
@@ -0,0 +1,95 @@
+package log
+
+import "strings"
+
+// Flags select the fields written by the text formatter, in the spirit of
+// the standard library's log flags. Fields are separated by " - ".
+const (
+	Ldate         = 1 << iota // the date: 2006/01/02
+	Ltime                     // the time: 15:04:05
+	Lmicroseconds             // microsecond resolution: 15:04:05.000000, assumes Ltime
+	Llongfile                 // full file path and line number: /a/b/c/d.go:[23]
+	Lshortfile                // final file name element and line number: d.go:[23]; overrides Llongfile
+	LUTC                      // timestamps in UTC rather than the local time zone
+	Llevel                    // the level name: INFO
+	Lfuncname                 // the calling function without its package: (Server.handleConn)
+
+	LstdFlags = Ldate | Ltime | Lshortfile | Llevel
+)
+
+// SetFlags sets the output flags. The default is LstdFlags. When none of
+// Lshortfile, Llongfile and Lfuncname is set the caller is not looked up at
+// all, which makes logging noticeably cheaper.
+func (l *Logger) SetFlags(flag int) {
+	l.flag.Store(int32(flag))
+}
+
+func (l *Logger) Flags() int {
+	return int(l.flag.Load())
+}
+
+// SetTimeFormat sets the time.Format layout of the timestamp, overriding the
+// layout implied by Ldate, Ltime and Lmicroseconds. An empty layout restores
+// the flag based one.
+func (l *Logger) SetTimeFormat(layout string) {
+	l.timeFormat.Store(&layout)
+}
+
+// timeLayout returns the timestamp layout for flag, or "" when no
+// timestamp is wanted.
+func (l *Logger) timeLayout(flag int) string {
+	if custom := l.timeFormat.Load(); custom != nil && *custom != "" {
+		return *custom
+	}
+	var layout string
+	if flag&Ldate != 0 {
+		layout = "2006/01/02"
+	}
+	if flag&(Ltime|Lmicroseconds) != 0 {
+		if layout != "" {
+			layout += " "
+		}
+		layout += "15:04:05"
+		if flag&Lmicroseconds != 0 {
+			layout += ".000000"
+		}
+	}
+	return layout
+}
+
+// wantsCaller reports whether flag asks for any caller information.
+func wantsCaller(flag int) bool {
+	return flag&(Lshortfile|Llongfile|Lfuncname) != 0
+}
+
+// setCaller fills the caller fields of r selected by r.Flags.
+func (r *Record) setCaller(fn, file string, line int) {
+	if r.Flags&(Lshortfile|Llongfile) != 0 {
+		r.File = callerFile(file, r.Flags)
+		r.Line = line
+	}
+	if r.Flags&Lfuncname != 0 {
+		r.Func = shortFuncName(fn)
+	}
+}
+
+// receiverReplacer strips the parentheses and star of method receivers.
+var receiverReplacer = strings.NewReplacer("(*", "", "(", "", ")", "")
+
+// shortFuncName turns "example.com/pkg.(*Server).handleConn" into
+// "Server.handleConn".
+func shortFuncName(fn string) string {
+	fn = fn[strings.LastIndexByte(fn, '/')+1:]
+	if i := strings.IndexByte(fn, '.'); i >= 0 {
+		fn = fn[i+1:]
+	}
+	return receiverReplacer.Replace(fn)
+}
+
+// callerFile trims file according to flag.
+func callerFile(file string, flag int) string {
+	if flag&Lshortfile != 0 {
+		return file[strings.LastIndexByte(file, '/')+1:]
+	}
+	return file
+}
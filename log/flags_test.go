@@ -0,0 +1,185 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loggedLine logs msg with flags set through f and returns the line.
+func loggedLine(t *testing.T, flags int, f func(l *Logger)) string {
+	t.Helper()
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(flags)
+	f(l)
+	l.Close()
+	entries := h.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v", entries)
+	}
+	return entries[0].Line
+}
+
+// here returns the line it is called from.
+func here() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+type testServer struct {
+	l    *Logger
+	line int
+}
+
+func (s *testServer) handleConn() { s.line = here(); s.l.Info("conn") }
+
+func TestFuncName(t *testing.T) {
+	s := new(testServer)
+	got := loggedLine(t, Llevel|Lshortfile|Lfuncname, func(l *Logger) {
+		s.l = l
+		s.handleConn()
+	})
+	want := fmt.Sprintf("INFO - flags_test.go:[%d] - (testServer.handleConn) - conn", s.line)
+	if got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+
+	got = loggedLine(t, Lfuncname, func(l *Logger) { l.Info("closure") })
+	if got != "(TestFuncName.func2) - closure" {
+		t.Errorf("line = %q", got)
+	}
+}
+
+func TestShortFuncName(t *testing.T) {
+	tests := map[string]string{
+		"example.com/pkg.(*Server).handleConn":  "Server.handleConn",
+		"example.com/pkg.Server.handleConn":     "Server.handleConn",
+		"example.com/a/b.TestX.func1":           "TestX.func1",
+		"main.main":                             "main",
+		"github.com/x/y.(*T[...]).Method":       "T[...].Method",
+		"example.com/pkg.(*Server).serve.func2": "Server.serve.func2",
+	}
+	for in, want := range tests {
+		if got := shortFuncName(in); got != want {
+			t.Errorf("shortFuncName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// logHelper wraps the logger the way helper packages do.
+func logHelper(l *Logger, msg string) {
+	l.OutputDepth(1, LevelInfo, "%s", msg)
+}
+
+func TestOutputDepth(t *testing.T) {
+	var line int
+	got := loggedLine(t, Lshortfile, func(l *Logger) {
+		line = here() + 1
+		logHelper(l, "helped")
+	})
+	if want := fmt.Sprintf("flags_test.go:[%d] - helped", line); got != want {
+		t.Errorf("line = %q, want the helper's caller %q", got, want)
+	}
+}
+
+func TestNoCallerLookup(t *testing.T) {
+	got := loggedLine(t, Llevel, func(l *Logger) { l.Info("quick") })
+	if got != "INFO - quick" {
+		t.Errorf("line = %q, want no caller fields", got)
+	}
+}
+
+func TestFlagsLayout(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	tests := []struct {
+		flags int
+		// pattern matches the line, with FILE standing for the file name.
+		pattern string
+	}{
+		// The default keeps the historical format.
+		{LstdFlags, `^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d - INFO - FILE:\[\d+\] - msg$`},
+		{Ldate, `^\d{4}/\d\d/\d\d - msg$`},
+		{Ltime, `^\d\d:\d\d:\d\d - msg$`},
+		{Ltime | Lmicroseconds, `^\d\d:\d\d:\d\d\.\d{6} - msg$`},
+		{Lmicroseconds, `^\d\d:\d\d:\d\d\.\d{6} - msg$`},
+		{Llongfile, `^` + regexp.QuoteMeta(file) + `:\[\d+\] - msg$`},
+		{Llongfile | Lshortfile, `^flags_test.go:\[\d+\] - msg$`},
+		{0, `^msg$`},
+	}
+	for _, tt := range tests {
+		got := loggedLine(t, tt.flags, func(l *Logger) { l.Info("msg") })
+		pattern := strings.Replace(tt.pattern, "FILE", regexp.QuoteMeta(filepath.Base(file)), 1)
+		if !regexp.MustCompile(pattern).MatchString(got) {
+			t.Errorf("flags %b: line = %q, want a match for %s", tt.flags, got, pattern)
+		}
+	}
+}
+
+func TestSetTimeFormat(t *testing.T) {
+	const layout = time.RFC3339
+	got := loggedLine(t, Ldate|Llevel|LUTC, func(l *Logger) {
+		l.SetTimeFormat(layout)
+		l.Info("custom")
+	})
+	ts, rest, _ := strings.Cut(got, " - ")
+	at, err := time.Parse(layout, ts)
+	if err != nil || rest != "INFO - custom" {
+		t.Fatalf("line = %q: %v", got, err)
+	}
+	if _, offset := at.Zone(); offset != 0 || !strings.HasSuffix(ts, "Z") {
+		t.Errorf("timestamp %q is not in UTC", ts)
+	}
+	if d := time.Since(at); d < 0 || d > time.Minute {
+		t.Errorf("timestamp %v is off by %v", at, d)
+	}
+
+	// The custom layout applies even when the flags ask for no timestamp,
+	// and an empty layout goes back to the flags.
+	got = loggedLine(t, 0, func(l *Logger) {
+		l.SetTimeFormat("2006")
+		l.Info("year")
+	})
+	if want := fmt.Sprintf("%d - year", time.Now().Year()); got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+	got = loggedLine(t, Llevel, func(l *Logger) {
+		l.SetTimeFormat("2006")
+		l.SetTimeFormat("")
+		l.Info("none")
+	})
+	if got != "INFO - none" {
+		t.Errorf("line = %q after resetting the layout", got)
+	}
+}
+
+func TestLocalTimeByDefault(t *testing.T) {
+	l := New(NewMemoryHandler())
+	defer l.Close()
+	if r := l.newRecord(LevelInfo, time.Now()); r.Time.Location() != time.Local {
+		t.Errorf("record time in %v, want Local", r.Time.Location())
+	}
+	l.SetFlags(LstdFlags | LUTC)
+	if r := l.newRecord(LevelInfo, time.Now()); r.Time.Location() != time.UTC {
+		t.Errorf("record time in %v with LUTC", r.Time.Location())
+	}
+}
+
+func benchmarkOutput(b *testing.B, flags int) {
+	l := New(new(NullHandler))
+	defer l.Close()
+	l.SetFlags(flags)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("request %d", i)
+	}
+}
+
+func BenchmarkOutputNoCaller(b *testing.B)  { benchmarkOutput(b, Ldate|Ltime|Llevel) }
+func BenchmarkOutputShortFile(b *testing.B) { benchmarkOutput(b, LstdFlags) }
+func BenchmarkOutputFuncName(b *testing.B)  { benchmarkOutput(b, LstdFlags|Lfuncname) }
@@ -0,0 +1,157 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// Record is a single log event as passed to a Formatter.
+type Record struct {
+	Time  time.Time
+	Level LogLever
+	File  string
+	Line  int
+	Func  string
+	// Msg is the formatted message without its trailing newline.
+	Msg string
+	// Stack is a stack trace attached by ErrorStack, one line per entry,
+	// without a trailing newline.
+	Stack string
+
+	// Flags are the Logger's output flags. File and Line are only set when
+	// they include Lshortfile or Llongfile, Func only with Lfuncname.
+	Flags int
+	// TimeFormat is the layout the text output uses for Time, "" for no
+	// timestamp.
+	TimeFormat string
+	// Color is the color of the level name, nil when the output must not be
+	// colored.
+	Color *color.Color
+}
+
+// Formatter turns a Record into the bytes written to the Handler. Format
+// appends to buf, which may be reused between calls, and returns the
+// extended slice; the result should end with a newline.
+type Formatter interface {
+	Format(buf []byte, r *Record) []byte
+}
+
+// TextFormatter is the default formatter. With LstdFlags it writes
+//
+//	2006/01/02 15:04:05 - INFO - file.go:[12] - message
+//
+// and other flags drop or change the fields before the message. The level
+// name is colored according to the Logger's ColorMode. A stack trace follows
+// the message on its own lines.
+type TextFormatter struct{}
+
+func NewTextFormatter() *TextFormatter {
+	return &TextFormatter{}
+}
+
+func (f *TextFormatter) Format(buf []byte, r *Record) []byte {
+	if r.TimeFormat != "" {
+		buf = r.Time.AppendFormat(buf, r.TimeFormat)
+		buf = append(buf, " - "...)
+	}
+	if r.Flags&Llevel != 0 {
+		if r.Color != nil {
+			buf = append(buf, r.Color.Sprint(strings.ToUpper(r.Level.String()))...)
+		} else {
+			buf = append(buf, strings.ToUpper(r.Level.String())...)
+		}
+		buf = append(buf, " - "...)
+	}
+	if r.File != "" {
+		buf = append(buf, r.File...)
+		buf = append(buf, ":["...)
+		buf = strconv.AppendInt(buf, int64(r.Line), 10)
+		buf = append(buf, "] - "...)
+	}
+	if r.Func != "" {
+		buf = append(buf, '(')
+		buf = append(buf, r.Func...)
+		buf = append(buf, ") - "...)
+	}
+	buf = append(buf, r.Msg...)
+	if r.Stack != "" {
+		buf = append(buf, '\n')
+		buf = append(buf, r.Stack...)
+	}
+	return append(buf, '\n')
+}
+
+// JSONFormatter writes one JSON object per line with the keys ts, level,
+// file, line, func, msg and stack, suitable for newline-delimited JSON ingestion. It
+// never emits color codes. Only LUTC and the caller flags affect it; file,
+// line and func are left out when not requested, stack when empty.
+type JSONFormatter struct{}
+
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+func (f *JSONFormatter) Format(buf []byte, r *Record) []byte {
+	buf = append(buf, `{"ts":"`...)
+	buf = r.Time.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, `","level":`...)
+	buf = appendJSONString(buf, strings.ToUpper(r.Level.String()))
+	if r.File != "" {
+		buf = append(buf, `,"file":`...)
+		buf = appendJSONString(buf, r.File)
+		buf = append(buf, `,"line":`...)
+		buf = strconv.AppendInt(buf, int64(r.Line), 10)
+	}
+	if r.Func != "" {
+		buf = append(buf, `,"func":`...)
+		buf = appendJSONString(buf, r.Func)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, r.Msg)
+	if r.Stack != "" {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, r.Stack)
+	}
+	return append(buf, "}\n"...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string. Invalid UTF-8 is
+// replaced with U+FFFD so the output is always valid JSON.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, "\ufffd"...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
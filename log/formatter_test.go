@@ -0,0 +1,182 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// jsonLine is a line written by JSONFormatter.
+type jsonLine struct {
+	TS    string `json:"ts"`
+	Level string `json:"level"`
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Func  string `json:"func"`
+	Msg   string `json:"msg"`
+	Stack string `json:"stack"`
+}
+
+func TestJSONFormatter(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFormatter(NewJSONFormatter())
+	l.SetLevel(LevelAll)
+	// Colors are forced on, and must still stay out of the JSON.
+	green := color.New(color.FgGreen)
+	green.EnableColor()
+	l.SetColor(ColorAlways)
+	l.SetLevelColor(LevelInfo, green)
+
+	line := here() + 1
+	l.Info("quote \" backslash \\ newline \n tab \t bell \a bad \xff é")
+	l.SetFlags(Llevel)
+	l.Warn("no caller")
+	l.Close()
+
+	entries := h.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v", entries)
+	}
+	var got jsonLine
+	if err := json.Unmarshal([]byte(entries[0].Line), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", entries[0].Line, err)
+	}
+	if strings.Contains(entries[0].Line, "\x1b[") {
+		t.Errorf("color codes in %q", entries[0].Line)
+	}
+	if got.Level != "INFO" || got.File != "formatter_test.go" || got.Line != line {
+		t.Errorf("decoded %+v", got)
+	}
+	if want := "quote \" backslash \\ newline \n tab \t bell \a bad � é"; got.Msg != want {
+		t.Errorf("msg = %q, want %q", got.Msg, want)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, got.TS); err != nil {
+		t.Errorf("ts %q: %v", got.TS, err)
+	}
+
+	// Fields not asked for by the flags are left out.
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(entries[1].Line), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"file", "line", "func", "stack"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("%s present in %q", key, entries[1].Line)
+		}
+	}
+	if fields["level"] != "WARN" || fields["msg"] != "no caller" {
+		t.Errorf("decoded %v", fields)
+	}
+}
+
+func TestJSONFormatterUTC(t *testing.T) {
+	r := Record{Time: time.Date(2025, 1, 10, 9, 30, 0, 5, time.UTC), Level: LevelError, Msg: "x"}
+	got := string(NewJSONFormatter().Format(nil, &r))
+	if want := `{"ts":"2025-01-10T09:30:00.000000005Z","level":"ERROR","msg":"x"}` + "\n"; got != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+}
+
+func TestTextFormatter(t *testing.T) {
+	at := time.Date(2025, 1, 10, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		r    Record
+		want string
+	}{
+		{
+			Record{Time: at, Level: LevelInfo, File: "a.go", Line: 12, Msg: "hi", Flags: LstdFlags, TimeFormat: TimeFormat},
+			"2025/01/10 09:30:00 - INFO - a.go:[12] - hi\n",
+		},
+		{Record{Level: LevelWarn, Msg: "hi", Flags: Llevel}, "WARN - hi\n"},
+		{Record{Level: LevelWarn, Func: "S.f", Msg: "hi"}, "(S.f) - hi\n"},
+		{Record{Level: LevelError, Msg: "boom", Stack: "main.f\n\ta.go:1"}, "boom\nmain.f\n\ta.go:1\n"},
+	}
+	for _, tt := range tests {
+		if got := string(NewTextFormatter().Format(nil, &tt.r)); got != tt.want {
+			t.Errorf("Format = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSetFormatterNil(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(Llevel)
+	l.SetFormatter(NewJSONFormatter())
+	l.SetFormatter(nil)
+	l.Info("text again")
+	l.Close()
+	if entries := h.Entries(); len(entries) != 1 || entries[0].Line != "INFO - text again" {
+		t.Errorf("entries = %v", entries)
+	}
+}
+
+func TestJSONFormatterRotatingFile(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2025, 1, 10, 9, 59, 0, 0, time.Local)
+	fh, err := NewTimeRotatingFileHandler(base, WhenHour, 1, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(fh)
+	l.SetFormatter(NewJSONFormatter())
+	l.Info("before")
+	l.Flush()
+	now = now.Add(time.Minute)
+	l.Info("after")
+	l.Close()
+
+	for name, msg := range map[string]string{"app.log2025-01-10_09": "before", "app.log": "after"} {
+		f, err := os.Open(filepath.Join(filepath.Dir(base), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var got jsonLine
+			if err := json.Unmarshal(sc.Bytes(), &got); err != nil || got.Msg != msg {
+				t.Errorf("%s: %q decoded to %+v, %v", name, sc.Text(), got, err)
+			}
+		}
+		f.Close()
+	}
+}
+
+func TestSetFormatterConcurrent(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			l.Info("line %d", i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			l.SetFormatter(NewJSONFormatter())
+		} else {
+			l.SetFormatter(nil)
+		}
+	}
+	<-done
+	l.Close()
+
+	// Every line is whole, in either format.
+	for _, e := range h.Entries() {
+		if !strings.HasPrefix(e.Line, "line ") && !json.Valid([]byte(e.Line)) {
+			t.Errorf("line = %q", e.Line)
+		}
+	}
+	if n := len(h.Entries()); n != 1000 {
+		t.Errorf("%d lines, want 1000", n)
+	}
+}
@@ -2,6 +2,9 @@ package log
 
 import (
 	"io"
+	"os"
+
+	"github.com/mattn/go-isatty"
 )
 
 //Handler writes logs to somewhere
@@ -31,6 +34,16 @@ func (h *StreamHandler) Close() error {
 	return nil
 }
 
+//IsTerminal reports whether the underlying writer is a terminal, which is
+//what ColorAuto checks.
+func (h *StreamHandler) IsTerminal() bool {
+	f, ok := h.w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
 //NullHandler does nothing, it discards anything.
 type NullHandler struct {
 }
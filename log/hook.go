@@ -0,0 +1,79 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Hook is notified of every record whose level is in Levels(), e.g. to count
+// errors or forward them to an error tracker.
+type Hook interface {
+	Levels() LogLever
+	Fire(level LogLever, msg string, t time.Time) error
+}
+
+// AddHook registers h. Hooks run on the logger's writer goroutine, after the
+// record has been written to the handler, in the order they were added, so
+// a slow hook delays the following records but never the caller. Errors
+// and panics from a hook are reported to stderr and otherwise ignored.
+func (l *Logger) AddHook(h Hook) {
+	l.updateHooks(func(hooks []Hook) []Hook {
+		return append(hooks, h)
+	})
+}
+
+// RemoveHook unregisters every registration of h. Hooks are compared with
+// ==, so h must be of a comparable type such as a pointer.
+func (l *Logger) RemoveHook(h Hook) {
+	l.updateHooks(func(hooks []Hook) []Hook {
+		kept := hooks[:0]
+		for _, hook := range hooks {
+			if hook != h {
+				kept = append(kept, hook)
+			}
+		}
+		return kept
+	})
+}
+
+// ClearHooks unregisters all hooks.
+func (l *Logger) ClearHooks() {
+	l.hooks.Store(nil)
+}
+
+// updateHooks replaces the hook list with fn applied to a copy of it.
+func (l *Logger) updateHooks(fn func([]Hook) []Hook) {
+	l.Lock()
+	defer l.Unlock()
+
+	var hooks []Hook
+	if cur := l.hooks.Load(); cur != nil {
+		hooks = append(hooks, *cur...)
+	}
+	hooks = fn(hooks)
+	l.hooks.Store(&hooks)
+}
+
+func (l *Logger) fireHooks(e *entry) {
+	hooks := l.hooks.Load()
+	if hooks == nil {
+		return
+	}
+	for _, h := range *hooks {
+		if h.Levels()&e.level != 0 {
+			fireHook(h, e)
+		}
+	}
+}
+
+func fireHook(h Hook, e *entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "log: hook %T panicked: %v\n", h, r)
+		}
+	}()
+	if err := h.Fire(e.level, e.msg, e.t); err != nil {
+		fmt.Fprintf(os.Stderr, "log: hook %T: %v\n", h, err)
+	}
+}
@@ -0,0 +1,157 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookCalls records the calls of every recordingHook sharing it.
+type hookCalls struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *hookCalls) add(s string) {
+	c.mu.Lock()
+	c.calls = append(c.calls, s)
+	c.mu.Unlock()
+}
+
+func (c *hookCalls) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+type recordingHook struct {
+	name   string
+	levels LogLever
+	calls  *hookCalls
+	err    error
+	panics bool
+}
+
+func (h *recordingHook) Levels() LogLever { return h.levels }
+
+func (h *recordingHook) Fire(level LogLever, msg string, t time.Time) error {
+	if t.IsZero() {
+		return errors.New("zero time")
+	}
+	h.calls.add(fmt.Sprintf("%s:%v:%s", h.name, level, msg))
+	if h.panics {
+		panic("hook failure")
+	}
+	return h.err
+}
+
+func TestHooksOrderAndLevels(t *testing.T) {
+	l := New(NewMemoryHandler())
+	defer l.Close()
+	l.SetLevel(LevelAll)
+	calls := new(hookCalls)
+	l.AddHook(&recordingHook{name: "errors", levels: AtLeast(LevelError), calls: calls})
+	l.AddHook(&recordingHook{name: "all", levels: LevelAll, calls: calls})
+
+	l.Info("started")
+	l.Error("failed")
+	l.Flush()
+
+	want := []string{"all:info:started", "errors:error:failed", "all:error:failed"}
+	if got := calls.get(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestHookFailuresDoNotBreakLogging(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	defer l.Close()
+	calls := new(hookCalls)
+	l.AddHook(&recordingHook{name: "err", levels: LevelAll, calls: calls, err: errors.New("sentry down")})
+	l.AddHook(&recordingHook{name: "panic", levels: LevelAll, calls: calls, panics: true})
+	l.AddHook(&recordingHook{name: "ok", levels: LevelAll, calls: calls})
+
+	l.Info("one")
+	l.Info("two")
+	l.Flush()
+
+	if n := len(h.Entries()); n != 2 {
+		t.Errorf("%d lines written, want 2", n)
+	}
+	want := []string{"err:info:one", "panic:info:one", "ok:info:one", "err:info:two", "panic:info:two", "ok:info:two"}
+	if got := calls.get(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+// blockingHook holds the writer goroutine until release is closed.
+type blockingHook struct{ release chan struct{} }
+
+func (h blockingHook) Levels() LogLever { return LevelAll }
+
+func (h blockingHook) Fire(LogLever, string, time.Time) error {
+	<-h.release
+	return nil
+}
+
+func TestHooksRunOnWriterGoroutine(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	defer l.Close()
+	hook := blockingHook{make(chan struct{})}
+	l.AddHook(hook)
+
+	done := make(chan struct{})
+	go func() {
+		l.Info("first")
+		l.Info("second")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow hook blocked the caller")
+	}
+
+	// The record is written before its hooks run.
+	if !h.WaitFor(1, 5*time.Second) {
+		t.Fatal("the first line was not written")
+	}
+	if h.Contains("second") {
+		t.Error("the following record was written while a hook was running")
+	}
+	close(hook.release)
+	if !h.WaitFor(2, 5*time.Second) {
+		t.Error("the second line was not written")
+	}
+}
+
+func TestRemoveAndClearHooks(t *testing.T) {
+	l := New(NewMemoryHandler())
+	defer l.Close()
+	calls := new(hookCalls)
+	a := &recordingHook{name: "a", levels: LevelAll, calls: calls}
+	b := &recordingHook{name: "b", levels: LevelAll, calls: calls}
+	l.AddHook(a)
+	l.AddHook(b)
+	l.AddHook(a)
+
+	l.RemoveHook(a)
+	l.Info("one")
+	l.Flush()
+	l.ClearHooks()
+	l.Info("two")
+	// Hooks are looked up when the writer gets to a record.
+	l.Flush()
+	l.AddHook(a)
+	l.Info("three")
+	l.Flush()
+
+	want := []string{"b:info:one", "a:info:three"}
+	if got := calls.get(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
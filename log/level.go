@@ -0,0 +1,113 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// levelOrder lists the single levels from least to most severe.
+var levelOrder = []struct {
+	level LogLever
+	name  string
+}{
+	{LevelDebug, "debug"},
+	{LevelInfo, "info"},
+	{LevelWarn, "warn"},
+	{LevelError, "error"},
+	{LevelFatal, "fatal"},
+	{LevelPanic, "panic"},
+}
+
+// String returns the lower case name of a single level, "all" for LevelAll,
+// "none" for 0, and otherwise the names of the levels in the mask joined
+// with "|", e.g. "warn|error". ParseLevel accepts all of these forms.
+func (l LogLever) String() string {
+	switch l {
+	case 0:
+		return "none"
+	case LevelAll:
+		return "all"
+	}
+	var names []string
+	for _, o := range levelOrder {
+		if l&o.level != 0 {
+			names = append(names, o.name)
+			l &^= o.level
+		}
+	}
+	if l != 0 {
+		names = append(names, fmt.Sprintf("LogLever(%d)", int(l)))
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseLevel parses a level name as returned by String. Names are case
+// insensitive; "warning" is accepted for "warn".
+func ParseLevel(s string) (LogLever, error) {
+	var level LogLever
+	for _, name := range strings.Split(s, "|") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "all":
+			level |= LevelAll
+		case "none":
+		case "warning":
+			level |= LevelWarn
+		default:
+			found := false
+			for _, o := range levelOrder {
+				if o.name == name {
+					level |= o.level
+					found = true
+					break
+				}
+			}
+			if !found {
+				return 0, fmt.Errorf("log: invalid level %q", s)
+			}
+		}
+	}
+	return level, nil
+}
+
+// AtLeast returns the mask enabling level and every more severe level, so
+// that SetLevel(AtLeast(LevelWarn)) logs warnings, errors, fatals and
+// panics. For a mask the least severe level in it is used.
+func AtLeast(level LogLever) LogLever {
+	var mask LogLever
+	for _, o := range levelOrder {
+		if mask != 0 || level&o.level != 0 {
+			mask |= o.level
+		}
+	}
+	return mask
+}
+
+// alwaysLogged are the levels logged whatever the mask, since the process
+// exits or panics right after: their line must never be lost.
+const alwaysLogged = LevelFatal | LevelPanic
+
+// Enabled reports whether records at level are logged. It is cheap enough
+// to guard the construction of expensive arguments. LevelFatal and
+// LevelPanic are always enabled.
+func (l *Logger) Enabled(level LogLever) bool {
+	return (l.Level()|alwaysLogged)&level == level
+}
+
+// IsDebugEnabled reports whether debug records are logged, e.g.
+//
+//	if l.IsDebugEnabled() {
+//		l.Debug("%s", expensiveDump(obj))
+//	}
+func (l *Logger) IsDebugEnabled() bool {
+	return l.Enabled(LevelDebug)
+}
+
+// Enabled reports whether the default logger logs records at level.
+func Enabled(level LogLever) bool {
+	return StdLogger().Enabled(level)
+}
+
+// IsDebugEnabled reports whether the default logger logs debug records.
+func IsDebugEnabled() bool {
+	return StdLogger().IsDebugEnabled()
+}
@@ -0,0 +1,144 @@
+package log
+
+import "testing"
+
+func TestLevelStringRoundTrip(t *testing.T) {
+	levels := []LogLever{0, LevelAll, LevelWarn | LevelError, AtLeast(LevelInfo)}
+	for _, o := range levelOrder {
+		levels = append(levels, o.level)
+	}
+	for _, level := range levels {
+		s := level.String()
+		got, err := ParseLevel(s)
+		if err != nil || got != level {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", s, got, err, int(level))
+		}
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	tests := []struct {
+		level LogLever
+		want  string
+	}{
+		{LevelDebug, "debug"},
+		{LevelPanic, "panic"},
+		{0, "none"},
+		{LevelAll, "all"},
+		{LevelError | LevelWarn, "warn|error"},
+		{LevelInfo | 1<<10, "info|LogLever(1024)"},
+	}
+	for _, tt := range tests {
+		if got := tt.level.String(); got != tt.want {
+			t.Errorf("LogLever(%d).String() = %q, want %q", int(tt.level), got, tt.want)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in   string
+		want LogLever
+	}{
+		{"debug", LevelDebug},
+		{"INFO", LevelInfo},
+		{" Warn ", LevelWarn},
+		{"warning", LevelWarn},
+		{"error", LevelError},
+		{"fatal", LevelFatal},
+		{"panic", LevelPanic},
+		{"all", LevelAll},
+		{"ALL", LevelAll},
+		{"none", 0},
+		{"warn|Error", LevelWarn | LevelError},
+		{"info|all", LevelAll},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "loud", "warn|", "debug,info", "LogLever(1024)"} {
+		if _, err := ParseLevel(in); err == nil {
+			t.Errorf("ParseLevel(%q) should fail", in)
+		}
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	tests := []struct {
+		level LogLever
+		want  LogLever
+	}{
+		{LevelDebug, LevelAll},
+		{LevelInfo, LevelAll &^ LevelDebug},
+		{LevelWarn, LevelWarn | LevelError | LevelFatal | LevelPanic},
+		{LevelPanic, LevelPanic},
+		{LevelError | LevelWarn, LevelWarn | LevelError | LevelFatal | LevelPanic},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := AtLeast(tt.level); got != tt.want {
+			t.Errorf("AtLeast(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	l := New(NewMemoryHandler())
+	defer l.Close()
+	l.SetLevel(AtLeast(LevelWarn))
+	if l.Enabled(LevelInfo) || l.IsDebugEnabled() {
+		t.Error("info and debug should be disabled")
+	}
+	if !l.Enabled(LevelError) || !l.Enabled(LevelWarn|LevelFatal) {
+		t.Error("warn and above should be enabled")
+	}
+	if l.Enabled(LevelWarn | LevelDebug) {
+		t.Error("a mask is enabled only if all its levels are")
+	}
+}
+
+type dumpable struct{ fields [16]int }
+
+func TestDisabledDoesNotAllocate(t *testing.T) {
+	l := New(new(NullHandler))
+	defer l.Close()
+	l.SetLevel(AtLeast(LevelWarn))
+	obj := new(dumpable)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Debug("dump %v", obj)
+		l.Info("state %s", "ready")
+		if l.IsDebugEnabled() {
+			t.Fatal("debug is disabled")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("disabled logging allocated %v times per run", allocs)
+	}
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	l := New(new(NullHandler))
+	defer l.Close()
+	l.SetLevel(AtLeast(LevelError))
+	obj := new(dumpable)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Debug("dump %v", obj)
+	}
+}
+
+func BenchmarkNullHandler(b *testing.B) {
+	l := New(new(NullHandler))
+	defer l.Close()
+	l.SetFlags(0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("request %s", "done")
+	}
+}
@@ -5,12 +5,11 @@ import (
 	"io"
 	"os"
 	"runtime"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fatih/color"
-	"github.com/mattn/go-isatty"
 )
 
 type LogLever int
@@ -20,7 +19,9 @@ const (
 	LevelDebug LogLever = 1 << 1
 	LevelWarn  LogLever = 1 << 2
 	LevelError LogLever = 1 << 3
-	LevelAll   LogLever = LevelInfo | LevelDebug | LevelWarn | LevelError
+	LevelFatal LogLever = 1 << 4
+	LevelPanic LogLever = 1 << 5
+	LevelAll   LogLever = LevelInfo | LevelDebug | LevelWarn | LevelError | LevelFatal | LevelPanic
 )
 
 const TimeFormat = "2006/01/02 15:04:05"
@@ -30,36 +31,66 @@ const maxBufPoolSize = 16
 type Logger struct {
 	sync.Mutex
 
-	level LogLever
-	flag  int
+	level      atomic.Int32
+	flag       atomic.Int32
+	timeFormat atomic.Pointer[string]
+	formatter  atomic.Pointer[Formatter]
+
+	colorMode   atomic.Int32
+	colorOn     atomic.Bool
+	levelColors atomic.Pointer[map[LogLever]*color.Color]
+
+	hooks   atomic.Pointer[[]Hook]
+	sampler atomic.Pointer[Sampler]
+
+	overflow   atomic.Int32
+	dropped    atomic.Uint64
+	reported   uint64    // dropped count last reported, owned by run
+	reportedAt time.Time // owned by run
 
 	handler Handler
 
-	quit chan struct{}
-	msg  chan []byte
+	msg chan entry
 
 	bufs [][]byte
 
 	wg sync.WaitGroup
 
+	// state guards closed and the sends on msg, so that Close never
+	// closes msg under a sender.
+	state  sync.RWMutex
 	closed bool
 }
 
+// entry is a queued message. An entry with a non-nil done channel carries no
+// message; run closes done once everything queued before it was written.
+type entry struct {
+	buf  []byte
+	done chan struct{}
+
+	// level, msg and t are passed to the hooks.
+	level LogLever
+	msg   string
+	t     time.Time
+}
+
 func New(handler Handler) *Logger {
 	var l = new(Logger)
 
-	l.level = LevelInfo
+	l.level.Store(int32(LevelInfo))
+	l.flag.Store(LstdFlags)
+	l.SetColor(ColorAuto)
 	l.handler = handler
+	l.SetFormatter(nil)
 
-	l.quit = make(chan struct{})
 	l.closed = false
 
-	l.msg = make(chan []byte, 1024)
+	l.msg = make(chan entry, defaultQueueSize)
 
 	l.bufs = make([][]byte, 0, 16)
 
 	l.wg.Add(1)
-	go l.run()
+	go l.run(l.msg)
 
 	return l
 }
@@ -73,25 +104,50 @@ func newStdHandler() *StreamHandler {
 	return h
 }
 
-var defLoger = NewDefault(newStdHandler())
+// defLoger is the logger used by the package level functions.
+var defLoger atomic.Pointer[Logger]
 
 func Close() {
-	defLoger.Close()
+	StdLogger().Close()
 }
 
-func (l *Logger) run() {
+func (l *Logger) run(msg chan entry) {
 	defer l.wg.Done()
-	for {
-		select {
-		case msg := <-l.msg:
-			l.handler.Write(msg)
-			l.putBuf(msg)
-		case <-l.quit:
-			if len(l.msg) == 0 {
-				return
-			}
+	for e := range msg {
+		if e.done != nil {
+			close(e.done)
+			continue
 		}
+		l.reportDrops(false)
+		l.writeEntry(&e)
+		l.putBuf(e.buf)
+		l.fireHooks(&e)
+	}
+	l.reportDrops(true)
+}
+
+func (l *Logger) writeEntry(e *entry) {
+	if lh, ok := l.handler.(LeveledHandler); ok {
+		lh.WriteLevel(e.level, e.buf)
+	} else {
+		l.handler.Write(e.buf)
+	}
+}
+
+// send queues e and reports whether it was accepted, which it is not once
+// the logger is closed.
+func (l *Logger) send(e entry) bool {
+	l.state.RLock()
+	defer l.state.RUnlock()
+	if l.closed {
+		return false
 	}
+	if e.done != nil {
+		// flush markers are never dropped
+		l.msg <- e
+		return true
+	}
+	return l.enqueue(e)
 }
 
 func (l *Logger) popBuf() []byte {
@@ -117,113 +173,129 @@ func (l *Logger) putBuf(buf []byte) {
 	l.Unlock()
 }
 
+// Flush blocks until every message logged before the call has been handed
+// to the handler. It returns immediately once the logger is closed.
+func (l *Logger) Flush() {
+	done := make(chan struct{})
+	if l.send(entry{done: done}) {
+		<-done
+	}
+}
+
+// Close stops accepting messages, waits until everything already queued has
+// been written, then closes the handler. Logging after Close is a no-op and
+// closing twice is harmless.
 func (l *Logger) Close() {
+	l.state.Lock()
 	if l.closed {
+		l.state.Unlock()
 		return
 	}
 	l.closed = true
+	close(l.msg)
+	l.state.Unlock()
 
-	close(l.quit)
 	l.wg.Wait()
-	l.quit = nil
 
 	l.handler.Close()
 }
 
+// SetLevel sets the mask of levels that are logged. Fatal and Panic
+// records are logged even when the mask leaves them out. It is safe to call
+// while other goroutines are logging.
 func (l *Logger) SetLevel(level LogLever) {
-	l.level = level
+	l.level.Store(int32(level))
 }
 
 func (l *Logger) Level() LogLever {
-	return l.level
+	return LogLever(l.level.Load())
+}
+
+// SetFormatter changes how records are rendered before they reach the
+// handler. The default is a TextFormatter; a nil f restores it. It is safe
+// to call while other goroutines are logging.
+func (l *Logger) SetFormatter(f Formatter) {
+	if f == nil {
+		f = NewTextFormatter()
+	}
+	l.formatter.Store(&f)
+}
+
+// format renders r with the current formatter, appending to buf.
+func (l *Logger) format(buf []byte, r *Record) []byte {
+	return (*l.formatter.Load()).Format(buf, r)
 }
 
 func (l *Logger) Output(callDepth int, level LogLever, format string, v ...interface{}) {
-	if l.level&level != level {
+	if !l.Enabled(level) {
 		return
 	}
 
-	buf := l.popBuf()
+	r := l.callerRecord(callDepth, level)
 
-	buf = append(buf, time.Now().Format(TimeFormat)...)
-	buf = append(buf, " - "...)
+	r.Msg = fmt.Sprintf(format, v...)
+	if n := len(r.Msg); n > 0 && r.Msg[n-1] == '\n' {
+		r.Msg = r.Msg[:n-1]
+	}
 
-	buf = append(buf, l.colorLevel(level)...)
-	buf = append(buf, " - "...)
+	l.output(&r)
+}
 
-	/*pc*/
-	_, file, line, ok := runtime.Caller(callDepth)
-	if !ok {
-		file = "???"
-		line = 0
-	} else {
-		for i := len(file) - 1; i > 0; i-- {
-			if file[i] == '/' {
-				file = file[i+1:]
-				break
+// callerRecord is newRecord with the caller fields filled in. callDepth is
+// counted from the function calling callerRecord, as for Output.
+func (l *Logger) callerRecord(callDepth int, level LogLever) Record {
+	r := l.newRecord(level, time.Now())
+
+	if wantsCaller(r.Flags) {
+		fn, file, line := "???", "???", 0
+		if pc, f, ln, ok := runtime.Caller(callDepth + 1); ok {
+			file, line = f, ln
+			if fi := runtime.FuncForPC(pc); fi != nil {
+				fn = fi.Name()
 			}
 		}
+		r.setCaller(fn, file, line)
 	}
+	return r
+}
 
-	buf = append(buf, file...)
-	buf = append(buf, ":["...)
-
-	buf = strconv.AppendInt(buf, int64(line), 10)
-	buf = append(buf, "] - "...)
-
-	/*if pc != 0 {
-		f := runtime.FuncForPC(pc)
-		if f != nil {
-			funcNamePath := strings.Split(f.Name(), "/")
-			buf = append(buf, "["+funcNamePath[len(funcNamePath)-1]+"] - "...)
-		}
-	}*/
-
-	s := fmt.Sprintf(format, v...)
-
-	buf = append(buf, s...)
-
-	if len(s) == 0 || s[len(s)-1] != '\n' {
-		buf = append(buf, '\n')
+// newRecord returns a record with the fields derived from the logger's
+// settings filled in.
+func (l *Logger) newRecord(level LogLever, t time.Time) Record {
+	flag := l.Flags()
+	if flag&LUTC != 0 {
+		t = t.UTC()
+	}
+	return Record{
+		Time:       t,
+		Level:      level,
+		Flags:      flag,
+		TimeFormat: l.timeLayout(flag),
+		Color:      l.levelColor(level),
 	}
-
-	l.msg <- buf
 }
 
-func (l *Logger) colorLevel(level LogLever) string {
-
-	isTerm := true
-
-	var out io.Writer = os.Stdout
+// OutputDepth is like Output with the caller extraDepth frames above the
+// caller of OutputDepth, so helpers wrapping the logger can report their own
+// caller: a helper calling OutputDepth(1, ...) directly reports the line
+// that called the helper.
+func (l *Logger) OutputDepth(extraDepth int, level LogLever, format string, v ...interface{}) {
+	l.Output(2+extraDepth, level, format, v...)
+}
 
-	if w, ok := out.(*os.File); !ok || os.Getenv("TERM") == "dumb" ||
-		(!isatty.IsTerminal(w.Fd()) && !isatty.IsCygwinTerminal(w.Fd())) {
-		isTerm = false
+// output samples r, then formats it and queues it for the handler.
+func (l *Logger) output(r *Record) {
+	if l.sample(r) {
+		l.write(r)
 	}
+}
 
-	switch level {
-	case LevelDebug:
-		return "DEBUG"
-	case LevelInfo:
-		if isTerm {
-			return color.GreenString("INFO")
-		} else {
-			return "INFO"
-		}
-	case LevelWarn:
-		if isTerm {
-			return color.YellowString("WARN")
-		} else {
-			return "WARN"
-		}
-	case LevelError:
-		if isTerm {
-			return color.RedString("ERROR")
-		} else {
-			return "ERROR"
-		}
+func (l *Logger) write(r *Record) {
+	buf := l.format(l.popBuf(), r)
+
+	if !l.send(entry{buf: buf, level: r.Level, msg: r.Msg, t: r.Time}) {
+		l.putBuf(buf)
 	}
-	return ""
 }
 
 func (l *Logger) Debug(format string, v ...interface{}) {
@@ -242,66 +314,89 @@ func (l *Logger) Error(format string, v ...interface{}) {
 	l.Output(2, LevelError, format, v...)
 }
 
-func SetLevel(level LogLever) {
-	defLoger.SetLevel(level)
+// Fatal logs at LevelFatal, closes the logger so the message and everything
+// queued before it reach the handler, then calls os.Exit(1).
+func (l *Logger) Fatal(format string, v ...interface{}) {
+	l.Output(2, LevelFatal, format, v...)
+	l.Close()
+	os.Exit(1)
 }
 
-func SetLogFile(logFile string) {
-	if defLoger != nil {
-		defLoger.Close()
-	}
-
-	var h Handler
-
-	if len(logFile) != 0 {
-		var err error
-		h, err = NewTimeRotatingFileHandler(logFile, WhenDay, 1)
-		if err != nil {
-			fmt.Println(err.Error())
-			return
-		}
-	}
-
-	if h == nil {
-		h = newStdHandler()
-	}
+// Panic logs at LevelPanic, waits for the message to reach the handler, then
+// panics with the formatted message.
+func (l *Logger) Panic(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	l.Output(2, LevelPanic, "%s", s)
+	l.Flush()
+	panic(s)
+}
 
-	defLoger = NewDefault(h)
+func SetLevel(level LogLever) {
+	StdLogger().SetLevel(level)
 }
 
 func Debug(format string, v ...interface{}) {
-	defLoger.Output(2, LevelDebug, format, v...)
+	StdLogger().Output(2, LevelDebug, format, v...)
 }
 
 func DebugLine(format string, v ...interface{}) {
-	defLoger.Output(2, LevelDebug, format, v...)
+	StdLogger().Output(2, LevelDebug, format, v...)
 }
 
 func Info(format string, v ...interface{}) {
-	defLoger.Output(2, LevelInfo, format, v...)
+	StdLogger().Output(2, LevelInfo, format, v...)
 }
 
 func Warn(format string, v ...interface{}) {
-	defLoger.Output(2, LevelWarn, format, v...)
+	StdLogger().Output(2, LevelWarn, format, v...)
 }
 
 func Error(format string, v ...interface{}) {
-	defLoger.Output(2, LevelError, format, v...)
+	StdLogger().Output(2, LevelError, format, v...)
+}
+
+func Fatal(format string, v ...interface{}) {
+	l := StdLogger()
+	l.Output(2, LevelFatal, format, v...)
+	l.Close()
+	os.Exit(1)
+}
+
+func Panic(format string, v ...interface{}) {
+	l := StdLogger()
+	s := fmt.Sprintf(format, v...)
+	l.Output(2, LevelPanic, "%s", s)
+	l.Flush()
+	panic(s)
 }
 
 func Error2(err error) {
-	defLoger.Output(2, LevelError, "%v", err)
+	StdLogger().Output(2, LevelError, "%v", err)
 }
 
 func StdLogger() *Logger {
-	return defLoger
+	return defLoger.Load()
 }
 
 func GetLevel() LogLever {
-	return defLoger.level
+	return StdLogger().Level()
 }
 
 func init() {
 	color.NoColor = false
+	defLoger.Store(NewDefault(newStdHandler()))
 	SetLevel(LevelAll)
 }
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// Closer returns an io.Closer that flushes and closes the default logger,
+// for use with util.Shutdown. Closing it more than once is harmless.
+func Closer() io.Closer {
+	return closerFunc(func() error {
+		Close()
+		return nil
+	})
+}
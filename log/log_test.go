@@ -0,0 +1,136 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetLevelConcurrent(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				l.Info("hello %d", 1)
+				l.Debug("debug")
+				l.IsDebugEnabled()
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			l.SetLevel(LevelAll)
+		} else {
+			l.SetLevel(AtLeast(LevelWarn))
+		}
+		l.SetFlags(Lshortfile)
+		l.Level()
+	}
+	close(stop)
+	wg.Wait()
+
+	l.SetLevel(LevelError)
+	l.Info("after")
+	l.Flush()
+	if h.Contains("after") {
+		t.Error("the last SetLevel was not applied")
+	}
+}
+
+// slowHandler delays every write so messages pile up in the queue.
+type slowHandler struct {
+	*MemoryHandler
+	delay time.Duration
+}
+
+func (h slowHandler) WriteLevel(level LogLever, p []byte) (int, error) {
+	time.Sleep(h.delay)
+	return h.MemoryHandler.WriteLevel(level, p)
+}
+
+func TestCloseWritesEverythingQueued(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	const lines = 10000
+	for i := 0; i < lines; i++ {
+		l.Info("line %d", i)
+	}
+	l.Close()
+
+	entries := h.Entries()
+	if len(entries) != lines {
+		t.Fatalf("%d lines reached the handler, want %d", len(entries), lines)
+	}
+	if !strings.HasSuffix(entries[lines-1].Line, fmt.Sprintf("line %d", lines-1)) {
+		t.Errorf("last line = %q", entries[lines-1].Line)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	h := slowHandler{NewMemoryHandler(), time.Millisecond}
+	l := New(h)
+	defer l.Close()
+	for i := 0; i < 20; i++ {
+		l.Info("line %d", i)
+	}
+	l.Flush()
+	if n := len(h.Entries()); n != 20 {
+		t.Errorf("%d lines written after Flush, want 20", n)
+	}
+}
+
+func TestLoggingAfterClose(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.Info("before")
+	l.Close()
+
+	// None of these may panic or block.
+	l.Info("after")
+	l.Flush()
+	l.Close()
+	if h.Contains("after") {
+		t.Error("a line logged after Close was written")
+	}
+}
+
+func TestCloseWhileLogging(t *testing.T) {
+	l := New(slowHandler{NewMemoryHandler(), 10 * time.Microsecond})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				l.Info("line %d", j)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging goroutines blocked after Close")
+	}
+}
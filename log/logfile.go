@@ -0,0 +1,111 @@
+package log
+
+// SetHandler replaces the handler of l. Records queued before the call are
+// written to the old handler, which is then closed; later ones go to h.
+// Nothing is lost in between. On a closed logger it does nothing.
+func (l *Logger) SetHandler(h Handler) {
+	var old Handler
+	l.restart(0, func() {
+		old = l.handler
+		l.handler = h
+		l.updateColor(h)
+	})
+	// Setting the same handler again must not close it.
+	if old != nil && old != h {
+		old.Close()
+	}
+}
+
+// SetHandler replaces the handler of the default logger, keeping its level
+// and other settings. If the default logger was closed, it is replaced by a
+// new one with the same settings, hooks and sampler writing to h.
+func SetHandler(h Handler) {
+	l := StdLogger()
+	l.state.RLock()
+	closed := l.closed
+	l.state.RUnlock()
+
+	if !closed {
+		l.SetHandler(h)
+		return
+	}
+	nl := NewDefault(h)
+	nl.inherit(l)
+	defLoger.Store(nl)
+}
+
+// inherit copies the settings of old, a closed logger, to l.
+func (l *Logger) inherit(old *Logger) {
+	l.level.Store(old.level.Load())
+	l.flag.Store(old.flag.Load())
+	l.timeFormat.Store(old.timeFormat.Load())
+	l.formatter.Store(old.formatter.Load())
+	l.levelColors.Store(old.levelColors.Load())
+	l.hooks.Store(old.hooks.Load())
+	l.sampler.Store(old.sampler.Load())
+	l.overflow.Store(old.overflow.Load())
+	l.SetColor(ColorMode(old.colorMode.Load()))
+
+	old.state.RLock()
+	n := cap(old.msg)
+	old.state.RUnlock()
+	if n != defaultQueueSize {
+		l.SetQueueSize(n)
+	}
+}
+
+// LogFileOption configures SetLogFile.
+type LogFileOption func(*logFileOptions)
+
+type logFileOptions struct {
+	when     int8
+	interval int
+	rotate   []RotateOption
+}
+
+// WithRotation rotates the file every interval units of when (WhenSecond,
+// WhenMinute, WhenHour or WhenDay). The default is once a day.
+func WithRotation(when int8, interval int) LogFileOption {
+	return func(o *logFileOptions) {
+		o.when = when
+		o.interval = interval
+	}
+}
+
+// WithBackupCount keeps at most n rotated files.
+func WithBackupCount(n int) LogFileOption {
+	return WithRotateOptions(WithMaxBackups(n))
+}
+
+// WithRotateOptions passes options such as WithCompress to the rotating
+// file handler.
+func WithRotateOptions(opts ...RotateOption) LogFileOption {
+	return func(o *logFileOptions) {
+		o.rotate = append(o.rotate, opts...)
+	}
+}
+
+// SetLogFile makes the default logger write to logFile, rotated daily unless
+// configured otherwise, or to stdout if logFile is empty. It is safe to call
+// while other goroutines are logging. On error the default logger is left
+// unchanged.
+func SetLogFile(logFile string, opts ...LogFileOption) error {
+	o := logFileOptions{when: WhenDay, interval: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var h Handler
+	if len(logFile) != 0 {
+		var err error
+		h, err = NewTimeRotatingFileHandler(logFile, o.when, o.interval, o.rotate...)
+		if err != nil {
+			return err
+		}
+	} else {
+		h = newStdHandler()
+	}
+
+	SetHandler(h)
+	return nil
+}
@@ -0,0 +1,199 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeCountingHandler is a MemoryHandler counting the calls to Close.
+type closeCountingHandler struct {
+	*MemoryHandler
+	closes atomic.Int32
+}
+
+func (h *closeCountingHandler) Close() error {
+	h.closes.Add(1)
+	return nil
+}
+
+// useDefault makes l the default logger for the duration of the test.
+func useDefault(t *testing.T, l *Logger) {
+	old := StdLogger()
+	defLoger.Store(l)
+	t.Cleanup(func() {
+		StdLogger().Close()
+		defLoger.Store(old)
+	})
+}
+
+func TestSetHandlerKeepsEveryRecord(t *testing.T) {
+	first := &closeCountingHandler{MemoryHandler: NewMemoryHandler()}
+	second := &closeCountingHandler{MemoryHandler: NewMemoryHandler()}
+	l := New(first)
+
+	const writers, lines = 4, 500
+	var wg sync.WaitGroup
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				l.Info("line %d", i)
+			}
+		}()
+	}
+	l.SetHandler(second)
+	wg.Wait()
+	l.Close()
+
+	if n := len(first.Entries()) + len(second.Entries()); n != writers*lines {
+		t.Errorf("%d lines written, want %d", n, writers*lines)
+	}
+	if first.closes.Load() != 1 || second.closes.Load() != 1 {
+		t.Errorf("closes: old handler %d, new handler %d, want 1 each", first.closes.Load(), second.closes.Load())
+	}
+
+	// Setting the handler in use again does not close it.
+	l2 := New(first)
+	l2.SetHandler(first)
+	if first.closes.Load() != 1 {
+		t.Error("SetHandler closed the handler it was given")
+	}
+	l2.Close()
+
+	// Swapping the handler of a closed logger does nothing.
+	third := &closeCountingHandler{MemoryHandler: NewMemoryHandler()}
+	l.SetHandler(third)
+	if l.handler != second {
+		t.Error("SetHandler replaced the handler of a closed logger")
+	}
+}
+
+func TestSetHandlerDefault(t *testing.T) {
+	l := New(NewMemoryHandler())
+	l.SetLevel(LevelWarn | LevelError)
+	useDefault(t, l)
+
+	h := NewMemoryHandler()
+	SetHandler(h)
+	if StdLogger() != l {
+		t.Fatal("SetHandler replaced an open default logger")
+	}
+	Warn("to h")
+
+	// A closed default logger is replaced, keeping its level.
+	Close()
+	h2 := NewMemoryHandler()
+	SetHandler(h2)
+	if StdLogger() == l || GetLevel() != LevelWarn|LevelError {
+		t.Fatalf("default logger %p with level %v after SetHandler on a closed one", StdLogger(), GetLevel())
+	}
+	Warn("to h2")
+	Info("filtered")
+	StdLogger().Flush()
+	if !h.Contains("to h") || len(h2.Entries()) != 1 || !h2.Contains("to h2") {
+		t.Errorf("h %v, h2 %v", h.Entries(), h2.Entries())
+	}
+}
+
+func TestSetHandlerDefaultKeepsSettings(t *testing.T) {
+	l := New(NewMemoryHandler())
+	l.SetFlags(Lshortfile)
+	formatter := NewJSONFormatter()
+	l.SetFormatter(formatter)
+	l.SetColor(ColorNever)
+	l.SetOverflowPolicy(DropOldest)
+	l.SetQueueSize(8)
+	l.AddHook(&recordingHook{levels: LevelAll, calls: new(hookCalls)})
+	sampler := NewDedupSampler(time.Second, 1)
+	l.SetSampler(sampler)
+	useDefault(t, l)
+
+	Close()
+	SetHandler(NewMemoryHandler())
+	nl := StdLogger()
+	if nl == l {
+		t.Fatal("the closed default logger was kept")
+	}
+	if nl.Flags() != Lshortfile || *nl.formatter.Load() != Formatter(formatter) ||
+		ColorMode(nl.colorMode.Load()) != ColorNever || OverflowPolicy(nl.overflow.Load()) != DropOldest ||
+		cap(nl.msg) != 8 || *nl.sampler.Load() != Sampler(sampler) || len(*nl.hooks.Load()) != 1 {
+		t.Error("the settings of the closed default logger were lost")
+	}
+}
+
+func TestSetLogFile(t *testing.T) {
+	l := New(NewMemoryHandler())
+	useDefault(t, l)
+
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	if err := SetLogFile(path, WithBackupCount(3), WithRotateOptions(WithCompress())); err != nil {
+		t.Fatal(err)
+	}
+	Info("to the file")
+	l.Flush()
+	if got := readFile(t, path); got == "" {
+		t.Error("nothing written to the log file")
+	}
+	h := l.handler.(*TimeRotatingFileHandler)
+	if h.when != WhenDay || h.interval != 1 || h.opts.maxBackups != 3 || !h.opts.compress {
+		t.Errorf("handler %+v with options %+v", h, h.opts)
+	}
+
+	if err := SetLogFile(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.handler.(*StreamHandler); !ok {
+		t.Errorf("SetLogFile(\"\") installed %T, want stdout", l.handler)
+	}
+}
+
+func TestSetLogFileError(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	useDefault(t, l)
+
+	// A file stands where the directory should be.
+	blocker := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocker, nil, 0666)
+	if err := SetLogFile(filepath.Join(blocker, "app.log")); err == nil {
+		t.Fatal("SetLogFile succeeded")
+	}
+	if StdLogger() != l || l.handler != Handler(h) {
+		t.Error("a failed SetLogFile changed the default logger")
+	}
+}
+
+func TestSetLogFileConcurrent(t *testing.T) {
+	l := New(NewMemoryHandler())
+	useDefault(t, l)
+	dir := t.TempDir()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					Info("busy")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := SetLogFile(filepath.Join(dir, "app.log")); err != nil {
+			t.Error(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a line recorded by a MemoryHandler.
+type Entry struct {
+	Level LogLever
+	// Time is when the handler received the line.
+	Time time.Time
+	// Line is the formatted line without its trailing newline.
+	Line string
+}
+
+// MemoryHandler keeps every line in memory. It is the recommended way to
+// assert on log output in tests:
+//
+//	h := log.NewMemoryHandler()
+//	l := log.New(h)
+//	l.Info("hello")
+//	if !h.WaitFor(1, time.Second) || !h.Contains("hello") {
+//		t.Fatal("missing log line")
+//	}
+//
+// WaitFor copes with the Logger writing asynchronously, so tests need no
+// sleeps. The zero value is ready to use.
+type MemoryHandler struct {
+	mu      sync.Mutex
+	entries []Entry
+	// changed is made by WaitFor, and closed and cleared whenever an entry
+	// is added.
+	changed chan struct{}
+}
+
+func NewMemoryHandler() *MemoryHandler {
+	return new(MemoryHandler)
+}
+
+func (h *MemoryHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(0, p)
+}
+
+func (h *MemoryHandler) WriteLevel(level LogLever, p []byte) (n int, err error) {
+	line := string(bytes.TrimSuffix(p, []byte{'\n'}))
+
+	h.mu.Lock()
+	h.entries = append(h.entries, Entry{Level: level, Time: time.Now(), Line: line})
+	if h.changed != nil {
+		close(h.changed)
+		h.changed = nil
+	}
+	h.mu.Unlock()
+
+	return len(p), nil
+}
+
+func (h *MemoryHandler) Close() error {
+	return nil
+}
+
+// Entries returns a copy of the recorded entries, oldest first.
+func (h *MemoryHandler) Entries() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Entry(nil), h.entries...)
+}
+
+// Contains reports whether any recorded line contains substr.
+func (h *MemoryHandler) Contains(substr string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if strings.Contains(e.Line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset discards the recorded entries.
+func (h *MemoryHandler) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = nil
+}
+
+// WaitFor waits until at least n entries are recorded and reports whether
+// that happened within timeout.
+func (h *MemoryHandler) WaitFor(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		h.mu.Lock()
+		if h.changed == nil {
+			h.changed = make(chan struct{})
+		}
+		count, changed := len(h.entries), h.changed
+		h.mu.Unlock()
+
+		if count >= n {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
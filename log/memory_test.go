@@ -0,0 +1,103 @@
+package log
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryHandlerRecords(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	defer l.Close()
+	l.SetLevel(LevelAll)
+	l.SetFlags(Llevel)
+
+	before := time.Now()
+	l.Info("hello %s", "world")
+	l.Warn("careful\n")
+	if !h.WaitFor(2, 5*time.Second) {
+		t.Fatal("WaitFor timed out")
+	}
+
+	entries := h.Entries()
+	want := []Entry{{Level: LevelInfo, Line: "INFO - hello world"}, {Level: LevelWarn, Line: "WARN - careful"}}
+	for i, e := range entries {
+		if e.Level != want[i].Level || e.Line != want[i].Line {
+			t.Errorf("entry %d = %v %q, want %v %q", i, e.Level, e.Line, want[i].Level, want[i].Line)
+		}
+		if e.Time.Before(before) || e.Time.After(time.Now()) {
+			t.Errorf("entry %d time = %v", i, e.Time)
+		}
+	}
+	if !h.Contains("world") || h.Contains("goodbye") {
+		t.Error("Contains does not match the recorded lines")
+	}
+
+	// Entries returns a copy.
+	entries[0].Line = "changed"
+	if h.Entries()[0].Line == "changed" {
+		t.Error("Entries exposes the handler's slice")
+	}
+
+	h.Reset()
+	if len(h.Entries()) != 0 || h.Contains("world") {
+		t.Error("Reset kept entries")
+	}
+	l.Info("again")
+	if !h.WaitFor(1, 5*time.Second) || !h.Contains("again") {
+		t.Error("the handler stopped recording after Reset")
+	}
+}
+
+func TestMemoryHandlerWrite(t *testing.T) {
+	h := NewMemoryHandler()
+	h.Write([]byte("plain\n"))
+	h.Write([]byte("two\nlines\n"))
+	entries := h.Entries()
+	if len(entries) != 2 || entries[0].Line != "plain" || entries[1].Line != "two\nlines" || entries[0].Level != 0 {
+		t.Errorf("entries = %q", entries)
+	}
+	if err := h.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryHandlerWaitFor(t *testing.T) {
+	h := NewMemoryHandler()
+	if !h.WaitFor(0, 0) {
+		t.Error("WaitFor(0) should succeed at once")
+	}
+	start := time.Now()
+	if h.WaitFor(1, 20*time.Millisecond) {
+		t.Error("WaitFor succeeded without entries")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("WaitFor gave up after %v", elapsed)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			h.Write([]byte("x"))
+		}()
+	}
+	if !h.WaitFor(10, 5*time.Second) {
+		t.Errorf("WaitFor(10) saw %d entries", len(h.Entries()))
+	}
+	wg.Wait()
+}
+
+func TestMemoryHandlerZeroValue(t *testing.T) {
+	var h MemoryHandler
+	h.Write([]byte("before\n"))
+	l := New(&h)
+	defer l.Close()
+	l.Info("after")
+	if !h.WaitFor(2, 5*time.Second) || !h.Contains("after") {
+		t.Errorf("entries = %q", h.Entries())
+	}
+}
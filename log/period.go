@@ -0,0 +1,45 @@
+package log
+
+import "time"
+
+// periodStart returns the start of the rotation period containing t: t
+// truncated to the unit in its own location, and for sub-day units further
+// down to a multiple of interval units since midnight.
+func periodStart(t time.Time, when int8, interval int) time.Time {
+	y, mo, d := t.Date()
+	midnight := time.Date(y, mo, d, 0, 0, 0, 0, t.Location())
+	if when == WhenDay {
+		return midnight
+	}
+
+	unit := rotationUnit(when) * time.Duration(interval)
+	since := t.Sub(midnight)
+	return midnight.Add(since - since%unit)
+}
+
+// nextPeriod returns the start of the period following the one starting at
+// start.
+func nextPeriod(start time.Time, when int8, interval int) time.Time {
+	if when == WhenDay {
+		// AddDate keeps midnight across DST changes
+		return start.AddDate(0, 0, interval)
+	}
+	next := start.Add(rotationUnit(when) * time.Duration(interval))
+	if y, m, d := next.Date(); d != start.Day() {
+		// periods restart at midnight even if interval does not divide a day
+		next = time.Date(y, m, d, 0, 0, 0, 0, next.Location())
+	}
+	return next
+}
+
+func rotationUnit(when int8) time.Duration {
+	switch when {
+	case WhenSecond:
+		return time.Second
+	case WhenMinute:
+		return time.Minute
+	case WhenHour:
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeriods(t *testing.T) {
+	at := func(day, h, m, s int) time.Time { return time.Date(2025, 1, day, h, m, s, 0, time.UTC) }
+	tests := []struct {
+		when       int8
+		interval   int
+		t          time.Time
+		start, end time.Time
+	}{
+		{WhenHour, 1, at(10, 9, 59, 30), at(10, 9, 0, 0), at(10, 10, 0, 0)},
+		{WhenHour, 1, at(10, 10, 0, 0), at(10, 10, 0, 0), at(10, 11, 0, 0)},
+		{WhenHour, 4, at(10, 13, 2, 0), at(10, 12, 0, 0), at(10, 16, 0, 0)},
+		// 5 does not divide a day: the last period ends at midnight.
+		{WhenHour, 5, at(10, 22, 30, 0), at(10, 20, 0, 0), at(11, 0, 0, 0)},
+		{WhenMinute, 1, at(10, 23, 59, 59), at(10, 23, 59, 0), at(11, 0, 0, 0)},
+		{WhenMinute, 15, at(10, 10, 44, 0), at(10, 10, 30, 0), at(10, 10, 45, 0)},
+		{WhenSecond, 10, at(10, 10, 0, 19), at(10, 10, 0, 10), at(10, 10, 0, 20)},
+		{WhenDay, 1, at(10, 13, 2, 0), at(10, 0, 0, 0), at(11, 0, 0, 0)},
+		{WhenDay, 7, at(10, 13, 2, 0), at(10, 0, 0, 0), at(17, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		start := periodStart(tt.t, tt.when, tt.interval)
+		end := nextPeriod(start, tt.when, tt.interval)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("when %d every %d at %s: period %s to %s, want %s to %s",
+				tt.when, tt.interval, tt.t.Format(time.TimeOnly), start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestPeriodsFollowLocalWallClock(t *testing.T) {
+	// Hourly periods start at the top of the local hour even in a zone
+	// offset by a fraction of an hour from UTC.
+	zone := time.FixedZone("IST", 5*3600+1800)
+	tm := time.Date(2025, 1, 10, 9, 40, 0, 0, zone)
+	start := periodStart(tm, WhenHour, 1)
+	if want := time.Date(2025, 1, 10, 9, 0, 0, 0, zone); !start.Equal(want) {
+		t.Errorf("period start = %s, want %s", start, want)
+	}
+}
+
+// listDir returns the names in dir, sorted.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestHourlyRotationAfterIdle(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	now := time.Date(2025, 1, 10, 9, 59, 0, 0, time.Local)
+	h, err := NewTimeRotatingFileHandler(base, WhenHour, 1, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("09:59\n"))
+
+	// Idle across three boundaries: only the 09:00 file is rotated out and
+	// writing goes on in the 13:00 period.
+	now = time.Date(2025, 1, 10, 13, 2, 0, 0, time.Local)
+	h.Write([]byte("13:02\n"))
+	now = now.Add(57 * time.Minute)
+	h.Write([]byte("13:59\n"))
+	now = now.Add(time.Minute)
+	h.Write([]byte("14:00\n"))
+	h.Close()
+
+	want := "app.log app.log2025-01-10_09 app.log2025-01-10_13"
+	if got := strings.Join(listDir(t, dir), " "); got != want {
+		t.Fatalf("files = %s, want %s", got, want)
+	}
+	for name, content := range map[string]string{
+		"app.log2025-01-10_09": "09:59\n",
+		"app.log2025-01-10_13": "13:02\n13:59\n",
+		"app.log":              "14:00\n",
+	} {
+		if got := readFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestMinuteRotationSuffix(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	now := time.Date(2025, 1, 10, 9, 14, 59, 0, time.Local)
+	h, _ := NewTimeRotatingFileHandler(base, WhenMinute, 15, WithClock(func() time.Time { return now }))
+	h.Write([]byte("a\n"))
+	now = now.Add(time.Second)
+	h.Write([]byte("b\n"))
+	h.Close()
+
+	if got := readFile(t, base+"2025-01-10_09-00"); got != "a\n" {
+		t.Errorf("rotated file = %q", got)
+	}
+}
+
+func TestSetLogFileRotation(t *testing.T) {
+	old := StdLogger()
+	defer defLoger.Store(old)
+	l := New(NewMemoryHandler())
+	defLoger.Store(l)
+	defer l.Close()
+
+	base := filepath.Join(t.TempDir(), "app.log")
+	if err := SetLogFile(base, WithRotation(WhenHour, 2)); err != nil {
+		t.Fatal(err)
+	}
+	l.state.RLock()
+	h, ok := l.handler.(*TimeRotatingFileHandler)
+	l.state.RUnlock()
+	if !ok || h.when != WhenHour || h.interval != 2 || h.suffix != "2006-01-02_15" {
+		t.Errorf("SetLogFile installed %#v", h)
+	}
+
+	if err := SetLogFile(base, WithRotation(42, 1)); err == nil {
+		t.Error("an invalid unit should be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultQueueSize = 1024
+
+	// dropReportInterval is the minimum time between two "dropped" lines.
+	dropReportInterval = 10 * time.Second
+)
+
+// OverflowPolicy decides what Output does when the queue in front of the
+// handler is full.
+type OverflowPolicy int32
+
+const (
+	// Block waits for room in the queue. This is the default.
+	Block OverflowPolicy = iota
+	// DropNewest discards the record being logged.
+	DropNewest
+	// DropOldest discards the oldest queued record to make room.
+	DropOldest
+)
+
+// SetOverflowPolicy sets what happens when the handler cannot keep up.
+// Dropped records are counted by DroppedCount and summarized in a warning
+// line at most every 10 seconds.
+func (l *Logger) SetOverflowPolicy(p OverflowPolicy) {
+	l.overflow.Store(int32(p))
+}
+
+// DroppedCount returns the number of records dropped by the overflow
+// policy so far.
+func (l *Logger) DroppedCount() uint64 {
+	return l.dropped.Load()
+}
+
+// SetQueueSize replaces the queue with one holding n records. It may be
+// called at any time: logging is paused while the records already queued
+// are written.
+func (l *Logger) SetQueueSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	l.restart(n, nil)
+}
+
+// restart stops the writer goroutine once it has written everything
+// queued, calls fn if not nil, then starts it again with a queue of n
+// records, or of the same size if n is 0. Logging blocks meanwhile. It does
+// nothing on a closed logger.
+func (l *Logger) restart(n int, fn func()) {
+	l.state.Lock()
+	defer l.state.Unlock()
+	if l.closed {
+		return
+	}
+
+	close(l.msg)
+	l.wg.Wait()
+
+	if fn != nil {
+		fn()
+	}
+
+	if n == 0 {
+		n = cap(l.msg)
+	}
+	l.msg = make(chan entry, n)
+	l.wg.Add(1)
+	go l.run(l.msg)
+}
+
+// enqueue queues e according to the overflow policy. The caller holds
+// l.state for reading.
+func (l *Logger) enqueue(e entry) bool {
+	switch OverflowPolicy(l.overflow.Load()) {
+	case DropNewest:
+		select {
+		case l.msg <- e:
+			return true
+		default:
+			l.dropped.Add(1)
+			return false
+		}
+	case DropOldest:
+		for {
+			select {
+			case l.msg <- e:
+				return true
+			default:
+			}
+			select {
+			case old := <-l.msg:
+				if old.done != nil {
+					// everything before the marker is handled, as far as
+					// a Flush waiting on it is concerned
+					close(old.done)
+					continue
+				}
+				l.dropped.Add(1)
+				l.putBuf(old.buf)
+			default:
+			}
+		}
+	default:
+		l.msg <- e
+		return true
+	}
+}
+
+// reportDrops writes a warning about records dropped since the last report,
+// if there are any and the last report is old enough or force is set. It
+// runs on the writer goroutine and bypasses the queue.
+func (l *Logger) reportDrops(force bool) {
+	dropped := l.dropped.Load()
+	if dropped == l.reported {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(l.reportedAt) < dropReportInterval {
+		return
+	}
+
+	r := l.newRecord(LevelWarn, now)
+	r.Msg = fmt.Sprintf("dropped %d log messages", dropped-l.reported)
+
+	e := entry{buf: l.format(l.popBuf(), &r), level: r.Level, msg: r.Msg, t: r.Time}
+	l.writeEntry(&e)
+	l.putBuf(e.buf)
+
+	l.reported = dropped
+	l.reportedAt = now
+}
@@ -0,0 +1,147 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedHandler holds every write until release is closed, telling entered
+// about each write that started.
+type gatedHandler struct {
+	*MemoryHandler
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGatedHandler() gatedHandler {
+	return gatedHandler{NewMemoryHandler(), make(chan struct{}, 100), make(chan struct{})}
+}
+
+func (h gatedHandler) WriteLevel(level LogLever, p []byte) (int, error) {
+	h.entered <- struct{}{}
+	<-h.release
+	return h.MemoryHandler.WriteLevel(level, p)
+}
+
+// stalledLogger returns a logger with a queue of n records whose writer is
+// stuck in the handler on a first record.
+func stalledLogger(t *testing.T, policy OverflowPolicy, n int) (*Logger, gatedHandler) {
+	t.Helper()
+	h := newGatedHandler()
+	l := New(h)
+	l.SetFlags(0)
+	l.SetQueueSize(n)
+	l.SetOverflowPolicy(policy)
+	l.Info("0")
+	select {
+	case <-h.entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the writer did not pick up the first record")
+	}
+	return l, h
+}
+
+func lines(h gatedHandler) string {
+	var s []string
+	for _, e := range h.Entries() {
+		s = append(s, e.Line)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	l, h := stalledLogger(t, DropNewest, 2)
+	for i := 1; i <= 4; i++ {
+		l.Info("%d", i)
+	}
+	if n := l.DroppedCount(); n != 2 {
+		t.Errorf("DroppedCount = %d, want 2", n)
+	}
+	close(h.release)
+	l.Close()
+	if got := lines(h); got != "0,dropped 2 log messages,1,2" {
+		t.Errorf("written %s", got)
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	l, h := stalledLogger(t, DropOldest, 2)
+	for i := 1; i <= 4; i++ {
+		l.Info("%d", i)
+	}
+	if n := l.DroppedCount(); n != 2 {
+		t.Errorf("DroppedCount = %d, want 2", n)
+	}
+	close(h.release)
+	l.Close()
+	if got := lines(h); got != "0,dropped 2 log messages,3,4" {
+		t.Errorf("written %s", got)
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	l, h := stalledLogger(t, Block, 2)
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 4; i++ {
+			l.Info("%d", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("logging did not block on a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(h.release)
+	<-done
+	l.Close()
+	if got := lines(h); got != "0,1,2,3,4" || l.DroppedCount() != 0 {
+		t.Errorf("written %s, dropped %d", got, l.DroppedCount())
+	}
+}
+
+func TestFlushWhileDroppingOldest(t *testing.T) {
+	l, h := stalledLogger(t, DropOldest, 1)
+	flushed := make(chan struct{})
+	go func() {
+		l.Flush()
+		close(flushed)
+	}()
+	// Wait for the flush marker to take the only slot, then push it out.
+	for len(l.msg) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Info("1")
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush hung after its marker was pushed out of the queue")
+	}
+	close(h.release)
+	l.Close()
+}
+
+func TestSetQueueSizeKeepsQueued(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(0)
+	for i := 0; i < 100; i++ {
+		l.Info("%d", i)
+		if i == 50 {
+			l.SetQueueSize(4)
+		}
+	}
+	l.Close()
+	entries := h.Entries()
+	if len(entries) != 100 {
+		t.Fatalf("%d lines written, want 100", len(entries))
+	}
+	for i, e := range entries {
+		if e.Line != fmt.Sprint(i) {
+			t.Fatalf("line %d = %q", i, e.Line)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	redisFlushInterval = time.Second
+	redisBatchSize     = 128
+)
+
+// RedisHandler appends logs to a capped Redis list, keeping the last maxLen lines.
+//
+// Writes are queued in memory and pushed in batches by a background goroutine, so an
+// unreachable Redis never blocks logging: lines that cannot be delivered, or that
+// overflow the queue, are dropped and counted.
+type RedisHandler struct {
+	pool   *redis.Pool
+	key    string
+	maxLen int
+
+	mu      sync.Mutex
+	pending [][]byte
+
+	dropped uint64
+
+	kick      chan struct{}
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewRedisHandler(pool *redis.Pool, key string, maxLen int) (*RedisHandler, error) {
+	if maxLen <= 0 {
+		return nil, fmt.Errorf("invalid max len")
+	}
+
+	h := new(RedisHandler)
+
+	h.pool = pool
+	h.key = key
+	h.maxLen = maxLen
+
+	h.kick = make(chan struct{}, 1)
+	h.quit = make(chan struct{})
+
+	h.wg.Add(1)
+	go h.run()
+
+	return h, nil
+}
+
+func (h *RedisHandler) Write(b []byte) (n int, err error) {
+	line := append([]byte(nil), bytes.TrimRight(b, "\n")...)
+
+	h.mu.Lock()
+	if len(h.pending) >= h.maxLen {
+		// shift rather than reslice, so that the dropped line is not kept
+		// alive by the backing array
+		n := copy(h.pending, h.pending[1:])
+		h.pending[n] = nil
+		h.pending = h.pending[:n]
+		atomic.AddUint64(&h.dropped, 1)
+	}
+	h.pending = append(h.pending, line)
+	full := len(h.pending) >= redisBatchSize
+	h.mu.Unlock()
+
+	if full {
+		select {
+		case h.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(b), nil
+}
+
+// Close pushes any pending lines, then stops the background goroutine.
+// Calling it again does nothing.
+func (h *RedisHandler) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.quit)
+		h.wg.Wait()
+	})
+	return nil
+}
+
+// Dropped returns the number of lines discarded so far.
+func (h *RedisHandler) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// run pushes the pending lines every redisFlushInterval, or as soon as a
+// batch is full. It runs on a goroutine of its own rather than on the
+// logger's writer goroutine: a Redis round trip can take as long as the dial
+// timeout, which would hold up every other record queued on the logger, and
+// lines must still be pushed when no new record arrives to trigger it.
+func (h *RedisHandler) run() {
+	defer h.wg.Done()
+
+	t := time.NewTicker(redisFlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.flush()
+		case <-h.kick:
+			h.flush()
+		case <-h.quit:
+			h.flush()
+			return
+		}
+	}
+}
+
+func (h *RedisHandler) flush() {
+	h.mu.Lock()
+	lines := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+
+	if len(lines) == 0 {
+		return
+	}
+
+	conn := h.pool.Get()
+	defer conn.Close()
+
+	if err := h.push(conn, lines); err != nil {
+		atomic.AddUint64(&h.dropped, uint64(len(lines)))
+	}
+}
+
+func (h *RedisHandler) push(conn redis.Conn, lines [][]byte) error {
+	conn.Send("RPUSH", redis.Args{h.key}.AddFlat(lines)...)
+	conn.Send("LTRIM", h.key, -h.maxLen, -1)
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	if _, err := conn.Receive(); err != nil {
+		return err
+	}
+	_, err := conn.Receive()
+	return err
+}
+
+// ReadRedisLog returns the last n lines written by a RedisHandler to key, oldest first.
+func ReadRedisLog(pool *redis.Pool, key string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	return redis.Strings(conn.Do("LRANGE", key, -n, -1))
+}
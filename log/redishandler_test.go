@@ -0,0 +1,130 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/garyburd/redigo/redis"
+)
+
+func newTestPool(t *testing.T) (*redis.Pool, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	addr := m.Addr()
+	p := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, redis.DialConnectTimeout(time.Second))
+		},
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, m
+}
+
+func TestRedisHandlerCloseFlushes(t *testing.T) {
+	pool, m := newTestPool(t)
+	h, err := NewRedisHandler(pool, "job:log", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("first\n"))
+	h.Write([]byte("second"))
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Errorf("closing again = %v", err)
+	}
+
+	lines, err := m.List("job:log")
+	if err != nil || len(lines) != 2 || lines[0] != "first" || lines[1] != "second" {
+		t.Errorf("list = %q, %v, want the lines without newlines, oldest first", lines, err)
+	}
+	if h.Dropped() != 0 {
+		t.Errorf("Dropped = %d", h.Dropped())
+	}
+}
+
+func TestRedisHandlerCapsList(t *testing.T) {
+	pool, m := newTestPool(t)
+	h, _ := NewRedisHandler(pool, "log", 10)
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(h, "line %d\n", i)
+		if i%5 == 4 {
+			// Let some batches reach Redis before the next lines.
+			h.kick <- struct{}{}
+		}
+	}
+	h.Close()
+
+	lines, _ := m.List("log")
+	if len(lines) != 10 || lines[0] != "line 15" || lines[9] != "line 24" {
+		t.Errorf("list = %q, want the last 10 lines", lines)
+	}
+	got, err := ReadRedisLog(pool, "log", 3)
+	if err != nil || len(got) != 3 || got[0] != "line 22" || got[2] != "line 24" {
+		t.Errorf("ReadRedisLog(3) = %q, %v", got, err)
+	}
+	if got, _ := ReadRedisLog(pool, "log", 0); got != nil {
+		t.Errorf("ReadRedisLog(0) = %q", got)
+	}
+	if got, err := ReadRedisLog(pool, "missing", 5); err != nil || len(got) != 0 {
+		t.Errorf("ReadRedisLog(missing) = %q, %v", got, err)
+	}
+}
+
+func TestRedisHandlerQueueOverflow(t *testing.T) {
+	pool, m := newTestPool(t)
+	h, _ := NewRedisHandler(pool, "log", 3)
+	// Hold the queue: the background goroutine only flushes on a kick, a
+	// full batch or the ticker, none of which happens for 5 lines.
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(h, "%d", i)
+	}
+	// The oldest lines are shifted out in place.
+	h.mu.Lock()
+	size := cap(h.pending)
+	h.mu.Unlock()
+	if size > 4 {
+		t.Errorf("the queue grew to %d lines", size)
+	}
+	h.Close()
+	if h.Dropped() != 2 {
+		t.Errorf("Dropped = %d, want the 2 oldest lines", h.Dropped())
+	}
+	if lines, _ := m.List("log"); len(lines) != 3 || lines[0] != "2" || lines[2] != "4" {
+		t.Errorf("list = %q", lines)
+	}
+}
+
+func TestRedisHandlerUnreachable(t *testing.T) {
+	pool, m := newTestPool(t)
+	h, _ := NewRedisHandler(pool, "log", 1000)
+	m.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(h, "line %d", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked while Redis was down")
+	}
+	h.Close()
+	if h.Dropped() != 500 {
+		t.Errorf("Dropped = %d, want every line", h.Dropped())
+	}
+}
+
+func TestNewRedisHandlerInvalid(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		if _, err := NewRedisHandler(nil, "log", n); err == nil {
+			t.Errorf("maxLen %d should be rejected", n)
+		}
+	}
+}
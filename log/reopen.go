@@ -0,0 +1,73 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ErrNotReopenable is returned by Logger.Reopen when the handler has no
+// Reopen method.
+var ErrNotReopenable = errors.New("log: handler cannot be reopened")
+
+// reopen opens name in append mode and swaps it into *fd, closing the old
+// file only once the new one is open.
+func reopen(fd **os.File, name string) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	old := *fd
+	*fd = f
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Reopen reopens the files of l's handler, if it supports it.
+func (l *Logger) Reopen() error {
+	l.state.RLock()
+	h := l.handler
+	l.state.RUnlock()
+
+	r, ok := h.(interface{ Reopen() error })
+	if !ok {
+		return ErrNotReopenable
+	}
+	return r.Reopen()
+}
+
+// HandleSIGHUP reopens the default logger's files whenever the process
+// receives SIGHUP, which is what logrotate's postrotate scripts usually
+// send. Failures are reported to stderr. Call the returned function to stop
+// handling the signal; it is safe to call more than once.
+func HandleSIGHUP() (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := StdLogger().Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "log: reopening on SIGHUP: %v\n", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(quit)
+		})
+	}
+}
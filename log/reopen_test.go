@@ -0,0 +1,156 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type reopenHandler interface {
+	Handler
+	Reopen() error
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// moveAway renames path the way logrotate does before signalling.
+func moveAway(t *testing.T, path string) string {
+	t.Helper()
+	moved := path + ".rotated"
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	return moved
+}
+
+func TestReopen(t *testing.T) {
+	handlers := map[string]func(path string) (reopenHandler, error){
+		"file": func(path string) (reopenHandler, error) {
+			// created beforehand: NewFileHandler creates files without
+			// permissions
+			os.WriteFile(path, nil, 0666)
+			return NewFileHandler(path, os.O_WRONLY|os.O_APPEND)
+		},
+		"rotating": func(path string) (reopenHandler, error) {
+			return NewRotatingFileHandler(path, 1<<20, 3)
+		},
+		"time rotating": func(path string) (reopenHandler, error) {
+			return NewTimeRotatingFileHandler(path, WhenDay, 1)
+		},
+		"buffered": func(path string) (reopenHandler, error) {
+			h, err := NewRotatingFileHandler(path, 1<<20, 3)
+			if err != nil {
+				return nil, err
+			}
+			return NewBufferedHandler(h, 4096, time.Hour), nil
+		},
+	}
+	for name, newHandler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			h, err := newHandler(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Write([]byte("before\n"))
+			moved := moveAway(t, path)
+
+			if err := h.Reopen(); err != nil {
+				t.Fatal(err)
+			}
+			h.Write([]byte("after\n"))
+			if err := h.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFile(t, moved); got != "before\n" {
+				t.Errorf("moved file = %q, want only the lines before Reopen", got)
+			}
+			if got := readFile(t, path); got != "after\n" {
+				t.Errorf("reopened file = %q, want the lines after Reopen", got)
+			}
+		})
+	}
+}
+
+func TestLoggerReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h, _ := NewRotatingFileHandler(path, 1<<20, 3)
+	l := New(h)
+	l.SetFlags(0)
+	l.Info("before")
+	l.Flush()
+	moved := moveAway(t, path)
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after")
+	l.Close()
+
+	if got := readFile(t, moved); got != "before\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("reopened file = %q", got)
+	}
+
+	m := New(NewMemoryHandler())
+	defer m.Close()
+	if err := m.Reopen(); !errors.Is(err, ErrNotReopenable) {
+		t.Errorf("Reopen of a MemoryHandler = %v, want ErrNotReopenable", err)
+	}
+}
+
+func TestHandleSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h, _ := NewRotatingFileHandler(path, 1<<20, 3)
+	old := StdLogger()
+	l := New(h)
+	l.SetFlags(0)
+	defLoger.Store(l)
+	defer func() {
+		defLoger.Store(old)
+		l.Close()
+	}()
+
+	stop := HandleSIGHUP()
+	defer stop()
+	Info("before")
+	l.Flush()
+	moved := moveAway(t, path)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the file was not reopened on SIGHUP")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	Info("after")
+	l.Flush()
+	stop()
+	stop()
+
+	if got := readFile(t, moved); got != "before\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("reopened file = %q", got)
+	}
+}
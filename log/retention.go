@@ -0,0 +1,257 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOption configures a RotatingFileHandler or TimeRotatingFileHandler.
+type RotateOption func(*rotateOptions)
+
+type rotateOptions struct {
+	maxTotalSize int64
+	maxBackups   int
+	maxAge       time.Duration
+	compress     bool
+	symlink      bool
+
+	// warned is set once a retention failure has been reported, so a
+	// persistent problem is not reported again on every rotation.
+	warned bool
+
+	// jobs tracks the background compression of the last rotated file.
+	jobs sync.WaitGroup
+
+	clock func() time.Time
+}
+
+// now returns the current time according to the clock option.
+func (o *rotateOptions) now() time.Time {
+	if o.clock != nil {
+		return o.clock()
+	}
+	return time.Now()
+}
+
+// WithMaxTotalSize bounds the disk space used by rotated files. After each
+// rotation the handler sums the sizes of its own rotated files and deletes
+// the oldest until the total is within bytes. The active file and the most
+// recent rotated file are always kept.
+func WithMaxTotalSize(bytes int64) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxTotalSize = bytes
+	}
+}
+
+// WithMaxBackups keeps at most n rotated files, deleting the oldest after
+// each rotation. n <= 0 means no limit.
+func WithMaxBackups(n int) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxBackups = n
+	}
+}
+
+// WithMaxAge deletes rotated files last modified more than d ago, checked
+// after each rotation. d <= 0 means no limit.
+func WithMaxAge(d time.Duration) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxAge = d
+	}
+}
+
+// WithClock makes a time rotating handler read the time from now instead of
+// time.Now, so tests can cross rotation boundaries without waiting.
+func WithClock(now func() time.Time) RotateOption {
+	return func(o *rotateOptions) {
+		o.clock = now
+	}
+}
+
+// WithCurrentSymlink makes a TimeRotatingFileHandler write straight to the
+// dated file of the current period, e.g. "app.log2025-01-02", and keep the
+// base name "app.log" as a symlink to it, replaced atomically on rotation.
+// Without it the handler writes to the base name and renames the file on
+// rotation, which also keeps a stable path. Where symlinks cannot be
+// created the link is silently not maintained. RotatingFileHandler always
+// writes to a stable path and ignores this option.
+func WithCurrentSymlink() RotateOption {
+	return func(o *rotateOptions) {
+		o.symlink = true
+	}
+}
+
+// WithCompress gzips each rotated file in the background, producing
+// "<name>.gz". The uncompressed file is only removed once the compressed
+// copy has been fully written and synced, so a crash never loses it.
+func WithCompress() RotateOption {
+	return func(o *rotateOptions) {
+		o.compress = true
+	}
+}
+
+func newRotateOptions(opts []RotateOption) *rotateOptions {
+	o := new(rotateOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// rotatedFile is a file produced by a rotation. Larger ages are older.
+type rotatedFile struct {
+	path    string
+	size    int64
+	age     int64
+	modTime time.Time
+}
+
+// sizeRotatedFiles lists the "<fileName>.<n>" backups of a size rotating
+// handler.
+func sizeRotatedFiles(fileName string) ([]rotatedFile, error) {
+	return listRotated(fileName+".", func(suffix string) (int64, bool) {
+		n, err := strconv.Atoi(strings.TrimSuffix(suffix, gzSuffix))
+		return int64(n), err == nil && n > 0
+	})
+}
+
+// timeRotatedFiles lists the "<baseName><time suffix>" backups of a time
+// rotating handler.
+func timeRotatedFiles(baseName string, layout string) ([]rotatedFile, error) {
+	return listRotated(baseName, func(suffix string) (int64, bool) {
+		t, err := time.ParseInLocation(layout, strings.TrimSuffix(suffix, gzSuffix), time.Local)
+		return -t.Unix(), err == nil
+	})
+}
+
+// listRotated returns the files named prefix+suffix for which age accepts
+// the suffix. Any other file is ignored.
+func listRotated(prefix string, age func(suffix string) (int64, bool)) ([]rotatedFile, error) {
+	matches, err := filepath.Glob(globEscape(prefix) + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []rotatedFile
+	for _, path := range matches {
+		a, ok := age(strings.TrimPrefix(path, prefix))
+		if !ok {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode().IsRegular() {
+			files = append(files, rotatedFile{path: path, size: fi.Size(), age: a, modTime: fi.ModTime()})
+		}
+	}
+	return files, nil
+}
+
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// afterRotate compresses the just rotated file at path if requested, then
+// applies retention, both in the background. Call wait before touching
+// rotated files again.
+func (o *rotateOptions) afterRotate(path string, list func() ([]rotatedFile, error)) {
+	if !o.compress && !o.hasRetention() {
+		return
+	}
+	o.jobs.Add(1)
+	go func() {
+		defer o.jobs.Done()
+		if o.compress {
+			if err := compressFile(path); err != nil {
+				o.warn(err)
+			}
+		}
+		o.applyRetention(list)
+	}()
+}
+
+// wait blocks until the background work of the last rotation is done.
+func (o *rotateOptions) wait() {
+	o.jobs.Wait()
+}
+
+// warn reports a failure to stderr once per handler.
+func (o *rotateOptions) warn(err error) {
+	if !o.warned {
+		o.warned = true
+		fmt.Fprintf(os.Stderr, "log: processing rotated files: %v\n", err)
+	}
+}
+
+func (o *rotateOptions) hasRetention() bool {
+	return o.maxTotalSize > 0 || o.maxBackups > 0 || o.maxAge > 0
+}
+
+// applyRetention deletes rotated files according to the options. list
+// returns the handler's rotated files; nothing else is ever deleted.
+func (o *rotateOptions) applyRetention(list func() ([]rotatedFile, error)) {
+	if !o.hasRetention() {
+		return
+	}
+	files, err := list()
+	if err == nil {
+		err = o.enforce(files, time.Now())
+	}
+	if err != nil {
+		o.warn(err)
+	}
+}
+
+func (o *rotateOptions) enforce(files []rotatedFile, now time.Time) error {
+	// newest first
+	sort.Slice(files, func(i, j int) bool { return files[i].age < files[j].age })
+
+	var firstErr error
+	remove := func(f rotatedFile) bool {
+		if err := os.Remove(f.path); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return false
+		}
+		return true
+	}
+
+	kept := files[:0]
+	for i, f := range files {
+		tooMany := o.maxBackups > 0 && i >= o.maxBackups
+		tooOld := o.maxAge > 0 && now.Sub(f.modTime) > o.maxAge
+		if (tooMany || tooOld) && remove(f) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	if o.maxTotalSize > 0 {
+		var total int64
+		for _, f := range kept {
+			total += f.size
+		}
+		for i := len(kept) - 1; i > 0 && total > o.maxTotalSize; i-- {
+			if remove(kept[i]) {
+				total -= kept[i].size
+			}
+		}
+	}
+	return firstErr
+}
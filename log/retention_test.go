@@ -0,0 +1,230 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFakeFile writes size bytes to path.
+func writeFakeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFiles(t *testing.T, exist bool, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		_, err := os.Stat(p)
+		if exist && err != nil {
+			t.Errorf("%s should have been kept: %v", filepath.Base(p), err)
+		} else if !exist && err == nil {
+			t.Errorf("%s should have been deleted", filepath.Base(p))
+		}
+	}
+}
+
+func TestMaxTotalSizeRotatingFileHandler(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	writeFakeFile(t, name, 100)
+	for i := 1; i <= 4; i++ {
+		writeFakeFile(t, fmt.Sprintf("%s.%d", name, i), 100)
+	}
+	// Files outside the handler's pattern never count towards the budget.
+	others := []string{name + ".bak", name + ".0", filepath.Join(dir, "other.log.1")}
+	for _, p := range others {
+		writeFakeFile(t, p, 1000)
+	}
+
+	h, err := NewRotatingFileHandler(name, 100, 10, WithMaxTotalSize(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The active file is full: this write rotates it to app.log.1, leaving
+	// five 100 byte backups for a 250 byte budget.
+	h.Write([]byte("line\n"))
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFiles(t, true, name, name+".1", name+".2")
+	assertFiles(t, false, name+".3", name+".4", name+".5")
+	assertFiles(t, true, others...)
+}
+
+func TestMaxTotalSizeKeepsNewestBackup(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	writeFakeFile(t, name, 500)
+	writeFakeFile(t, name+".1", 500)
+
+	h, _ := NewRotatingFileHandler(name, 100, 10, WithMaxTotalSize(1))
+	h.Write([]byte("line\n"))
+	h.Close()
+
+	// Even a budget smaller than a single file keeps one backup.
+	assertFiles(t, true, name, name+".1")
+	assertFiles(t, false, name+".2")
+}
+
+func TestMaxTotalSizeTimeRotatingFileHandler(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	writeFakeFile(t, base, 100)
+	var old []string
+	for i := 1; i <= 3; i++ {
+		p := base + day.AddDate(0, 0, -i).Format("2006-01-02")
+		writeFakeFile(t, p, 100)
+		old = append(old, p)
+	}
+	writeFakeFile(t, base+".tmp", 1000)
+
+	now := day
+	h, err := NewTimeRotatingFileHandler(base, WhenDay, 1, WithMaxTotalSize(300), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake active file was written today, by the wall clock; put it in
+	// the fake clock's day so the next one rotates it.
+	h.setPeriod(day)
+	now = day.AddDate(0, 0, 1)
+	h.Write([]byte("line\n"))
+	h.Close()
+
+	// 400 bytes of backups: the oldest day goes.
+	assertFiles(t, true, base, base+day.Format("2006-01-02"), old[0], old[1], base+".tmp")
+	assertFiles(t, false, old[2])
+}
+
+func TestRetentionWarnsOnce(t *testing.T) {
+	dir := t.TempDir()
+	// A non-empty directory cannot be removed, whoever runs the test.
+	stuck := filepath.Join(dir, "app.log.2")
+	os.Mkdir(stuck, 0777)
+	writeFakeFile(t, filepath.Join(stuck, "f"), 1)
+	list := func() ([]rotatedFile, error) {
+		return []rotatedFile{
+			{path: filepath.Join(dir, "app.log.1"), size: 100, age: 1},
+			{path: stuck, size: 100, age: 2},
+		}, nil
+	}
+
+	r, w, _ := os.Pipe()
+	stderr := os.Stderr
+	os.Stderr = w
+	o := newRotateOptions([]RotateOption{WithMaxTotalSize(100)})
+	for i := 0; i < 3; i++ {
+		o.applyRetention(list)
+	}
+	os.Stderr = stderr
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	if n := strings.Count(string(out), "\n"); n != 1 {
+		t.Errorf("warned %d times, want once: %q", n, out)
+	}
+	if !strings.Contains(string(out), "app.log.2") {
+		t.Errorf("warning %q does not name the file", out)
+	}
+}
+
+// rotateOnce makes a daily handler on base with a fake clock, moves to the
+// next day and writes, so the handler rotates once, then closes it, which
+// waits for the retention work.
+func rotateOnce(t *testing.T, base string, day time.Time, setup func(h *TimeRotatingFileHandler), opts ...RotateOption) {
+	t.Helper()
+	now := day
+	opts = append(opts, WithClock(func() time.Time { return now }))
+	h, err := NewTimeRotatingFileHandler(base, WhenDay, 1, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.setPeriod(day)
+	if setup != nil {
+		setup(h)
+	}
+	h.Write([]byte("today\n"))
+	now = day.AddDate(0, 0, 1)
+	h.Write([]byte("tomorrow\n"))
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeRotatingMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	dated := func(days int) string { return base + day.AddDate(0, 0, -days).Format("2006-01-02") }
+	for i := 1; i <= 4; i++ {
+		writeFakeFile(t, dated(i), 10)
+	}
+	writeFakeFile(t, dated(5)+".gz", 10)
+	// Nothing that does not parse as one of the handler's backups is touched.
+	others := []string{
+		base + ".bak",
+		base + "2025-13-45",
+		base + "-2025-01-01",
+		filepath.Join(dir, "other.log2025-01-01"),
+		filepath.Join(dir, "app.log2025-01-01.txt"),
+	}
+	for _, p := range others {
+		writeFakeFile(t, p, 10)
+	}
+
+	rotateOnce(t, base, day, func(h *TimeRotatingFileHandler) { h.SetMaxBackups(3) })
+
+	assertFiles(t, true, base, dated(0), dated(1), dated(2))
+	assertFiles(t, false, dated(3), dated(4), dated(5)+".gz")
+	assertFiles(t, true, others...)
+	if got := readFile(t, base); got != "tomorrow\n" {
+		t.Errorf("active file = %q", got)
+	}
+}
+
+func TestTimeRotatingMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	recent := base + "2025-01-08"
+	old := base + "2025-01-01"
+	unrelated := base + ".old"
+	for _, p := range []string{recent, old, unrelated} {
+		writeFakeFile(t, p, 10)
+	}
+	// Age is judged by the modification time, not the name.
+	week := time.Now().Add(-7 * 24 * time.Hour)
+	os.Chtimes(old, week, week)
+	os.Chtimes(unrelated, week, week)
+
+	rotateOnce(t, base, day, func(h *TimeRotatingFileHandler) { h.SetMaxAge(24 * time.Hour) })
+
+	assertFiles(t, true, base, base+"2025-01-10", recent, unrelated)
+	assertFiles(t, false, old)
+}
+
+func TestTimeRotatingRetentionKeepsCurrentLinkedFile(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	writeFakeFile(t, base+"2025-01-09", 10)
+
+	rotateOnce(t, base, day, nil, WithCurrentSymlink(), WithMaxBackups(1))
+
+	// The file being written is named like a backup but never counts as
+	// one.
+	assertFiles(t, true, base+"2025-01-11", base+"2025-01-10")
+	assertFiles(t, false, base+"2025-01-09")
+	if got := readFile(t, base); got != "tomorrow\n" {
+		t.Errorf("file behind the link = %q", got)
+	}
+}
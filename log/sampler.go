@@ -0,0 +1,138 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Sampler decides which records are logged. Sample is called for every
+// record that passed the level check, from the logging goroutine, and must
+// be safe for concurrent use.
+type Sampler interface {
+	// Sample reports whether the record should be logged. A non-zero
+	// rep.Count asks the logger to first log that rep.Count records with
+	// rep.Level and rep.Msg were suppressed.
+	Sample(level LogLever, msg string, now time.Time) (keep bool, rep Repeat)
+}
+
+// Repeat describes suppressed duplicates of a record.
+type Repeat struct {
+	Level LogLever
+	Msg   string
+	Count int
+}
+
+// SetSampler installs s, or removes the sampler when s is nil. Without a
+// sampler every record is logged and sampling costs nothing.
+func (l *Logger) SetSampler(s Sampler) {
+	if s == nil {
+		l.sampler.Store(nil)
+		return
+	}
+	l.sampler.Store(&s)
+}
+
+// sample applies the sampler to r, logging a repeat summary if needed, and
+// reports whether r should be logged.
+func (l *Logger) sample(r *Record) bool {
+	s := l.sampler.Load()
+	if s == nil {
+		return true
+	}
+	keep, rep := (*s).Sample(r.Level, r.Msg, r.Time)
+	if rep.Count > 0 {
+		summary := *r
+		summary.Level = rep.Level
+		summary.Color = l.levelColor(rep.Level)
+		summary.Msg = fmt.Sprintf("last message repeated %d times: %s", rep.Count, rep.Msg)
+		l.write(&summary)
+	}
+	return keep
+}
+
+// maxDedupKeys bounds the number of distinct messages a DedupSampler tracks.
+const maxDedupKeys = 1024
+
+type dedupKey struct {
+	level LogLever
+	msg   string
+}
+
+type dedupState struct {
+	start time.Time
+	seen  int
+}
+
+// DedupSampler lets through the first burst identical records (same level
+// and message) per window and suppresses the rest. The number suppressed
+// is reported once the window of that message is over and it is logged
+// again, or as soon as a different record arrives.
+type DedupSampler struct {
+	window time.Duration
+	burst  int
+
+	mu         sync.Mutex
+	keys       map[dedupKey]*dedupState
+	last       dedupKey
+	suppressed int
+}
+
+func NewDedupSampler(window time.Duration, burst int) *DedupSampler {
+	if burst < 1 {
+		burst = 1
+	}
+	return &DedupSampler{
+		window: window,
+		burst:  burst,
+		keys:   make(map[dedupKey]*dedupState),
+	}
+}
+
+func (s *DedupSampler) Sample(level LogLever, msg string, now time.Time) (keep bool, rep Repeat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := dedupKey{level, msg}
+	st := s.keys[k]
+
+	// only the last message can have suppressed records pending, since any
+	// other one reports them
+	if s.suppressed > 0 && (k != s.last || now.Sub(st.start) >= s.window) {
+		rep = Repeat{Level: s.last.level, Msg: s.last.msg, Count: s.suppressed}
+		s.suppressed = 0
+	}
+	s.last = k
+
+	if st == nil {
+		if len(s.keys) >= maxDedupKeys {
+			s.evict(now)
+		}
+		st = new(dedupState)
+		s.keys[k] = st
+	}
+	if st.seen == 0 || now.Sub(st.start) >= s.window {
+		st.start = now
+		st.seen = 0
+	}
+	st.seen++
+
+	if st.seen > s.burst {
+		s.suppressed++
+		return false, rep
+	}
+	return true, rep
+}
+
+// evict forgets messages whose window is over, or everything if that does
+// not free any room.
+func (s *DedupSampler) evict(now time.Time) {
+	for k, st := range s.keys {
+		if now.Sub(st.start) >= s.window && k != s.last {
+			delete(s.keys, k)
+		}
+	}
+	if len(s.keys) >= maxDedupKeys {
+		clear(s.keys)
+	}
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupSampler(t *testing.T) {
+	s := NewDedupSampler(time.Second, 2)
+	t0 := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+
+	steps := []struct {
+		level LogLever
+		msg   string
+		at    int
+		keep  bool
+		rep   Repeat
+	}{
+		{LevelError, "conn refused", 0, true, Repeat{}},
+		{LevelError, "conn refused", 1, true, Repeat{}},
+		{LevelError, "conn refused", 2, false, Repeat{}},
+		{LevelError, "conn refused", 3, false, Repeat{}},
+		// A different message reports the suppressed ones first.
+		{LevelInfo, "retrying", 4, true, Repeat{LevelError, "conn refused", 2}},
+		// The same text at another level is another message.
+		{LevelWarn, "retrying", 5, true, Repeat{}},
+		// The window of "conn refused" is still running.
+		{LevelError, "conn refused", 6, false, Repeat{}},
+		// Once it is over the count is reported and the burst starts again.
+		{LevelError, "conn refused", 1000, true, Repeat{LevelError, "conn refused", 1}},
+		{LevelError, "conn refused", 1001, true, Repeat{}},
+		{LevelError, "conn refused", 1002, false, Repeat{}},
+	}
+	for i, st := range steps {
+		keep, rep := s.Sample(st.level, st.msg, at(st.at))
+		if keep != st.keep || rep != st.rep {
+			t.Errorf("step %d: Sample(%v, %q) = %v, %+v, want %v, %+v", i, st.level, st.msg, keep, rep, st.keep, st.rep)
+		}
+	}
+}
+
+func TestDedupSamplerBounded(t *testing.T) {
+	s := NewDedupSampler(time.Hour, 1)
+	now := time.Now()
+	for i := 0; i < 3*maxDedupKeys; i++ {
+		s.Sample(LevelError, fmt.Sprint("message ", i), now)
+	}
+	if n := len(s.keys); n > maxDedupKeys {
+		t.Errorf("%d messages tracked, want at most %d", n, maxDedupKeys)
+	}
+	if keep, _ := s.Sample(LevelError, fmt.Sprint("message ", 3*maxDedupKeys-1), now); keep {
+		t.Error("the last message was forgotten")
+	}
+}
+
+func TestSetSampler(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(Llevel)
+	l.SetSampler(NewDedupSampler(time.Hour, 1))
+	for i := 0; i < 100; i++ {
+		l.Info("reconnecting")
+	}
+	l.Info("connected")
+	l.SetSampler(nil)
+	l.Info("connected")
+	l.Close()
+
+	want := []string{
+		"INFO - reconnecting",
+		"INFO - last message repeated 99 times: reconnecting",
+		"INFO - connected",
+		"INFO - connected",
+	}
+	if got := loggedLines(h); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestDedupSamplerConcurrent(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(0)
+	l.SetSampler(NewDedupSampler(time.Hour, 5))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				l.Info("flood")
+			}
+		}()
+	}
+	wg.Wait()
+	l.Info("done")
+	l.Close()
+	want := []string{"flood", "flood", "flood", "flood", "flood", "last message repeated 3995 times: flood", "done"}
+	if got := loggedLines(h); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
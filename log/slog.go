@@ -0,0 +1,128 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// slogHandler is a slog.Handler writing through a Logger.
+type slogHandler struct {
+	l *Logger
+
+	// group is the dotted prefix of the open groups, with a trailing dot.
+	group string
+	// attrs are the pre-rendered attributes added by WithAttrs.
+	attrs string
+}
+
+// NewSlogHandler returns a slog.Handler that logs through l, so code using
+// log/slog shares l's handler, formatter and level. Records are mapped to
+// the nearest LogLever at or below their level (slog.LevelWarn+2 is still
+// LevelWarn), and attributes are appended to the message as key=value
+// pairs, with group names joined to the keys by dots.
+func NewSlogHandler(l *Logger) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+func slogLevel(level slog.Level) LogLever {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Level()&slogLevel(level) != 0
+}
+
+func (h *slogHandler) Handle(_ context.Context, sr slog.Record) error {
+	r := h.l.newRecord(slogLevel(sr.Level), sr.Time)
+	if wantsCaller(r.Flags) {
+		if sr.PC != 0 {
+			frame, _ := runtime.CallersFrames([]uintptr{sr.PC}).Next()
+			r.setCaller(frame.Function, frame.File, frame.Line)
+		} else {
+			r.setCaller("???", "???", 0)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(sr.Message)
+	b.WriteString(h.attrs)
+	sr.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	r.Msg = b.String()
+
+	h.l.output(&r)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	h2 := *h
+	h2.attrs = b.String()
+	return &h2
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// appendAttr writes " key=value" for a, following the slog.Handler rules:
+// values are resolved, empty attributes are dropped and groups with an
+// empty key are inlined.
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, group, ga)
+		}
+		return
+	}
+	b.WriteByte(' ')
+	b.WriteString(group)
+	b.WriteString(a.Key)
+	b.WriteByte('=')
+	b.WriteString(quoteValue(a.Value.String()))
+}
+
+// quoteValue quotes s if it would otherwise be ambiguous in a key=value
+// list.
+func quoteValue(s string) string {
+	if s == "" || strings.ContainsFunc(s, func(r rune) bool {
+		return r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) {
+		return strconv.Quote(s)
+	}
+	return s
+}
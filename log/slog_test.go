@@ -0,0 +1,113 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSlog returns a slog.Logger writing through a Logger into a
+// MemoryHandler, with the plain message as the whole line.
+func newSlog(t *testing.T) (*slog.Logger, *Logger, *MemoryHandler) {
+	h := NewMemoryHandler()
+	l := New(h)
+	t.Cleanup(l.Close)
+	l.SetLevel(LevelAll)
+	l.SetFlags(0)
+	return slog.New(NewSlogHandler(l)), l, h
+}
+
+func TestSlogLevels(t *testing.T) {
+	sl, l, h := newSlog(t)
+	ctx := context.Background()
+	tests := []struct {
+		level slog.Level
+		want  LogLever
+	}{
+		{slog.LevelDebug - 4, LevelDebug},
+		{slog.LevelDebug, LevelDebug},
+		{slog.LevelInfo, LevelInfo},
+		{slog.LevelInfo + 2, LevelInfo},
+		{slog.LevelWarn, LevelWarn},
+		{slog.LevelWarn + 2, LevelWarn},
+		{slog.LevelError, LevelError},
+		{slog.LevelError + 8, LevelError},
+	}
+	for _, tt := range tests {
+		sl.Log(ctx, tt.level, tt.level.String())
+	}
+	l.Flush()
+	entries := h.Entries()
+	if len(entries) != len(tests) {
+		t.Fatalf("logged %d lines, want %d", len(entries), len(tests))
+	}
+	for i, tt := range tests {
+		if entries[i].Level != tt.want || entries[i].Line != tt.level.String() {
+			t.Errorf("slog level %v logged %q at %v, want %v", tt.level, entries[i].Line, entries[i].Level, tt.want)
+		}
+	}
+}
+
+func TestSlogEnabledFollowsLogger(t *testing.T) {
+	sl, l, h := newSlog(t)
+	ctx := context.Background()
+	l.SetLevel(AtLeast(LevelWarn))
+	if sl.Enabled(ctx, slog.LevelInfo) || !sl.Enabled(ctx, slog.LevelWarn) {
+		t.Error("Enabled does not follow the Logger's level")
+	}
+	sl.Info("hidden")
+	sl.Error("shown")
+	l.SetLevel(LevelDebug)
+	if !sl.Enabled(ctx, slog.LevelDebug) || sl.Enabled(ctx, slog.LevelError) {
+		t.Error("Enabled does not follow a changed level")
+	}
+	l.Flush()
+	if h.Contains("hidden") || !h.Contains("shown") {
+		t.Errorf("entries = %v", h.Entries())
+	}
+}
+
+func TestSlogAttrs(t *testing.T) {
+	sl, l, h := newSlog(t)
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sl.With("service", "api").WithGroup("req").With("id", 7).Info("served",
+		"path", "/a b",
+		"empty", "",
+		"q", `say "hi"`,
+		slog.Group("resp", "status", 200, "ok", true),
+		slog.Group("", "inlined", 1.5),
+		slog.Attr{},
+		slog.Group("nothing"),
+		"at", at,
+	)
+	sl.WithGroup("").With().Info("plain")
+	l.Flush()
+
+	want := []string{
+		`served service=api req.id=7 req.path="/a b" req.empty="" req.q="say \"hi\"" ` +
+			`req.resp.status=200 req.resp.ok=true req.inlined=1.5 req.at="2025-01-02 03:04:05 +0000 UTC"`,
+		"plain",
+	}
+	entries := h.Entries()
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %v", entries)
+	}
+	for i, e := range entries {
+		if e.Line != want[i] {
+			t.Errorf("line %d =\n\t%s\nwant\n\t%s", i, e.Line, want[i])
+		}
+	}
+}
+
+func TestSlogCaller(t *testing.T) {
+	sl, l, h := newSlog(t)
+	l.SetFlags(Lshortfile)
+	sl.Info("where")
+	l.Flush()
+	if line := h.Entries()[0].Line; !strings.Contains(line, "slog_test.go:") {
+		t.Errorf("line %q does not show the slog caller", line)
+	}
+}
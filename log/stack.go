@@ -0,0 +1,79 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth bounds the number of frames captured by ErrorStack.
+const maxStackDepth = 64
+
+// ErrorStack logs err at LevelError with a stack trace. The trace is the
+// one carried by err when available, either through a StackTrace() []uintptr
+// method anywhere in its chain or by formatting with %+v like the errors of
+// github.com/pkg/errors, and otherwise the stack of the caller.
+func (l *Logger) ErrorStack(err error) {
+	l.errorStack(2, err)
+}
+
+func ErrorStack(err error) {
+	StdLogger().errorStack(2, err)
+}
+
+func (l *Logger) errorStack(callDepth int, err error) {
+	if !l.Enabled(LevelError) {
+		return
+	}
+
+	r := l.callerRecord(callDepth, LevelError)
+	if err == nil {
+		r.Msg = "<nil>"
+	} else {
+		r.Msg = err.Error()
+		r.Stack = errorStackTrace(err)
+	}
+	if r.Stack == "" {
+		pcs := make([]uintptr, maxStackDepth)
+		// skip runtime.Callers, errorStack and its caller
+		r.Stack = formatStack(pcs[:runtime.Callers(callDepth+1, pcs)])
+	}
+
+	l.output(&r)
+}
+
+// errorStackTrace returns the stack trace carried by err, or "".
+func errorStackTrace(err error) string {
+	var st interface{ StackTrace() []uintptr }
+	if errors.As(err, &st) {
+		return formatStack(st.StackTrace())
+	}
+	if _, ok := err.(fmt.Formatter); ok {
+		// %+v starts with the message, followed by the stack
+		verbose := fmt.Sprintf("%+v", err)
+		return strings.Trim(strings.TrimPrefix(verbose, err.Error()), "\n")
+	}
+	return ""
+}
+
+// formatStack formats pcs like a goroutine trace in a panic:
+//
+//	main.handle(...)
+//		/src/main.go:42
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" || f.File != "" {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "%s(...)\n\t%s:%d", f.Function, f.File, f.Line)
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
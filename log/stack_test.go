@@ -0,0 +1,136 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// stackError carries the stack where it was created, like the errors of
+// github.com/pkg/errors.
+type stackError struct {
+	msg string
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []uintptr { return e.pcs }
+
+//go:noinline
+func failDeep() error {
+	pcs := make([]uintptr, 16)
+	return &stackError{"deep failure", pcs[:runtime.Callers(1, pcs)]}
+}
+
+// verboseError renders its stack with %+v only.
+type verboseError struct{}
+
+func (verboseError) Error() string { return "verbose failure" }
+
+func (e verboseError) Format(s fmt.State, verb rune) {
+	io.WriteString(s, e.Error())
+	if s.Flag('+') {
+		io.WriteString(s, "\nlib.Origin\n\t/lib/origin.go:7")
+	}
+}
+
+// stackLines logs err with ErrorStack and returns the logged lines.
+func stackLines(t *testing.T, err error) []string {
+	t.Helper()
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetLevel(LevelAll)
+	l.SetFlags(Llevel)
+	l.ErrorStack(err)
+	l.Close()
+	entries := h.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v", entries)
+	}
+	return strings.Split(entries[0].Line, "\n")
+}
+
+func TestErrorStackCallSite(t *testing.T) {
+	lines := stackLines(t, errors.New("plain"))
+	if lines[0] != "ERROR - plain" {
+		t.Errorf("first line = %q", lines[0])
+	}
+	// The trace starts at the caller of ErrorStack, without the logging
+	// frames, formatted like a panic.
+	if len(lines) < 3 || lines[1] != "github.com/0x6666/util/log.stackLines(...)" || !strings.HasPrefix(lines[2], "\t") ||
+		!strings.Contains(lines[2], "stack_test.go:") {
+		t.Errorf("trace = %q", lines[1:])
+	}
+
+	lines = stackLines(t, nil)
+	if lines[0] != "ERROR - <nil>" || len(lines) < 3 {
+		t.Errorf("nil error logged as %q", lines)
+	}
+}
+
+func TestErrorStackCarried(t *testing.T) {
+	err := fmt.Errorf("loading config: %w", failDeep())
+	lines := stackLines(t, err)
+	if lines[0] != "ERROR - loading config: deep failure" {
+		t.Errorf("first line = %q", lines[0])
+	}
+	if len(lines) < 2 || lines[1] != "github.com/0x6666/util/log.failDeep(...)" {
+		t.Errorf("trace = %q, want the one of the wrapped error", lines[1:])
+	}
+
+	lines = stackLines(t, verboseError{})
+	if want := []string{"ERROR - verbose failure", "lib.Origin", "\t/lib/origin.go:7"}; fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestErrorStackJSON(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFormatter(NewJSONFormatter())
+	l.SetLevel(LevelAll)
+	l.ErrorStack(verboseError{})
+	l.Close()
+
+	var got jsonLine
+	if err := json.Unmarshal([]byte(h.Entries()[0].Line), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Msg != "verbose failure" || got.Stack != "lib.Origin\n\t/lib/origin.go:7" {
+		t.Errorf("decoded %+v", got)
+	}
+}
+
+func TestErrorStackDisabled(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.ErrorStack(errors.New("hidden"))
+	l.Close()
+	if len(h.Entries()) != 0 {
+		t.Errorf("logged %v with errors disabled", h.Entries())
+	}
+}
+
+func TestErrorStackDefaultLogger(t *testing.T) {
+	old := StdLogger()
+	defer defLoger.Store(old)
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetLevel(LevelAll)
+	l.SetFlags(Lshortfile)
+	defLoger.Store(l)
+
+	line := here() + 1
+	ErrorStack(errors.New("from the package"))
+	l.Close()
+	lines := strings.Split(h.Entries()[0].Line, "\n")
+	if lines[0] != fmt.Sprintf("stack_test.go:[%d] - from the package", line) ||
+		len(lines) < 2 || lines[1] != "github.com/0x6666/util/log.TestErrorStackDefaultLogger(...)" {
+		t.Errorf("lines = %q", lines)
+	}
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// openLinked opens the file of the current period for a handler using
+// WithCurrentSymlink. A regular file left at the base name by a handler
+// without the option is first moved to its dated name.
+func (h *TimeRotatingFileHandler) openLinked() error {
+	now := h.opts.now()
+	if fi, err := os.Lstat(h.baseName); err == nil && fi.Mode().IsRegular() {
+		dated := h.baseName + periodStart(fi.ModTime(), h.when, h.interval).Format(h.suffix)
+		if _, err := os.Lstat(dated); err == nil {
+			return fmt.Errorf("log: cannot replace %s with a symlink, %s already exists", h.baseName, dated)
+		}
+		if err := os.Rename(h.baseName, dated); err != nil {
+			return err
+		}
+	}
+
+	h.setPeriod(now)
+	var err error
+	h.fd, err = h.openPeriodFile()
+	return err
+}
+
+// openPeriodFile opens the dated file of the current period and points the
+// symlink at it.
+func (h *TimeRotatingFileHandler) openPeriodFile() (*os.File, error) {
+	name := h.baseName + h.periodStart.Format(h.suffix)
+	fd, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	updateSymlink(h.baseName, filepath.Base(name))
+	return fd, nil
+}
+
+// updateSymlink atomically points link at target by renaming a temporary
+// symlink over it. Failures, e.g. on systems without symlinks, are ignored.
+func updateSymlink(link, target string) {
+	tmp := link + ".tmp-link"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// withoutFile returns files minus the one at path.
+func withoutFile(files []rotatedFile, path string) []rotatedFile {
+	kept := files[:0]
+	for _, f := range files {
+		if f.path != path {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
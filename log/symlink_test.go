@@ -0,0 +1,114 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// linkTarget returns where the symlink at path points.
+func linkTarget(t *testing.T, path string) string {
+	t.Helper()
+	target, err := os.Readlink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestCurrentSymlink(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	now := time.Date(2025, 1, 10, 23, 59, 0, 0, time.Local)
+	h, err := NewTimeRotatingFileHandler(base, WhenDay, 1, WithCurrentSymlink(), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("today\n"))
+	if got := linkTarget(t, base); got != "app.log2025-01-10" {
+		t.Errorf("link points at %q", got)
+	}
+
+	now = now.Add(time.Minute)
+	h.Write([]byte("tomorrow\n"))
+	h.Close()
+
+	// The link is relative and follows the rotation; nothing is renamed.
+	if got := linkTarget(t, base); got != "app.log2025-01-11" {
+		t.Errorf("link points at %q after rotating", got)
+	}
+	if got := readFile(t, base); got != "tomorrow\n" {
+		t.Errorf("read through the link: %q", got)
+	}
+	if got := readFile(t, base+"2025-01-10"); got != "today\n" {
+		t.Errorf("previous file = %q", got)
+	}
+	if got := strings.Join(listDir(t, dir), " "); got != "app.log app.log2025-01-10 app.log2025-01-11" {
+		t.Errorf("files = %s", got)
+	}
+}
+
+func TestCurrentSymlinkReplacesRegularFile(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	os.WriteFile(base, []byte("old\n"), 0666)
+	written := time.Date(2025, 1, 9, 8, 0, 0, 0, time.Local)
+	os.Chtimes(base, written, written)
+
+	now := time.Date(2025, 1, 10, 9, 0, 0, 0, time.Local)
+	h, err := NewTimeRotatingFileHandler(base, WhenDay, 1, WithCurrentSymlink(), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	// The file left by a handler without the option becomes the backup of
+	// the day it was written.
+	if got := readFile(t, base+"2025-01-09"); got != "old\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := linkTarget(t, base); got != "app.log2025-01-10" {
+		t.Errorf("link points at %q", got)
+	}
+
+	// A regular file is not moved over an existing backup.
+	os.Remove(base)
+	os.WriteFile(base, []byte("newer\n"), 0666)
+	os.Chtimes(base, written, written)
+	if _, err := NewTimeRotatingFileHandler(base, WhenDay, 1, WithCurrentSymlink()); err == nil {
+		t.Error("the existing backup would be overwritten")
+	}
+	if got := readFile(t, base); got != "newer\n" {
+		t.Errorf("file at the base name = %q", got)
+	}
+}
+
+func TestWithoutCurrentSymlink(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.log")
+	h, err := NewTimeRotatingFileHandler(base, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if fi, err := os.Lstat(base); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("base name is %v, %v, want a regular file by default", fi, err)
+	}
+}
+
+func TestUpdateSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "current")
+	updateSymlink(link, "a")
+	updateSymlink(link, "b")
+	if got := linkTarget(t, link); got != "b" {
+		t.Errorf("link points at %q", got)
+	}
+	if got := strings.Join(listDir(t, dir), " "); got != "current" {
+		t.Errorf("files = %s, want no temporary link left", got)
+	}
+
+	// Failing to create the link is not an error.
+	updateSymlink(filepath.Join(dir, "missing", "current"), "a")
+}
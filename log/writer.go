@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+	"sync"
+)
+
+// maxPartialLine bounds how much of a line without a newline a LevelWriter
+// buffers before logging it anyway.
+const maxPartialLine = 64 << 10
+
+// LevelWriter is an io.Writer that logs each line written to it at a fixed
+// level, for libraries that only accept an io.Writer or a *log.Logger from
+// the standard library. The file and line of the records point at the
+// adapter rather than at the library's internals.
+//
+// A trailing partial line is buffered until its newline arrives, until it
+// grows past 64KB, or until Flush or Close is called. LevelWriter is safe
+// for concurrent use.
+type LevelWriter struct {
+	l     *Logger
+	level LogLever
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Writer returns a LevelWriter logging at level through l.
+func (l *Logger) Writer(level LogLever) *LevelWriter {
+	return &LevelWriter{l: l, level: level}
+}
+
+// StdLogger returns a standard library logger whose output is logged at
+// level through l. Its own prefix and flags are empty, since l adds the
+// time and level.
+func (l *Logger) StdLogger(level LogLever) *stdlog.Logger {
+	return stdlog.New(l.Writer(level), "", 0)
+}
+
+func (w *LevelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) > maxPartialLine {
+		w.emit(w.buf)
+		w.buf = w.buf[:0]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush logs the buffered partial line, if any.
+func (w *LevelWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+// Close flushes the writer. It does not close the Logger.
+func (w *LevelWriter) Close() error {
+	w.Flush()
+	return nil
+}
+
+func (w *LevelWriter) emit(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(line) == 0 {
+		return
+	}
+	w.l.Output(1, w.level, "%s", line)
+}
@@ -0,0 +1,112 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// loggedLines returns the lines recorded by h.
+func loggedLines(h *MemoryHandler) []string {
+	var r []string
+	for _, e := range h.Entries() {
+		r = append(r, e.Line)
+	}
+	return r
+}
+
+func TestLevelWriter(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetLevel(LevelAll)
+	l.SetFlags(Llevel | Lshortfile)
+	w := l.Writer(LevelWarn)
+
+	w.Write([]byte("one\ntwo\r\n\nthr"))
+	w.Write([]byte("ee\npartial"))
+	l.Flush()
+	// The caller is the adapter, not the code calling Write.
+	got := loggedLines(h)
+	want := []string{"one", "two", "three"}
+	if len(got) != len(want) {
+		t.Fatalf("lines = %q, want %q", got, want)
+	}
+	for i, line := range got {
+		if !strings.HasPrefix(line, "WARN - writer.go:[") || !strings.HasSuffix(line, "] - "+want[i]) {
+			t.Errorf("line %d = %q, want %q from writer.go", i, line, want[i])
+		}
+	}
+
+	// The partial line waits for Flush or Close.
+	w.Close()
+	l.Close()
+	if got := loggedLines(h); len(got) != 4 || !strings.HasSuffix(got[3], " - partial") {
+		t.Errorf("lines after Close = %q", got)
+	}
+}
+
+func TestLevelWriterLongLine(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(0)
+	w := l.Writer(LevelInfo)
+	long := strings.Repeat("x", maxPartialLine+1)
+	w.Write([]byte(long))
+	l.Close()
+	if got := loggedLines(h); len(got) != 1 || got[0] != long {
+		t.Errorf("a line past the limit was not logged without its newline: %d lines", len(got))
+	}
+}
+
+func TestLevelWriterDisabledLevel(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	w := l.Writer(LevelDebug)
+	if n, err := w.Write([]byte("hidden\n")); n != 7 || err != nil {
+		t.Errorf("Write = %d, %v", n, err)
+	}
+	l.Close()
+	if got := loggedLines(h); len(got) != 0 {
+		t.Errorf("debug lines logged at LevelInfo: %q", got)
+	}
+}
+
+func TestLevelWriterConcurrent(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetFlags(0)
+	w := l.Writer(LevelInfo)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				// Lines are split across writes so they interleave.
+				fmt.Fprintf(w, "g%d ", g)
+				fmt.Fprintf(w, "line %d\n", i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	l.Close()
+	if got := loggedLines(h); len(got) != 400 {
+		t.Errorf("%d lines, want 400", len(got))
+	}
+}
+
+func TestStdLoggerAdapter(t *testing.T) {
+	h := NewMemoryHandler()
+	l := New(h)
+	l.SetLevel(LevelAll)
+	l.SetFlags(Llevel)
+	std := l.StdLogger(LevelError)
+	std.Printf("http: TLS handshake error from %s", "10.0.0.1")
+	std.Print("no newline")
+	l.Close()
+	want := []string{"ERROR - http: TLS handshake error from 10.0.0.1", "ERROR - no newline"}
+	if got := loggedLines(h); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
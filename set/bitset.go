@@ -0,0 +1,135 @@
+package set
+
+import "math/bits"
+
+// BitSet is a set of non-negative integers stored as one bit per possible
+// member, which suits dense ranges of small IDs far better than a map. It
+// grows automatically on Add. The zero value is an empty set ready to use.
+type BitSet struct {
+	words []uint64
+}
+
+// NewBitSet returns an empty set with room for members in [0, size) before
+// it needs to grow.
+func NewBitSet(size int) *BitSet {
+	if size < 0 {
+		size = 0
+	}
+	return &BitSet{words: make([]uint64, (size+63)/64)}
+}
+
+// Add inserts i. It panics if i is negative.
+func (b *BitSet) Add(i int) {
+	if i < 0 {
+		panic("set: negative BitSet index")
+	}
+	w := i / 64
+	if w >= len(b.words) {
+		if w < cap(b.words) {
+			n := len(b.words)
+			b.words = b.words[:w+1]
+			clear(b.words[n:])
+		} else {
+			words := make([]uint64, w+1, max(w+1, 2*cap(b.words)))
+			copy(words, b.words)
+			b.words = words
+		}
+	}
+	b.words[w] |= 1 << (uint(i) % 64)
+}
+
+// Has reports whether i is a member. Negative values are never members.
+func (b *BitSet) Has(i int) bool {
+	if i < 0 || i/64 >= len(b.words) {
+		return false
+	}
+	return b.words[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+// Remove deletes i and reports whether it was present.
+func (b *BitSet) Remove(i int) bool {
+	if !b.Has(i) {
+		return false
+	}
+	b.words[i/64] &^= 1 << (uint(i) % 64)
+	return true
+}
+
+// Count returns the number of members.
+func (b *BitSet) Count() int {
+	n := 0
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Clear removes all members, keeping the allocated words.
+func (b *BitSet) Clear() {
+	clear(b.words)
+}
+
+// NextSet returns the smallest member >= from, or false if there is none.
+// Iterate with:
+//
+//	for i, ok := b.NextSet(0); ok; i, ok = b.NextSet(i + 1) {
+//		...
+//	}
+func (b *BitSet) NextSet(from int) (int, bool) {
+	if from < 0 {
+		from = 0
+	}
+	w := from / 64
+	if w >= len(b.words) {
+		return 0, false
+	}
+	if word := b.words[w] >> (uint(from) % 64); word != 0 {
+		return from + bits.TrailingZeros64(word), true
+	}
+	for w++; w < len(b.words); w++ {
+		if b.words[w] != 0 {
+			return w*64 + bits.TrailingZeros64(b.words[w]), true
+		}
+	}
+	return 0, false
+}
+
+// Clone returns an independent copy of b.
+func (b *BitSet) Clone() *BitSet {
+	return &BitSet{words: append([]uint64(nil), b.words...)}
+}
+
+// Union returns a new set holding the members of b and other.
+func (b *BitSet) Union(other *BitSet) *BitSet {
+	long, short := b.words, other.words
+	if len(long) < len(short) {
+		long, short = short, long
+	}
+	r := &BitSet{words: append([]uint64(nil), long...)}
+	for i, w := range short {
+		r.words[i] |= w
+	}
+	return r
+}
+
+// Intersect returns a new set holding the members present in both b and
+// other.
+func (b *BitSet) Intersect(other *BitSet) *BitSet {
+	n := min(len(b.words), len(other.words))
+	r := &BitSet{words: make([]uint64, n)}
+	for i := 0; i < n; i++ {
+		r.words[i] = b.words[i] & other.words[i]
+	}
+	return r
+}
+
+// Difference returns a new set holding the members of b that are not in
+// other.
+func (b *BitSet) Difference(other *BitSet) *BitSet {
+	r := b.Clone()
+	n := min(len(r.words), len(other.words))
+	for i := 0; i < n; i++ {
+		r.words[i] &^= other.words[i]
+	}
+	return r
+}
@@ -0,0 +1,124 @@
+package set
+
+import (
+	"reflect"
+	"testing"
+)
+
+func bitMembers(b *BitSet) []int {
+	var r []int
+	for i, ok := b.NextSet(0); ok; i, ok = b.NextSet(i + 1) {
+		r = append(r, i)
+	}
+	return r
+}
+
+func TestBitSet(t *testing.T) {
+	var b BitSet
+	for _, i := range []int{0, 63, 64, 1000, 5} {
+		b.Add(i)
+	}
+	if b.Count() != 5 || !b.Has(1000) || b.Has(999) || b.Has(-1) || b.Has(1<<20) {
+		t.Fatalf("members %v", bitMembers(&b))
+	}
+	if got := bitMembers(&b); !reflect.DeepEqual(got, []int{0, 5, 63, 64, 1000}) {
+		t.Errorf("NextSet iteration = %v", got)
+	}
+	if i, ok := b.NextSet(65); !ok || i != 1000 {
+		t.Errorf("NextSet(65) = %d, %v", i, ok)
+	}
+	if _, ok := b.NextSet(1001); ok {
+		t.Error("NextSet past the last member")
+	}
+	if !b.Remove(63) || b.Remove(63) || b.Remove(-3) || b.Remove(1<<20) {
+		t.Error("Remove")
+	}
+	b.Clear()
+	if b.Count() != 0 {
+		t.Error("Clear")
+	}
+	b.Add(3)
+	if !b.Has(3) {
+		t.Error("Add after Clear")
+	}
+}
+
+func TestBitSetNegativePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Add(-1) did not panic")
+		}
+	}()
+	NewBitSet(8).Add(-1)
+}
+
+func TestBitSetAlgebra(t *testing.T) {
+	a, b := NewBitSet(0), NewBitSet(0)
+	for _, i := range []int{1, 2, 100} {
+		a.Add(i)
+	}
+	for _, i := range []int{2, 3, 500} {
+		b.Add(i)
+	}
+	if got := bitMembers(a.Union(b)); !reflect.DeepEqual(got, []int{1, 2, 3, 100, 500}) {
+		t.Errorf("Union = %v", got)
+	}
+	if got := bitMembers(a.Intersect(b)); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Intersect = %v", got)
+	}
+	if got := bitMembers(a.Difference(b)); !reflect.DeepEqual(got, []int{1, 100}) {
+		t.Errorf("Difference = %v", got)
+	}
+	if got := bitMembers(b.Difference(a)); !reflect.DeepEqual(got, []int{3, 500}) {
+		t.Errorf("reverse Difference = %v", got)
+	}
+	c := a.Clone()
+	c.Add(7)
+	if a.Has(7) {
+		t.Error("Clone shares words")
+	}
+}
+
+const denseIDs = 1 << 20
+
+func BenchmarkBitSetAdd(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s BitSet
+		for id := 0; id < denseIDs; id++ {
+			s.Add(id)
+		}
+	}
+}
+
+func BenchmarkSetIntAdd(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := make(Set[int])
+		for id := 0; id < denseIDs; id++ {
+			s.Add(id)
+		}
+	}
+}
+
+func BenchmarkBitSetHas(b *testing.B) {
+	s := NewBitSet(denseIDs)
+	for id := 0; id < denseIDs; id += 2 {
+		s.Add(id)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Has(i % denseIDs)
+	}
+}
+
+func BenchmarkSetIntHas(b *testing.B) {
+	s := make(Set[int], denseIDs/2)
+	for id := 0; id < denseIDs; id += 2 {
+		s.Add(id)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Has(i % denseIDs)
+	}
+}
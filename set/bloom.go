@@ -0,0 +1,179 @@
+package set
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+)
+
+var (
+	ErrBloomIncompatible = errors.New("set: bloom filters have different parameters")
+	ErrCorruptBloom      = errors.New("set: corrupt bloom filter data")
+)
+
+const bloomMagic = "bloom v1"
+
+// Bloom is a probabilistic membership filter. MayContain never reports a
+// false negative, and reports a false positive with roughly the rate the
+// filter was sized for, while using a small fraction of the memory of a
+// set. Members cannot be removed or listed. Bloom is not safe for
+// concurrent use.
+type Bloom struct {
+	m     uint64 // number of bits
+	k     uint64 // number of hash functions
+	words []uint64
+}
+
+// NewBloom returns a filter sized to hold expectedItems members with the
+// given false positive rate, using the optimal number of bits and hash
+// functions for those parameters.
+func NewBloom(expectedItems int, falsePositiveRate float64) *Bloom {
+	if expectedItems < 1 {
+		expectedItems = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+	n := float64(expectedItems)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/n*math.Ln2))
+	words := (uint64(m) + 63) / 64
+	return &Bloom{
+		m:     words * 64,
+		k:     uint64(k),
+		words: make([]uint64, words),
+	}
+}
+
+// bloomHashes returns the two base hashes used for double hashing: the i-th
+// probe is h1 + i*h2. The halves of FNV-128a are run through a finalizer
+// because its high half barely changes between short, similar inputs.
+func bloomHashes(data []byte) (h1, h2 uint64) {
+	h := fnv.New128a()
+	h.Write(data)
+	sum := h.Sum(nil)
+	hi, lo := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+	return mix64(lo), mix64(hi^lo) | 1
+}
+
+// mix64 is the 64-bit finalizer of MurmurHash3.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (b *Bloom) AddBytes(data []byte) {
+	h1, h2 := bloomHashes(data)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.words[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *Bloom) Add(s string) {
+	b.AddBytes([]byte(s))
+}
+
+// MayContainBytes reports whether data may have been added. A false result
+// is definite; a true result is wrong with about the configured rate.
+func (b *Bloom) MayContainBytes(data []byte) bool {
+	h1, h2 := bloomHashes(data)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.words[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bloom) MayContain(s string) bool {
+	return b.MayContainBytes([]byte(s))
+}
+
+// EstimatedCount estimates the number of distinct members added, from the
+// fraction of bits set.
+func (b *Bloom) EstimatedCount() int {
+	set := 0
+	for _, w := range b.words {
+		set += bits.OnesCount64(w)
+	}
+	m, k := float64(b.m), float64(b.k)
+	if set == len(b.words)*64 {
+		return int(m / k)
+	}
+	return int(math.Round(-m / k * math.Log(1-float64(set)/m)))
+}
+
+// Union adds the members of other to b. Both filters must have been
+// created with the same parameters, otherwise ErrBloomIncompatible is
+// returned and b is unchanged.
+func (b *Bloom) Union(other *Bloom) error {
+	if b.m != other.m || b.k != other.k {
+		return ErrBloomIncompatible
+	}
+	for i, w := range other.words {
+		b.words[i] |= w
+	}
+	return nil
+}
+
+// WriteTo writes the filter in a binary format readable by ReadFrom.
+func (b *Bloom) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, len(bloomMagic)+16)
+	buf = append(buf, bloomMagic...)
+	buf = binary.BigEndian.AppendUint64(buf, b.m)
+	buf = binary.BigEndian.AppendUint64(buf, b.k)
+	n, err := bw.Write(buf)
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+	for _, word := range b.words {
+		n, err = bw.Write(binary.BigEndian.AppendUint64(buf[:0], word))
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// ReadFrom replaces the filter with one written by WriteTo.
+func (b *Bloom) ReadFrom(r io.Reader) (int64, error) {
+	header := make([]byte, len(bloomMagic)+16)
+	n, err := io.ReadFull(r, header)
+	read := int64(n)
+	if err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		return read, ErrCorruptBloom
+	}
+	m := binary.BigEndian.Uint64(header[len(bloomMagic):])
+	k := binary.BigEndian.Uint64(header[len(bloomMagic)+8:])
+	if m == 0 || m%64 != 0 || k == 0 {
+		return read, ErrCorruptBloom
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(m/8)))
+	read += int64(len(data))
+	if err != nil {
+		return read, err
+	}
+	if uint64(len(data)) != m/8 {
+		return read, ErrCorruptBloom
+	}
+	words := make([]uint64, m/64)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint64(data[i*8:])
+	}
+	b.m, b.k, b.words = m, k, words
+	return read, nil
+}
@@ -0,0 +1,152 @@
+package set
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestBloomNoFalseNegatives(t *testing.T) {
+	b := NewBloom(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		b.Add(fmt.Sprintf("member-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !b.MayContain(fmt.Sprintf("member-%d", i)) {
+			t.Fatalf("member-%d: false negative", i)
+		}
+	}
+	b.AddBytes([]byte{0, 1, 2})
+	if !b.MayContainBytes([]byte{0, 1, 2}) || !b.MayContain("\x00\x01\x02") {
+		t.Error("AddBytes and Add should hash the same data alike")
+	}
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	for _, target := range []float64{0.1, 0.01, 0.001} {
+		const items, probes = 20000, 200000
+		b := NewBloom(items, target)
+		for i := 0; i < items; i++ {
+			b.Add(fmt.Sprintf("in-%d", i))
+		}
+		fp := 0
+		for i := 0; i < probes; i++ {
+			if b.MayContain(fmt.Sprintf("out-%d", i)) {
+				fp++
+			}
+		}
+		// Allow for rounding of k and sampling noise: at 0.001 there are
+		// about 200 expected hits, so ±50% is several standard deviations.
+		if rate := float64(fp) / probes; rate > target*1.5 || rate < target*0.5 {
+			t.Errorf("target %v: observed false positive rate %v", target, rate)
+		}
+	}
+}
+
+func TestBloomSizing(t *testing.T) {
+	b := NewBloom(1000, 0.01)
+	// m = -n ln p / ln²2 ≈ 9586 bits, k = m/n ln 2 ≈ 7.
+	if b.m < 9586 || b.m%64 != 0 || b.m > 9586+63 || b.k != 7 {
+		t.Errorf("NewBloom(1000, 0.01): m = %d, k = %d", b.m, b.k)
+	}
+	for _, p := range []float64{0, -1, 1, 2} {
+		if got, want := NewBloom(1000, p), NewBloom(1000, 0.01); got.m != want.m || got.k != want.k {
+			t.Errorf("rate %v should default to 0.01", p)
+		}
+	}
+	if b := NewBloom(0, 0.01); b.k < 1 || len(b.words) == 0 {
+		t.Errorf("NewBloom(0, ...) = m %d, k %d", b.m, b.k)
+	}
+}
+
+func TestBloomEstimatedCount(t *testing.T) {
+	b := NewBloom(10000, 0.01)
+	if n := b.EstimatedCount(); n != 0 {
+		t.Errorf("empty filter estimates %d", n)
+	}
+	for i := 0; i < 5000; i++ {
+		b.Add(fmt.Sprintf("%d", i))
+		b.Add(fmt.Sprintf("%d", i)) // duplicates are not counted twice
+	}
+	if n := b.EstimatedCount(); math.Abs(float64(n)-5000) > 250 {
+		t.Errorf("EstimatedCount = %d, want about 5000", n)
+	}
+	for i := range b.words {
+		b.words[i] = math.MaxUint64
+	}
+	if n := b.EstimatedCount(); n != int(b.m/b.k) {
+		t.Errorf("saturated filter estimates %d", n)
+	}
+}
+
+func TestBloomUnion(t *testing.T) {
+	a, b := NewBloom(100, 0.01), NewBloom(100, 0.01)
+	a.Add("a")
+	b.Add("b")
+	if err := a.Union(b); err != nil {
+		t.Fatal(err)
+	}
+	if !a.MayContain("a") || !a.MayContain("b") {
+		t.Error("Union lost a member")
+	}
+
+	before := append([]uint64(nil), a.words...)
+	for _, other := range []*Bloom{NewBloom(1000, 0.01), NewBloom(100, 0.2)} {
+		other.Add("c")
+		if err := a.Union(other); err != ErrBloomIncompatible {
+			t.Errorf("Union(m %d, k %d) err = %v, want ErrBloomIncompatible", other.m, other.k, err)
+		}
+	}
+	for i := range before {
+		if a.words[i] != before[i] {
+			t.Fatal("a failed Union modified the filter")
+		}
+	}
+}
+
+func TestBloomWriteReadFrom(t *testing.T) {
+	b := NewBloom(500, 0.01)
+	for i := 0; i < 500; i++ {
+		b.Add(fmt.Sprintf("k%d", i))
+	}
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) || n != int64(len(bloomMagic)+16)+int64(b.m/8) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	data := buf.Bytes()
+
+	var got Bloom
+	if n, err := got.ReadFrom(bytes.NewReader(data)); err != nil || n != int64(len(data)) {
+		t.Fatalf("ReadFrom = %d, %v", n, err)
+	}
+	if got.m != b.m || got.k != b.k || got.EstimatedCount() != b.EstimatedCount() {
+		t.Errorf("round trip: m %d k %d, want m %d k %d", got.m, got.k, b.m, b.k)
+	}
+	for i := 0; i < 500; i++ {
+		if !got.MayContain(fmt.Sprintf("k%d", i)) {
+			t.Fatalf("k%d lost in the round trip", i)
+		}
+	}
+	if err := got.Union(b); err != nil {
+		t.Errorf("a read filter should be compatible with its source: %v", err)
+	}
+
+	hdr := len(bloomMagic)
+	for name, bad := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("bloom v2"), data[hdr:]...),
+		"short":     data[:len(data)-1],
+		"header":    data[:hdr+4],
+		"zero bits": append(append([]byte(bloomMagic), make([]byte, 8)...), data[hdr+8:]...),
+	} {
+		var r Bloom
+		if _, err := r.ReadFrom(bytes.NewReader(bad)); err != ErrCorruptBloom {
+			t.Errorf("%s: err = %v, want ErrCorruptBloom", name, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package set
+
+import (
+	"context"
+	"iter"
+)
+
+// Collect returns a set holding every string produced by seq.
+func Collect(seq iter.Seq[string]) StrSet {
+	s := make(StrSet)
+	for str := range seq {
+		s.Add(str)
+	}
+	return s
+}
+
+// CollectChan reads from ch until it is closed and returns the set of
+// received strings.
+func CollectChan(ch <-chan string) StrSet {
+	s := make(StrSet)
+	for str := range ch {
+		s.Add(str)
+	}
+	return s
+}
+
+// CollectN reads from ch until it is closed or a string would grow the set
+// beyond limit members. truncated reports whether a member was dropped; in
+// that case ch has not been drained.
+func CollectN(ch <-chan string, limit int) (s StrSet, truncated bool) {
+	s = make(StrSet)
+	for str := range ch {
+		if s.Has(str) {
+			continue
+		}
+		if len(s) >= limit {
+			return s, true
+		}
+		s.Add(str)
+	}
+	return s, false
+}
+
+// CollectCtx is like CollectChan but gives up when ctx is done, returning
+// the members received so far together with ctx.Err().
+func CollectCtx(ctx context.Context, ch <-chan string) (StrSet, error) {
+	s := make(StrSet)
+	for {
+		select {
+		case str, ok := <-ch:
+			if !ok {
+				return s, nil
+			}
+			s.Add(str)
+		case <-ctx.Done():
+			return s, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package set
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// feed returns a closed channel holding items.
+func feed(items ...string) <-chan string {
+	ch := make(chan string, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return ch
+}
+
+func TestCollect(t *testing.T) {
+	s := Collect(slices.Values([]string{"a", "b", "a"}))
+	if !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("Collect = %v", s)
+	}
+	if s := Collect(slices.Values([]string(nil))); s == nil || s.Count() != 0 {
+		t.Errorf("Collect(empty) = %#v, want an empty non nil set", s)
+	}
+}
+
+func TestCollectChan(t *testing.T) {
+	if s := CollectChan(feed("x", "y", "x")); !s.Equal(NewStrSet("x", "y")) {
+		t.Errorf("CollectChan = %v", s)
+	}
+	if s := CollectChan(feed()); s == nil || s.Count() != 0 {
+		t.Errorf("CollectChan(closed) = %#v", s)
+	}
+
+	// Strings sent while collecting are all seen before the close.
+	ch := make(chan string)
+	go func() {
+		for _, str := range []string{"1", "2", "3"} {
+			ch <- str
+		}
+		close(ch)
+	}()
+	if s := CollectChan(ch); s.Count() != 3 {
+		t.Errorf("CollectChan from a producer = %v", s)
+	}
+}
+
+func TestCollectN(t *testing.T) {
+	s, truncated := CollectN(feed("a", "b", "a", "b"), 2)
+	if truncated || !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("duplicates at the limit: %v, truncated %v", s, truncated)
+	}
+
+	ch := feed("a", "b", "c", "d")
+	s, truncated = CollectN(ch, 2)
+	if !truncated || !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("CollectN = %v, truncated %v", s, truncated)
+	}
+	// c was read and dropped; d is still in the channel.
+	if rest := CollectChan(ch); !rest.Equal(NewStrSet("d")) {
+		t.Errorf("channel left with %v, want d", rest)
+	}
+
+	if s, truncated = CollectN(feed("a"), 0); !truncated || s.Count() != 0 {
+		t.Errorf("limit 0: %v, truncated %v", s, truncated)
+	}
+	if s, truncated = CollectN(feed(), 0); truncated || s.Count() != 0 {
+		t.Errorf("closed channel with limit 0: %v, truncated %v", s, truncated)
+	}
+}
+
+func TestCollectCtx(t *testing.T) {
+	s, err := CollectCtx(context.Background(), feed("a", "b"))
+	if err != nil || !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("CollectCtx(closed) = %v, %v", s, err)
+	}
+
+	ch := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var got StrSet
+	go func() {
+		defer close(done)
+		got, err = CollectCtx(ctx, ch)
+	}()
+	ch <- "first"
+	ch <- "second"
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CollectCtx did not return after cancel")
+	}
+	if err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if !got.Equal(NewStrSet("first", "second")) {
+		t.Errorf("CollectCtx returned %v, want what it had received", got)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if s, err := CollectCtx(ctx, make(chan string)); err != context.DeadlineExceeded || s.Count() != 0 {
+		t.Errorf("CollectCtx(deadline) = %v, %v", s, err)
+	}
+}
@@ -0,0 +1,164 @@
+package set
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var errBinaryFormat = errors.New("set: invalid binary encoding")
+
+// MarshalJSON encodes the set as a sorted JSON array of strings. A nil set
+// is encoded as an empty array, never as null.
+func (s StrSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.SortedSlice())
+}
+
+// UnmarshalJSON decodes a JSON array of strings into the set, collapsing
+// duplicates. The legacy object form {"a":true,"b":true} produced by older
+// versions is still accepted; members mapped to false are skipped.
+func (s *StrSet) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*s = nil
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var m map[string]bool
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		*s = FromBoolMap(m)
+		return nil
+	}
+
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*s = FromSlice(items)
+	return nil
+}
+
+// MarshalBinary encodes the set as a sorted list of length-prefixed strings.
+// The encoding does not depend on how the set is represented in memory, so
+// values stored through the cache package keep decoding across versions.
+func (s StrSet) MarshalBinary() ([]byte, error) {
+	members := s.SortedSlice()
+	size := binary.MaxVarintLen64
+	for _, str := range members {
+		size += binary.MaxVarintLen64 + len(str)
+	}
+	buf := make([]byte, 0, size)
+	buf = binary.AppendUvarint(buf, uint64(len(members)))
+	for _, str := range members {
+		buf = binary.AppendUvarint(buf, uint64(len(str)))
+		buf = append(buf, str...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, replacing the
+// contents of the set.
+func (s *StrSet) UnmarshalBinary(data []byte) error {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return errBinaryFormat
+	}
+	data = data[n:]
+	r := make(StrSet, count)
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return errBinaryFormat
+		}
+		data = data[n:]
+		r.Add(string(data[:size]))
+		data = data[size:]
+	}
+	if len(data) != 0 {
+		return errBinaryFormat
+	}
+	*s = r
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder using MarshalBinary.
+func (s StrSet) GobEncode() ([]byte, error) {
+	return s.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder using UnmarshalBinary.
+func (s *StrSet) GobDecode(data []byte) error {
+	return s.UnmarshalBinary(data)
+}
+
+// MarshalText encodes the set as its sorted members joined by commas, for
+// config formats that honor encoding.TextMarshaler. There is no escaping:
+// members that contain a comma, are empty, or have leading or trailing
+// whitespace cannot survive UnmarshalText and are rejected with an error.
+func (s StrSet) MarshalText() ([]byte, error) {
+	members := s.SortedSlice()
+	for _, str := range members {
+		if str == "" || strings.ContainsRune(str, ',') || strings.TrimSpace(str) != str {
+			return nil, fmt.Errorf("set: member %q cannot be encoded as text", str)
+		}
+	}
+	return []byte(strings.Join(members, ",")), nil
+}
+
+// UnmarshalText decodes a comma-separated list, trimming whitespace around
+// each member and dropping empty ones. It replaces the contents of the set.
+func (s *StrSet) UnmarshalText(text []byte) error {
+	*s = make(StrSet)
+	s.addList(string(text))
+	return nil
+}
+
+// addList adds the members of a comma-separated list, as UnmarshalText
+// reads it.
+func (s *StrSet) addList(list string) {
+	if *s == nil {
+		*s = make(StrSet)
+	}
+	for _, str := range strings.Split(list, ",") {
+		if str = strings.TrimSpace(str); str != "" {
+			s.Add(str)
+		}
+	}
+}
+
+// FlagValue returns a flag.Value adding to s the members of every
+// occurrence of the flag, each a comma-separated list, so the flag can be
+// repeated:
+//
+//	var hosts set.StrSet
+//	flag.Var(hosts.FlagValue(), "host", "`hosts` to query, comma-separated")
+//
+// Its String is the sorted members joined by commas, the form Set parses,
+// rather than the {a, b} of StrSet.String, so defaults print as they are
+// typed.
+func (s *StrSet) FlagValue() flag.Value {
+	return &strSetFlag{s}
+}
+
+type strSetFlag struct {
+	s *StrSet
+}
+
+func (f *strSetFlag) Set(value string) error {
+	f.s.addList(value)
+	return nil
+}
+
+func (f *strSetFlag) String() string {
+	if f == nil || f.s == nil {
+		return ""
+	}
+	return strings.Join(f.s.SortedSlice(), ",")
+}
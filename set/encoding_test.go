@@ -0,0 +1,157 @@
+package set
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestStrSetJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		set  StrSet
+		want string
+	}{
+		{"nil", nil, `[]`},
+		{"empty", NewStrSet(), `[]`},
+		{"sorted", NewStrSet("b", "a", "c"), `["a","b","c"]`},
+		{"unicode", NewStrSet("日本", "émoji 🎉", "a"), `["a","émoji 🎉","日本"]`},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.set)
+		if err != nil || string(b) != tt.want {
+			t.Errorf("%s: Marshal = %s, %v; want %s", tt.name, b, err, tt.want)
+			continue
+		}
+		var got StrSet
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("%s: Unmarshal: %v", tt.name, err)
+		} else if !got.Equal(tt.set) {
+			t.Errorf("%s: round trip = %v, want %v", tt.name, got, tt.set)
+		}
+	}
+}
+
+func TestStrSetUnmarshalJSON(t *testing.T) {
+	var s StrSet
+	if err := json.Unmarshal([]byte(`["a","b","a"]`), &s); err != nil || !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("duplicates: %v, %v", s, err)
+	}
+	if err := json.Unmarshal([]byte(`{"a":true,"b":false}`), &s); err != nil || !s.Equal(NewStrSet("a")) {
+		t.Errorf("legacy object: %v, %v", s, err)
+	}
+	if err := json.Unmarshal([]byte(`null`), &s); err != nil || s != nil {
+		t.Errorf("null: %v, %v", s, err)
+	}
+	if err := json.Unmarshal([]byte(`[1]`), &s); err == nil {
+		t.Error("a non-string member should be rejected")
+	}
+
+	var v struct {
+		Tags StrSet `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(`{"tags":["x","y"]}`), &v); err != nil || !v.Tags.Equal(NewStrSet("x", "y")) {
+		t.Errorf("struct field: %v, %v", v.Tags, err)
+	}
+}
+
+func TestStrSetBinary(t *testing.T) {
+	for _, s := range []StrSet{nil, NewStrSet(), NewStrSet("a", "", "日本", "with,comma")} {
+		b, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got StrSet
+		if err := got.UnmarshalBinary(b); err != nil || !got.Equal(s) {
+			t.Errorf("round trip of %v = %v, %v", s, got, err)
+		}
+	}
+
+	b, _ := NewStrSet("abc").MarshalBinary()
+	for _, bad := range [][]byte{nil, b[:len(b)-1], append(b, 0)} {
+		var got StrSet
+		if err := got.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%v) succeeded", bad)
+		}
+	}
+}
+
+func TestStrSetGob(t *testing.T) {
+	type wrapper struct{ Set StrSet }
+	var buf bytes.Buffer
+	in := wrapper{NewStrSet("x", "y")}
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out wrapper
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil || !out.Set.Equal(in.Set) {
+		t.Errorf("gob round trip = %v, %v", out.Set, err)
+	}
+}
+
+func TestStrSetText(t *testing.T) {
+	s := NewStrSet("b", "a")
+	text, err := s.MarshalText()
+	if err != nil || string(text) != "a,b" {
+		t.Fatalf("MarshalText = %q, %v", text, err)
+	}
+	var got StrSet
+	if err := got.UnmarshalText([]byte(" b , a,,a ")); err != nil || !got.Equal(s) {
+		t.Errorf("UnmarshalText = %v, %v", got, err)
+	}
+	for _, bad := range []string{"a,b", "", " a"} {
+		if _, err := NewStrSet(bad).MarshalText(); err == nil {
+			t.Errorf("MarshalText accepted member %q", bad)
+		}
+	}
+
+	// encoding/xml honors encoding.TextMarshaler, as config libraries do.
+	type config struct {
+		Hosts StrSet `xml:"hosts"`
+	}
+	b, err := xml.Marshal(config{NewStrSet("web2", "web1")})
+	if err != nil || string(b) != "<config><hosts>web1,web2</hosts></config>" {
+		t.Fatalf("xml.Marshal = %s, %v", b, err)
+	}
+	var c config
+	if err := xml.Unmarshal(b, &c); err != nil || !c.Hosts.Equal(NewStrSet("web1", "web2")) {
+		t.Errorf("xml.Unmarshal = %v, %v", c.Hosts, err)
+	}
+}
+
+func TestStrSetFlagValue(t *testing.T) {
+	hosts := NewStrSet("x", "y")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(hosts.FlagValue(), "host", "hosts")
+
+	var usage strings.Builder
+	fs.SetOutput(&usage)
+	fs.PrintDefaults()
+	if !strings.Contains(usage.String(), "(default x,y)") {
+		t.Errorf("PrintDefaults = %q", usage.String())
+	}
+	// The printed default parses back to the same set.
+	var fromDefault StrSet
+	if err := fromDefault.FlagValue().Set("x,y"); err != nil || !fromDefault.Equal(hosts) {
+		t.Errorf("default round trip = %v, %v", fromDefault, err)
+	}
+
+	if err := fs.Parse([]string{"-host", "a,b", "-host", " c ,,a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !hosts.Equal(NewStrSet("x", "y", "a", "b", "c")) {
+		t.Errorf("after Parse: %v", hosts)
+	}
+	if got := fs.Lookup("host").Value.String(); got != "a,b,c,x,y" {
+		t.Errorf("flag String = %q", got)
+	}
+
+	var unset StrSet
+	if err := unset.FlagValue().Set("a"); err != nil || !unset.Has("a") {
+		t.Errorf("Set on a nil set = %v, %v", unset, err)
+	}
+}
@@ -0,0 +1,132 @@
+package set
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The on-disk format written by WriteTo is a single header line followed by
+// the members in sorted order, one per line:
+//
+//	strset v1 <count> <crc32>
+//	member1
+//	member2
+//
+// count is the number of members in decimal and crc32 is the IEEE CRC-32 of
+// everything after the header line, as eight lowercase hex digits. Members
+// may not contain a newline.
+const fileMagic = "strset v1"
+
+var ErrCorruptFile = errors.New("set: corrupt set file")
+
+// WriteTo writes the set to w in the documented file format. It fails
+// without writing anything if a member contains a newline.
+func (s StrSet) WriteTo(w io.Writer) (int64, error) {
+	var body bytes.Buffer
+	for _, str := range s.SortedSlice() {
+		if strings.ContainsRune(str, '\n') {
+			return 0, fmt.Errorf("set: member %q contains a newline", str)
+		}
+		body.WriteString(str)
+		body.WriteByte('\n')
+	}
+
+	n, err := fmt.Fprintf(w, "%s %d %08x\n", fileMagic, len(s), crc32.ChecksumIEEE(body.Bytes()))
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := body.WriteTo(w)
+	return int64(n) + m, err
+}
+
+// ReadFrom replaces the contents of the set with data in the format written
+// by WriteTo, returning ErrCorruptFile if the header, count or checksum do
+// not match.
+func (s *StrSet) ReadFrom(r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	n := int64(len(header))
+	if err != nil {
+		if err == io.EOF {
+			err = ErrCorruptFile
+		}
+		return n, err
+	}
+
+	var count int
+	var sum uint32
+	if _, err := fmt.Sscanf(header, fileMagic+" %d %08x\n", &count, &sum); err != nil || count < 0 {
+		return n, ErrCorruptFile
+	}
+
+	body, err := io.ReadAll(br)
+	n += int64(len(body))
+	if err != nil {
+		return n, err
+	}
+	if crc32.ChecksumIEEE(body) != sum || bytes.Count(body, []byte{'\n'}) != count ||
+		(len(body) > 0 && body[len(body)-1] != '\n') {
+		return n, ErrCorruptFile
+	}
+
+	set := make(StrSet, count)
+	for len(body) > 0 {
+		i := bytes.IndexByte(body, '\n')
+		set.Add(string(body[:i]))
+		body = body[i+1:]
+	}
+	*s = set
+	return n, nil
+}
+
+// SaveFile writes the set to path atomically: the data goes to a temporary
+// file in the same directory, which is synced and then renamed over path.
+func (s StrSet) SaveFile(path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if _, err = s.WriteTo(w); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile reads a set saved by SaveFile.
+func LoadFile(path string) (StrSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var s StrSet
+	if _, err := s.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
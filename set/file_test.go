@@ -0,0 +1,134 @@
+package set
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrSetWriteToFormat(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := NewStrSet("b", "a").WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("strset v1 2 %08x\na\nb\n", crc32.ChecksumIEEE([]byte("a\nb\n")))
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo wrote %q, want %q", got, want)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+}
+
+func TestStrSetReadFromRoundTrip(t *testing.T) {
+	for _, s := range []StrSet{
+		NewStrSet(),
+		NewStrSet(""),
+		NewStrSet("a", "", "with space", "tab\tinside", "ünïcode"),
+	} {
+		var buf bytes.Buffer
+		if _, err := s.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		size := buf.Len()
+		got := NewStrSet("stale")
+		n, err := got.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("ReadFrom(%v): %v", s, err)
+		}
+		if n != int64(size) {
+			t.Errorf("ReadFrom returned %d, want %d", n, size)
+		}
+		if !got.Equal(s) {
+			t.Errorf("round trip = %v, want %v", got, s)
+		}
+	}
+}
+
+func TestStrSetWriteToNewline(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewStrSet("ok", "two\nlines").WriteTo(&buf); err == nil {
+		t.Fatal("a member with a newline should be rejected")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("nothing should be written on error, got %q", buf.String())
+	}
+}
+
+func TestStrSetReadFromCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	NewStrSet("alpha", "beta").WriteTo(&buf)
+	good := buf.String()
+	header, body, _ := strings.Cut(good, "\n")
+
+	for name, data := range map[string]string{
+		"empty":         "",
+		"no newline":    header,
+		"bad magic":     strings.Replace(good, "strset v1", "strset v2", 1),
+		"bad checksum":  header[:len(header)-1] + "0\n" + body,
+		"flipped byte":  header + "\n" + strings.Replace(body, "alpha", "alphA", 1),
+		"truncated":     good[:len(good)-3],
+		"missing final": good[:len(good)-1],
+		"extra member":  good + "gamma\n",
+		"wrong count":   strings.Replace(good, " 2 ", " 3 ", 1),
+		"negative":      strings.Replace(good, " 2 ", " -2 ", 1),
+	} {
+		s := NewStrSet("keep")
+		if _, err := s.ReadFrom(strings.NewReader(data)); err != ErrCorruptFile {
+			t.Errorf("%s: err = %v, want ErrCorruptFile", name, err)
+		}
+		if !s.Equal(NewStrSet("keep")) {
+			t.Errorf("%s: a failed ReadFrom modified the set: %v", name, s)
+		}
+	}
+}
+
+func TestStrSetSaveLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seen.txt")
+	s := NewStrSet("id-1", "id-2", "id-3")
+	if err := s.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(s) {
+		t.Errorf("LoadFile = %v, want %v", got, s)
+	}
+
+	// Saving over an existing file replaces it.
+	s.Add("id-4")
+	if err := s.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = LoadFile(path); got.Count() != 4 {
+		t.Errorf("LoadFile after overwrite = %v", got)
+	}
+
+	// A failed save leaves the old file alone and no temporary behind.
+	if err := NewStrSet("bad\n").SaveFile(path); err == nil {
+		t.Error("SaveFile should fail for a member with a newline")
+	}
+	if got, _ = LoadFile(path); got.Count() != 4 {
+		t.Errorf("failed save changed the file: %v", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the set file", len(entries))
+	}
+
+	if _, err := LoadFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("LoadFile(missing) err = %v", err)
+	}
+	os.WriteFile(path, []byte("garbage\n"), 0o644)
+	if _, err := LoadFile(path); err != ErrCorruptFile {
+		t.Errorf("LoadFile(garbage) err = %v, want ErrCorruptFile", err)
+	}
+}
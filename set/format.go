@@ -0,0 +1,78 @@
+package set
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxStringMembers bounds how many members the String methods print before
+// truncating with a "... +N more" marker. Values <= 0 disable truncation.
+var MaxStringMembers = 32
+
+// String returns the members sorted and bounded by MaxStringMembers,
+// e.g. {a, b, c, ... +47 more}.
+func (s StrSet) String() string {
+	members := make([]string, 0, stringLimit(s.Count()))
+	for str := range s.Sorted() {
+		if len(members) == cap(members) {
+			break
+		}
+		members = append(members, str)
+	}
+	return formatMembers(members, s.Count())
+}
+
+func (s IntSet) String() string {
+	vals := s.SortedSlice()
+	members := make([]string, 0, stringLimit(len(vals)))
+	for _, v := range vals[:cap(members)] {
+		members = append(members, strconv.Itoa(v))
+	}
+	return formatMembers(members, len(vals))
+}
+
+func (s Int64Set) String() string {
+	vals := s.SortedSlice()
+	members := make([]string, 0, stringLimit(len(vals)))
+	for _, v := range vals[:cap(members)] {
+		members = append(members, strconv.FormatInt(v, 10))
+	}
+	return formatMembers(members, len(vals))
+}
+
+// String formats the members with fmt.Sprint and returns them sorted by
+// their formatted form and bounded by MaxStringMembers. Members of types
+// without a natural order thus still print the same way every time.
+func (s Set[T]) String() string {
+	members := make([]string, 0, len(s))
+	for v := range s {
+		members = append(members, fmt.Sprint(v))
+	}
+	sort.Strings(members)
+	return formatMembers(members[:stringLimit(len(members))], len(members))
+}
+
+func stringLimit(total int) int {
+	if MaxStringMembers > 0 && total > MaxStringMembers {
+		return MaxStringMembers
+	}
+	return total
+}
+
+func formatMembers(members []string, total int) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	b.WriteString(strings.Join(members, ", "))
+	if more := total - len(members); more > 0 {
+		if len(members) > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("... +")
+		b.WriteString(strconv.Itoa(more))
+		b.WriteString(" more")
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package set
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	defer func(n int) { MaxStringMembers = n }(MaxStringMembers)
+	MaxStringMembers = 3
+
+	many := NewStrSet()
+	manyInts := NewIntSet()
+	manyInt64s := NewInt64Set()
+	for i := 0; i < 50; i++ {
+		many.Add(fmt.Sprintf("m%02d", i))
+		manyInts.Add(i)
+		manyInt64s.Add(int64(i))
+	}
+
+	tests := []struct {
+		name string
+		set  fmt.Stringer
+		want string
+	}{
+		{"nil", StrSet(nil), "{}"},
+		{"empty", NewStrSet(), "{}"},
+		{"small", NewStrSet("c", "a", "b"), "{a, b, c}"},
+		{"truncated", many, "{m00, m01, m02, ... +47 more}"},
+		{"int empty", NewIntSet(), "{}"},
+		{"int small", NewIntSet(10, -1, 2), "{-1, 2, 10}"},
+		{"int truncated", manyInts, "{0, 1, 2, ... +47 more}"},
+		{"int64 small", NewInt64Set(1<<40, 0), "{0, 1099511627776}"},
+		{"int64 truncated", manyInt64s, "{0, 1, 2, ... +47 more}"},
+	}
+	for _, tt := range tests {
+		if got := tt.set.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	MaxStringMembers = 0
+	if got := NewIntSet(1, 2, 3, 4).String(); got != "{1, 2, 3, 4}" {
+		t.Errorf("without limit: %q", got)
+	}
+	if got := fmt.Sprint(NewStrSet("x")); got != "{x}" {
+		t.Errorf("fmt.Sprint = %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package set
+
+import "iter"
+
+// FrozenStrSet is an immutable string set. It exposes only read operations,
+// and the members are copied when it is built, so nothing can modify it
+// afterwards. A FrozenStrSet can therefore be shared between goroutines
+// without locking. Algebra operations return new, mutable StrSets.
+type FrozenStrSet struct {
+	set StrSet
+}
+
+// NewFrozen returns a frozen set holding the given items.
+func NewFrozen(items ...string) FrozenStrSet {
+	return FrozenStrSet{set: NewStrSet(items...)}
+}
+
+// Freeze returns a frozen copy of s. Later changes to s do not affect it.
+func (s StrSet) Freeze() FrozenStrSet {
+	return FrozenStrSet{set: s.Clone()}
+}
+
+// Thaw returns a mutable copy of the set.
+func (f FrozenStrSet) Thaw() StrSet {
+	return f.set.Clone()
+}
+
+func (f FrozenStrSet) Count() int {
+	return f.set.Count()
+}
+
+func (f FrozenStrSet) Has(str string) bool {
+	return f.set.Has(str)
+}
+
+func (f FrozenStrSet) ToSlice() []string {
+	return f.set.ToSlice()
+}
+
+func (f FrozenStrSet) SortedSlice() []string {
+	return f.set.SortedSlice()
+}
+
+func (f FrozenStrSet) All() iter.Seq[string] {
+	return f.set.All()
+}
+
+func (f FrozenStrSet) Sorted() iter.Seq[string] {
+	return f.set.Sorted()
+}
+
+func (f FrozenStrSet) Union(other StrSet) StrSet {
+	return f.set.Union(other)
+}
+
+func (f FrozenStrSet) Intersect(other StrSet) StrSet {
+	return f.set.Intersect(other)
+}
+
+func (f FrozenStrSet) Difference(other StrSet) StrSet {
+	return f.set.Difference(other)
+}
+
+func (f FrozenStrSet) SymmetricDifference(other StrSet) StrSet {
+	return f.set.SymmetricDifference(other)
+}
+
+func (f FrozenStrSet) Equal(other StrSet) bool {
+	return f.set.Equal(other)
+}
+
+func (f FrozenStrSet) IsSubset(other StrSet) bool {
+	return f.set.IsSubset(other)
+}
+
+func (f FrozenStrSet) IsSuperset(other StrSet) bool {
+	return f.set.IsSuperset(other)
+}
+
+func (f FrozenStrSet) IsDisjoint(other StrSet) bool {
+	return f.set.IsDisjoint(other)
+}
+
+func (f FrozenStrSet) String() string {
+	return f.set.String()
+}
+
+func (f FrozenStrSet) MarshalJSON() ([]byte, error) {
+	return f.set.MarshalJSON()
+}
@@ -0,0 +1,67 @@
+package set
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestFrozenStrSet(t *testing.T) {
+	src := NewStrSet("a", "b")
+	f := src.Freeze()
+	src.Add("c")
+	if f.Count() != 2 || f.Has("c") {
+		t.Errorf("Freeze shares the map: %v", f)
+	}
+	u := f.Union(NewStrSet("z"))
+	u.Add("y")
+	if f.Has("z") || f.Has("y") {
+		t.Error("algebra results share the frozen map")
+	}
+	thawed := f.Thaw()
+	thawed.Add("x")
+	if f.Has("x") {
+		t.Error("Thaw shares the frozen map")
+	}
+	if !NewFrozen("b", "a").Equal(NewStrSet("a", "b")) || f.String() != "{a, b}" {
+		t.Errorf("NewFrozen = %v", NewFrozen("b", "a"))
+	}
+	var zero FrozenStrSet
+	if zero.Count() != 0 || zero.Has("a") {
+		t.Error("zero FrozenStrSet")
+	}
+}
+
+// TestFrozenStrSetConcurrentReads is meant for -race: readers share the set
+// without locking.
+func TestFrozenStrSetConcurrentReads(t *testing.T) {
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = strconv.Itoa(i)
+	}
+	f := NewFrozen(items...)
+	other := NewStrSet("1", "2", "x")
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if !f.Has(items[(g*i)%len(items)]) {
+					t.Error("missing member")
+					return
+				}
+				f.Count()
+				if i%50 == 0 {
+					f.Intersect(other)
+					f.IsSubset(other)
+					for range f.All() {
+						break
+					}
+					f.ToSlice()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,142 @@
+package set
+
+import "sort"
+
+// IntSet is a set of int values. It avoids the strconv round trip (and the
+// extra string allocations) of keeping numeric IDs in a StrSet.
+type IntSet map[int]struct{}
+
+func NewIntSet(vals ...int) IntSet {
+	s := make(IntSet, len(vals))
+	for _, v := range vals {
+		s.Add(v)
+	}
+	return s
+}
+
+func FromInts(vals []int) IntSet {
+	return NewIntSet(vals...)
+}
+
+func (s IntSet) Count() int {
+	return len(s)
+}
+
+func (s IntSet) Add(v int) {
+	s[v] = struct{}{}
+}
+
+func (s IntSet) Has(v int) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// AddAll adds the given values and returns how many were not already
+// present.
+func (s IntSet) AddAll(vals ...int) int {
+	n := 0
+	for _, v := range vals {
+		if !s.Has(v) {
+			s.Add(v)
+			n++
+		}
+	}
+	return n
+}
+
+// AddSlice is like AddAll but takes a slice.
+func (s IntSet) AddSlice(vals []int) int {
+	return s.AddAll(vals...)
+}
+
+// Remove deletes v from the set and reports whether it was present.
+func (s IntSet) Remove(v int) bool {
+	ok := s.Has(v)
+	delete(s, v)
+	return ok
+}
+
+// ToSlice returns the members in unspecified order.
+func (s IntSet) ToSlice() []int {
+	r := make([]int, 0, len(s))
+	for v := range s {
+		r = append(r, v)
+	}
+	return r
+}
+
+// SortedSlice returns the members sorted in increasing order.
+func (s IntSet) SortedSlice() []int {
+	r := s.ToSlice()
+	sort.Ints(r)
+	return r
+}
+
+// Int64Set is a set of int64 values.
+type Int64Set map[int64]struct{}
+
+func NewInt64Set(vals ...int64) Int64Set {
+	s := make(Int64Set, len(vals))
+	for _, v := range vals {
+		s.Add(v)
+	}
+	return s
+}
+
+func FromInt64s(vals []int64) Int64Set {
+	return NewInt64Set(vals...)
+}
+
+func (s Int64Set) Count() int {
+	return len(s)
+}
+
+func (s Int64Set) Add(v int64) {
+	s[v] = struct{}{}
+}
+
+func (s Int64Set) Has(v int64) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// AddAll adds the given values and returns how many were not already
+// present.
+func (s Int64Set) AddAll(vals ...int64) int {
+	n := 0
+	for _, v := range vals {
+		if !s.Has(v) {
+			s.Add(v)
+			n++
+		}
+	}
+	return n
+}
+
+// AddSlice is like AddAll but takes a slice.
+func (s Int64Set) AddSlice(vals []int64) int {
+	return s.AddAll(vals...)
+}
+
+// Remove deletes v from the set and reports whether it was present.
+func (s Int64Set) Remove(v int64) bool {
+	ok := s.Has(v)
+	delete(s, v)
+	return ok
+}
+
+// ToSlice returns the members in unspecified order.
+func (s Int64Set) ToSlice() []int64 {
+	r := make([]int64, 0, len(s))
+	for v := range s {
+		r = append(r, v)
+	}
+	return r
+}
+
+// SortedSlice returns the members sorted in increasing order.
+func (s Int64Set) SortedSlice() []int64 {
+	r := s.ToSlice()
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
@@ -0,0 +1,73 @@
+package set
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestIntSet(t *testing.T) {
+	s := NewIntSet(3, 1, 3)
+	if s.Count() != 2 || !s.Has(1) || !s.Has(3) || s.Has(2) {
+		t.Fatalf("NewIntSet(3, 1, 3) = %v", s)
+	}
+	if n := s.AddAll(1, 2, 2); n != 1 {
+		t.Errorf("AddAll added %d, want 1", n)
+	}
+	if got := s.SortedSlice(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("SortedSlice = %v", got)
+	}
+	if !s.Remove(2) || s.Remove(2) {
+		t.Error("Remove(2) should succeed once")
+	}
+	slice := s.SortedSlice()
+	slice[0] = 100
+	if s.Has(100) || !s.Has(1) {
+		t.Error("mutating the returned slice changed the set")
+	}
+	if got := FromInts(nil); got.Count() != 0 {
+		t.Errorf("FromInts(nil) = %v", got)
+	}
+}
+
+func TestInt64Set(t *testing.T) {
+	s := NewInt64Set(1<<40, -5)
+	if s.AddSlice([]int64{-5, 7}) != 1 || s.Count() != 3 {
+		t.Fatalf("AddSlice: %v", s)
+	}
+	if got := s.SortedSlice(); !reflect.DeepEqual(got, []int64{-5, 7, 1 << 40}) {
+		t.Errorf("SortedSlice = %v", got)
+	}
+	if !s.Remove(7) || s.Has(7) {
+		t.Error("Remove(7) failed")
+	}
+	if got := FromInt64s([]int64{1, 1}); got.Count() != 1 {
+		t.Errorf("FromInt64s = %v", got)
+	}
+}
+
+const benchIDs = 10000
+
+func BenchmarkInt64SetHas(b *testing.B) {
+	s := make(Int64Set, benchIDs)
+	for i := int64(0); i < benchIDs; i++ {
+		s.Add(i * 7919)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Has(int64(i%benchIDs) * 7919)
+	}
+}
+
+// BenchmarkStrSetHasFormatted is the pattern Int64Set replaces: numeric
+// IDs kept in a StrSet and formatted on every lookup.
+func BenchmarkStrSetHasFormatted(b *testing.B) {
+	s := make(StrSet, benchIDs)
+	for i := int64(0); i < benchIDs; i++ {
+		s.Add(strconv.FormatInt(i*7919, 10))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Has(strconv.FormatInt(int64(i%benchIDs)*7919, 10))
+	}
+}
@@ -0,0 +1,79 @@
+package set
+
+import (
+	"container/heap"
+	"iter"
+)
+
+// All returns an iterator over the members in unspecified order, see
+// Set.All.
+func (s StrSet) All() iter.Seq[string] {
+	return Set[string](s).All()
+}
+
+// All returns an iterator over the members in unspecified order. It ranges
+// over the live set, so the usual map rules apply when the loop body
+// modifies it: a member removed before it is reached is not produced, and a
+// member added during iteration may or may not be produced.
+func (s Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range s {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Sorted returns an iterator over a snapshot of the members in increasing
+// order. Members are ordered lazily through a heap, so breaking out of the
+// loop early avoids the cost of sorting the remainder. Changes to the set
+// during iteration are not observed.
+func (s StrSet) Sorted() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		h := strHeap(s.ToSlice())
+		heap.Init(&h)
+		for h.Len() > 0 {
+			if !yield(heap.Pop(&h).(string)) {
+				return
+			}
+		}
+	}
+}
+
+type strHeap []string
+
+func (h strHeap) Len() int           { return len(h) }
+func (h strHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h strHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *strHeap) Push(x interface{}) { *h = append(*h, x.(string)) }
+
+func (h *strHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// Each calls fn for every member in unspecified order until fn returns
+// false. Modifying the set from fn follows the same rules as for All.
+func (s StrSet) Each(fn func(string) bool) {
+	for str := range s {
+		if !fn(str) {
+			return
+		}
+	}
+}
+
+// Find returns a member for which pred returns true, or false if there is
+// none. If several members match, which one is returned is unspecified.
+func (s StrSet) Find(pred func(string) bool) (string, bool) {
+	for str := range s {
+		if pred(str) {
+			return str, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,127 @@
+package set
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestStrSetAll(t *testing.T) {
+	s := NewStrSet("a", "b", "c")
+	got := NewStrSet()
+	for str := range s.All() {
+		got.Add(str)
+	}
+	if !got.Equal(s) {
+		t.Errorf("All yielded %v", got)
+	}
+
+	// All ranges over the live set: a member removed before it is reached
+	// is not produced.
+	for i := 0; i < 20; i++ {
+		s := NewStrSet("a", "b", "c", "d")
+		var yielded []string
+		for str := range s.All() {
+			yielded = append(yielded, str)
+			for other := range s {
+				if other != str && !contains(yielded, other) {
+					s.Remove(other)
+					break
+				}
+			}
+		}
+		for _, str := range yielded {
+			if !s.Has(str) {
+				t.Fatalf("yielded %q after it was removed (yielded %v, left %v)", str, yielded, s)
+			}
+		}
+	}
+
+	n := 0
+	for range s.All() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("break did not stop the iteration")
+	}
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStrSetSorted(t *testing.T) {
+	s := NewStrSet("c", "a", "b")
+	var got []string
+	for str := range s.Sorted() {
+		got = append(got, str)
+		// Sorted iterates over a snapshot.
+		s.Remove("b")
+		s.Add("0")
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Sorted yielded %v", got)
+	}
+
+	got = got[:0]
+	for str := range NewStrSet("c", "a", "b").Sorted() {
+		if str == "b" {
+			break
+		}
+		got = append(got, str)
+	}
+	if !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Sorted with break yielded %v", got)
+	}
+
+	var nilSet StrSet
+	for str := range nilSet.Sorted() {
+		t.Errorf("nil set yielded %q", str)
+	}
+}
+
+func ExampleStrSet_All() {
+	hosts := NewStrSet("db1", "web1", "web2")
+	n := 0
+	for host := range hosts.All() {
+		if host != "db1" {
+			n++
+		}
+	}
+	fmt.Println(n, "web hosts")
+	// Output: 2 web hosts
+}
+
+func ExampleStrSet_Sorted() {
+	hosts := NewStrSet("web2", "db1", "web1")
+	for host := range hosts.Sorted() {
+		fmt.Println(host)
+	}
+	// Output:
+	// db1
+	// web1
+	// web2
+}
+func TestStrSetEachFind(t *testing.T) {
+	s := NewStrSet("a", "b", "c")
+	n := 0
+	s.Each(func(string) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("Each called fn %d times, want 2", n)
+	}
+	if str, ok := s.Find(func(str string) bool { return str > "a" }); !ok || str == "a" {
+		t.Errorf("Find = %q, %v", str, ok)
+	}
+	if _, ok := s.Find(func(str string) bool { return str == "z" }); ok {
+		t.Error("Find matched nothing but returned true")
+	}
+}
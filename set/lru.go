@@ -0,0 +1,83 @@
+package set
+
+import "container/list"
+
+// LRUSet is a string set bounded to a fixed capacity. When a new member
+// would exceed the capacity, the least recently used member is evicted.
+// Add and Has are O(1). LRUSet is not safe for concurrent use.
+//
+// Use NewLRUSet to create one: the zero value has a capacity of zero, so it
+// is safe to use but remembers nothing.
+type LRUSet struct {
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front is the most recently used
+
+	// RefreshOnHas makes Has count as a use, moving the member to the most
+	// recently used position. It is off by default, so Has is read-only.
+	RefreshOnHas bool
+}
+
+// NewLRUSet returns an empty set that holds at most capacity members. A
+// capacity of zero or less remembers nothing.
+func NewLRUSet(capacity int) *LRUSet {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &LRUSet{
+		capacity: capacity,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Add inserts key as the most recently used member. If that pushes the set
+// over capacity, the least recently used member is removed and returned
+// with didEvict set. With a capacity of zero the key itself is reported as
+// evicted.
+func (s *LRUSet) Add(key string) (evicted string, didEvict bool) {
+	if e, ok := s.items[key]; ok {
+		s.order.MoveToFront(e)
+		return "", false
+	}
+	if s.capacity == 0 {
+		return key, true
+	}
+
+	s.items[key] = s.order.PushFront(key)
+	if s.order.Len() <= s.capacity {
+		return "", false
+	}
+
+	oldest := s.order.Back()
+	evicted = s.order.Remove(oldest).(string)
+	delete(s.items, evicted)
+	return evicted, true
+}
+
+func (s *LRUSet) Has(key string) bool {
+	e, ok := s.items[key]
+	if ok && s.RefreshOnHas {
+		s.order.MoveToFront(e)
+	}
+	return ok
+}
+
+// Remove deletes key and reports whether it was present.
+func (s *LRUSet) Remove(key string) bool {
+	e, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	s.order.Remove(e)
+	delete(s.items, key)
+	return true
+}
+
+func (s *LRUSet) Count() int {
+	return len(s.items)
+}
+
+func (s *LRUSet) Capacity() int {
+	return s.capacity
+}
@@ -0,0 +1,92 @@
+package set
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLRUSetEvictionOrder(t *testing.T) {
+	s := NewLRUSet(3)
+	type step struct {
+		op      string // "add" or "has"
+		key     string
+		evicted string // for "add"; "" if nothing is evicted
+		has     bool   // for "has"
+	}
+	script := []step{
+		{op: "add", key: "a"},
+		{op: "add", key: "b"},
+		{op: "add", key: "c"},
+		{op: "add", key: "d", evicted: "a"},
+		{op: "add", key: "b"}, // refreshes b
+		{op: "add", key: "e", evicted: "c"},
+		{op: "has", key: "d", has: true}, // does not refresh by default
+		{op: "add", key: "f", evicted: "d"},
+		{op: "has", key: "a", has: false},
+		{op: "add", key: "g", evicted: "b"},
+	}
+	for i, st := range script {
+		switch st.op {
+		case "add":
+			evicted, did := s.Add(st.key)
+			if evicted != st.evicted || did != (st.evicted != "") {
+				t.Fatalf("step %d: Add(%q) = %q, %v; want %q", i, st.key, evicted, did, st.evicted)
+			}
+		case "has":
+			if got := s.Has(st.key); got != st.has {
+				t.Fatalf("step %d: Has(%q) = %v", i, st.key, got)
+			}
+		}
+		if s.Count() > s.Capacity() {
+			t.Fatalf("step %d: Count %d exceeds capacity", i, s.Count())
+		}
+	}
+	if got := lruKeys(s); !reflect.DeepEqual(got, []string{"g", "f", "e"}) {
+		t.Errorf("final order %v", got)
+	}
+}
+
+func TestLRUSetRefreshOnHas(t *testing.T) {
+	s := NewLRUSet(2)
+	s.RefreshOnHas = true
+	s.Add("a")
+	s.Add("b")
+	s.Has("a")
+	if evicted, _ := s.Add("c"); evicted != "b" {
+		t.Errorf("evicted %q, want b", evicted)
+	}
+}
+
+func TestLRUSetSmallCapacities(t *testing.T) {
+	for _, s := range []*LRUSet{NewLRUSet(0), NewLRUSet(-1), new(LRUSet)} {
+		if evicted, did := s.Add("a"); !did || evicted != "a" {
+			t.Errorf("capacity 0: Add = %q, %v", evicted, did)
+		}
+		if s.Has("a") || s.Count() != 0 || s.Remove("a") {
+			t.Error("capacity 0 remembered a key")
+		}
+	}
+
+	s := NewLRUSet(1)
+	if _, did := s.Add("a"); did {
+		t.Error("first Add evicted")
+	}
+	if _, did := s.Add("a"); did {
+		t.Error("re-adding the only member evicted")
+	}
+	if evicted, _ := s.Add("b"); evicted != "a" || !s.Has("b") || s.Count() != 1 {
+		t.Errorf("capacity 1: evicted %q", evicted)
+	}
+	if !s.Remove("b") || s.Remove("b") || s.Count() != 0 {
+		t.Error("Remove")
+	}
+}
+
+// lruKeys returns the members of s, most recently used first.
+func lruKeys(s *LRUSet) []string {
+	var keys []string
+	for e := s.order.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(string))
+	}
+	return keys
+}
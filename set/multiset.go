@@ -0,0 +1,141 @@
+package set
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// MultiSet is a counted set of strings: it tracks how many times each
+// member was added. Only positive counts are stored.
+type MultiSet map[string]int
+
+// ValueCount pairs a MultiSet member with its count.
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+// NewMultiSet returns a multiset counting each occurrence of the items.
+func NewMultiSet(items ...string) MultiSet {
+	m := make(MultiSet, len(items))
+	for _, item := range items {
+		m.Add(item)
+	}
+	return m
+}
+
+// MultiSetFrom returns a multiset holding every member of s once.
+func MultiSetFrom(s StrSet) MultiSet {
+	m := make(MultiSet, len(s))
+	for str := range s {
+		m[str] = 1
+	}
+	return m
+}
+
+// Add increments the count of s and returns the new count.
+func (m MultiSet) Add(s string) int {
+	return m.AddN(s, 1)
+}
+
+// AddN adds n to the count of s and returns the new count. Non-positive n
+// leaves the count unchanged.
+func (m MultiSet) AddN(s string, n int) int {
+	if n > 0 {
+		m[s] += n
+	}
+	return m[s]
+}
+
+// Remove decrements the count of s, deleting it when the count reaches
+// zero, and returns the new count.
+func (m MultiSet) Remove(s string) int {
+	c, ok := m[s]
+	if !ok {
+		return 0
+	}
+	if c <= 1 {
+		delete(m, s)
+		return 0
+	}
+	m[s] = c - 1
+	return c - 1
+}
+
+// Count returns how many times s was added; 0 if it is not a member.
+func (m MultiSet) Count(s string) int {
+	return m[s]
+}
+
+func (m MultiSet) Has(s string) bool {
+	return m[s] > 0
+}
+
+// Distinct returns the number of distinct members.
+func (m MultiSet) Distinct() int {
+	return len(m)
+}
+
+// Total returns the sum of all counts.
+func (m MultiSet) Total() int {
+	n := 0
+	for _, c := range m {
+		n += c
+	}
+	return n
+}
+
+// Merge adds the counts of others to m.
+func (m MultiSet) Merge(others ...MultiSet) {
+	for _, other := range others {
+		for s, c := range other {
+			m.AddN(s, c)
+		}
+	}
+}
+
+// TopN returns the n members with the highest counts, most frequent first.
+// Ties are broken by value so the result is deterministic.
+func (m MultiSet) TopN(n int) []ValueCount {
+	r := make([]ValueCount, 0, len(m))
+	for s, c := range m {
+		r = append(r, ValueCount{Value: s, Count: c})
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Count != r[j].Count {
+			return r[i].Count > r[j].Count
+		}
+		return r[i].Value < r[j].Value
+	})
+	if n < 0 {
+		n = 0
+	}
+	if n < len(r) {
+		r = r[:n]
+	}
+	return r
+}
+
+// StrSet returns the distinct members as a StrSet.
+func (m MultiSet) StrSet() StrSet {
+	return KeysOf(m)
+}
+
+// UnmarshalJSON decodes an object of counts, dropping members whose count
+// is not positive. MultiSet marshals as that same object by default.
+func (m *MultiSet) UnmarshalJSON(data []byte) error {
+	var counts map[string]int
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return err
+	}
+	if counts == nil {
+		*m = nil
+		return nil
+	}
+	r := make(MultiSet, len(counts))
+	for s, c := range counts {
+		r.AddN(s, c)
+	}
+	*m = r
+	return nil
+}
@@ -0,0 +1,122 @@
+package set
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMultiSetCounts(t *testing.T) {
+	m := NewMultiSet("go", "rust", "go", "go")
+	if m.Count("go") != 3 || m.Count("rust") != 1 || m.Count("zig") != 0 {
+		t.Errorf("counts = %v", m)
+	}
+	if m.Distinct() != 2 || m.Total() != 4 {
+		t.Errorf("Distinct = %d, Total = %d, want 2, 4", m.Distinct(), m.Total())
+	}
+	if n := m.Add("rust"); n != 2 {
+		t.Errorf("Add returned %d, want 2", n)
+	}
+	if n := m.AddN("zig", 5); n != 5 {
+		t.Errorf("AddN returned %d, want 5", n)
+	}
+	for _, n := range []int{0, -3} {
+		if got := m.AddN("zig", n); got != 5 {
+			t.Errorf("AddN(zig, %d) = %d, want the count unchanged", n, got)
+		}
+	}
+	if m.AddN("none", 0); m.Has("none") || m.Distinct() != 3 {
+		t.Error("AddN with n <= 0 must not create a member")
+	}
+}
+
+func TestMultiSetRemoveToZero(t *testing.T) {
+	m := NewMultiSet("a", "a", "b")
+	if n := m.Remove("a"); n != 1 || !m.Has("a") {
+		t.Errorf("first Remove(a) = %d", n)
+	}
+	if n := m.Remove("a"); n != 0 {
+		t.Errorf("second Remove(a) = %d, want 0", n)
+	}
+	if _, ok := m["a"]; ok || m.Has("a") || m.Distinct() != 1 {
+		t.Errorf("a member at zero must be deleted: %v", m)
+	}
+	if n := m.Remove("a"); n != 0 || m.Distinct() != 1 {
+		t.Errorf("Remove below zero = %d, set %v", n, m)
+	}
+	if n := m.Remove("missing"); n != 0 {
+		t.Errorf("Remove(missing) = %d", n)
+	}
+	m.Remove("b")
+	if m.Distinct() != 0 || m.Total() != 0 {
+		t.Errorf("empty multiset: %v", m)
+	}
+}
+
+func TestMultiSetTopN(t *testing.T) {
+	m := MultiSet{"a": 2, "b": 5, "c": 2, "d": 1}
+	want := []ValueCount{{"b", 5}, {"a", 2}, {"c", 2}}
+	if got := m.TopN(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("TopN(3) = %v, want %v", got, want)
+	}
+	if got := m.TopN(10); len(got) != 4 || got[3] != (ValueCount{"d", 1}) {
+		t.Errorf("TopN(10) = %v", got)
+	}
+	for _, n := range []int{0, -1} {
+		if got := m.TopN(n); len(got) != 0 {
+			t.Errorf("TopN(%d) = %v, want empty", n, got)
+		}
+	}
+}
+
+func TestMultiSetMerge(t *testing.T) {
+	m := NewMultiSet("a", "b")
+	m.Merge(MultiSet{"a": 2, "c": 1}, nil, NewMultiSet("b"))
+	want := MultiSet{"a": 3, "b": 2, "c": 1}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Merge = %v, want %v", m, want)
+	}
+}
+
+func TestMultiSetStrSet(t *testing.T) {
+	s := NewStrSet("x", "y")
+	m := MultiSetFrom(s)
+	if m.Total() != 2 || m.Count("x") != 1 {
+		t.Errorf("MultiSetFrom = %v", m)
+	}
+	m.Add("x")
+	if !m.StrSet().Equal(s) {
+		t.Errorf("StrSet = %v, want %v", m.StrSet(), s)
+	}
+}
+
+func TestMultiSetJSON(t *testing.T) {
+	m := NewMultiSet("a", "a", "b")
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":2,"b":1}` {
+		t.Errorf("Marshal = %s", b)
+	}
+	var got MultiSet
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("round trip = %v, want %v", got, m)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":1,"zero":0,"neg":-2}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, MultiSet{"a": 1}) {
+		t.Errorf("non positive counts should be dropped: %v", got)
+	}
+	if err := json.Unmarshal([]byte(`null`), &got); err != nil || got != nil {
+		t.Errorf("null = %v, %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(`["a"]`), &got); err == nil {
+		t.Error("an array should not decode into a MultiSet")
+	}
+}
@@ -0,0 +1,158 @@
+package set
+
+import "strings"
+
+// NormalizedStrSet is a string set that canonicalizes every string before
+// storing or looking it up, e.g. to get case-insensitive membership. It
+// remembers the first-seen spelling of each member for ToSlice.
+//
+// Algebra operations accept plain StrSets and canonicalize their members
+// with the receiver's normalizer, so mixing normalized and plain sets always
+// behaves as if both had been normalized the same way.
+type NormalizedStrSet struct {
+	normalize func(string) string
+	members   map[string]string // canonical form -> first-seen original
+}
+
+// NewStrSetNormalized returns an empty set that passes every string through
+// normalize before Add, Has, Remove and the algebra operations, e.g.
+//
+//	emails := set.NewStrSetNormalized(func(s string) string {
+//		return strings.ToLower(strings.TrimSpace(s))
+//	})
+//
+// normalize must be pure: it must always return the same result for the
+// same input, or members become unreachable. Sets derived from the result
+// (Clone, Union, ...) share the normalizer. A nil normalize leaves strings
+// unchanged, making the set behave exactly like a StrSet.
+func NewStrSetNormalized(normalize func(string) string) *NormalizedStrSet {
+	return &NormalizedStrSet{
+		normalize: normalize,
+		members:   make(map[string]string),
+	}
+}
+
+// NewFoldedStrSet returns a case-insensitive set holding the given items.
+func NewFoldedStrSet(items ...string) *NormalizedStrSet {
+	s := NewStrSetNormalized(strings.ToLower)
+	for _, item := range items {
+		s.Add(item)
+	}
+	return s
+}
+
+func (s *NormalizedStrSet) canonical(str string) string {
+	if s.normalize == nil {
+		return str
+	}
+	return s.normalize(str)
+}
+
+// derive returns an empty set sharing the normalizer of s.
+func (s *NormalizedStrSet) derive(size int) *NormalizedStrSet {
+	return &NormalizedStrSet{
+		normalize: s.normalize,
+		members:   make(map[string]string, size),
+	}
+}
+
+func (s *NormalizedStrSet) Count() int {
+	return len(s.members)
+}
+
+// Add inserts str. If an equivalent member is already present its original
+// spelling is kept.
+func (s *NormalizedStrSet) Add(str string) {
+	key := s.canonical(str)
+	if _, ok := s.members[key]; ok {
+		return
+	}
+	if s.members == nil {
+		s.members = make(map[string]string)
+	}
+	s.members[key] = str
+}
+
+func (s *NormalizedStrSet) Has(str string) bool {
+	_, ok := s.members[s.canonical(str)]
+	return ok
+}
+
+// Remove deletes the member equivalent to str and reports whether it was
+// present.
+func (s *NormalizedStrSet) Remove(str string) bool {
+	key := s.canonical(str)
+	_, ok := s.members[key]
+	delete(s.members, key)
+	return ok
+}
+
+// ToSlice returns the first-seen spelling of every member in unspecified
+// order.
+func (s *NormalizedStrSet) ToSlice() []string {
+	r := make([]string, 0, len(s.members))
+	for _, str := range s.members {
+		r = append(r, str)
+	}
+	return r
+}
+
+// Canonical returns a plain set of the canonical forms of the members.
+func (s *NormalizedStrSet) Canonical() StrSet {
+	r := make(StrSet, len(s.members))
+	for key := range s.members {
+		r.Add(key)
+	}
+	return r
+}
+
+// Clone returns an independent copy of s with the same normalizer.
+func (s *NormalizedStrSet) Clone() *NormalizedStrSet {
+	r := s.derive(len(s.members))
+	for key, str := range s.members {
+		r.members[key] = str
+	}
+	return r
+}
+
+// Union returns a new set holding the members of s and other.
+func (s *NormalizedStrSet) Union(other StrSet) *NormalizedStrSet {
+	r := s.Clone()
+	for str := range other {
+		r.Add(str)
+	}
+	return r
+}
+
+// Intersect returns a new set holding the members of s equivalent to a
+// member of other.
+func (s *NormalizedStrSet) Intersect(other StrSet) *NormalizedStrSet {
+	keys := s.canonicalSet(other)
+	r := s.derive(len(keys))
+	for key, str := range s.members {
+		if keys.Has(key) {
+			r.members[key] = str
+		}
+	}
+	return r
+}
+
+// Difference returns a new set holding the members of s not equivalent to
+// any member of other.
+func (s *NormalizedStrSet) Difference(other StrSet) *NormalizedStrSet {
+	keys := s.canonicalSet(other)
+	r := s.derive(len(s.members))
+	for key, str := range s.members {
+		if !keys.Has(key) {
+			r.members[key] = str
+		}
+	}
+	return r
+}
+
+func (s *NormalizedStrSet) canonicalSet(other StrSet) StrSet {
+	if s.normalize == nil {
+		return other
+	}
+	return other.MapTo(s.normalize)
+}
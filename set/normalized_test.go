@@ -0,0 +1,132 @@
+package set
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFoldedStrSet(t *testing.T) {
+	s := NewFoldedStrSet("Content-Type", "content-type", "Accept")
+	if s.Count() != 2 || !s.Has("CONTENT-TYPE") || !s.Has("accept") {
+		t.Fatalf("folded set: %v", s.ToSlice())
+	}
+	got := s.ToSlice()
+	sort.Strings(got)
+	if got[0] != "Accept" || got[1] != "Content-Type" {
+		t.Errorf("ToSlice should keep the first-seen spelling: %v", got)
+	}
+	if !s.Remove("ACCEPT") || s.Remove("accept") || s.Count() != 1 {
+		t.Error("Remove should match case-insensitively")
+	}
+}
+
+func TestFoldedStrSetMixedAlgebra(t *testing.T) {
+	// Plain sets passed to the algebra of a folded set are folded too.
+	s := NewFoldedStrSet("Host", "Accept")
+	plain := NewStrSet("HOST", "x-Trace")
+
+	if u := s.Union(plain); u.Count() != 3 || !u.Has("x-trace") || !u.Has("host") {
+		t.Errorf("Union = %v", u.ToSlice())
+	}
+	if i := s.Intersect(plain); i.Count() != 1 || !i.Has("host") || i.ToSlice()[0] != "Host" {
+		t.Errorf("Intersect = %v", i.ToSlice())
+	}
+	if d := s.Difference(plain); d.Count() != 1 || !d.Has("ACCEPT") {
+		t.Errorf("Difference = %v", d.ToSlice())
+	}
+	if s.Count() != 2 {
+		t.Errorf("algebra modified the receiver: %v", s.ToSlice())
+	}
+	if c := s.Canonical(); !c.Equal(NewStrSet("host", "accept")) {
+		t.Errorf("Canonical = %v", c)
+	}
+}
+
+func normalizeEmail(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func TestStrSetNormalizedEmails(t *testing.T) {
+	s := NewStrSetNormalized(normalizeEmail)
+	for _, email := range []string{
+		"Alice@Example.com", " alice@example.com", "ALICE@EXAMPLE.COM\t",
+		"bob@example.com", "Bob@Example.Com ", "carol@example.com",
+	} {
+		s.Add(email)
+	}
+	if s.Count() != 3 {
+		t.Fatalf("Count = %d, want 3: %q", s.Count(), s.ToSlice())
+	}
+	if !s.Canonical().Equal(NewStrSet("alice@example.com", "bob@example.com", "carol@example.com")) {
+		t.Errorf("Canonical = %v", s.Canonical())
+	}
+	got := s.ToSlice()
+	sort.Strings(got)
+	if got[0] != "Alice@Example.com" || got[1] != "bob@example.com" {
+		t.Errorf("ToSlice should keep the first-seen spelling: %q", got)
+	}
+	if !s.Has("  CAROL@example.com ") || !s.Remove("\tBOB@EXAMPLE.COM") || s.Has("bob@example.com") {
+		t.Error("Has and Remove should normalize their argument")
+	}
+}
+
+func TestStrSetNormalizedDerivedSets(t *testing.T) {
+	s := NewStrSetNormalized(normalizeEmail)
+	s.Add("a@x.io")
+	other := NewStrSet(" A@X.IO", "B@x.io")
+
+	derived := map[string]*NormalizedStrSet{
+		"Clone":        s.Clone(),
+		"Union":        s.Union(other),
+		"Intersect":    s.Intersect(other),
+		"Difference":   s.Difference(other),
+		"zero derived": s.Difference(NewStrSet("a@x.io")),
+	}
+	for name, d := range derived {
+		d.Add(" NEW@X.IO ")
+		if !d.Has("new@x.io") {
+			t.Errorf("%s did not keep the normalizer", name)
+		}
+	}
+	if u := derived["Union"]; u.Count() != 3 || !u.Has("b@X.IO") {
+		t.Errorf("Union = %q", u.ToSlice())
+	}
+	if i := derived["Intersect"]; i.Count() != 2 || !i.Has("a@x.io") {
+		t.Errorf("Intersect = %q", i.ToSlice())
+	}
+	if s.Count() != 1 || s.Has("new@x.io") {
+		t.Errorf("derived sets must not share members with s: %q", s.ToSlice())
+	}
+}
+
+func TestStrSetNormalizedNil(t *testing.T) {
+	// With a nil normalizer the set must agree with a plain StrSet.
+	for i, pair := range randomSets(485, 200) {
+		a, b := pair[0], pair[1]
+		n := NewStrSetNormalized(nil)
+		for str := range a {
+			n.Add(str)
+		}
+		if !n.Canonical().Equal(a) {
+			t.Fatalf("#%d: members = %v, want %v", i, n.Canonical(), a)
+		}
+		if got, want := n.Union(b).Canonical(), a.Union(b); !got.Equal(want) {
+			t.Errorf("#%d: Union = %v, want %v", i, got, want)
+		}
+		if got, want := n.Intersect(b).Canonical(), a.Intersect(b); !got.Equal(want) {
+			t.Errorf("#%d: Intersect = %v, want %v", i, got, want)
+		}
+		if got, want := n.Difference(b).Canonical(), a.Difference(b); !got.Equal(want) {
+			t.Errorf("#%d: Difference = %v, want %v", i, got, want)
+		}
+		for str := range b {
+			if n.Has(str) != a.Has(str) || n.Remove(str) != a.Has(str) {
+				t.Errorf("#%d: Has/Remove(%q) disagree with StrSet", i, str)
+			}
+		}
+	}
+	if NewStrSetNormalized(nil).Has("") {
+		t.Error("empty set has the empty string")
+	}
+}
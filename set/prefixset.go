@@ -0,0 +1,128 @@
+package set
+
+import "sort"
+
+// PrefixSet is a set of string prefixes backed by a byte-wise trie. It
+// answers "which member is a prefix of this string" in O(len(s)), which a
+// hash set cannot. The zero value is an empty set ready to use.
+type PrefixSet struct {
+	root  prefixNode
+	count int
+}
+
+type prefixNode struct {
+	children map[byte]*prefixNode
+	member   bool
+}
+
+func NewPrefixSet(prefixes ...string) *PrefixSet {
+	s := new(PrefixSet)
+	for _, prefix := range prefixes {
+		s.Add(prefix)
+	}
+	return s
+}
+
+// Add inserts prefix. The empty string is a valid member and is a prefix
+// of every string.
+func (s *PrefixSet) Add(prefix string) {
+	n := &s.root
+	for i := 0; i < len(prefix); i++ {
+		child, ok := n.children[prefix[i]]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[byte]*prefixNode)
+			}
+			child = new(prefixNode)
+			n.children[prefix[i]] = child
+		}
+		n = child
+	}
+	if !n.member {
+		n.member = true
+		s.count++
+	}
+}
+
+// Has reports whether prefix itself is a member.
+func (s *PrefixSet) Has(prefix string) bool {
+	n := s.find(prefix)
+	return n != nil && n.member
+}
+
+// HasPrefixOf returns the longest member that is a prefix of str.
+func (s *PrefixSet) HasPrefixOf(str string) (matchedPrefix string, ok bool) {
+	n := &s.root
+	if n.member {
+		ok = true
+	}
+	for i := 0; i < len(str); i++ {
+		if n = n.children[str[i]]; n == nil {
+			break
+		}
+		if n.member {
+			matchedPrefix, ok = str[:i+1], true
+		}
+	}
+	return matchedPrefix, ok
+}
+
+// Remove deletes prefix and reports whether it was present. Trie nodes left
+// without members are pruned.
+func (s *PrefixSet) Remove(prefix string) bool {
+	path := make([]*prefixNode, 0, len(prefix)+1)
+	n := &s.root
+	path = append(path, n)
+	for i := 0; i < len(prefix); i++ {
+		if n = n.children[prefix[i]]; n == nil {
+			return false
+		}
+		path = append(path, n)
+	}
+	if !n.member {
+		return false
+	}
+	n.member = false
+	s.count--
+
+	for i := len(prefix); i > 0; i-- {
+		if n = path[i]; n.member || len(n.children) > 0 {
+			break
+		}
+		delete(path[i-1].children, prefix[i-1])
+	}
+	return true
+}
+
+func (s *PrefixSet) Count() int {
+	return s.count
+}
+
+// ToSlice returns the members in increasing byte order.
+func (s *PrefixSet) ToSlice() []string {
+	r := make([]string, 0, s.count)
+	var walk func(n *prefixNode, buf []byte)
+	walk = func(n *prefixNode, buf []byte) {
+		if n.member {
+			r = append(r, string(buf))
+		}
+		keys := make([]byte, 0, len(n.children))
+		for c := range n.children {
+			keys = append(keys, c)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		for _, c := range keys {
+			walk(n.children[c], append(buf, c))
+		}
+	}
+	walk(&s.root, nil)
+	return r
+}
+
+func (s *PrefixSet) find(prefix string) *prefixNode {
+	n := &s.root
+	for i := 0; i < len(prefix) && n != nil; i++ {
+		n = n.children[prefix[i]]
+	}
+	return n
+}
@@ -0,0 +1,141 @@
+package set
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bruteLongestPrefix is the reference for HasPrefixOf: a linear scan for
+// the longest member that prefixes str.
+func bruteLongestPrefix(members StrSet, str string) (string, bool) {
+	best, ok := "", false
+	for m := range members {
+		if strings.HasPrefix(str, m) && (!ok || len(m) > len(best)) {
+			best, ok = m, true
+		}
+	}
+	return best, ok
+}
+
+func TestPrefixSetLongestMatch(t *testing.T) {
+	s := NewPrefixSet("/api", "/api/v1", "/api/v1/admin", "/static")
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"/api/v1/admin/users", "/api/v1/admin", true},
+		{"/api/v1/users", "/api/v1", true},
+		{"/api/v2", "/api", true},
+		{"/api", "/api", true},
+		{"/ap", "", false},
+		{"/static/css", "/static", true},
+		{"/home", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := s.HasPrefixOf(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("HasPrefixOf(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPrefixSetEmptyMember(t *testing.T) {
+	var s PrefixSet
+	if _, ok := s.HasPrefixOf("x"); ok {
+		t.Error("empty set should match nothing")
+	}
+	s.Add("")
+	if s.Count() != 1 || !s.Has("") {
+		t.Errorf("Add(\"\"): count %d", s.Count())
+	}
+	for _, in := range []string{"", "anything"} {
+		if got, ok := s.HasPrefixOf(in); !ok || got != "" {
+			t.Errorf("HasPrefixOf(%q) = %q, %v, want \"\", true", in, got, ok)
+		}
+	}
+	s.Add("ab")
+	if got, _ := s.HasPrefixOf("abc"); got != "ab" {
+		t.Errorf("HasPrefixOf(abc) = %q, want ab", got)
+	}
+	if got, ok := s.HasPrefixOf("a"); !ok || got != "" {
+		t.Errorf("HasPrefixOf(a) = %q, %v, want the empty member", got, ok)
+	}
+	if !s.Remove("") || s.Remove("") {
+		t.Error("Remove(\"\") should succeed once")
+	}
+	if _, ok := s.HasPrefixOf("a"); ok {
+		t.Error("removed empty member still matches")
+	}
+}
+
+func TestPrefixSetRemovePrunes(t *testing.T) {
+	s := NewPrefixSet("abc", "abcdef", "abx")
+	if s.Remove("ab") || s.Remove("abcd") || s.Remove("zzz") {
+		t.Error("Remove of a non member should fail")
+	}
+	if s.Has("ab") {
+		t.Error("an inner trie node is not a member")
+	}
+	if !s.Remove("abcdef") {
+		t.Fatal("Remove(abcdef) failed")
+	}
+	if n := s.find("abc"); n == nil || len(n.children) != 0 {
+		t.Error("nodes below abc should have been pruned")
+	}
+	if got, _ := s.HasPrefixOf("abcdefg"); got != "abc" {
+		t.Errorf("HasPrefixOf after Remove = %q, want abc", got)
+	}
+	s.Remove("abc")
+	s.Remove("abx")
+	if s.Count() != 0 || len(s.root.children) != 0 {
+		t.Errorf("empty set kept %d nodes under the root", len(s.root.children))
+	}
+}
+
+func TestPrefixSetToSlice(t *testing.T) {
+	s := NewPrefixSet("b", "a", "ab", "", "a")
+	want := []string{"", "a", "ab", "b"}
+	if got := s.ToSlice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToSlice = %q, want %q", got, want)
+	}
+	if s.Count() != len(want) {
+		t.Errorf("Count = %d, want %d", s.Count(), len(want))
+	}
+}
+
+// FuzzPrefixSet adds and removes the ';' separated prefixes of ops, then
+// checks HasPrefixOf against a linear scan over the same members.
+func FuzzPrefixSet(f *testing.F) {
+	f.Add("/api;/api/v1;-/api;;/static", "/api/v1/users")
+	f.Add(";a;ab;abc;-ab", "abd")
+	f.Add("x;xy;-x;-xy", "xyz")
+	f.Fuzz(func(t *testing.T, ops, query string) {
+		s := NewPrefixSet()
+		ref := NewStrSet()
+		for _, op := range strings.Split(ops, ";") {
+			if p, ok := strings.CutPrefix(op, "-"); ok {
+				if got, want := s.Remove(p), ref.Remove(p); got != want {
+					t.Fatalf("Remove(%q) = %v, want %v", p, got, want)
+				}
+				continue
+			}
+			s.Add(op)
+			ref.Add(op)
+		}
+		if s.Count() != ref.Count() {
+			t.Fatalf("Count = %d, want %d", s.Count(), ref.Count())
+		}
+		if got, want := s.ToSlice(), ref.SortedSlice(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("ToSlice = %q, want %q", got, want)
+		}
+		for _, q := range []string{query, query + ops, ops} {
+			got, ok := s.HasPrefixOf(q)
+			want, wantOK := bruteLongestPrefix(ref, q)
+			if got != want || ok != wantOK {
+				t.Fatalf("HasPrefixOf(%q) = %q, %v, want %q, %v", q, got, ok, want, wantOK)
+			}
+		}
+	})
+}
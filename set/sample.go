@@ -0,0 +1,43 @@
+package set
+
+import (
+	"iter"
+	"math/rand"
+)
+
+// Sample returns n members chosen uniformly at random using reservoir
+// sampling. If n >= Count all members are returned.
+//
+// With a nil rng the math/rand package source is used and the set is
+// scanned once in map order. Because map order is itself random, a seeded
+// rng alone would not make the result reproducible, so when rng is non-nil
+// the members are visited in sorted order instead, at the cost of sorting.
+func (s StrSet) Sample(n int, rng *rand.Rand) []string {
+	if n <= 0 {
+		return nil
+	}
+	if rng == nil {
+		if n >= len(s) {
+			return s.ToSlice()
+		}
+		return sample(s.All(), n, rand.Intn)
+	}
+	if n >= len(s) {
+		return s.SortedSlice()
+	}
+	return sample(s.Sorted(), n, rng.Intn)
+}
+
+func sample(seq iter.Seq[string], n int, intn func(int) int) []string {
+	r := make([]string, 0, n)
+	i := 0
+	for str := range seq {
+		if i < n {
+			r = append(r, str)
+		} else if j := intn(i + 1); j < n {
+			r[j] = str
+		}
+		i++
+	}
+	return r
+}
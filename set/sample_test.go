@@ -0,0 +1,69 @@
+package set
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSampleDeterministic(t *testing.T) {
+	s := NewStrSet()
+	for i := 0; i < 100; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	a := s.Sample(5, rand.New(rand.NewSource(42)))
+	b := s.Sample(5, rand.New(rand.NewSource(42)))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed gave %v and %v", a, b)
+	}
+	if len(a) != 5 || FromSlice(a).Count() != 5 || !FromSlice(a).IsSubset(s) {
+		t.Errorf("Sample = %v", a)
+	}
+}
+
+func TestSampleBounds(t *testing.T) {
+	s := NewStrSet("a", "b", "c")
+	for _, rng := range []*rand.Rand{nil, rand.New(rand.NewSource(1))} {
+		if got := s.Sample(0, rng); got != nil {
+			t.Errorf("Sample(0) = %v", got)
+		}
+		if got := s.Sample(5, rng); !FromSlice(got).Equal(s) || len(got) != 3 {
+			t.Errorf("Sample(5) = %v", got)
+		}
+		if got := s.Sample(2, rng); len(got) != 2 || !FromSlice(got).IsSubset(s) {
+			t.Errorf("Sample(2) = %v", got)
+		}
+		var nilSet StrSet
+		if got := nilSet.Sample(2, rng); len(got) != 0 {
+			t.Errorf("nil Sample = %v", got)
+		}
+	}
+}
+
+// TestSampleUniform checks with a chi-squared test that every member is
+// picked equally often.
+func TestSampleUniform(t *testing.T) {
+	const members, n, trials = 10, 3, 30000
+	s := NewStrSet()
+	for i := 0; i < members; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	rng := rand.New(rand.NewSource(7))
+	counts := make(map[string]int)
+	for i := 0; i < trials; i++ {
+		for _, str := range s.Sample(n, rng) {
+			counts[str]++
+		}
+	}
+	expected := float64(trials*n) / members
+	chi2 := 0.0
+	for str := range s {
+		d := float64(counts[str]) - expected
+		chi2 += d * d / expected
+	}
+	// 27.88 is the critical value for 9 degrees of freedom at p = 0.001.
+	if chi2 > 27.88 {
+		t.Errorf("chi² = %.2f, counts %v", chi2, counts)
+	}
+}
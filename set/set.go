@@ -0,0 +1,364 @@
+package set
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Set is a set of any comparable type, such as IDs, UUIDs or small structs.
+// Like StrSet it is a map, passed by reference, and its methods have value
+// receivers. The zero value is a nil map: Has, Count and ToSlice work on
+// it, but Add panics, so create sets with New or make.
+//
+// StrSet is a Set[string] with extra helpers for strings; the methods they
+// share are implemented here. Both being maps of empty structs, a StrSet
+// converts to a Set[string], and back, with a plain conversion that shares
+// the same map.
+type Set[T comparable] map[T]struct{}
+
+// New returns a set holding the given items. Duplicates are collapsed.
+func New[T comparable](items ...T) Set[T] {
+	s := make(Set[T], len(items))
+	for _, item := range items {
+		s.Add(item)
+	}
+	return s
+}
+
+// Keys returns a set holding the keys of m. A nil map yields an empty set.
+func Keys[K comparable, V any](m map[K]V) Set[K] {
+	s := make(Set[K], len(m))
+	for k := range m {
+		s.Add(k)
+	}
+	return s
+}
+
+func (s Set[T]) Count() int {
+	return len(s)
+}
+
+// Add inserts v. It panics if s is nil.
+func (s Set[T]) Add(v T) {
+	s[v] = struct{}{}
+}
+
+func (s Set[T]) Has(v T) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// AddAll adds the given values and returns how many were not already
+// present.
+func (s Set[T]) AddAll(vals ...T) int {
+	n := 0
+	for _, v := range vals {
+		if !s.Has(v) {
+			s.Add(v)
+			n++
+		}
+	}
+	return n
+}
+
+// Remove deletes v from the set and reports whether it was present.
+func (s Set[T]) Remove(v T) bool {
+	ok := s.Has(v)
+	delete(s, v)
+	return ok
+}
+
+// RemoveAll deletes every given value and returns how many were present.
+func (s Set[T]) RemoveAll(vals ...T) int {
+	n := 0
+	for _, v := range vals {
+		if s.Remove(v) {
+			n++
+		}
+	}
+	return n
+}
+
+// RemoveFunc deletes every member for which pred returns true and returns
+// how many were removed.
+func (s Set[T]) RemoveFunc(pred func(T) bool) int {
+	n := 0
+	for v := range s {
+		if pred(v) {
+			delete(s, v)
+			n++
+		}
+	}
+	return n
+}
+
+// Clone returns an independent copy of s. Cloning a nil set returns an
+// empty, non-nil set.
+func (s Set[T]) Clone() Set[T] {
+	r := make(Set[T], len(s))
+	s.CopyInto(r)
+	return r
+}
+
+// CopyInto replaces the contents of dst with the members of s, reusing the
+// memory already allocated by dst.
+func (s Set[T]) CopyInto(dst Set[T]) {
+	clear(dst)
+	for v := range s {
+		dst.Add(v)
+	}
+}
+
+// Filter returns a new set holding the members for which pred returns true.
+func (s Set[T]) Filter(pred func(T) bool) Set[T] {
+	r := make(Set[T])
+	for v := range s {
+		if pred(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
+
+// Map returns a new set holding fn applied to every member of s. Members
+// that fn maps to the same value collapse into one, so the result may be
+// smaller than s. It is a function rather than a method since methods
+// cannot have type parameters.
+func Map[T, U comparable](s Set[T], fn func(T) U) Set[U] {
+	r := make(Set[U], len(s))
+	for v := range s {
+		r.Add(fn(v))
+	}
+	return r
+}
+
+// Partition splits s in one pass into the members for which pred returns
+// true and the rest.
+func (s Set[T]) Partition(pred func(T) bool) (match, rest Set[T]) {
+	match, rest = make(Set[T]), make(Set[T])
+	for v := range s {
+		if pred(v) {
+			match.Add(v)
+		} else {
+			rest.Add(v)
+		}
+	}
+	return match, rest
+}
+
+// Chunk splits the members, in unspecified order, into groups of at most
+// size; only the last group may be shorter. A size <= 0 yields a single
+// group holding every member. An empty set yields no groups.
+func (s Set[T]) Chunk(size int) [][]T {
+	members := s.ToSlice()
+	if len(members) == 0 {
+		return nil
+	}
+	if size <= 0 || size > len(members) {
+		size = len(members)
+	}
+	r := make([][]T, 0, (len(members)+size-1)/size)
+	for len(members) > size {
+		r = append(r, members[:size:size])
+		members = members[size:]
+	}
+	return append(r, members)
+}
+
+// Pop removes and returns an arbitrary member, or false if the set is
+// empty. Which member is returned is unspecified.
+func (s Set[T]) Pop() (T, bool) {
+	for v := range s {
+		delete(s, v)
+		return v, true
+	}
+	var zero T
+	return zero, false
+}
+
+// ToSlice returns the members in unspecified order. The slice is a copy;
+// modifying it does not affect the set.
+func (s Set[T]) ToSlice() []T {
+	r := make([]T, 0, len(s))
+	for v := range s {
+		r = append(r, v)
+	}
+	return r
+}
+
+// SortedSlice returns the members of s sorted in increasing order. It is a
+// function rather than a method since it needs ordered members.
+func SortedSlice[T cmp.Ordered](s Set[T]) []T {
+	r := s.ToSlice()
+	slices.Sort(r)
+	return r
+}
+
+// Equal reports whether s and other hold exactly the same members. Nil and
+// empty sets are equal.
+func (s Set[T]) Equal(other Set[T]) bool {
+	return s.Count() == other.Count() && s.IsSubset(other)
+}
+
+// IsSubset reports whether every member of s is also in other. The empty
+// set is a subset of every set.
+func (s Set[T]) IsSubset(other Set[T]) bool {
+	if s.Count() > other.Count() {
+		return false
+	}
+	for v := range s {
+		if !other.Has(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSuperset reports whether every member of other is also in s.
+func (s Set[T]) IsSuperset(other Set[T]) bool {
+	return other.IsSubset(s)
+}
+
+// IsDisjoint reports whether s and other have no members in common. It
+// iterates over the smaller operand and stops at the first shared member.
+// A nil set is disjoint with every set.
+func (s Set[T]) IsDisjoint(other Set[T]) bool {
+	small, large := s, other
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	for v := range small {
+		if large.Has(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Clear removes all members, keeping the allocated memory for reuse, and
+// returns s for chaining.
+func (s Set[T]) Clear() Set[T] {
+	clear(s)
+	return s
+}
+
+// Union returns a new set holding the members of both s and other.
+// A nil operand is treated as an empty set.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	r := make(Set[T], len(s)+len(other))
+	for v := range s {
+		r.Add(v)
+	}
+	for v := range other {
+		r.Add(v)
+	}
+	return r
+}
+
+// Merge adds the members of others to s and returns how many were new.
+// Nil arguments are treated as empty sets.
+func (s Set[T]) Merge(others ...Set[T]) int {
+	n := 0
+	for _, other := range others {
+		for v := range other {
+			if !s.Has(v) {
+				s.Add(v)
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Intersect returns a new set holding the members present in both s and
+// other. It iterates over the smaller operand.
+func (s Set[T]) Intersect(other Set[T]) Set[T] {
+	small, large := s, other
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	r := make(Set[T], len(small))
+	for v := range small {
+		if large.Has(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
+
+// IntersectCount returns the number of members present in both s and
+// other without building the intersection.
+func (s Set[T]) IntersectCount(other Set[T]) int {
+	small, large := s, other
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	n := 0
+	for v := range small {
+		if large.Has(v) {
+			n++
+		}
+	}
+	return n
+}
+
+// Jaccard returns the Jaccard similarity |s∩other| / |s∪other| computed from
+// counts alone. Two empty sets have a similarity of 0.
+func (s Set[T]) Jaccard(other Set[T]) float64 {
+	inter := s.IntersectCount(other)
+	union := len(s) + len(other) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// Difference returns a new set holding the members of s that are not in
+// other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	r := make(Set[T], len(s))
+	for v := range s {
+		if !other.Has(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
+
+// Subtract removes the members of other from s and returns how many were
+// removed. It iterates over the smaller operand.
+func (s Set[T]) Subtract(other Set[T]) int {
+	n := 0
+	if len(other) < len(s) {
+		for v := range other {
+			if s.Remove(v) {
+				n++
+			}
+		}
+		return n
+	}
+	for v := range s {
+		if other.Has(v) {
+			delete(s, v)
+			n++
+		}
+	}
+	return n
+}
+
+// SymmetricDifference returns a new set holding the members that are in
+// exactly one of s and other.
+func (s Set[T]) SymmetricDifference(other Set[T]) Set[T] {
+	r := make(Set[T], len(s)+len(other))
+	for v := range s {
+		if !other.Has(v) {
+			r.Add(v)
+		}
+	}
+	for v := range other {
+		if !s.Has(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
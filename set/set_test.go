@@ -0,0 +1,449 @@
+package set
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type point struct{ X, Y int }
+
+func TestSetNew(t *testing.T) {
+	s := New(3, 1, 3, 2, 1)
+	if s.Count() != 3 || !s.Has(1) || !s.Has(2) || !s.Has(3) || s.Has(0) {
+		t.Errorf("New with duplicates = %v", s)
+	}
+	if s := New[int](); s == nil || s.Count() != 0 {
+		t.Errorf("New() = %#v, want an empty non-nil set", s)
+	}
+	p := New(point{1, 2}, point{1, 2}, point{2, 1})
+	if p.Count() != 2 || !p.Has(point{2, 1}) {
+		t.Errorf("set of structs = %v", p)
+	}
+}
+
+func TestSetAdd(t *testing.T) {
+	s := New[string]()
+	s.Add("a")
+	s.Add("a")
+	if s.Count() != 1 || !s.Has("a") {
+		t.Errorf("after a duplicate Add: %v", s)
+	}
+	if n := s.AddAll("a", "b", "c", "b"); n != 2 {
+		t.Errorf("AddAll added %d, want 2", n)
+	}
+	if s.Count() != 3 {
+		t.Errorf("Count = %d, want 3", s.Count())
+	}
+	if n := s.AddAll(); n != 0 {
+		t.Errorf("AddAll() added %d", n)
+	}
+
+	// A set is a map: copies share the same members.
+	alias := s
+	alias.Add("d")
+	if !s.Has("d") {
+		t.Error("Add through a copy is not visible")
+	}
+}
+
+func TestSetNil(t *testing.T) {
+	var s Set[int]
+	if s.Count() != 0 || s.Has(1) || len(s.ToSlice()) != 0 || len(SortedSlice(s)) != 0 {
+		t.Error("reading a nil set should see an empty set")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Add on a nil set should panic")
+		}
+	}()
+	s.Add(1)
+}
+
+func TestSetRemove(t *testing.T) {
+	s := New(1, 2, 3, 4, 5)
+	if !s.Remove(1) || s.Has(1) {
+		t.Error("Remove(1) should succeed")
+	}
+	if n := s.RemoveAll(2, 2, 9); n != 1 {
+		t.Errorf("RemoveAll removed %d, want 1", n)
+	}
+	if n := s.RemoveFunc(func(v int) bool { return v%2 == 0 }); n != 1 {
+		t.Errorf("RemoveFunc removed %d, want 1", n)
+	}
+	if !s.Equal(New(3, 5)) {
+		t.Errorf("after removing: %v", s)
+	}
+}
+
+func TestSetRemoveAbsent(t *testing.T) {
+	s := New(1, 2)
+	if s.Remove(3) || s.RemoveAll(3, 4) != 0 || s.RemoveFunc(func(int) bool { return false }) != 0 {
+		t.Error("removing absent members should report nothing removed")
+	}
+	if !s.Equal(New(1, 2)) {
+		t.Errorf("removing absent members changed the set: %v", s)
+	}
+	s.Remove(1)
+	if s.Remove(1) {
+		t.Error("Remove reported a member removed twice")
+	}
+
+	var nilSet Set[int]
+	if nilSet.Remove(1) || nilSet.RemoveAll(1) != 0 || nilSet.RemoveFunc(func(int) bool { return true }) != 0 {
+		t.Error("removing from a nil set should be a no-op")
+	}
+}
+
+func TestSetPop(t *testing.T) {
+	var nilSet Set[point]
+	if v, ok := nilSet.Pop(); ok || v != (point{}) {
+		t.Errorf("Pop on nil = %v, %v", v, ok)
+	}
+	if v, ok := New[string]().Pop(); ok || v != "" {
+		t.Errorf("Pop on empty = %q, %v", v, ok)
+	}
+
+	s := New(1, 2, 3)
+	alias := s
+	seen := New[int]()
+	for i := 3; i > 0; i-- {
+		v, ok := s.Pop()
+		if !ok || s.Has(v) || s.Count() != i-1 {
+			t.Fatalf("Pop = %d, %v; set %v", v, ok, s)
+		}
+		seen.Add(v)
+	}
+	if !seen.Equal(New(1, 2, 3)) || alias.Count() != 0 {
+		t.Errorf("popped %v, left %v in the shared map", seen, alias)
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("Pop on an emptied set succeeded")
+	}
+}
+
+func TestSetFilterMap(t *testing.T) {
+	s := New(1, 2, 3, 4, 5, 6)
+	even := s.Filter(func(v int) bool { return v%2 == 0 })
+	if !even.Equal(New(2, 4, 6)) || s.Count() != 6 {
+		t.Errorf("Filter = %v, set left as %v", even, s)
+	}
+	var nilSet Set[int]
+	if r := nilSet.Filter(func(int) bool { return true }); r == nil || r.Count() != 0 {
+		t.Errorf("Filter of a nil set = %#v", r)
+	}
+
+	// 1 and 4, 2 and 5, 3 and 6 collide.
+	mod := Map(s, func(v int) int { return v % 3 })
+	if !mod.Equal(New(0, 1, 2)) {
+		t.Errorf("Map with collisions = %v", mod)
+	}
+	strs := Map(New(point{1, 2}, point{2, 1}), func(p point) string { return fmt.Sprint(p.X + p.Y) })
+	if !strs.Equal(New("3")) {
+		t.Errorf("Map to another type = %v", strs)
+	}
+}
+
+func TestSetClonePartitionChunk(t *testing.T) {
+	s := New(1, 2, 3, 4, 5)
+	c := s.Clone()
+	c.Add(6)
+	if !s.Equal(New(1, 2, 3, 4, 5)) || c.Count() != 6 {
+		t.Errorf("the clone shares the map: %v %v", s, c)
+	}
+	var nilSet Set[int]
+	if r := nilSet.Clone(); r == nil || r.Count() != 0 {
+		t.Errorf("Clone of a nil set = %#v", r)
+	}
+
+	odd, even := s.Partition(func(v int) bool { return v%2 == 1 })
+	if !odd.Equal(New(1, 3, 5)) || !even.Equal(New(2, 4)) {
+		t.Errorf("Partition = %v, %v", odd, even)
+	}
+
+	chunks := s.Chunk(2)
+	got := New[int]()
+	for i, chunk := range chunks {
+		if len(chunk) > 2 || (i < len(chunks)-1 && len(chunk) != 2) {
+			t.Errorf("chunk %d = %v", i, chunk)
+		}
+		got.AddAll(chunk...)
+	}
+	if len(chunks) != 3 || !got.Equal(s) {
+		t.Errorf("Chunk(2) = %v", chunks)
+	}
+	if len(s.Chunk(0)) != 1 || nilSet.Chunk(2) != nil {
+		t.Error("Chunk(0) or Chunk of an empty set")
+	}
+}
+
+func TestSetToSlice(t *testing.T) {
+	s := New(3, -1, 2)
+	if got := SortedSlice(s); !reflect.DeepEqual(got, []int{-1, 2, 3}) {
+		t.Fatalf("SortedSlice = %v", got)
+	}
+	slice := s.ToSlice()
+	if len(slice) != 3 {
+		t.Fatalf("ToSlice = %v", slice)
+	}
+	for i := range slice {
+		slice[i] = 0
+	}
+	if s.Has(0) || !s.Equal(New(-1, 2, 3)) {
+		t.Errorf("mutating the returned slice changed the set: %v", s)
+	}
+	if got := SortedSlice(New("b", "a")); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("SortedSlice of strings = %v", got)
+	}
+}
+
+func TestSetClear(t *testing.T) {
+	s := New(1, 2)
+	alias := s
+	if got := s.Clear(); got.Count() != 0 {
+		t.Errorf("Clear returned %v", got)
+	}
+	if alias.Count() != 0 {
+		t.Error("Clear did not empty the shared map")
+	}
+	s.Add(3)
+	if !alias.Has(3) {
+		t.Error("Clear replaced the map")
+	}
+	var nilSet Set[int]
+	if nilSet.Clear() != nil {
+		t.Error("Clear on nil should return nil")
+	}
+}
+
+func TestSetPredicates(t *testing.T) {
+	tests := []struct {
+		a, b                    Set[int]
+		equal, subset, superset bool
+		disjoint                bool
+		intersectCount          int
+	}{
+		{nil, nil, true, true, true, true, 0},
+		{nil, New[int](), true, true, true, true, 0},
+		{New(1), nil, false, false, true, true, 0},
+		{nil, New(1), false, true, false, true, 0},
+		{New(1, 2), New(1, 2), true, true, true, false, 2},
+		{New(1), New(1, 2), false, true, false, false, 1},
+		{New(1, 2), New(2, 3), false, false, false, false, 1},
+		{New(1, 2), New(3, 4), false, false, false, true, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.equal {
+			t.Errorf("%v.Equal(%v) = %v", tt.a, tt.b, got)
+		}
+		if got := tt.a.IsSubset(tt.b); got != tt.subset {
+			t.Errorf("%v.IsSubset(%v) = %v", tt.a, tt.b, got)
+		}
+		if got := tt.a.IsSuperset(tt.b); got != tt.superset {
+			t.Errorf("%v.IsSuperset(%v) = %v", tt.a, tt.b, got)
+		}
+		if got := tt.a.IsDisjoint(tt.b); got != tt.disjoint {
+			t.Errorf("%v.IsDisjoint(%v) = %v", tt.a, tt.b, got)
+		}
+		if got := tt.a.IntersectCount(tt.b); got != tt.intersectCount {
+			t.Errorf("%v.IntersectCount(%v) = %d", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestSetAll(t *testing.T) {
+	s := New(1, 2, 3, 4)
+	seen := New[int]()
+	for v := range s.All() {
+		seen.Add(v)
+	}
+	if !seen.Equal(s) {
+		t.Errorf("All produced %v", seen)
+	}
+	n := 0
+	for range s.All() {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("breaking out of All ran %d times", n)
+	}
+	for range Set[int](nil).All() {
+		t.Error("All on a nil set produced a member")
+	}
+}
+
+func TestSetString(t *testing.T) {
+	defer func(n int) { MaxStringMembers = n }(MaxStringMembers)
+	MaxStringMembers = 3
+
+	many := New[int]()
+	for i := 0; i < 50; i++ {
+		many.Add(i)
+	}
+	tests := []struct {
+		set  fmt.Stringer
+		want string
+	}{
+		{Set[int](nil), "{}"},
+		{New(2, 1), "{1, 2}"},
+		{New("b", "a"), "{a, b}"},
+		{New(point{2, 1}, point{1, 2}), "{{1 2}, {2 1}}"},
+		// Members are sorted by their formatted form.
+		{New(10, 9), "{10, 9}"},
+		{many, "{0, 1, 10, ... +47 more}"},
+	}
+	for _, tt := range tests {
+		if got := tt.set.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a, b := New(1, 2, 3), New(3, 4)
+	tests := []struct {
+		name      string
+		got, want Set[int]
+	}{
+		{"Union", a.Union(b), New(1, 2, 3, 4)},
+		{"Intersect", a.Intersect(b), New(3)},
+		{"Difference", a.Difference(b), New(1, 2)},
+		{"SymmetricDifference", a.SymmetricDifference(b), New(1, 2, 4)},
+	}
+	for _, tt := range tests {
+		if !tt.got.Equal(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if !a.Equal(New(1, 2, 3)) || !b.Equal(New(3, 4)) {
+		t.Errorf("operands modified: %v %v", a, b)
+	}
+
+	if n := a.Merge(b, nil, New(5, 4)); n != 2 || !a.Equal(New(1, 2, 3, 4, 5)) {
+		t.Errorf("Merge added %d: %v", n, a)
+	}
+	if n := a.Subtract(New(1, 4, 9)); n != 2 || !a.Equal(New(2, 3, 5)) {
+		t.Errorf("Subtract removed %d: %v", n, a)
+	}
+	// Subtract iterates over whichever operand is smaller.
+	if n := a.Subtract(New(0, 2, 3, 4, 6, 7)); n != 2 || !a.Equal(New(5)) {
+		t.Errorf("Subtract of a larger set removed %d: %v", n, a)
+	}
+}
+
+func TestSetAlgebraEmptyOperands(t *testing.T) {
+	a := New("a", "b")
+	for _, empty := range []Set[string]{nil, New[string]()} {
+		tests := []struct {
+			name      string
+			got, want Set[string]
+		}{
+			{"a ∪ ∅", a.Union(empty), a},
+			{"∅ ∪ a", empty.Union(a), a},
+			{"∅ ∪ ∅", empty.Union(empty), nil},
+			{"a ∩ ∅", a.Intersect(empty), nil},
+			{"∅ ∩ a", empty.Intersect(a), nil},
+			{"a ∖ ∅", a.Difference(empty), a},
+			{"∅ ∖ a", empty.Difference(a), nil},
+			{"a △ ∅", a.SymmetricDifference(empty), a},
+			{"∅ △ a", empty.SymmetricDifference(a), a},
+			{"∅ △ ∅", empty.SymmetricDifference(empty), nil},
+		}
+		for _, tt := range tests {
+			if tt.got == nil || !tt.got.Equal(tt.want) {
+				t.Errorf("%s with %#v = %#v, want %v as a non-nil set", tt.name, empty, tt.got, tt.want)
+			}
+		}
+
+		s := New("a", "b")
+		if s.Merge(empty) != 0 || s.Subtract(empty) != 0 || !s.Equal(a) {
+			t.Errorf("merging or subtracting %#v changed the set: %v", empty, s)
+		}
+		if empty.Subtract(a) != 0 || empty.Merge() != 0 {
+			t.Errorf("Subtract from %#v removed members", empty)
+		}
+	}
+	if !a.Equal(New("a", "b")) {
+		t.Errorf("operand modified: %v", a)
+	}
+}
+
+func TestSetAlgebraMatchesStrSet(t *testing.T) {
+	for _, p := range randomSets(7, 300) {
+		a, b := p[0], p[1]
+		ga, gb := Set[string](a), Set[string](b)
+		if !StrSet(ga.Union(gb)).Equal(a.Union(b)) ||
+			!StrSet(ga.Intersect(gb)).Equal(a.Intersect(b)) ||
+			!StrSet(ga.Difference(gb)).Equal(a.Difference(b)) ||
+			!StrSet(ga.SymmetricDifference(gb)).Equal(a.SymmetricDifference(b)) {
+			t.Fatalf("Set and StrSet disagree on %v %v", a, b)
+		}
+		if ga.IntersectCount(gb) != a.IntersectCount(b) || ga.IsDisjoint(gb) != a.IsDisjoint(b) ||
+			ga.Jaccard(gb) != a.Jaccard(b) {
+			t.Fatalf("Set and StrSet predicates disagree on %v %v", a, b)
+		}
+		s, ss := Set[string](a.Clone()), a.Clone()
+		if s.Subtract(gb) != ss.Subtract(b) || !StrSet(s).Equal(ss) {
+			t.Fatalf("Subtract disagrees on %v %v", a, b)
+		}
+	}
+}
+
+func TestSetJaccard(t *testing.T) {
+	if j := New(1, 2, 3).Jaccard(New(2, 3, 4)); j != 0.5 {
+		t.Errorf("Jaccard = %v, want 0.5", j)
+	}
+	var nilSet Set[int]
+	if nilSet.Jaccard(nil) != 0 || nilSet.Jaccard(New[int]()) != 0 {
+		t.Error("the Jaccard similarity of two empty sets should be 0")
+	}
+	if a := New(1, 2); a.Jaccard(a) != 1 || a.Jaccard(New(3)) != 0 || a.Jaccard(nil) != 0 {
+		t.Error("Jaccard with identical, disjoint or nil sets")
+	}
+}
+
+func BenchmarkSetJaccard(b *testing.B) {
+	x, y := New(benchKeys(10000)...), New(benchKeys(5000)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Jaccard(y)
+	}
+}
+
+func TestSetStrSetConversion(t *testing.T) {
+	s := NewStrSet("a", "b")
+	g := Set[string](s)
+	g.Add("c")
+	if !s.Has("c") {
+		t.Error("the converted set does not share the map")
+	}
+	if back := StrSet(g); !back.Equal(NewStrSet("a", "b", "c")) {
+		t.Errorf("converted back to %v", back)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	s := Keys(map[int]string{1: "a", 2: "b"})
+	if s.Count() != 2 || !s.Has(1) || !s.Has(2) {
+		t.Errorf("Keys = %v", s)
+	}
+	if s := Keys(map[int]bool(nil)); s == nil || s.Count() != 0 {
+		t.Errorf("Keys(nil) = %#v", s)
+	}
+}
+
+func ExampleKeys() {
+	ports := map[int]string{80: "http", 443: "https"}
+	open := Keys(ports)
+	fmt.Println(open.Has(443), open.Has(22))
+	// Output: true false
+}
+
+func ExampleSortedSlice() {
+	ids := New(42, 7, 19, 7)
+	fmt.Println(SortedSlice(ids))
+	// Output: [7 19 42]
+}
@@ -1,16 +1,358 @@
 package set
 
-type StrSet map[string]bool
+// StrSet is a set of strings. Members are stored as keys with empty struct
+// values, so Count always equals the number of members Has reports.
+//
+// StrSet is not an alias of Set[string], since methods such as Sorted,
+// AddSlice and Grow cannot be declared on an instantiated generic type. The
+// methods it shares with Set convert to Set[string], which costs nothing,
+// and delegate to it.
+type StrSet map[string]struct{}
+
+// WithCapacity returns an empty set with room for n members before it needs
+// to grow.
+func WithCapacity(n int) StrSet {
+	return make(StrSet, n)
+}
+
+// FromBoolMap returns a set holding the keys of m that map to true. It eases
+// migrating code that built a StrSet from a map[string]bool literal.
+func FromBoolMap(m map[string]bool) StrSet {
+	s := make(StrSet, len(m))
+	for k, v := range m {
+		if v {
+			s.Add(k)
+		}
+	}
+	return s
+}
+
+// NewStrSet returns a set holding the given items.
+func NewStrSet(items ...string) StrSet {
+	return FromSlice(items)
+}
+
+// FromSlice returns a set holding the items of the slice. Duplicates are
+// collapsed.
+func FromSlice(items []string) StrSet {
+	s := make(StrSet, len(items))
+	for _, item := range items {
+		s.Add(item)
+	}
+	return s
+}
+
+// KeysOf returns a set holding the keys of m. A nil map yields an empty
+// set.
+func KeysOf[M ~map[string]V, V any](m M) StrSet {
+	s := make(StrSet, len(m))
+	for k := range m {
+		s.Add(k)
+	}
+	return s
+}
+
+// ValuesOf returns a set holding the values of m. A nil map yields an empty
+// set.
+func ValuesOf[M ~map[K]string, K comparable](m M) StrSet {
+	s := make(StrSet, len(m))
+	for _, v := range m {
+		s.Add(v)
+	}
+	return s
+}
 
 func (s StrSet) Count() int {
-	return len(s)
+	return Set[string](s).Count()
 }
 
 func (s StrSet) Add(str string) {
-	s[str] = true
+	Set[string](s).Add(str)
 }
 
 func (s StrSet) Has(str string) bool {
-	a, b := s[str]
-	return a && b
+	return Set[string](s).Has(str)
+}
+
+// Remove deletes str from the set and reports whether it was present.
+func (s StrSet) Remove(str string) bool {
+	return Set[string](s).Remove(str)
+}
+
+// RemoveAll deletes every given string and returns how many were present.
+func (s StrSet) RemoveAll(strs ...string) int {
+	return Set[string](s).RemoveAll(strs...)
+}
+
+// RemoveFunc deletes every member for which pred returns true and returns
+// how many were removed.
+func (s StrSet) RemoveFunc(pred func(string) bool) int {
+	return Set[string](s).RemoveFunc(pred)
+}
+
+// Union returns a new set holding the members of both s and other.
+// A nil operand is treated as an empty set.
+func (s StrSet) Union(other StrSet) StrSet {
+	return StrSet(Set[string](s).Union(Set[string](other)))
+}
+
+// Merge adds the members of others to s and returns how many were new.
+// Nil arguments are treated as empty sets.
+func (s StrSet) Merge(others ...StrSet) int {
+	n := 0
+	for _, other := range others {
+		n += Set[string](s).Merge(Set[string](other))
+	}
+	return n
+}
+
+// Intersect returns a new set holding the members present in both s and
+// other. It iterates over the smaller operand.
+func (s StrSet) Intersect(other StrSet) StrSet {
+	return StrSet(Set[string](s).Intersect(Set[string](other)))
+}
+
+// IntersectCount returns the number of members present in both s and
+// other without building the intersection.
+func (s StrSet) IntersectCount(other StrSet) int {
+	return Set[string](s).IntersectCount(Set[string](other))
+}
+
+// Jaccard returns the Jaccard similarity |s∩other| / |s∪other| computed from
+// counts alone. Two empty sets have a similarity of 0.
+func (s StrSet) Jaccard(other StrSet) float64 {
+	return Set[string](s).Jaccard(Set[string](other))
+}
+
+// Difference returns a new set holding the members of s that are not in
+// other.
+func (s StrSet) Difference(other StrSet) StrSet {
+	return StrSet(Set[string](s).Difference(Set[string](other)))
+}
+
+// Subtract removes the members of other from s and returns how many were
+// removed. It iterates over the smaller operand.
+func (s StrSet) Subtract(other StrSet) int {
+	return Set[string](s).Subtract(Set[string](other))
+}
+
+// SymmetricDifference returns a new set holding the members that are in
+// exactly one of s and other.
+func (s StrSet) SymmetricDifference(other StrSet) StrSet {
+	return StrSet(Set[string](s).SymmetricDifference(Set[string](other)))
+}
+
+// SymmetricDifferenceInPlace updates s to hold the members that are in
+// exactly one of s and other.
+func (s StrSet) SymmetricDifferenceInPlace(other StrSet) {
+	for str := range other {
+		if s.Has(str) {
+			delete(s, str)
+		} else {
+			s.Add(str)
+		}
+	}
+}
+
+// ToSlice returns the members in unspecified order. The slice is a copy;
+// modifying it does not affect the set.
+func (s StrSet) ToSlice() []string {
+	return Set[string](s).ToSlice()
+}
+
+// SortedSlice returns the members sorted in increasing order.
+func (s StrSet) SortedSlice() []string {
+	return SortedSlice(Set[string](s))
+}
+
+// Equal reports whether s and other hold exactly the same members. Nil and
+// empty sets are equal.
+func (s StrSet) Equal(other StrSet) bool {
+	return Set[string](s).Equal(Set[string](other))
+}
+
+// IsSubset reports whether every member of s is also in other. The empty
+// set is a subset of every set.
+func (s StrSet) IsSubset(other StrSet) bool {
+	return Set[string](s).IsSubset(Set[string](other))
+}
+
+// IsSuperset reports whether every member of other is also in s.
+func (s StrSet) IsSuperset(other StrSet) bool {
+	return Set[string](s).IsSuperset(Set[string](other))
+}
+
+// IsDisjoint reports whether s and other have no members in common. It
+// iterates over the smaller operand and stops at the first shared member.
+// A nil set is disjoint with every set.
+func (s StrSet) IsDisjoint(other StrSet) bool {
+	return Set[string](s).IsDisjoint(Set[string](other))
+}
+
+// Clone returns an independent copy of s. Cloning a nil set returns an
+// empty, non-nil set.
+func (s StrSet) Clone() StrSet {
+	return StrSet(Set[string](s).Clone())
+}
+
+// CopyInto replaces the contents of dst with the members of s, reusing the
+// memory already allocated by dst.
+func (s StrSet) CopyInto(dst StrSet) {
+	Set[string](s).CopyInto(Set[string](dst))
+}
+
+// Pop removes and returns an arbitrary member, or false if the set is
+// empty. Which member is returned is unspecified.
+func (s StrSet) Pop() (string, bool) {
+	return Set[string](s).Pop()
+}
+
+// PopN removes and returns up to n arbitrary members.
+func (s StrSet) PopN(n int) []string {
+	if n > len(s) {
+		n = len(s)
+	}
+	if n <= 0 {
+		return nil
+	}
+	r := make([]string, 0, n)
+	for str := range s {
+		if len(r) == n {
+			break
+		}
+		delete(s, str)
+		r = append(r, str)
+	}
+	return r
+}
+
+// Filter returns a new set holding the members for which pred returns true.
+func (s StrSet) Filter(pred func(string) bool) StrSet {
+	return StrSet(Set[string](s).Filter(pred))
+}
+
+// FilterInPlace removes the members for which pred returns false and
+// returns how many were removed.
+func (s StrSet) FilterInPlace(pred func(string) bool) int {
+	return s.RemoveFunc(func(str string) bool { return !pred(str) })
+}
+
+// MapTo returns a new set holding fn applied to every member. Members that
+// fn maps to the same value collapse into one, so the result may be smaller
+// than s.
+func (s StrSet) MapTo(fn func(string) string) StrSet {
+	return StrSet(Map(Set[string](s), fn))
+}
+
+// AddAll adds the given strings and returns how many were not already
+// present. Callers inserting many new members into an existing set can call
+// Grow first to avoid repeated rehashing.
+func (s StrSet) AddAll(strs ...string) int {
+	return Set[string](s).AddAll(strs...)
+}
+
+// AddSlice is like AddAll but takes a slice.
+func (s StrSet) AddSlice(strs []string) int {
+	return s.AddAll(strs...)
+}
+
+// Clear removes all members, keeping the allocated memory for reuse, and
+// returns s for chaining.
+func (s StrSet) Clear() StrSet {
+	Set[string](s).Clear()
+	return s
+}
+
+// Chunk splits the members, in unspecified order, into groups of at most
+// size; only the last group may be shorter. A size <= 0 yields a single
+// group holding every member. An empty set yields no groups.
+func (s StrSet) Chunk(size int) [][]string {
+	return Set[string](s).Chunk(size)
+}
+
+// Partition splits s in one pass into the members for which pred returns
+// true and the rest.
+func (s StrSet) Partition(pred func(string) bool) (match, rest StrSet) {
+	m, r := Set[string](s).Partition(pred)
+	return StrSet(m), StrSet(r)
+}
+
+// IntersectAll returns a new set holding the members common to every given
+// set. It iterates over the smallest input once and checks each member
+// against the others, so no intermediate sets are built. A nil or empty
+// input, or no inputs at all, yields an empty set.
+func IntersectAll(sets ...StrSet) StrSet {
+	if len(sets) == 0 {
+		return make(StrSet)
+	}
+	smallest := 0
+	for i, set := range sets {
+		if len(set) == 0 {
+			return make(StrSet)
+		}
+		if len(set) < len(sets[smallest]) {
+			smallest = i
+		}
+	}
+
+	r := make(StrSet, len(sets[smallest]))
+next:
+	for str := range sets[smallest] {
+		for i, set := range sets {
+			if i != smallest && !set.Has(str) {
+				continue next
+			}
+		}
+		r.Add(str)
+	}
+	return r
+}
+
+// UnionAll returns a new set holding the members of every given set.
+func UnionAll(sets ...StrSet) StrSet {
+	size := 0
+	for _, set := range sets {
+		size += len(set)
+	}
+	r := make(StrSet, size)
+	r.Merge(sets...)
+	return r
+}
+
+// Grow makes room for n more members without further allocation. Go maps
+// cannot be resized in place, so Grow replaces *s with a copy sized for
+// Count()+n members; other references to the old map keep seeing the old
+// map. Call it before a large bulk insertion, not in a loop.
+func (s *StrSet) Grow(n int) {
+	if n <= 0 {
+		return
+	}
+	r := make(StrSet, len(*s)+n)
+	for str := range *s {
+		r.Add(str)
+	}
+	*s = r
+}
+
+// Diff reports the members added and removed going from before to after,
+// in one pass over each set. Nil sets are treated as empty.
+func Diff(before, after StrSet) (added, removed StrSet) {
+	added, removed = make(StrSet), make(StrSet)
+	for str := range after {
+		if !before.Has(str) {
+			added.Add(str)
+		}
+	}
+	for str := range before {
+		if !after.Has(str) {
+			removed.Add(str)
+		}
+	}
+	return added, removed
+}
+
+// DiffSlices is like Diff for callers holding slices. Duplicates within a
+// slice are ignored.
+func DiffSlices(before, after []string) (added, removed StrSet) {
+	return Diff(FromSlice(before), FromSlice(after))
 }
@@ -0,0 +1,937 @@
+package set
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// randomSets returns pairs of sets drawn from a small universe, so that they
+// overlap, including empty and nil sets, for the property tests.
+func randomSets(seed int64, pairs int) [][2]StrSet {
+	r := rand.New(rand.NewSource(seed))
+	random := func() StrSet {
+		switch r.Intn(10) {
+		case 0:
+			return nil
+		case 1:
+			return NewStrSet()
+		}
+		s := NewStrSet()
+		for i := r.Intn(20); i > 0; i-- {
+			s.Add(strconv.Itoa(r.Intn(30)))
+		}
+		return s
+	}
+	out := make([][2]StrSet, pairs)
+	for i := range out {
+		out[i] = [2]StrSet{random(), random()}
+	}
+	return out
+}
+
+func TestStrSetRemove(t *testing.T) {
+	s := NewStrSet("a", "b", "c")
+	if !s.Remove("a") || s.Remove("a") || s.Has("a") {
+		t.Error("Remove(a) should succeed once")
+	}
+	if n := s.RemoveAll("b", "x", "b"); n != 1 {
+		t.Errorf("RemoveAll removed %d, want 1", n)
+	}
+	if s.Count() != 1 || !s.Has("c") {
+		t.Errorf("after RemoveAll: %v", s)
+	}
+
+	s = NewStrSet("apple", "avocado", "banana")
+	if n := s.RemoveFunc(func(str string) bool { return strings.HasPrefix(str, "a") }); n != 2 {
+		t.Errorf("RemoveFunc removed %d, want 2", n)
+	}
+	if !s.Equal(NewStrSet("banana")) {
+		t.Errorf("after RemoveFunc: %v", s)
+	}
+
+	if s.Remove("x") || s.RemoveAll("x", "y") != 0 || !s.Equal(NewStrSet("banana")) {
+		t.Errorf("removing absent members changed the set: %v", s)
+	}
+
+	var nilSet StrSet
+	if nilSet.Remove("a") || nilSet.RemoveAll("a") != 0 || nilSet.RemoveFunc(func(string) bool { return true }) != 0 {
+		t.Error("removing from a nil set should be a no-op")
+	}
+}
+
+func TestStrSetAlgebra(t *testing.T) {
+	a, b := NewStrSet("a", "b", "c"), NewStrSet("c", "d")
+	if got := a.Union(b); !got.Equal(NewStrSet("a", "b", "c", "d")) {
+		t.Errorf("Union = %v", got)
+	}
+	if got := a.Intersect(b); !got.Equal(NewStrSet("c")) {
+		t.Errorf("Intersect = %v", got)
+	}
+	if got := a.Difference(b); !got.Equal(NewStrSet("a", "b")) {
+		t.Errorf("Difference = %v", got)
+	}
+	if !a.Equal(NewStrSet("a", "b", "c")) || !b.Equal(NewStrSet("c", "d")) {
+		t.Errorf("operands modified: %v %v", a, b)
+	}
+
+	var nilSet StrSet
+	for _, got := range []StrSet{nilSet.Union(nil), nilSet.Intersect(a), a.Intersect(nil), nilSet.Difference(a)} {
+		if got == nil || got.Count() != 0 {
+			t.Errorf("nil operand: got %v, want an empty non-nil set", got)
+		}
+	}
+	if got := a.Union(nil); !got.Equal(a) {
+		t.Errorf("a ∪ nil = %v", got)
+	}
+	if got := a.Difference(nil); !got.Equal(a) {
+		t.Errorf("a ∖ nil = %v", got)
+	}
+}
+
+func TestStrSetAlgebraEmptyOperands(t *testing.T) {
+	a := NewStrSet("a", "b")
+	for _, empty := range []StrSet{nil, NewStrSet()} {
+		tests := []struct {
+			name      string
+			got, want StrSet
+		}{
+			{"a ∪ ∅", a.Union(empty), a},
+			{"∅ ∪ a", empty.Union(a), a},
+			{"∅ ∪ ∅", empty.Union(empty), nil},
+			{"a ∩ ∅", a.Intersect(empty), nil},
+			{"∅ ∩ a", empty.Intersect(a), nil},
+			{"a ∖ ∅", a.Difference(empty), a},
+			{"∅ ∖ a", empty.Difference(a), nil},
+			{"a △ ∅", a.SymmetricDifference(empty), a},
+			{"∅ △ a", empty.SymmetricDifference(a), a},
+			{"∅ △ ∅", empty.SymmetricDifference(empty), nil},
+		}
+		for _, tt := range tests {
+			if tt.got == nil || !tt.got.Equal(tt.want) {
+				t.Errorf("%s with %#v = %#v, want %v as a non-nil set", tt.name, empty, tt.got, tt.want)
+			}
+		}
+
+		s := a.Clone()
+		if s.Merge(empty) != 0 || s.Subtract(empty) != 0 || !s.Equal(a) {
+			t.Errorf("merging or subtracting %#v changed the set: %v", empty, s)
+		}
+		if empty.Subtract(a) != 0 || empty.Merge() != 0 {
+			t.Errorf("Subtract from %#v removed members", empty)
+		}
+	}
+	if !a.Equal(NewStrSet("a", "b")) {
+		t.Errorf("operand modified: %v", a)
+	}
+}
+
+func TestStrSetAlgebraProperties(t *testing.T) {
+	for _, p := range randomSets(1, 500) {
+		a, b := p[0], p[1]
+		union, inter, diff := a.Union(b), a.Intersect(b), a.Difference(b)
+		if !inter.IsSubset(a) || !inter.IsSubset(b) {
+			t.Fatalf("A∩B ⊄ A or B: %v %v", a, b)
+		}
+		if !union.IsSuperset(a) || !union.IsSuperset(b) {
+			t.Fatalf("A∪B ⊉ A or B: %v %v", a, b)
+		}
+		if !union.Equal(b.Union(a)) || !inter.Equal(b.Intersect(a)) {
+			t.Fatalf("∪ or ∩ not commutative: %v %v", a, b)
+		}
+		if !diff.IsSubset(a) || !diff.IsDisjoint(b) {
+			t.Fatalf("A∖B ⊄ A or meets B: %v %v", a, b)
+		}
+		if !diff.Union(inter).Equal(a) {
+			t.Fatalf("(A∖B)∪(A∩B) != A: %v %v", a, b)
+		}
+		if union.Count() != a.Count()+b.Count()-inter.Count() {
+			t.Fatalf("|A∪B| != |A|+|B|-|A∩B|: %v %v", a, b)
+		}
+	}
+}
+
+func TestStrSetToSlice(t *testing.T) {
+	s := NewStrSet("b", "c", "a")
+	got := s.SortedSlice()
+	if !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("SortedSlice = %v", got)
+	}
+	got[0] = "z"
+	slice := s.ToSlice()
+	for i := range slice {
+		slice[i] = "z"
+	}
+	if s.Has("z") || !s.Equal(NewStrSet("a", "b", "c")) {
+		t.Errorf("mutating the returned slices changed the set: %v", s)
+	}
+
+	var nilSet StrSet
+	if got := nilSet.ToSlice(); got == nil || len(got) != 0 {
+		t.Errorf("nil ToSlice = %#v, want an empty slice", got)
+	}
+}
+
+func TestStrSetConstructors(t *testing.T) {
+	if s := NewStrSet("a", "b", "a"); !reflect.DeepEqual(s, StrSet{"a": {}, "b": {}}) {
+		t.Errorf("NewStrSet = %v", s)
+	}
+	if s := FromSlice([]string{"x", "x"}); s.Count() != 1 || !s.Has("x") {
+		t.Errorf("FromSlice = %v", s)
+	}
+	if s := FromSlice(nil); s == nil || s.Count() != 0 {
+		t.Errorf("FromSlice(nil) = %#v, want an empty non-nil set", s)
+	}
+	if s := KeysOf(map[string]int{"a": 1, "b": 2}); !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("KeysOf = %v", s)
+	}
+	if s := FromBoolMap(map[string]bool{"a": true, "b": false}); !s.Equal(NewStrSet("a")) {
+		t.Errorf("FromBoolMap = %v", s)
+	}
+}
+
+func TestStrSetPredicates(t *testing.T) {
+	var nilSet StrSet
+	empty := NewStrSet()
+	ab := NewStrSet("a", "b")
+	tests := []struct {
+		a, b                    StrSet
+		equal, subset, superset bool
+	}{
+		{nilSet, empty, true, true, true},
+		{empty, ab, false, true, false},
+		{ab, empty, false, false, true},
+		{ab, NewStrSet("b", "a"), true, true, true},
+		{NewStrSet("a"), ab, false, true, false},
+		{NewStrSet("a", "c"), ab, false, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.equal {
+			t.Errorf("%v.Equal(%v) = %v", tt.a, tt.b, got)
+		}
+		if got := tt.a.IsSubset(tt.b); got != tt.subset {
+			t.Errorf("%v.IsSubset(%v) = %v", tt.a, tt.b, got)
+		}
+		if got := tt.a.IsSuperset(tt.b); got != tt.superset {
+			t.Errorf("%v.IsSuperset(%v) = %v", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestStrSetPredicateProperties(t *testing.T) {
+	for _, p := range randomSets(2, 500) {
+		a, b := p[0], p[1]
+		if a.IsSubset(b) != a.Intersect(b).Equal(a) {
+			t.Fatalf("A ⊆ B iff A∩B == A: %v %v", a, b)
+		}
+		if a.IsSuperset(b) != a.Union(b).Equal(a) {
+			t.Fatalf("A ⊇ B iff A∪B == A: %v %v", a, b)
+		}
+		if a.Equal(b) != (a.IsSubset(b) && b.IsSubset(a)) {
+			t.Fatalf("A == B iff A ⊆ B and B ⊆ A: %v %v", a, b)
+		}
+	}
+}
+
+func TestStrSetClone(t *testing.T) {
+	orig := NewStrSet("a", "b")
+	clone := orig.Clone()
+	clone.Add("c")
+	clone.Remove("a")
+	if !orig.Equal(NewStrSet("a", "b")) {
+		t.Errorf("mutating the clone changed the original: %v", orig)
+	}
+	orig.Add("d")
+	if !clone.Equal(NewStrSet("b", "c")) {
+		t.Errorf("mutating the original changed the clone: %v", clone)
+	}
+
+	var nilSet StrSet
+	if c := nilSet.Clone(); c == nil || c.Count() != 0 {
+		t.Errorf("nil Clone = %#v, want an empty non-nil set", c)
+	}
+
+	dst := NewStrSet("x", "y")
+	orig.CopyInto(dst)
+	if !dst.Equal(orig) {
+		t.Errorf("CopyInto = %v, want %v", dst, orig)
+	}
+	dst.Add("z")
+	if orig.Has("z") {
+		t.Error("CopyInto shares the map")
+	}
+}
+
+func TestStrSetPop(t *testing.T) {
+	var nilSet StrSet
+	if str, ok := nilSet.Pop(); ok || str != "" {
+		t.Errorf("Pop on nil = %q, %v", str, ok)
+	}
+	if got := nilSet.PopN(3); len(got) != 0 {
+		t.Errorf("PopN on nil = %v", got)
+	}
+	if str, ok := NewStrSet().Pop(); ok || str != "" {
+		t.Errorf("Pop on empty = %q, %v", str, ok)
+	}
+
+	s := NewStrSet("a", "b", "c", "d")
+	seen := NewStrSet()
+	str, ok := s.Pop()
+	if !ok || s.Has(str) || s.Count() != 3 {
+		t.Fatalf("Pop = %q, %v; set %v", str, ok, s)
+	}
+	seen.Add(str)
+	batch := s.PopN(2)
+	if len(batch) != 2 || s.Count() != 1 {
+		t.Fatalf("PopN(2) = %v; set %v", batch, s)
+	}
+	seen.AddAll(batch...)
+	batch = s.PopN(5)
+	if len(batch) != 1 || s.Count() != 0 {
+		t.Fatalf("PopN(5) = %v; set %v", batch, s)
+	}
+	seen.AddAll(batch...)
+	if !seen.Equal(NewStrSet("a", "b", "c", "d")) {
+		t.Errorf("popped %v", seen)
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("Pop on an emptied set succeeded")
+	}
+	if got := NewStrSet("a").PopN(0); got != nil {
+		t.Errorf("PopN(0) = %v", got)
+	}
+}
+
+func TestStrSetFilterMap(t *testing.T) {
+	s := NewStrSet("user:1", "user:2", "group:1")
+	isUser := func(str string) bool { return strings.HasPrefix(str, "user:") }
+	if got := s.Filter(isUser); !got.Equal(NewStrSet("user:1", "user:2")) {
+		t.Errorf("Filter = %v", got)
+	}
+	if s.Count() != 3 {
+		t.Errorf("Filter modified the set: %v", s)
+	}
+	if n := s.FilterInPlace(isUser); n != 1 || !s.Equal(NewStrSet("user:1", "user:2")) {
+		t.Errorf("FilterInPlace removed %d, left %v", n, s)
+	}
+
+	// MapTo collapses members mapped to the same value.
+	mixed := NewStrSet("Go", "GO", "go", "Rust")
+	lower := mixed.MapTo(strings.ToLower)
+	if !lower.Equal(NewStrSet("go", "rust")) {
+		t.Errorf("MapTo = %v", lower)
+	}
+	if mixed.Count() != 4 {
+		t.Errorf("MapTo modified the set: %v", mixed)
+	}
+	if got := NewStrSet("a", "b").MapTo(func(string) string { return "x" }); got.Count() != 1 {
+		t.Errorf("MapTo to a constant = %v", got)
+	}
+}
+
+func TestStrSetAddAll(t *testing.T) {
+	s := NewStrSet("a")
+	if n := s.AddAll("a", "b", "b", "c"); n != 2 {
+		t.Errorf("AddAll added %d, want 2", n)
+	}
+	if n := s.AddSlice([]string{"c", "d"}); n != 1 {
+		t.Errorf("AddSlice added %d, want 1", n)
+	}
+	if !s.Equal(NewStrSet("a", "b", "c", "d")) {
+		t.Errorf("set = %v", s)
+	}
+	if n := s.AddAll(); n != 0 {
+		t.Errorf("AddAll() added %d", n)
+	}
+}
+
+func BenchmarkStrSetAddLoop(b *testing.B) {
+	keys := benchKeys(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := make(StrSet)
+		for _, k := range keys {
+			s.Add(k)
+		}
+	}
+}
+
+func BenchmarkStrSetFromSlice(b *testing.B) {
+	keys := benchKeys(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FromSlice(keys)
+	}
+}
+
+func BenchmarkStrSetAddSlicePresized(b *testing.B) {
+	keys := benchKeys(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		make(StrSet, len(keys)).AddSlice(keys)
+	}
+}
+
+// checkCount asserts that Count agrees with Has, ToSlice, All and the JSON
+// encoding of s.
+func checkCount(t *testing.T, what string, s StrSet) {
+	t.Helper()
+	has := 0
+	for str := range s {
+		if s.Has(str) {
+			has++
+		}
+	}
+	iterated := 0
+	for range s.All() {
+		iterated++
+	}
+	var decoded []string
+	b, _ := s.MarshalJSON()
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+	if n := s.Count(); n != has || n != len(s.ToSlice()) || n != iterated || n != len(decoded) {
+		t.Fatalf("%s: Count %d, Has %d, ToSlice %d, All %d, JSON %d",
+			what, n, has, len(s.ToSlice()), iterated, len(decoded))
+	}
+}
+
+func TestStrSetCountAgreesWithHas(t *testing.T) {
+	literal := StrSet{"a": {}, "b": {}}
+	checkCount(t, "literal", literal)
+	for _, p := range randomSets(3, 200) {
+		a, b := p[0], p[1]
+		checkCount(t, "a", a)
+		checkCount(t, "union", a.Union(b))
+		checkCount(t, "intersect", a.Intersect(b))
+		checkCount(t, "difference", a.Difference(b))
+		checkCount(t, "symmetric difference", a.SymmetricDifference(b))
+		checkCount(t, "clone", a.Clone())
+		c := a.Clone()
+		c.Merge(b)
+		checkCount(t, "merge", c)
+		c.Subtract(a)
+		checkCount(t, "subtract", c)
+	}
+}
+
+func TestStrSetClear(t *testing.T) {
+	s := NewStrSet("a", "b")
+	alias := s
+	if got := s.Clear(); got.Count() != 0 {
+		t.Errorf("Clear returned %v", got)
+	}
+	if alias.Count() != 0 {
+		t.Error("Clear did not empty the shared map")
+	}
+	s.Add("c")
+	if !alias.Has("c") {
+		t.Error("Clear replaced the map")
+	}
+	var nilSet StrSet
+	if nilSet.Clear() != nil {
+		t.Error("Clear on nil should return nil")
+	}
+
+	var ss SyncStrSet
+	ss.Clear()
+	ss.AddAll("a", "b")
+	ss.Clear()
+	if ss.Count() != 0 {
+		t.Errorf("SyncStrSet.Clear left %v", ss.ToSlice())
+	}
+}
+
+func BenchmarkStrSetClearReuse(b *testing.B) {
+	keys := benchKeys(1000)
+	s := make(StrSet)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Clear().AddSlice(keys)
+	}
+}
+
+func BenchmarkStrSetReallocate(b *testing.B) {
+	keys := benchKeys(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := make(StrSet)
+		s.AddSlice(keys)
+	}
+}
+
+func TestStrSetSymmetricDifference(t *testing.T) {
+	a, b := NewStrSet("a", "b", "c"), NewStrSet("c", "d")
+	if got := a.SymmetricDifference(b); !got.Equal(NewStrSet("a", "b", "d")) {
+		t.Errorf("SymmetricDifference = %v", got)
+	}
+	var nilSet StrSet
+	if got := nilSet.SymmetricDifference(b); !got.Equal(b) {
+		t.Errorf("nil △ b = %v", got)
+	}
+	a.SymmetricDifferenceInPlace(b)
+	if !a.Equal(NewStrSet("a", "b", "d")) {
+		t.Errorf("SymmetricDifferenceInPlace = %v", a)
+	}
+	a.SymmetricDifferenceInPlace(nil)
+	if a.Count() != 3 {
+		t.Errorf("in place with nil = %v", a)
+	}
+}
+
+func TestStrSetSymmetricDifferenceProperties(t *testing.T) {
+	for _, p := range randomSets(4, 500) {
+		a, b := p[0], p[1]
+		sym := a.SymmetricDifference(b)
+		if !sym.Equal(a.Difference(b).Union(b.Difference(a))) {
+			t.Fatalf("A△B != (A∖B)∪(B∖A): %v %v", a, b)
+		}
+		if a.SymmetricDifference(a).Count() != 0 {
+			t.Fatalf("A△A != ∅: %v", a)
+		}
+		if !sym.Equal(b.SymmetricDifference(a)) {
+			t.Fatalf("△ not commutative: %v %v", a, b)
+		}
+		inPlace := a.Clone()
+		inPlace.SymmetricDifferenceInPlace(b)
+		if !inPlace.Equal(sym) {
+			t.Fatalf("in place %v != %v", inPlace, sym)
+		}
+	}
+}
+
+func TestStrSetMerge(t *testing.T) {
+	s := NewStrSet("a")
+	if n := s.Merge(NewStrSet("a", "b"), nil, NewStrSet("c", "b")); n != 2 {
+		t.Errorf("Merge added %d, want 2", n)
+	}
+	if !s.Equal(NewStrSet("a", "b", "c")) {
+		t.Errorf("Merge = %v", s)
+	}
+	if n := s.Merge(); n != 0 {
+		t.Errorf("Merge() added %d", n)
+	}
+
+	ss := NewSyncStrSet()
+	if n := ss.Merge(NewStrSet("x"), NewStrSet("x", "y")); n != 2 || ss.Count() != 2 {
+		t.Errorf("SyncStrSet.Merge added %d: %v", n, ss.ToSlice())
+	}
+}
+
+func mergeBenchSets() []StrSet {
+	sets := make([]StrSet, 100)
+	for i := range sets {
+		sets[i] = make(StrSet, 100)
+		for j := 0; j < 100; j++ {
+			sets[i].Add(strconv.Itoa(i*50 + j))
+		}
+	}
+	return sets
+}
+
+func BenchmarkStrSetFoldMerge(b *testing.B) {
+	sets := mergeBenchSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		acc := make(StrSet)
+		for _, s := range sets {
+			acc.Merge(s)
+		}
+	}
+}
+
+func BenchmarkStrSetFoldUnion(b *testing.B) {
+	sets := mergeBenchSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		acc := make(StrSet)
+		for _, s := range sets {
+			acc = acc.Union(s)
+		}
+	}
+}
+
+func TestStrSetIsDisjoint(t *testing.T) {
+	var nilSet StrSet
+	if !nilSet.IsDisjoint(NewStrSet("a")) || !NewStrSet("a").IsDisjoint(nil) || !nilSet.IsDisjoint(nil) {
+		t.Error("nil should be disjoint with everything")
+	}
+	if NewStrSet("a", "b").IsDisjoint(NewStrSet("b", "c")) {
+		t.Error("overlapping sets reported disjoint")
+	}
+	if !NewStrSet("a").IsDisjoint(NewStrSet("b")) {
+		t.Error("disjoint sets reported overlapping")
+	}
+	if NewSyncStrSet("a").IsDisjoint(NewStrSet("a")) || !NewSyncStrSet("a").IsDisjoint(NewStrSet("b")) {
+		t.Error("SyncStrSet.IsDisjoint")
+	}
+}
+
+func TestStrSetIsDisjointProperties(t *testing.T) {
+	for _, p := range randomSets(5, 500) {
+		a, b := p[0], p[1]
+		if a.IsDisjoint(b) != (a.Intersect(b).Count() == 0) {
+			t.Fatalf("IsDisjoint ⟺ A∩B == ∅: %v %v", a, b)
+		}
+		if a.IsDisjoint(b) != b.IsDisjoint(a) {
+			t.Fatalf("IsDisjoint not symmetric: %v %v", a, b)
+		}
+	}
+}
+
+func TestStrSetChunk(t *testing.T) {
+	s := NewStrSet()
+	for i := 0; i < 10; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	for _, size := range []int{-1, 0, 1, 3, 10, 11} {
+		chunks := s.Chunk(size)
+		seen := NewStrSet()
+		for i, c := range chunks {
+			if size > 0 && len(c) > size {
+				t.Errorf("size %d: chunk %d has %d members", size, i, len(c))
+			}
+			if i < len(chunks)-1 && size > 0 && len(c) != size {
+				t.Errorf("size %d: chunk %d is short", size, i)
+			}
+			for _, str := range c {
+				if seen.Has(str) {
+					t.Errorf("size %d: %q appears twice", size, str)
+				}
+				seen.Add(str)
+			}
+		}
+		if !seen.Equal(s) {
+			t.Errorf("size %d: chunks hold %v", size, seen)
+		}
+		if size <= 0 && len(chunks) != 1 {
+			t.Errorf("size %d: %d chunks, want 1", size, len(chunks))
+		}
+	}
+	if got := len(s.Chunk(3)); got != 4 {
+		t.Errorf("Chunk(3) made %d chunks, want 4", got)
+	}
+	var nilSet StrSet
+	if got := nilSet.Chunk(3); len(got) != 0 {
+		t.Errorf("nil Chunk = %v", got)
+	}
+}
+
+func TestStrSetPartition(t *testing.T) {
+	s := NewStrSet("a1", "a2", "b1")
+	match, rest := s.Partition(func(str string) bool { return str[0] == 'a' })
+	if !match.Equal(NewStrSet("a1", "a2")) || !rest.Equal(NewStrSet("b1")) {
+		t.Errorf("Partition = %v, %v", match, rest)
+	}
+	if !match.IsDisjoint(rest) || !match.Union(rest).Equal(s) {
+		t.Error("Partition should split every member exactly once")
+	}
+	var nilSet StrSet
+	match, rest = nilSet.Partition(func(string) bool { return true })
+	if match == nil || rest == nil || match.Count()+rest.Count() != 0 {
+		t.Errorf("nil Partition = %#v, %#v", match, rest)
+	}
+}
+
+func TestStrSetSubtract(t *testing.T) {
+	for _, tt := range []struct {
+		s, other, want StrSet
+		n              int
+	}{
+		{NewStrSet("a", "b", "c"), NewStrSet("b", "x"), NewStrSet("a", "c"), 1},
+		{NewStrSet("a"), NewStrSet("a", "b", "c"), NewStrSet(), 1},
+		{NewStrSet("a"), nil, NewStrSet("a"), 0},
+		{nil, NewStrSet("a"), nil, 0},
+	} {
+		s := tt.s.Clone()
+		if n := s.Subtract(tt.other); n != tt.n || !s.Equal(tt.want) {
+			t.Errorf("%v.Subtract(%v) = %d, %v", tt.s, tt.other, n, s)
+		}
+	}
+
+	ss := NewSyncStrSet("a", "b")
+	if n := ss.Subtract(NewStrSet("a", "z")); n != 1 || ss.Count() != 1 {
+		t.Errorf("SyncStrSet.Subtract = %d: %v", n, ss.ToSlice())
+	}
+	var empty SyncStrSet
+	if n := empty.Subtract(NewStrSet("a")); n != 0 {
+		t.Errorf("zero SyncStrSet.Subtract = %d", n)
+	}
+}
+
+func subtractBenchSets() (all, done StrSet) {
+	all, done = make(StrSet, 100000), make(StrSet, 10000)
+	for i := 0; i < 100000; i++ {
+		all.Add(strconv.Itoa(i))
+		if i%10 == 0 {
+			done.Add(strconv.Itoa(i))
+		}
+	}
+	return all, done
+}
+
+func BenchmarkStrSetSubtract(b *testing.B) {
+	all, done := subtractBenchSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		work := all.Clone()
+		b.StartTimer()
+		work.Subtract(done)
+	}
+}
+
+func BenchmarkStrSetDifferenceReplace(b *testing.B) {
+	all, done := subtractBenchSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		work := all.Clone()
+		b.StartTimer()
+		work = work.Difference(done)
+	}
+}
+
+func TestStrSetFromMaps(t *testing.T) {
+	if s := ValuesOf(map[int]string{1: "a", 2: "b", 3: "a"}); !s.Equal(NewStrSet("a", "b")) {
+		t.Errorf("ValuesOf = %v", s)
+	}
+	var nilMap map[string]bool
+	if s := KeysOf(nilMap); s == nil || s.Count() != 0 {
+		t.Errorf("KeysOf(nil) = %#v", s)
+	}
+	if s := ValuesOf(map[string]string(nil)); s == nil || s.Count() != 0 {
+		t.Errorf("ValuesOf(nil) = %#v", s)
+	}
+	type headers map[string][]string
+	if s := KeysOf(headers{"Accept": nil}); !s.Has("Accept") {
+		t.Errorf("KeysOf(named map) = %v", s)
+	}
+}
+
+func ExampleKeysOf() {
+	owners := map[string]string{"api": "alice", "web": "bob", "db": "alice"}
+
+	// Instead of:
+	//	services := set.NewStrSet()
+	//	for name := range owners {
+	//		services.Add(name)
+	//	}
+	services := KeysOf(owners)
+	people := ValuesOf(owners)
+	fmt.Println(services, people)
+	// Output: {api, db, web} {alice, bob}
+}
+
+func TestIntersectAllUnionAll(t *testing.T) {
+	a, b, c := NewStrSet("a", "b", "c"), NewStrSet("b", "c", "d"), NewStrSet("c", "b", "x")
+	if got := IntersectAll(a, b, c); !got.Equal(NewStrSet("b", "c")) {
+		t.Errorf("IntersectAll = %v", got)
+	}
+	if got := IntersectAll(a); !got.Equal(a) {
+		t.Errorf("IntersectAll of one set = %v", got)
+	}
+	for _, got := range []StrSet{IntersectAll(), IntersectAll(a, nil, b), IntersectAll(a, NewStrSet())} {
+		if got == nil || got.Count() != 0 {
+			t.Errorf("IntersectAll with no or empty input = %#v", got)
+		}
+	}
+	if got := UnionAll(a, nil, b); !got.Equal(NewStrSet("a", "b", "c", "d")) {
+		t.Errorf("UnionAll = %v", got)
+	}
+	if got := UnionAll(); got == nil || got.Count() != 0 {
+		t.Errorf("UnionAll() = %#v", got)
+	}
+	if !a.Equal(NewStrSet("a", "b", "c")) {
+		t.Errorf("inputs modified: %v", a)
+	}
+}
+
+func TestIntersectAllProperties(t *testing.T) {
+	for _, p := range randomSets(6, 300) {
+		a, b := p[0], p[1]
+		if !IntersectAll(a, b).Equal(a.Intersect(b)) {
+			t.Fatalf("IntersectAll(a, b) != a∩b: %v %v", a, b)
+		}
+		if !UnionAll(a, b).Equal(a.Union(b)) {
+			t.Fatalf("UnionAll(a, b) != a∪b: %v %v", a, b)
+		}
+	}
+}
+
+// cohorts returns 10 sets of 100k members each, overlapping on half of
+// their members.
+func cohorts() []StrSet {
+	sets := make([]StrSet, 10)
+	for i := range sets {
+		sets[i] = make(StrSet, 100000)
+		for j := 0; j < 100000; j++ {
+			sets[i].Add(strconv.Itoa(i*5000 + j))
+		}
+	}
+	return sets
+}
+
+func BenchmarkIntersectAll(b *testing.B) {
+	sets := cohorts()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		IntersectAll(sets...)
+	}
+}
+
+func BenchmarkIntersectPairwise(b *testing.B) {
+	sets := cohorts()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := sets[0]
+		for _, s := range sets[1:] {
+			r = r.Intersect(s)
+		}
+	}
+}
+
+func TestStrSetCapacity(t *testing.T) {
+	s := WithCapacity(10)
+	if s == nil || s.Count() != 0 {
+		t.Fatalf("WithCapacity = %#v", s)
+	}
+	s.Add("a")
+	alias := s
+	s.Grow(100)
+	if !s.Has("a") || s.Count() != 1 {
+		t.Errorf("Grow lost members: %v", s)
+	}
+	s.Add("b")
+	if alias.Has("b") {
+		t.Error("Grow should replace the map")
+	}
+	before := s
+	s.Grow(0)
+	s.Add("c")
+	if !before.Has("c") {
+		t.Error("Grow(0) should keep the map")
+	}
+	var nilSet StrSet
+	nilSet.Grow(4)
+	nilSet.Add("x")
+	if !nilSet.Has("x") {
+		t.Error("Grow on a nil set")
+	}
+}
+
+func unionBenchSets() (a, b StrSet) {
+	a, b = make(StrSet, 1000000), make(StrSet, 1000000)
+	for i := 0; i < 1000000; i++ {
+		a.Add(strconv.Itoa(i))
+		b.Add(strconv.Itoa(i + 500000))
+	}
+	return a, b
+}
+
+// BenchmarkStrSetUnionUnsized is the union built without a capacity hint,
+// as Union did before it pre-sized its result.
+func BenchmarkStrSetUnionUnsized(b *testing.B) {
+	x, y := unionBenchSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := make(StrSet)
+		for str := range x {
+			r.Add(str)
+		}
+		for str := range y {
+			r.Add(str)
+		}
+	}
+}
+
+func BenchmarkStrSetUnion(b *testing.B) {
+	x, y := unionBenchSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Union(y)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	added, removed := Diff(NewStrSet("a", "b"), NewStrSet("b", "c"))
+	if !added.Equal(NewStrSet("c")) || !removed.Equal(NewStrSet("a")) {
+		t.Errorf("Diff = %v, %v", added, removed)
+	}
+	added, removed = Diff(nil, NewStrSet("a"))
+	if !added.Equal(NewStrSet("a")) || removed == nil || removed.Count() != 0 {
+		t.Errorf("Diff(nil, a) = %v, %#v", added, removed)
+	}
+	added, removed = DiffSlices([]string{"a", "a", "b"}, []string{"b", "c", "c"})
+	if !added.Equal(NewStrSet("c")) || !removed.Equal(NewStrSet("a")) {
+		t.Errorf("DiffSlices = %v, %v", added, removed)
+	}
+}
+
+func TestDiffProperties(t *testing.T) {
+	for _, p := range randomSets(7, 500) {
+		before, after := p[0], p[1]
+		added, removed := Diff(before, after)
+		if !before.Union(added).Difference(removed).Equal(after) {
+			t.Fatalf("old ∪ added ∖ removed != new: %v %v", before, after)
+		}
+		if !added.IsDisjoint(before) || !removed.IsSubset(before) || !removed.IsDisjoint(after) {
+			t.Fatalf("added %v or removed %v out of place: %v %v", added, removed, before, after)
+		}
+	}
+}
+
+func TestStrSetIntersectCount(t *testing.T) {
+	a, b := NewStrSet("a", "b", "c"), NewStrSet("b", "c", "d")
+	if n := a.IntersectCount(b); n != 2 {
+		t.Errorf("IntersectCount = %d", n)
+	}
+	if j := a.Jaccard(b); j != 0.5 {
+		t.Errorf("Jaccard = %v, want 0.5", j)
+	}
+	var nilSet StrSet
+	if nilSet.IntersectCount(a) != 0 || nilSet.Jaccard(nil) != 0 || a.Jaccard(a) != 1 {
+		t.Error("IntersectCount or Jaccard with nil or identical sets")
+	}
+	for _, p := range randomSets(8, 300) {
+		if p[0].IntersectCount(p[1]) != p[0].Intersect(p[1]).Count() {
+			t.Fatalf("IntersectCount != |A∩B|: %v %v", p[0], p[1])
+		}
+	}
+}
+
+func BenchmarkStrSetIntersectCount(b *testing.B) {
+	x, y := NewStrSet(benchKeys(10000)...), NewStrSet(benchKeys(5000)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.IntersectCount(y)
+	}
+}
+
+func BenchmarkStrSetIntersectThenCount(b *testing.B) {
+	x, y := NewStrSet(benchKeys(10000)...), NewStrSet(benchKeys(5000)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = x.Intersect(y).Count()
+	}
+}
@@ -0,0 +1,143 @@
+package set
+
+import "sync"
+
+// SyncStrSet is a StrSet guarded by a sync.RWMutex, safe for concurrent use.
+// The zero value is an empty set ready to use.
+type SyncStrSet struct {
+	mu  sync.RWMutex
+	set StrSet
+}
+
+func NewSyncStrSet(items ...string) *SyncStrSet {
+	return &SyncStrSet{set: NewStrSet(items...)}
+}
+
+func (s *SyncStrSet) Add(str string) {
+	s.mu.Lock()
+	if s.set == nil {
+		s.set = make(StrSet)
+	}
+	s.set.Add(str)
+	s.mu.Unlock()
+}
+
+// AddAll adds the given strings under a single lock acquisition and returns
+// how many were not already present.
+func (s *SyncStrSet) AddAll(strs ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.set == nil {
+		s.set = make(StrSet, len(strs))
+	}
+	return s.set.AddAll(strs...)
+}
+
+// AddSlice is like AddAll but takes a slice.
+func (s *SyncStrSet) AddSlice(strs []string) int {
+	return s.AddAll(strs...)
+}
+
+func (s *SyncStrSet) Has(str string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Has(str)
+}
+
+func (s *SyncStrSet) Remove(str string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.Remove(str)
+}
+
+func (s *SyncStrSet) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Count()
+}
+
+// ToSlice returns a snapshot of the members in unspecified order.
+func (s *SyncStrSet) ToSlice() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.ToSlice()
+}
+
+// Do calls fn with the underlying set while holding the write lock, so that
+// multi-step operations are applied atomically. fn must not retain the set
+// or call other methods of s.
+func (s *SyncStrSet) Do(fn func(StrSet)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.set == nil {
+		s.set = make(StrSet)
+	}
+	fn(s.set)
+}
+
+// Pop atomically removes and returns an arbitrary member.
+func (s *SyncStrSet) Pop() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.Pop()
+}
+
+// PopN atomically removes and returns up to n arbitrary members.
+func (s *SyncStrSet) PopN(n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.PopN(n)
+}
+
+func (s *SyncStrSet) Clear() {
+	s.mu.Lock()
+	s.set.Clear()
+	s.mu.Unlock()
+}
+
+// Merge adds the members of others under a single lock acquisition and
+// returns how many were new.
+func (s *SyncStrSet) Merge(others ...StrSet) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.set == nil {
+		s.set = make(StrSet)
+	}
+	return s.set.Merge(others...)
+}
+
+func (s *SyncStrSet) IsDisjoint(other StrSet) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.IsDisjoint(other)
+}
+
+// Subtract removes the members of other under a single lock acquisition and
+// returns how many were removed.
+func (s *SyncStrSet) Subtract(other StrSet) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.Subtract(other)
+}
+
+// Each calls fn for every member until fn returns false. It iterates over a
+// snapshot taken under the read lock and calls fn without holding it, so fn
+// may freely modify s; such changes are not reflected in the iteration.
+func (s *SyncStrSet) Each(fn func(string) bool) {
+	for _, str := range s.ToSlice() {
+		if !fn(str) {
+			return
+		}
+	}
+}
+
+// Find returns a member for which pred returns true, or false if there is
+// none. Like Each, it works on a snapshot.
+func (s *SyncStrSet) Find(pred func(string) bool) (string, bool) {
+	for _, str := range s.ToSlice() {
+		if pred(str) {
+			return str, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,177 @@
+package set
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSyncStrSetConcurrent(t *testing.T) {
+	var s SyncStrSet
+	const workers, perWorker = 8, 500
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				s.Add(strconv.Itoa(w*perWorker + i))
+				if i%50 == 0 {
+					s.AddAll(strconv.Itoa(i), "shared")
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				s.Has(strconv.Itoa(i))
+				s.Count()
+				if i%100 == 0 {
+					s.ToSlice()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := s.Count(); got != workers*perWorker+1 {
+		t.Errorf("Count = %d, want %d", got, workers*perWorker+1)
+	}
+}
+
+func TestSyncStrSetDo(t *testing.T) {
+	s := NewSyncStrSet("a")
+	s.Do(func(set StrSet) {
+		if set.Has("a") {
+			set.Remove("a")
+			set.Add("b")
+		}
+	})
+	if s.Has("a") || !s.Has("b") {
+		t.Errorf("Do: %v", s.ToSlice())
+	}
+}
+
+func TestSyncStrSetAddAllKeepsCapacity(t *testing.T) {
+	s := NewSyncStrSet()
+	s.AddAll("a", "b")
+	var before StrSet
+	s.Do(func(set StrSet) { before = set })
+	s.Clear()
+	s.AddAll("c")
+	var after StrSet
+	s.Do(func(set StrSet) { after = set })
+	// Clear keeps the map for reuse; AddAll must not replace it.
+	if !after.Has("c") || !before.Has("c") {
+		t.Error("AddAll after Clear allocated a new map")
+	}
+}
+
+func BenchmarkStrSetAddHas(b *testing.B) {
+	s := make(StrSet)
+	keys := benchKeys(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := keys[i%len(keys)]
+		s.Add(k)
+		s.Has(k)
+	}
+}
+
+func BenchmarkSyncStrSetAddHas(b *testing.B) {
+	s := NewSyncStrSet()
+	keys := benchKeys(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := keys[i%len(keys)]
+		s.Add(k)
+		s.Has(k)
+	}
+}
+
+func BenchmarkSyncStrSetHasParallel(b *testing.B) {
+	keys := benchKeys(1000)
+	s := NewSyncStrSet(keys...)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			s.Has(keys[i%len(keys)])
+		}
+	})
+}
+
+func benchKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func TestSyncStrSetPopConcurrent(t *testing.T) {
+	const n = 1000
+	s := NewSyncStrSet()
+	for i := 0; i < n; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	popped := make(chan string, n)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(batch bool) {
+			defer wg.Done()
+			for {
+				if batch {
+					strs := s.PopN(3)
+					if len(strs) == 0 {
+						return
+					}
+					for _, str := range strs {
+						popped <- str
+					}
+				} else {
+					str, ok := s.Pop()
+					if !ok {
+						return
+					}
+					popped <- str
+				}
+			}
+		}(w%2 == 0)
+	}
+	wg.Wait()
+	close(popped)
+	seen := NewStrSet()
+	for str := range popped {
+		if seen.Has(str) {
+			t.Fatalf("%q popped twice", str)
+		}
+		seen.Add(str)
+	}
+	if seen.Count() != n {
+		t.Errorf("popped %d members, want %d", seen.Count(), n)
+	}
+}
+
+func TestSyncStrSetEachMayModify(t *testing.T) {
+	s := NewSyncStrSet("a", "b", "c")
+	n := 0
+	// Each works on a snapshot, so fn may modify s without deadlocking.
+	s.Each(func(str string) bool {
+		s.Remove(str)
+		s.Add(str + "!")
+		n++
+		return true
+	})
+	if n != 3 || s.Count() != 3 || !s.Has("a!") {
+		t.Errorf("Each visited %d members, set %v", n, s.ToSlice())
+	}
+	str, ok := s.Find(func(str string) bool {
+		s.Add("new")
+		return str == "b!"
+	})
+	if !ok || str != "b!" {
+		t.Errorf("Find = %q, %v", str, ok)
+	}
+	if _, ok := s.Find(func(string) bool { return false }); ok {
+		t.Error("Find matched nothing but returned true")
+	}
+}
@@ -0,0 +1,41 @@
+// Package util holds helpers that tie the util sub-packages together.
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Shutdown closes the closers one after the other, in the order given,
+// under the deadline of ctx. Pass the components that may still log first
+// and the logger last, e.g.
+//
+//	util.Shutdown(ctx, cache.Closer(), log.Closer())
+//
+// If a Close call does not return before ctx is done, Shutdown stops
+// waiting for it and moves on; the remaining closers are still started but
+// not waited for. All errors, including ctx.Err() for every closer that was
+// not waited for, are joined into the returned error. Nil closers are
+// skipped.
+func Shutdown(ctx context.Context, closers ...io.Closer) error {
+	var errs []error
+	for i, c := range closers {
+		if c == nil {
+			continue
+		}
+		done := make(chan error, 1)
+		go func() { done <- c.Close() }()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("util: closer %d: %w", i, ctx.Err()))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x6666/util/cache"
+	"github.com/0x6666/util/log"
+)
+
+// fakeCloser records the order it was closed in and can be made slow.
+type fakeCloser struct {
+	name  string
+	order *[]string
+	mu    *sync.Mutex
+	err   error
+	delay time.Duration
+}
+
+func (f fakeCloser) Close() error {
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	*f.order = append(*f.order, f.name)
+	f.mu.Unlock()
+	return f.err
+}
+
+// closerSet makes fakeCloser values sharing one record of the close order.
+type closerSet struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (s *closerSet) fake(name string, delay time.Duration, err error) fakeCloser {
+	return fakeCloser{name: name, order: &s.order, mu: &s.mu, err: err, delay: delay}
+}
+
+func (s *closerSet) closed() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.order, ",")
+}
+
+func TestShutdownOrder(t *testing.T) {
+	var s closerSet
+	err := Shutdown(context.Background(),
+		s.fake("cache", 20*time.Millisecond, nil),
+		nil,
+		s.fake("queue", 0, nil),
+		s.fake("log", 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A slow closer is waited for before the next one starts.
+	if got := s.closed(); got != "cache,queue,log" {
+		t.Errorf("closed %s", got)
+	}
+}
+
+func TestShutdownJoinsErrors(t *testing.T) {
+	var s closerSet
+	errCache := errors.New("cache failed")
+	errLog := errors.New("log failed")
+	err := Shutdown(context.Background(),
+		s.fake("cache", 0, errCache),
+		s.fake("queue", 0, nil),
+		s.fake("log", 0, errLog))
+	if !errors.Is(err, errCache) || !errors.Is(err, errLog) {
+		t.Errorf("err = %v, want both failures", err)
+	}
+	if got := s.closed(); got != "cache,queue,log" {
+		t.Errorf("a failure stopped the shutdown: closed %s", got)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	var s closerSet
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := Shutdown(ctx,
+		s.fake("fast", 0, nil),
+		s.fake("stuck", time.Hour, nil),
+		s.fake("log", 0, nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v, want it bounded by the deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "closer 1") {
+		t.Errorf("err = %v, want the deadline of closer 1", err)
+	}
+
+	// The closers after the stuck one are still started.
+	deadline := time.Now().Add(5 * time.Second)
+	for s.closed() != "fast,log" {
+		if time.Now().After(deadline) {
+			t.Fatalf("closed %s, want the last closer started anyway", s.closed())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestShutdownCacheAndLog(t *testing.T) {
+	if err := cache.InitInMemoryCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	h := log.NewMemoryHandler()
+	log.SetHandler(h)
+	log.Info("serving")
+
+	closers := []io.Closer{cache.Closer(), log.Closer()}
+	if err := Shutdown(context.Background(), closers...); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Contains("serving") {
+		t.Error("lines logged before the shutdown were not flushed")
+	}
+	if err := cache.Set("k", 1, cache.DefaultExpiryTime); !errors.Is(err, cache.ErrNotInited) {
+		t.Errorf("Set after Shutdown = %v, want ErrNotInited", err)
+	}
+
+	// Both are already closed: a second shutdown is harmless.
+	if err := Shutdown(context.Background(), closers...); err != nil {
+		t.Errorf("second Shutdown = %v", err)
+	}
+}